@@ -9,21 +9,28 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"runtime/debug"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
-	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"cloud.google.com/go/storage"
+	"github.com/nrkno/terraform-registry/pkg/core"
 	"github.com/nrkno/terraform-registry/pkg/registry"
+	"github.com/nrkno/terraform-registry/pkg/store/azblob"
+	"github.com/nrkno/terraform-registry/pkg/store/gcs"
+	"github.com/nrkno/terraform-registry/pkg/store/git"
 	"github.com/nrkno/terraform-registry/pkg/store/github"
+	"github.com/nrkno/terraform-registry/pkg/store/oci"
 	"github.com/nrkno/terraform-registry/pkg/store/s3"
+	sqlstore "github.com/nrkno/terraform-registry/pkg/store/sql"
 	"go.uber.org/zap"
 )
 
@@ -33,6 +40,7 @@ var (
 	accessLogIgnoredPaths string
 	authDisabled          bool
 	authTokensFile        string
+	adminTokensFile       string
 	envJSONFiles          string
 	tlsEnabled            bool
 	tlsCertFile           string
@@ -42,11 +50,61 @@ var (
 	logLevelStr           string
 	logFormatStr          string
 	printVersionInfo      bool
+	snapshotFile          string
+	versionLifecycleFile  string
+	downloadTimeout       time.Duration
 
 	assetDownloadAuthSecret string
 
-	S3Region string
-	S3Bucket string
+	S3Region        string
+	S3Bucket        string
+	S3KeyPrefix     string
+	S3Endpoint      string
+	S3PathStyle     bool
+	S3SourceURLBase string
+	S3ModuleDocs    bool
+	S3IndexInterval time.Duration
+	S3DownloadMode  string
+	S3PresignTTL    time.Duration
+	S3Profile       string
+	S3RoleARN       string
+	S3RoleSession   string
+	S3ExternalID    string
+	S3WebIdentity   string
+
+	azblobAccountURL       string
+	azblobContainer        string
+	azblobKeyPrefix        string
+	azblobManagedIdentity  string
+	azblobDownloadMode     string
+	azblobSASTTL           time.Duration
+	azblobConnectionString string
+	azblobSASToken         string
+
+	gcsBucket            string
+	gcsKeyPrefix         string
+	gcsProviderKeyPrefix string
+	gcsDownloadMode      string
+	gcsSignedURLTTL      time.Duration
+
+	ociHost                     string
+	ociPlainHTTP                bool
+	ociRepositoryPrefix         string
+	ociProviderRepositoryPrefix string
+	ociDownloadMode             string
+	ociUsername                 string
+	ociPassword                 string
+
+	gitRepositoriesFile string
+	gitSourceTemplate   string
+	gitArchiveDownloads bool
+	gitMirrorDir        string
+	gitReloadInterval   time.Duration
+
+	sqlDatabase        string
+	sqlBlobDir         string
+	sqlBlobS3Bucket    string
+	sqlBlobS3KeyPrefix string
 
 	gitHubToken                string
 	githubPrivatePem           string
@@ -55,6 +113,25 @@ var (
 	gitHubTopicFilter          string
 	gitHubProvidersOwnerFilter string
 	gitHubProvidersTopicFilter string
+	gitHubTopicMatch           string
+	gitHubProvidersTopicMatch  string
+	gitHubExcludeRepos         string
+	gitHubExcludeArchived      bool
+	gitHubIncludeForks         bool
+	gitHubBaseURL              string
+	gitHubUploadURL            string
+	gitHubSourceTemplate       string
+	gitHubSourceTemplatesFile  string
+	gitHubArchiveDownloads     bool
+	gitHubModuleDocs           bool
+	gitHubProviderIgnoreTTL    time.Duration
+	gitHubTrustedKeyringFile   string
+	gitHubAssetCacheDir        string
+	gitHubAssetCacheMaxMB      int64
+	gitHubPreReleases          string
+	gitHubIncludeDrafts        bool
+	gitHubVersionFromTag       bool
+	gitHubMaxMajorVersions     int
 
 	// > Environment variable names used by the utilities in the Shell and Utilities
 	// > volume of IEEE Std 1003.1-2001 consist solely of uppercase letters, digits,
@@ -78,27 +155,97 @@ const (
 
 func init() {
 	flag.StringVar(&listenAddr, "listen-addr", ":8080", "")
+	flag.DurationVar(&downloadTimeout, "download-timeout", 10*time.Minute, "Write timeout for the /download/ routes serving provider assets and module archives. Set to 0 to disable")
 	flag.BoolVar(&accessLogDisabled, "access-log-disabled", false, "")
 	flag.StringVar(&accessLogIgnoredPaths, "access-log-ignored-paths", "", "Comma-separated list of request paths to ignore logging for")
 	flag.BoolVar(&authDisabled, "auth-disabled", false, "")
 	flag.StringVar(&authTokensFile, "auth-tokens-file", "", "JSON encoded file containing a map of auth token descriptions and tokens.")
+	flag.StringVar(&adminTokensFile, "admin-tokens-file", "", "JSON encoded file containing a map of admin token descriptions and tokens, for the /admin routes. The /admin routes are disabled when not set.")
 	flag.StringVar(&envJSONFiles, "env-json-files", "", "Comma-separated list of paths to JSON encoded files containing a map of environment variable names and values to set. Converts the keys to uppercase and replaces all occurences of '-' with '_'. E.g. prefix filepaths with 'myprefix_:' to prefix all keys in the file with 'MYPREFIX_' before they are set.")
 	flag.BoolVar(&tlsEnabled, "tls-enabled", false, "")
 	flag.StringVar(&tlsCertFile, "tls-cert-file", "", "")
 	flag.StringVar(&tlsKeyFile, "tls-key-file", "", "")
-	flag.StringVar(&storeType, "store", "", "Store backend to use (choices: github, s3)")
-	flag.StringVar(&providerStoreType, "provider-store", "", "Which backend to use for the provider store (choices: github)")
+	flag.StringVar(&storeType, "store", "", "Store backend to use (choices: github, s3, azblob, gcs, oci, git, sql)")
+	flag.StringVar(&providerStoreType, "provider-store", "", "Which backend to use for the provider store. Must be the same as the store (choices: github, gcs, oci, sql)")
 	flag.StringVar(&logLevelStr, "log-level", "info", "Levels: debug, info, warn, error")
 	flag.StringVar(&logFormatStr, "log-format", "console", "Formats: json, console")
 	flag.BoolVar(&printVersionInfo, "version", false, "Print version info and exit")
+	flag.StringVar(&versionLifecycleFile, "version-lifecycle-file", "", "JSON encoded file with deprecated and yanked module and provider versions. Changes made through the /admin/lifecycle routes are written back to it")
+	flag.StringVar(&snapshotFile, "store-snapshot-file", "", "Path to a file where the store caches are persisted. If the file exists at startup, the caches are loaded from it and refreshed in the background. Supported by the github and git stores")
+
+	flag.StringVar(&gitHubOwnerFilter, "github-owner-filter", "", "Comma-separated list of GitHub orgs/users to filter module repositories by")
+	flag.StringVar(&gitHubTopicFilter, "github-topic-filter", "", "Comma-separated list of GitHub topics to filter module repositories by. Prefix a topic with '-' to exclude repositories having it")
+	flag.StringVar(&gitHubTopicMatch, "github-topic-match", "any", "Whether module repositories must have any or all of the topics in -github-topic-filter (choices: any, all)")
+	flag.StringVar(&gitHubProvidersOwnerFilter, "github-providers-owner-filter", "", "Comma-separated list of GitHub orgs/users to filter provider repositories by")
+	flag.StringVar(&gitHubProvidersTopicFilter, "github-providers-topic-filter", "", "Comma-separated list of GitHub topics to filter provider repositories by. Prefix a topic with '-' to exclude repositories having it")
+	flag.StringVar(&gitHubProvidersTopicMatch, "github-providers-topic-match", "any", "Whether provider repositories must have any or all of the topics in -github-providers-topic-filter (choices: any, all)")
+	flag.StringVar(&gitHubExcludeRepos, "github-exclude-repos", "", "Comma-separated list of repositories (owner/repo) to exclude from module and provider discovery")
+	flag.BoolVar(&gitHubExcludeArchived, "github-exclude-archived", false, "Exclude archived repositories from module and provider discovery")
+	flag.BoolVar(&gitHubIncludeForks, "github-include-forks", false, "Include forked repositories in module and provider discovery")
+	flag.StringVar(&gitHubBaseURL, "github-base-url", "", "GitHub Enterprise Server API URL, e.g. https://github.example.com/api/v3/. Leave empty to use github.com")
+	flag.StringVar(&gitHubUploadURL, "github-upload-url", "", "GitHub Enterprise Server upload URL, e.g. https://github.example.com/api/uploads/. Defaults to -github-base-url")
+	flag.StringVar(&gitHubSourceTemplate, "github-module-source-template", "", "Template for module source URLs. Placeholders: {host}, {owner}, {repo}, {tag}, {version}, {sha} (default \""+github.DefaultModuleSourceTemplate+"\")")
+	flag.StringVar(&gitHubSourceTemplatesFile, "github-module-source-templates-file", "", "JSON encoded file with a default module source URL template and overrides per owner and topic")
+	flag.BoolVar(&gitHubArchiveDownloads, "github-module-archive-downloads", false, "Serve module source archives through the registry instead of returning GitHub source URLs")
+	flag.BoolVar(&gitHubModuleDocs, "github-module-docs", false, "Extract the README, inputs, outputs and required providers of module versions from their source archives")
+	flag.StringVar(&gitHubTrustedKeyringFile, "github-provider-trusted-keyring-file", "", "ASCII armored GPG keyring with the only keys allowed to sign provider releases")
+	flag.StringVar(&gitHubPreReleases, "github-prereleases", "include", "Whether to include or exclude pre-release versions, optionally followed by overrides per namespace, e.g. 'exclude,myorg=include'")
+	flag.BoolVar(&gitHubIncludeDrafts, "github-provider-include-drafts", false, "Include draft provider releases")
+	flag.BoolVar(&gitHubVersionFromTag, "github-provider-version-from-tag", false, "Take provider versions from release tags instead of release names")
+	flag.IntVar(&gitHubMaxMajorVersions, "github-max-major-versions", 0, "Hide versions older than this number of major versions, counting from the latest. Set to 0 to keep all versions")
+	flag.StringVar(&gitHubAssetCacheDir, "github-provider-asset-cache-dir", "", "Directory to cache provider assets served through the registry in. Leave empty to disable")
+	flag.Int64Var(&gitHubAssetCacheMaxMB, "github-provider-asset-cache-max-mb", 0, "Maximum size of '-github-provider-asset-cache-dir' in megabytes. The least recently used assets are removed when exceeded. Set to 0 for no limit")
+	flag.DurationVar(&gitHubProviderIgnoreTTL, "github-provider-ignore-ttl", github.DefaultProviderIgnoreTTL, "How long invalid provider releases are ignored before they are validated again")
+
+	flag.StringVar(&azblobAccountURL, "azblob-account-url", "", "URL of the blob service of the storage account, such as https://myaccount.blob.core.windows.net")
+	flag.StringVar(&azblobContainer, "azblob-container", "", "Name of the container with the modules")
+	flag.StringVar(&azblobKeyPrefix, "azblob-key-prefix", "", "Prefix of the module blob names in the container")
+	flag.StringVar(&azblobManagedIdentity, "azblob-managed-identity-client-id", "", "Client ID of a user-assigned managed identity. Defaults to the default Azure credential chain")
+	flag.StringVar(&azblobDownloadMode, "azblob-download-mode", string(azblob.DownloadSAS), "How Terraform downloads module archives (choices: https, sas, proxy)")
+	flag.DurationVar(&azblobSASTTL, "azblob-sas-ttl", azblob.DefaultSASTTL, "Lifetime of SAS download URLs when '-azblob-download-mode=sas'")
+
+	// GCS Store
+	flag.StringVar(&gcsBucket, "gcs-bucket", "", "Name of the bucket with the modules and providers")
+	flag.StringVar(&gcsKeyPrefix, "gcs-key-prefix", "", "Prefix of the module object names in the bucket")
+	flag.StringVar(&gcsProviderKeyPrefix, "gcs-provider-key-prefix", "", "Prefix of the provider object names in the bucket")
+	flag.StringVar(&gcsDownloadMode, "gcs-download-mode", string(gcs.DownloadGCS), "How Terraform downloads module archives and provider packages (choices: gcs, signed, proxy)")
+	flag.DurationVar(&gcsSignedURLTTL, "gcs-signed-url-ttl", gcs.DefaultSignedURLTTL, "Lifetime of signed download URLs when '-gcs-download-mode=signed'")
+
+	// OCI Store
+	flag.StringVar(&ociHost, "oci-host", "", "Host of the OCI registry with the modules and providers, such as harbor.example.com")
+	flag.BoolVar(&ociPlainHTTP, "oci-plain-http", false, "Use HTTP instead of HTTPS for the OCI registry")
+	flag.StringVar(&ociRepositoryPrefix, "oci-repository-prefix", "", "Prefix of the module repositories, such as a Harbor project")
+	flag.StringVar(&ociProviderRepositoryPrefix, "oci-provider-repository-prefix", "", "Prefix of the provider repositories")
+	flag.StringVar(&ociDownloadMode, "oci-download-mode", string(oci.DownloadProxy), "How Terraform downloads module archives and provider packages (choices: proxy, redirect)")
+
+	// Git Store
+	flag.StringVar(&gitRepositoriesFile, "git-repositories-file", "", "JSON encoded file with the git repositories of the modules")
+	flag.StringVar(&gitSourceTemplate, "git-source-template", "", "Template for module source URLs. Placeholders: {url}, {tag}, {version}, {sha} (default \""+git.DefaultSourceTemplate+"\")")
+	flag.BoolVar(&gitArchiveDownloads, "git-archive-downloads", false, "Serve module source archives through the registry instead of returning git source URLs")
+	flag.StringVar(&gitMirrorDir, "git-mirror-dir", "", "Directory to fetch remote repositories into for '-git-archive-downloads'. Defaults to a temporary directory")
+	flag.DurationVar(&gitReloadInterval, "git-reload-interval", 5*time.Minute, "How often the tags of the repositories are listed")
 
-	flag.StringVar(&gitHubOwnerFilter, "github-owner-filter", "", "GitHub org/user repository filter")
-	flag.StringVar(&gitHubTopicFilter, "github-topic-filter", "", "GitHub topic repository filter")
-	flag.StringVar(&gitHubProvidersOwnerFilter, "github-providers-owner-filter", "", "GitHub providers topic repository filter")
-	flag.StringVar(&gitHubProvidersTopicFilter, "github-providers-topic-filter", "", "GitHub providers topic repository filter")
+	// SQL Store
+	flag.StringVar(&sqlDatabase, "sql-database", "", "Path of the SQLite database file, or a postgres:// URL. Defaults to SQL_DATABASE_URL")
+	flag.StringVar(&sqlBlobDir, "sql-blob-dir", "", "Directory to keep module archives and provider files in")
+	flag.StringVar(&sqlBlobS3Bucket, "sql-blob-s3-bucket", "", "S3 bucket to keep module archives and provider files in, instead of '-sql-blob-dir'. The client is configured by the '-s3-*' flags")
+	flag.StringVar(&sqlBlobS3KeyPrefix, "sql-blob-s3-key-prefix", "", "Prefix of the blob object names in '-sql-blob-s3-bucket'")
 
 	flag.StringVar(&S3Region, "s3-region", "", "S3 region such as us-east-1")
 	flag.StringVar(&S3Bucket, "s3-bucket", "", "S3 bucket name")
+	flag.StringVar(&S3Profile, "s3-profile", "", "Named profile in the shared AWS config files. Defaults to AWS_PROFILE")
+	flag.StringVar(&S3RoleARN, "s3-role-arn", "", "ARN of an IAM role to assume for accessing the bucket")
+	flag.StringVar(&S3RoleSession, "s3-role-session-name", s3.DefaultRoleSessionName, "Session name used when assuming '-s3-role-arn'")
+	flag.StringVar(&S3ExternalID, "s3-role-external-id", "", "External ID used when assuming '-s3-role-arn'")
+	flag.StringVar(&S3WebIdentity, "s3-web-identity-token-file", "", "Path of an OIDC token file exchanged for credentials of '-s3-role-arn', such as an EKS service account token")
+	flag.StringVar(&S3KeyPrefix, "s3-key-prefix", "", "Prefix of the module keys in the S3 bucket")
+	flag.StringVar(&S3Endpoint, "s3-endpoint", "", "URL of an S3-compatible service, such as MinIO. Leave empty to use AWS")
+	flag.BoolVar(&S3PathStyle, "s3-force-path-style", false, "Use path-style addressing, with the bucket name in the path instead of the hostname")
+	flag.StringVar(&S3SourceURLBase, "s3-source-url-base", "", "Base URL of the module source URLs returned to Terraform, followed by the object key. Defaults to the bucket URL")
+	flag.BoolVar(&S3ModuleDocs, "s3-module-docs", false, "Extract the README, inputs, outputs and required providers of module versions from their archives")
+	flag.StringVar(&S3DownloadMode, "s3-download-mode", string(s3.DownloadS3), "How Terraform downloads module archives (choices: s3, presigned, proxy)")
+	flag.DurationVar(&S3PresignTTL, "s3-presign-ttl", s3.DefaultPresignTTL, "Lifetime of presigned download URLs when '-s3-download-mode=presigned'")
+	flag.DurationVar(&S3IndexInterval, "s3-index-interval", 0, "Serve lookups from an index of the S3 bucket, refreshed at this interval. Set to 0 to list the bucket on every lookup")
 }
 
 func main() {
@@ -153,16 +300,46 @@ func main() {
 	githubPrivatePem = os.Getenv("GITHUB_PRIVATE_PEM")
 	githubApplicationID = os.Getenv("GITHUB_APPLICATION_ID")
 	assetDownloadAuthSecret = os.Getenv("ASSET_DOWNLOAD_AUTH_SECRET")
+	azblobConnectionString = os.Getenv("AZURE_STORAGE_CONNECTION_STRING")
+	azblobSASToken = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	ociUsername = os.Getenv("OCI_USERNAME")
+	ociPassword = os.Getenv("OCI_PASSWORD")
+	if sqlDatabase == "" {
+		sqlDatabase = os.Getenv("SQL_DATABASE_URL")
+	}
+
+	// Publish to the SQL store instead of serving the registry
+	if flag.Arg(0) == "publish" {
+		err := publish(context.Background(), newSQLStore(), flag.Args()[1:])
+		if errors.Is(err, errPublishUsage) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err != nil {
+			logger.Fatal("failed to publish",
+				zap.Error(err),
+			)
+		}
+		return
+	}
 
 	reg := registry.NewRegistry(logger)
 	reg.AccessLogIgnoredPaths = strings.Split(accessLogIgnoredPaths, ",")
 	reg.IsAccessLogDisabled = accessLogDisabled
 	reg.IsAuthDisabled = authDisabled
 	reg.AssetDownloadAuthSecret = []byte(assetDownloadAuthSecret)
+	reg.DownloadTimeout = downloadTimeout
+	reg.LogLevel = &logLevel
 
-	if providerStoreType == "github" {
+	if providerStoreType != "" && providerStoreType != storeType {
+		logger.Fatal("provider store must be the same as the store",
+			zap.String("store", storeType),
+			zap.String("providerStore", providerStoreType),
+		)
+	}
+	if providerStoreType == "github" || providerStoreType == "gcs" || providerStoreType == "oci" || providerStoreType == "sql" {
 		reg.IsProviderEnabled = true
-		logger.Info("enabling github provider store")
+		logger.Info("enabling " + providerStoreType + " provider store")
 	}
 
 	logger.Info("HTTP access log configuration", zap.Bool("disabled", reg.IsAccessLogDisabled), zap.Strings("ignoredPaths", reg.AccessLogIgnoredPaths))
@@ -195,13 +372,70 @@ func main() {
 		logger.Warn("authentication disabled")
 	}
 
+	// Configure authentication of the administrative routes, which are disabled without admin tokens
+	if adminTokensFile != "" {
+		go watchFile(context.TODO(), adminTokensFile, 10*time.Second, func(b []byte) {
+			tokens, err := parseAuthTokens(b)
+			if err != nil {
+				logger.Error("failed to load admin tokens",
+					zap.Error(err),
+				)
+			}
+
+			reg.SetAdminTokens(tokens)
+
+			if len(tokens) == 0 {
+				logger.Warn("reloaded admin token file", zap.Int("count", len(tokens)))
+			} else {
+				logger.Info("reloaded admin token file", zap.Int("count", len(tokens)))
+			}
+		})
+		logger.Info("admin API enabled")
+	} else {
+		logger.Info("admin API disabled")
+	}
+
+	// Configure deprecated and yanked versions
+	if versionLifecycleFile != "" {
+		go watchFile(context.TODO(), versionLifecycleFile, 10*time.Second, func(b []byte) {
+			var lifecycle registry.VersionLifecycle
+			if err := json.Unmarshal(b, &lifecycle); err != nil {
+				logger.Error("failed to load version lifecycle",
+					zap.Error(err),
+				)
+				return
+			}
+			reg.SetVersionLifecycle(lifecycle)
+			logger.Info("reloaded version lifecycle file",
+				zap.Int("modules", len(lifecycle.Modules)),
+				zap.Int("providers", len(lifecycle.Providers)),
+			)
+		})
+		reg.OnVersionLifecycleChange = func(lifecycle registry.VersionLifecycle) error {
+			return saveVersionLifecycle(lifecycle, versionLifecycleFile)
+		}
+	}
+
 	logger.Info("initialising stores")
+	if snapshotFile != "" && storeType != "github" && storeType != "git" {
+		logger.Fatal("flag '-store-snapshot-file' is only supported by the github and git stores", zap.String("selected", storeType))
+	}
 	// Configure the chosen store type
 	switch storeType {
 	case "github":
 		gitHubRegistry(reg)
 	case "s3":
 		s3Registry(reg)
+	case "azblob":
+		azblobRegistry(reg)
+	case "gcs":
+		gcsRegistry(reg)
+	case "oci":
+		ociRegistry(reg)
+	case "git":
+		gitRegistry(reg)
+	case "sql":
+		sqlRegistry(reg)
 	default:
 		logger.Fatal("invalid store type", zap.String("selected", storeType))
 	}
@@ -283,18 +517,34 @@ func gitHubRegistry(reg *registry.Registry) {
 	if gitHubToken == "" && (githubPrivatePem == "" || githubApplicationID == "") {
 		logger.Fatal("either GITHUB_TOKEN must be set, or GITHUB_PRIVATE_PEM and GITHUB_APPLICATION_ID")
 	}
-	if gitHubOwnerFilter == "" && gitHubTopicFilter == "" {
+	moduleFilter, err := parseRepositoryFilter(gitHubOwnerFilter, gitHubTopicFilter, gitHubTopicMatch)
+	if err != nil {
+		logger.Fatal("invalid module repository filter", zap.Error(err))
+	}
+	if moduleFilter.IsEmpty() {
 		logger.Fatal("at least one of -github-owner-filter and -github-topic-filter must be set")
 	}
 
-	if reg.IsProviderEnabled && gitHubProvidersOwnerFilter == "" && gitHubProvidersTopicFilter == "" {
+	providerFilter, err := parseRepositoryFilter(gitHubProvidersOwnerFilter, gitHubProvidersTopicFilter, gitHubProvidersTopicMatch)
+	if err != nil {
+		logger.Fatal("invalid provider repository filter", zap.Error(err))
+	}
+	if reg.IsProviderEnabled && providerFilter.IsEmpty() {
 		logger.Fatal("at least one of -github-providers-owner-filter and -github-providers-topic-filter must be set when provider store is enabled")
 	}
 
-	store, err := github.NewGitHubStore(gitHubOwnerFilter, gitHubTopicFilter, gitHubProvidersOwnerFilter, gitHubProvidersTopicFilter, github.GithubAuthParams{
+	for _, filter := range []*github.RepositoryFilter{&moduleFilter, &providerFilter} {
+		filter.ExcludeRepos = splitList(gitHubExcludeRepos)
+		filter.ExcludeArchived = gitHubExcludeArchived
+		filter.IncludeForks = gitHubIncludeForks
+	}
+
+	store, err := github.NewGitHubStore(moduleFilter, providerFilter, github.GithubAuthParams{
 		AccessToken:   gitHubToken,
 		PrivatePem:    []byte(githubPrivatePem),
 		ApplicationID: githubApplicationID,
+		BaseURL:       gitHubBaseURL,
+		UploadURL:     gitHubUploadURL,
 	}, logger.Named("github store"))
 	if err != nil {
 		logger.Fatal(fmt.Sprintf("failed setting up github store, err: %s", err))
@@ -302,52 +552,279 @@ func gitHubRegistry(reg *registry.Registry) {
 	reg.SetModuleStore(store)
 	reg.SetProviderStore(store)
 
-	// Fill module store cache initially
-	logger.Debug("loading GitHub module store cache")
-	if err := store.ReloadCache(context.Background()); err != nil {
-		logger.Error("failed to load GitHub module store cache",
-			zap.Error(err),
-		)
+	templates, err := loadSourceTemplates(gitHubSourceTemplatesFile, gitHubSourceTemplate)
+	if err != nil {
+		logger.Fatal("failed to load module source templates", zap.Error(err))
 	}
+	if err := store.SetModuleSourceTemplates(templates); err != nil {
+		logger.Fatal("invalid module source templates", zap.Error(err))
+	}
+	store.SetModuleArchiveDownloads(gitHubArchiveDownloads)
+	store.SetModuleDocs(gitHubModuleDocs)
+	store.SetProviderIgnoreTTL(gitHubProviderIgnoreTTL)
 
-	// Fill provider store cache initially
-	if reg.IsProviderEnabled {
-		logger.Debug("loading GitHub provider store cache")
-		err := store.ReloadProviderCache(context.Background())
+	policy, err := parsePreReleasePolicy(gitHubPreReleases)
+	if err != nil {
+		logger.Fatal("invalid pre-release policy", zap.Error(err))
+	}
+	policy.IncludeDrafts = gitHubIncludeDrafts
+	policy.VersionFromTag = gitHubVersionFromTag
+	policy.MaxMajorVersions = gitHubMaxMajorVersions
+	store.SetVersionPolicy(policy)
+	if gitHubAssetCacheDir != "" {
+		if err := store.SetAssetCacheDir(gitHubAssetCacheDir, gitHubAssetCacheMaxMB<<20); err != nil {
+			logger.Fatal("failed to set up provider asset cache", zap.Error(err))
+		}
+	}
+	if gitHubTrustedKeyringFile != "" {
+		f, err := os.Open(gitHubTrustedKeyringFile)
+		if err != nil {
+			logger.Fatal("failed to open trusted keyring", zap.Error(err))
+		}
+		err = store.SetTrustedKeyring(f)
+		f.Close()
 		if err != nil {
-			logger.Error("failed to load GitHub provider store cache",
+			logger.Fatal("invalid trusted keyring", zap.Error(err))
+		}
+	}
+
+	save := func() {
+		if snapshotFile != "" {
+			if err := saveSnapshot(store, snapshotFile); err != nil {
+				logger.Error("failed to save store cache snapshot",
+					zap.String("filename", snapshotFile),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+	// Caches reloaded through the admin API are saved right away
+	reg.OnCacheReload = save
+
+	reload := func() {
+		logger.Debug("reloading GitHub module store cache")
+		if err := store.ReloadCache(context.Background()); err != nil {
+			logger.Error("failed to reload GitHub module store cache",
 				zap.Error(err),
 			)
 		}
+		if reg.IsProviderEnabled {
+			logger.Debug("reloading GitHub provider store cache")
+			err := store.ReloadProviderCache(context.Background())
+			if err != nil {
+				logger.Error("failed to reload GitHub provider store cache",
+					zap.Error(err),
+				)
+			}
+		}
+		logRateLimitStatus(store.RateLimitStatus())
+		save()
 	}
 
-	// Reload store caches on regular intervals
-	go func() {
-		t := time.NewTicker(5 * time.Minute)
-		defer t.Stop()
-		<-t.C // ignore the first tick
+	// Fill store caches initially. When a snapshot is available, serve from it
+	// while the caches are filled in the background.
+	if snapshotFile != "" && loadSnapshot(store, snapshotFile) {
+		go reload()
+	} else {
+		reload()
+	}
 
+	// Reload store caches on regular intervals. Reloads are postponed while
+	// the GitHub API rate limit is exhausted, and resumed once it resets.
+	go func() {
 		for {
-			logger.Debug("reloading GitHub module store cache")
-			if err := store.ReloadCache(context.Background()); err != nil {
-				logger.Error("failed to reload GitHub module store cache",
-					zap.Error(err),
+			wait := 5 * time.Minute
+			if until := store.RateLimitedUntil(); time.Until(until) > wait {
+				wait = time.Until(until)
+				logger.Warn("GitHub rate limit exhausted, pausing store cache reloads",
+					zap.Time("until", until),
 				)
 			}
-			if reg.IsProviderEnabled {
-				logger.Debug("reloading GitHub provider store cache")
-				err := store.ReloadProviderCache(context.Background())
-				if err != nil {
-					logger.Error("failed to reload GitHub provider store cache",
-						zap.Error(err),
-					)
-				}
-			}
-			<-t.C
+			time.Sleep(wait)
+			reload()
 		}
 	}()
 }
 
+// loadSnapshot loads the store caches from the snapshot file at `filename`.
+// Returns whether the snapshot was loaded. A missing file is not considered an error.
+func loadSnapshot(store core.Snapshotter, filename string) bool {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Info("store cache snapshot not found", zap.String("filename", filename))
+		return false
+	} else if err != nil {
+		logger.Error("failed to open store cache snapshot", zap.String("filename", filename), zap.Error(err))
+		return false
+	}
+	defer f.Close()
+
+	if err := store.LoadSnapshot(f); err != nil {
+		logger.Error("failed to load store cache snapshot", zap.String("filename", filename), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// saveSnapshot writes a snapshot of the store caches to `filename`. The file is replaced
+// atomically, so that a crash while writing never leaves a truncated snapshot behind.
+func saveSnapshot(store core.Snapshotter, filename string) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := store.SaveSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// saveVersionLifecycle writes the version lifecycle to a temporary file, which then replaces `filename`,
+// so that the file is never partially written.
+func saveVersionLifecycle(lifecycle registry.VersionLifecycle, filename string) error {
+	b, err := json.MarshalIndent(lifecycle, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// logRateLimitStatus logs the last known GitHub API rate limits, of each GitHub App installation if any.
+func logRateLimitStatus(status github.RateLimitStatus, fields ...zap.Field) {
+	for name, res := range status.Resources {
+		logger.Debug("GitHub API rate limit", append(fields,
+			zap.String("resource", name),
+			zap.Int("limit", res.Limit),
+			zap.Int("remaining", res.Remaining),
+			zap.Time("reset", res.Reset),
+		)...)
+	}
+	if status.PausedUntil != nil {
+		logger.Warn("GitHub API calls paused because of rate limiting", append(fields,
+			zap.Time("until", *status.PausedUntil),
+		)...)
+	}
+	for owner, installation := range status.Installations {
+		logRateLimitStatus(installation, zap.String("owner", owner))
+	}
+}
+
+// parseRepositoryFilter returns a repository filter from comma-separated lists of owners and topics.
+// Topics prefixed with `-` are excluded. `topicMatch` must be either `any` or `all`.
+func parseRepositoryFilter(owners, topics, topicMatch string) (github.RepositoryFilter, error) {
+	filter := github.RepositoryFilter{
+		Owners: splitList(owners),
+	}
+
+	switch topicMatch {
+	case "", "any":
+	case "all":
+		filter.MatchAllTopics = true
+	default:
+		return filter, fmt.Errorf("invalid topic match '%s', must be one of: any, all", topicMatch)
+	}
+
+	for _, topic := range splitList(topics) {
+		if excluded, ok := strings.CutPrefix(topic, "-"); ok {
+			filter.ExcludeTopics = append(filter.ExcludeTopics, excluded)
+		} else {
+			filter.Topics = append(filter.Topics, topic)
+		}
+	}
+
+	return filter, nil
+}
+
+// parsePreReleasePolicy parses a pre-release policy on the form `include|exclude[,namespace=include|exclude...]`.
+func parsePreReleasePolicy(s string) (github.VersionPolicy, error) {
+	var policy github.VersionPolicy
+	parse := func(value string) (bool, error) {
+		switch value {
+		case "include":
+			return true, nil
+		case "exclude":
+			return false, nil
+		}
+		return false, fmt.Errorf("invalid pre-release policy '%s', must be one of: include, exclude", value)
+	}
+
+	for i, item := range splitList(s) {
+		namespace, value, isOverride := strings.Cut(item, "=")
+		if !isOverride {
+			if i > 0 {
+				return policy, fmt.Errorf("default pre-release policy '%s' must come first", item)
+			}
+			include, err := parse(item)
+			if err != nil {
+				return policy, err
+			}
+			policy.ExcludePreReleases = !include
+			continue
+		}
+
+		include, err := parse(value)
+		if err != nil {
+			return policy, err
+		}
+		if policy.PreReleaseNamespaces == nil {
+			policy.PreReleaseNamespaces = make(map[string]bool)
+		}
+		policy.PreReleaseNamespaces[namespace] = include
+	}
+
+	return policy, nil
+}
+
+// splitList splits a comma-separated list, ignoring surrounding whitespace and empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadSourceTemplates reads module source URL templates from the JSON encoded file at `filename`, if set.
+// The default template from the file is replaced by `defaultTemplate` if that is set.
+func loadSourceTemplates(filename, defaultTemplate string) (github.SourceTemplates, error) {
+	var templates github.SourceTemplates
+	if filename != "" {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return templates, err
+		}
+		if err := json.Unmarshal(b, &templates); err != nil {
+			return templates, fmt.Errorf("while parsing file '%s': %w", filename, err)
+		}
+	}
+	if defaultTemplate != "" {
+		templates.Default = defaultTemplate
+	}
+	return templates, nil
+}
+
 // s3Registry configures the registry to use S3Store.
 func s3Registry(reg *registry.Registry) {
 	if S3Region == "" {
@@ -357,25 +834,344 @@ func s3Registry(reg *registry.Registry) {
 		logger.Fatal("Missing flag '-s3-bucket'")
 	}
 
-	sess, err := session.NewSession()
+	client, err := s3.NewClient(context.Background(), s3.ClientOptions{
+		Region:               S3Region,
+		Endpoint:             S3Endpoint,
+		PathStyle:            S3PathStyle,
+		Profile:              S3Profile,
+		RoleARN:              S3RoleARN,
+		RoleSessionName:      S3RoleSession,
+		ExternalID:           S3ExternalID,
+		WebIdentityTokenFile: S3WebIdentity,
+	})
+	if err != nil {
+		logger.Fatal("failed to create S3 client",
+			zap.Error(err),
+		)
+	}
+	logger.Debug("S3 client created successfully")
+
+	// Credentials are retrieved on first use, and refreshed when they expire. A failure here is not
+	// fatal, as credentials like instance roles may not be available until the network is ready.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if _, err := client.Options().Credentials.Retrieve(ctx); err != nil {
+		logger.Warn("unable to retrieve AWS credentials",
+			zap.Error(err),
+		)
+	}
+	cancel()
+
+	store := s3.NewS3Store(client, S3Region, S3Bucket, logger.Named("s3 store"))
+	store.SetKeyPrefix(S3KeyPrefix)
+
+	sourceURLBase := S3SourceURLBase
+	if sourceURLBase == "" && S3Endpoint != "" {
+		sourceURLBase, err = s3.EndpointSourceURLBase(S3Endpoint, S3Bucket)
+		if err != nil {
+			logger.Fatal("invalid S3 endpoint", zap.Error(err))
+		}
+	}
+	store.SetSourceURLBase(sourceURLBase)
+	store.SetModuleDocs(S3ModuleDocs)
+
+	downloadMode, err := s3.ParseDownloadMode(S3DownloadMode)
+	if err != nil {
+		logger.Fatal("invalid flag '-s3-download-mode'", zap.Error(err))
+	}
+	store.SetDownloadMode(downloadMode, S3PresignTTL)
+	reg.SetModuleStore(store)
+
+	if S3IndexInterval > 0 {
+		store.SetIndexEnabled(true)
+		reload := func() {
+			logger.Debug("reloading S3 store index")
+			if err := store.ReloadCache(context.Background()); err != nil {
+				logger.Error("failed to reload S3 store index",
+					zap.Error(err),
+				)
+			}
+		}
+
+		// Lookups list the bucket until the index is built
+		reload()
+		go func() {
+			for {
+				time.Sleep(S3IndexInterval)
+				reload()
+			}
+		}()
+	}
+}
+
+// azblobRegistry configures the registry to use AzureBlobStore.
+func azblobRegistry(reg *registry.Registry) {
+	if azblobContainer == "" {
+		logger.Fatal("Missing flag '-azblob-container'")
+	}
+	if azblobConnectionString == "" && azblobAccountURL == "" {
+		logger.Fatal("Missing flag '-azblob-account-url' or environment variable 'AZURE_STORAGE_CONNECTION_STRING'")
+	}
+
+	client, err := azblob.NewClient(azblob.ClientOptions{
+		ConnectionString:        azblobConnectionString,
+		AccountURL:              azblobAccountURL,
+		SASToken:                azblobSASToken,
+		ManagedIdentityClientID: azblobManagedIdentity,
+	})
 	if err != nil {
-		logger.Fatal("AWS session creation failed")
+		logger.Fatal("failed to create Azure Blob Storage client",
+			zap.Error(err),
+		)
 	}
-	logger.Debug("AWS session created successfully")
 
-	_, err = sess.Config.Credentials.Get()
+	store := azblob.NewAzureBlobStore(client, azblobContainer, logger.Named("azblob store"))
+	store.SetKeyPrefix(azblobKeyPrefix)
+
+	downloadMode, err := azblob.ParseDownloadMode(azblobDownloadMode)
 	if err != nil {
-		logger.Fatal("AWS session credentials not found")
+		logger.Fatal("invalid flag '-azblob-download-mode'", zap.Error(err))
+	}
+	if err := store.SetDownloadMode(downloadMode, azblobSASTTL); err != nil {
+		logger.Fatal("invalid flag '-azblob-download-mode'", zap.Error(err))
+	}
+	reg.SetModuleStore(store)
+}
+
+// gcsRegistry configures the registry to use GCSStore.
+func gcsRegistry(reg *registry.Registry) {
+	if gcsBucket == "" {
+		logger.Fatal("Missing flag '-gcs-bucket'")
 	}
-	s3Sess := awss3.New(sess)
 
-	store := s3.NewS3Store(s3Sess, S3Region, S3Bucket, logger.Named("s3 store"))
+	// Uses Application Default Credentials, or the emulator at STORAGE_EMULATOR_HOST when set
+	client, err := storage.NewClient(context.Background())
 	if err != nil {
-		logger.Fatal("failed to create S3 store",
+		logger.Fatal("failed to create GCS client",
 			zap.Error(err),
 		)
 	}
+
+	store := gcs.NewGCSStore(client, gcsBucket, logger.Named("gcs store"))
+	store.SetKeyPrefix(gcsKeyPrefix)
+	store.SetProviderKeyPrefix(gcsProviderKeyPrefix)
+
+	downloadMode, err := gcs.ParseDownloadMode(gcsDownloadMode)
+	if err != nil {
+		logger.Fatal("invalid flag '-gcs-download-mode'", zap.Error(err))
+	}
+	store.SetDownloadMode(downloadMode, gcsSignedURLTTL)
 	reg.SetModuleStore(store)
+	reg.SetProviderStore(store)
+}
+
+// ociRegistry configures the registry to use OCIStore.
+func ociRegistry(reg *registry.Registry) {
+	if ociHost == "" {
+		logger.Fatal("Missing flag '-oci-host'")
+	}
+
+	client, err := oci.NewClient(oci.ClientOptions{
+		Host:      ociHost,
+		PlainHTTP: ociPlainHTTP,
+		Username:  ociUsername,
+		Password:  ociPassword,
+		UserAgent: programName + "/" + version,
+	})
+	if err != nil {
+		logger.Fatal("failed to create OCI registry client",
+			zap.Error(err),
+		)
+	}
+
+	store := oci.NewOCIStore(client, logger.Named("oci store"))
+	store.SetRepositoryPrefix(ociRepositoryPrefix)
+	store.SetProviderRepositoryPrefix(ociProviderRepositoryPrefix)
+
+	downloadMode, err := oci.ParseDownloadMode(ociDownloadMode)
+	if err != nil {
+		logger.Fatal("invalid flag '-oci-download-mode'", zap.Error(err))
+	}
+	store.SetDownloadMode(downloadMode)
+	reg.SetModuleStore(store)
+	reg.SetProviderStore(store)
+}
+
+// gitRegistry configures the registry to use GitStore.
+func gitRegistry(reg *registry.Registry) {
+	if gitRepositoriesFile == "" {
+		logger.Fatal("Missing flag '-git-repositories-file'")
+	}
+
+	b, err := os.ReadFile(gitRepositoriesFile)
+	if err != nil {
+		logger.Fatal("failed to read git repositories file",
+			zap.Error(err),
+		)
+	}
+	repos, err := git.ParseRepositories(b)
+	if err != nil {
+		logger.Fatal("failed to parse git repositories file",
+			zap.Error(err),
+		)
+	}
+
+	store := git.NewGitStore(repos, logger.Named("git store"))
+	if err := store.SetSourceTemplate(gitSourceTemplate); err != nil {
+		logger.Fatal("invalid flag '-git-source-template'", zap.Error(err))
+	}
+	store.SetArchiveDownloads(gitArchiveDownloads, gitMirrorDir)
+	reg.SetModuleStore(store)
+
+	save := func() {
+		if snapshotFile != "" {
+			if err := saveSnapshot(store, snapshotFile); err != nil {
+				logger.Error("failed to save store cache snapshot",
+					zap.String("filename", snapshotFile),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+	// Caches reloaded through the admin API are saved right away
+	reg.OnCacheReload = save
+
+	reload := func() {
+		logger.Debug("reloading git store cache")
+		if err := store.ReloadCache(context.Background()); err != nil {
+			logger.Error("failed to reload git store cache",
+				zap.Error(err),
+			)
+		}
+		save()
+	}
+
+	// Fill the store cache initially. When a snapshot is available, serve from it
+	// while the cache is filled in the background.
+	if snapshotFile != "" && loadSnapshot(store, snapshotFile) {
+		go reload()
+	} else {
+		reload()
+	}
+	go func() {
+		for {
+			time.Sleep(gitReloadInterval)
+			reload()
+		}
+	}()
+}
+
+// newSQLStore returns the SQLStore configured by the '-sql-*' flags, with the database schema migrated.
+func newSQLStore() *sqlstore.SQLStore {
+	if sqlDatabase == "" {
+		logger.Fatal("Missing flag '-sql-database' or environment variable 'SQL_DATABASE_URL'")
+	}
+	if (sqlBlobDir == "") == (sqlBlobS3Bucket == "") {
+		logger.Fatal("Exactly one of the flags '-sql-blob-dir' and '-sql-blob-s3-bucket' must be set")
+	}
+
+	var blobs sqlstore.BlobStore
+	if sqlBlobDir != "" {
+		blobs = sqlstore.NewFileBlobStore(sqlBlobDir)
+	} else {
+		client, err := s3.NewClient(context.Background(), s3.ClientOptions{
+			Region:               S3Region,
+			Endpoint:             S3Endpoint,
+			PathStyle:            S3PathStyle,
+			Profile:              S3Profile,
+			RoleARN:              S3RoleARN,
+			RoleSessionName:      S3RoleSession,
+			ExternalID:           S3ExternalID,
+			WebIdentityTokenFile: S3WebIdentity,
+		})
+		if err != nil {
+			logger.Fatal("failed to create S3 client",
+				zap.Error(err),
+			)
+		}
+		blobs = sqlstore.NewS3BlobStore(client, sqlBlobS3Bucket, sqlBlobS3KeyPrefix)
+	}
+
+	db, dialect, err := sqlstore.Open(sqlDatabase)
+	if err != nil {
+		logger.Fatal("failed to open database",
+			zap.Error(err),
+		)
+	}
+
+	store := sqlstore.NewSQLStore(db, dialect, blobs, logger.Named("sql store"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	if err := store.Migrate(ctx); err != nil {
+		logger.Fatal("failed to migrate database",
+			zap.Error(err),
+		)
+	}
+	cancel()
+	return store
+}
+
+// sqlRegistry configures the registry to use SQLStore.
+func sqlRegistry(reg *registry.Registry) {
+	store := newSQLStore()
+	reg.SetModuleStore(store)
+	reg.SetProviderStore(store)
+
+	// Files of failed publishes are deleted once no publish of the same content can be in progress
+	go func() {
+		for {
+			n, err := store.DeleteUnreferencedBlobs(context.Background(), time.Hour)
+			if err != nil {
+				logger.Error("failed to delete unreferenced blobs", zap.Error(err))
+			} else if n > 0 {
+				logger.Info("deleted unreferenced blobs", zap.Int("count", n))
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+
+	// Deprecated and yanked versions are shared by the replicas through the database,
+	// unless they are kept in '-version-lifecycle-file'
+	if versionLifecycleFile != "" {
+		return
+	}
+	reload := func() {
+		lifecycle, err := store.GetVersionLifecycle(context.Background())
+		if err != nil {
+			logger.Error("failed to load version lifecycle", zap.Error(err))
+			return
+		}
+		reg.SetVersionLifecycle(registryVersionLifecycle(lifecycle))
+	}
+	// Only the changed version is written, so that concurrent changes through other replicas are kept
+	reg.OnVersionStatusChange = func(kind, key, version string, status *registry.VersionStatus) error {
+		set := store.SetModuleVersionStatus
+		if kind == "providers" {
+			set = store.SetProviderVersionStatus
+		}
+		return set(context.Background(), key, version, (*sqlstore.VersionStatus)(status))
+	}
+
+	reload()
+	go func() {
+		for {
+			time.Sleep(10 * time.Second)
+			reload()
+		}
+	}()
+}
+
+// registryVersionLifecycle converts the version lifecycle of SQLStore to the one of the registry.
+func registryVersionLifecycle(lifecycle sqlstore.VersionLifecycle) registry.VersionLifecycle {
+	convert := func(m map[string]map[string]sqlstore.VersionStatus) map[string]map[string]registry.VersionStatus {
+		res := make(map[string]map[string]registry.VersionStatus, len(m))
+		for key, versions := range m {
+			res[key] = make(map[string]registry.VersionStatus, len(versions))
+			for version, status := range versions {
+				res[key][version] = registry.VersionStatus(status)
+			}
+		}
+		return res
+	}
+	return registry.VersionLifecycle{Modules: convert(lifecycle.Modules), Providers: convert(lifecycle.Providers)}
 }
 
 // parseAuthTokens returns a map of all elements in the JSON object contained in `b`.
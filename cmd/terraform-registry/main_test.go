@@ -5,14 +5,24 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/registry"
+	sqlstore "github.com/nrkno/terraform-registry/pkg/store/sql"
 )
 
 func TestParseAuthTokenFile(t *testing.T) {
@@ -76,3 +86,258 @@ func TestWatchFile(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	is.Equal(len(results), 0) // should not be any more events
 }
+
+func TestLoadSourceTemplates(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, `{"default": "git::https://{host}/{owner}/{repo}.git?ref={tag}", "owners": {"foo": "git::https://{host}/foo/{repo}.git?ref={sha}"}}`)
+	f.Close()
+
+	t.Run("from file", func(t *testing.T) {
+		is := is.New(t)
+		templates, err := loadSourceTemplates(f.Name(), "")
+		is.NoErr(err)
+		is.Equal(templates.Default, "git::https://{host}/{owner}/{repo}.git?ref={tag}")
+		is.Equal(templates.Owners["foo"], "git::https://{host}/foo/{repo}.git?ref={sha}")
+	})
+
+	t.Run("default template overrides file", func(t *testing.T) {
+		is := is.New(t)
+		templates, err := loadSourceTemplates(f.Name(), "git::ssh://git@{host}/{owner}/{repo}.git?ref={sha}")
+		is.NoErr(err)
+		is.Equal(templates.Default, "git::ssh://git@{host}/{owner}/{repo}.git?ref={sha}")
+		is.Equal(templates.Owners["foo"], "git::https://{host}/foo/{repo}.git?ref={sha}")
+	})
+
+	t.Run("without file", func(t *testing.T) {
+		is := is.New(t)
+		templates, err := loadSourceTemplates("", "")
+		is.NoErr(err)
+		is.Equal(templates.Default, "")
+	})
+}
+
+func TestParsePreReleasePolicy(t *testing.T) {
+	t.Run("default only", func(t *testing.T) {
+		is := is.New(t)
+		policy, err := parsePreReleasePolicy("exclude")
+		is.NoErr(err)
+		is.True(policy.ExcludePreReleases)
+		is.Equal(len(policy.PreReleaseNamespaces), 0)
+	})
+
+	t.Run("namespace overrides", func(t *testing.T) {
+		is := is.New(t)
+		policy, err := parsePreReleasePolicy("exclude, myorg=include,other=exclude")
+		is.NoErr(err)
+		is.True(policy.ExcludePreReleases)
+		is.Equal(policy.PreReleaseNamespaces, map[string]bool{"myorg": true, "other": false})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		is := is.New(t)
+		_, err := parsePreReleasePolicy("maybe")
+		is.Equal(err.Error(), "invalid pre-release policy 'maybe', must be one of: include, exclude")
+		_, err = parsePreReleasePolicy("myorg=include,exclude")
+		is.Equal(err.Error(), "default pre-release policy 'exclude' must come first")
+	})
+}
+
+func TestParseRepositoryFilter(t *testing.T) {
+	t.Run("owners and topics", func(t *testing.T) {
+		is := is.New(t)
+		filter, err := parseRepositoryFilter("org-a, org-b,,org-c", "terraform-module,-deprecated", "all")
+		is.NoErr(err)
+		is.Equal(filter.Owners, []string{"org-a", "org-b", "org-c"})
+		is.Equal(filter.Topics, []string{"terraform-module"})
+		is.Equal(filter.ExcludeTopics, []string{"deprecated"})
+		is.True(filter.MatchAllTopics)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		is := is.New(t)
+		filter, err := parseRepositoryFilter("", "", "any")
+		is.NoErr(err)
+		is.True(filter.IsEmpty())
+	})
+
+	t.Run("invalid topic match", func(t *testing.T) {
+		is := is.New(t)
+		_, err := parseRepositoryFilter("org-a", "", "some")
+		is.True(err != nil)
+	})
+}
+
+// snapshotStore is a core.Snapshotter keeping the snapshot in memory.
+type snapshotStore struct {
+	data string
+}
+
+func (s *snapshotStore) SaveSnapshot(w io.Writer) error {
+	_, err := io.WriteString(w, s.data)
+	return err
+}
+
+func (s *snapshotStore) LoadSnapshot(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return fmt.Errorf("empty snapshot")
+	}
+	s.data = string(b)
+	return nil
+}
+
+func TestSnapshotFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "snapshot.json")
+
+	t.Run("missing file is not loaded", func(t *testing.T) {
+		is := is.New(t)
+		is.True(!loadSnapshot(&snapshotStore{}, filename))
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(saveSnapshot(&snapshotStore{data: "snapshot"}, filename))
+
+		store := &snapshotStore{}
+		is.True(loadSnapshot(store, filename))
+		is.Equal(store.data, "snapshot")
+
+		// No temporary files are left behind
+		entries, err := os.ReadDir(filepath.Dir(filename))
+		is.NoErr(err)
+		is.Equal(len(entries), 1)
+	})
+
+	t.Run("invalid snapshot is not loaded", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(os.WriteFile(filename, nil, 0o600))
+		is.True(!loadSnapshot(&snapshotStore{}, filename))
+	})
+}
+
+func TestSaveVersionLifecycle(t *testing.T) {
+	is := is.New(t)
+	filename := filepath.Join(t.TempDir(), "lifecycle.json")
+
+	lifecycle := registry.VersionLifecycle{
+		Modules: map[string]map[string]registry.VersionStatus{
+			"myorg/network/generic": {"1.2.0": {Deprecated: true, Reason: "Use 2.x"}},
+		},
+	}
+	is.NoErr(saveVersionLifecycle(lifecycle, filename))
+
+	b, err := os.ReadFile(filename)
+	is.NoErr(err)
+	var loaded registry.VersionLifecycle
+	is.NoErr(json.Unmarshal(b, &loaded))
+	is.Equal(loaded.Modules, lifecycle.Modules)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	is.NoErr(err)
+	is.Equal(len(entries), 1)
+}
+
+func TestSQLVersionLifecycle(t *testing.T) {
+	is := is.New(t)
+
+	lifecycle := sqlstore.VersionLifecycle{
+		Modules: map[string]map[string]sqlstore.VersionStatus{
+			"myorg/network/generic": {"1.2.0": {Deprecated: true, Reason: "Use 2.x"}},
+		},
+		Providers: map[string]map[string]sqlstore.VersionStatus{
+			"myorg/internal": {"0.3.1": {Yanked: true, Reason: "Broken on darwin"}},
+		},
+	}
+	is.Equal(registryVersionLifecycle(lifecycle), registry.VersionLifecycle{
+		Modules: map[string]map[string]registry.VersionStatus{
+			"myorg/network/generic": {"1.2.0": {Deprecated: true, Reason: "Use 2.x"}},
+		},
+		Providers: map[string]map[string]registry.VersionStatus{
+			"myorg/internal": {"0.3.1": {Yanked: true, Reason: "Broken on darwin"}},
+		},
+	})
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, dialect, err := sqlstore.Open(filepath.Join(dir, "registry.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store := sqlstore.NewSQLStore(db, dialect, sqlstore.NewFileBlobStore(filepath.Join(dir, "blobs")), nil)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("module", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gzw)
+		is.NoErr(tw.WriteHeader(&tar.Header{Name: "main.tf", Mode: 0o644, Size: 2}))
+		_, err := tw.Write([]byte("{}"))
+		is.NoErr(err)
+		is.NoErr(tw.Close())
+		is.NoErr(gzw.Close())
+		archive := filepath.Join(t.TempDir(), "module.tar.gz")
+		is.NoErr(os.WriteFile(archive, buf.Bytes(), 0o644))
+
+		is.NoErr(publish(ctx, store, []string{"module", "myorg/network/generic", "1.0.0", archive}))
+		versions, err := store.ListModuleVersions(ctx, "myorg", "network", "generic")
+		is.NoErr(err)
+		is.Equal(len(versions), 1)
+		is.Equal(versions[0].Version, "1.0.0")
+	})
+
+	t.Run("provider", func(t *testing.T) {
+		is := is.New(t)
+		releaseDir := t.TempDir()
+		key, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+		is.NoErr(err)
+		var armored bytes.Buffer
+		w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+		is.NoErr(err)
+		is.NoErr(key.Serialize(w))
+		is.NoErr(w.Close())
+		keyFile := filepath.Join(releaseDir, "key.asc")
+		is.NoErr(os.WriteFile(keyFile, armored.Bytes(), 0o644))
+		is.NoErr(publish(ctx, store, []string{"signing-key", "myorg", keyFile}))
+
+		var sums bytes.Buffer
+		for _, platform := range []string{"linux_amd64", "darwin_arm64"} {
+			name := "terraform-provider-internal_0.3.1_" + platform + ".zip"
+			content := []byte("package " + name)
+			is.NoErr(os.WriteFile(filepath.Join(releaseDir, name), content, 0o644))
+			fmt.Fprintf(&sums, "%x  %s\n", sha256.Sum256(content), name)
+		}
+		var sig bytes.Buffer
+		is.NoErr(openpgp.DetachSign(&sig, key, bytes.NewReader(sums.Bytes()), nil))
+		is.NoErr(os.WriteFile(filepath.Join(releaseDir, "terraform-provider-internal_0.3.1_SHA256SUMS"), sums.Bytes(), 0o644))
+		is.NoErr(os.WriteFile(filepath.Join(releaseDir, "terraform-provider-internal_0.3.1_SHA256SUMS.sig"), sig.Bytes(), 0o644))
+		is.NoErr(os.WriteFile(filepath.Join(releaseDir, "terraform-provider-internal_0.3.1_manifest.json"), []byte(`{"version":1,"metadata":{"protocol_versions":["6.0"]}}`), 0o644))
+
+		is.NoErr(publish(ctx, store, []string{"provider", "myorg/internal", "0.3.1", releaseDir}))
+		versions, err := store.ListProviderVersions(ctx, "myorg", "internal")
+		is.NoErr(err)
+		is.Equal(len(versions.Versions), 1)
+		is.Equal(versions.Versions[0].Protocols, []string{"6.0"})
+		is.Equal(len(versions.Versions[0].Platforms), 2)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(publish(ctx, store, nil), errPublishUsage)
+		is.Equal(publish(ctx, store, []string{"module", "myorg/network", "1.0.0", "module.tar.gz"}).Error(),
+			"invalid module address 'myorg/network', expected '<namespace>/<name>/<provider>'")
+		is.Equal(publish(ctx, store, []string{"provider", "myorg/internal", "0.3.1"}), errPublishUsage)
+	})
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	sqlstore "github.com/nrkno/terraform-registry/pkg/store/sql"
+)
+
+const publishUsage = `usage:
+  publish module <namespace>/<name>/<provider> <version> <archive.tar.gz>
+  publish signing-key <namespace> <public-key.asc>
+  publish provider <namespace>/<name> <version> <dir>`
+
+// errPublishUsage is returned by `publish` for invalid arguments.
+var errPublishUsage = errors.New(publishUsage)
+
+// publish runs the `publish` command, which adds module versions, provider versions and signing keys
+// to the SQL store. `args` are the arguments following `publish`.
+func publish(ctx context.Context, store *sqlstore.SQLStore, args []string) error {
+	if len(args) == 0 {
+		return errPublishUsage
+	}
+
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "module" && len(args) == 3:
+		parts := strings.Split(args[0], "/")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid module address '%s', expected '<namespace>/<name>/<provider>'", args[0])
+		}
+		f, err := os.Open(args[2])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return store.PublishModuleVersion(ctx, parts[0], parts[1], parts[2], args[1], f, nil)
+
+	case cmd == "signing-key" && len(args) == 2:
+		b, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		keyID, err := store.AddSigningKey(ctx, args[0], string(b))
+		if err != nil {
+			return err
+		}
+		fmt.Println(keyID)
+		return nil
+
+	case cmd == "provider" && len(args) == 3:
+		namespace, name, ok := strings.Cut(args[0], "/")
+		if !ok {
+			return fmt.Errorf("invalid provider address '%s', expected '<namespace>/<name>'", args[0])
+		}
+		release, closeFiles, err := readProviderRelease(args[2], namespace, name, args[1])
+		if err != nil {
+			return err
+		}
+		defer closeFiles()
+		return store.PublishProviderVersion(ctx, release)
+	}
+
+	return errPublishUsage
+}
+
+// readProviderRelease reads the provider release in `dir`, as built by GoReleaser: the packages
+// `terraform-provider-{name}_{version}_{os}_{arch}.zip`, the checksums `terraform-provider-{name}_{version}_SHA256SUMS`
+// and their signature `.sig`, and optionally the manifest `terraform-provider-{name}_{version}_manifest.json`.
+// Call the returned function to close the packages.
+func readProviderRelease(dir, namespace, name, version string) (sqlstore.ProviderRelease, func(), error) {
+	release := sqlstore.ProviderRelease{Namespace: namespace, Name: name, Version: version}
+	prefix := fmt.Sprintf("terraform-provider-%s_%s_", name, version)
+
+	var err error
+	if release.SHASums, err = os.ReadFile(filepath.Join(dir, prefix+"SHA256SUMS")); err != nil {
+		return release, nil, err
+	}
+	if release.SHASumsSignature, err = os.ReadFile(filepath.Join(dir, prefix+"SHA256SUMS.sig")); err != nil {
+		return release, nil, err
+	}
+
+	// The protocol version defaults to 5.0 in the store when there is no manifest
+	if b, err := os.ReadFile(filepath.Join(dir, prefix+"manifest.json")); err == nil {
+		manifest := &core.ProviderManifest{}
+		if err := json.Unmarshal(b, manifest); err != nil {
+			return release, nil, fmt.Errorf("unable to decode manifest: %w", err)
+		}
+		release.Protocols = manifest.Metadata.ProtocolVersions
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return release, nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return release, nil, err
+	}
+	var files []io.Closer
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for _, entry := range entries {
+		osArch, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || !strings.HasSuffix(osArch, ".zip") {
+			continue
+		}
+		goos, arch, ok := strings.Cut(strings.TrimSuffix(osArch, ".zip"), "_")
+		if !ok {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			closeFiles()
+			return release, nil, err
+		}
+		files = append(files, f)
+		release.Packages = append(release.Packages, sqlstore.ProviderPackage{OS: goos, Arch: arch, Filename: entry.Name(), Content: f})
+	}
+	return release, closeFiles, nil
+}
@@ -7,6 +7,7 @@ package core
 import (
 	"context"
 	"io"
+	"time"
 )
 
 type ModuleVersion struct {
@@ -15,10 +16,49 @@ type ModuleVersion struct {
 	// SourceURL specifies the download URL where Terraform can get the module source.
 	// https://www.terraform.io/language/modules/sources
 	SourceURL string
+	// Metadata holds details about the module version, if known by the store.
+	Metadata *ModuleMetadata `json:",omitempty"`
+}
+
+// ModuleMetadata describes a module version. Stores fill in the fields they know.
+type ModuleMetadata struct {
+	PublishedAt       time.Time                   `json:"published_at,omitzero"`
+	CommitSHA         string                      `json:"commit_sha,omitempty"`
+	Description       string                      `json:"description,omitempty"`
+	Readme            string                      `json:"readme,omitempty"`
+	Inputs            []ModuleInput               `json:"inputs,omitempty"`
+	Outputs           []ModuleOutput              `json:"outputs,omitempty"`
+	RequiredProviders []ModuleProviderRequirement `json:"required_providers,omitempty"`
+}
+
+// ModuleInput is an input variable of a module.
+type ModuleInput struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Default     any    `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+}
+
+// ModuleOutput is an output value of a module.
+type ModuleOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+}
+
+// ModuleProviderRequirement is a provider required by a module, by its local name.
+type ModuleProviderRequirement struct {
+	Name               string   `json:"name"`
+	Source             string   `json:"source,omitempty"`
+	VersionConstraints []string `json:"version_constraints,omitempty"`
 }
 
 type ProviderVersions struct {
 	Versions []ProviderVersion `json:"versions"`
+	// Warnings are shown to users by Terraform when it lists the versions of the provider.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type ProviderVersion struct {
@@ -83,9 +123,107 @@ type ModuleStore interface {
 	GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*ModuleVersion, error)
 }
 
+// ModuleAddress identifies a module in a store.
+type ModuleAddress struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+}
+
+// ModuleLister is implemented by module stores that can list all their modules, for browsing the registry.
+type ModuleLister interface {
+	ListModules(ctx context.Context) ([]ModuleAddress, error)
+}
+
+// ModuleArchiveStore is implemented by module stores that can serve module source
+// archives through the registry, for clients without direct access to the store backend.
+// The archive must be a gzipped tarball with the module source at its root.
+type ModuleArchiveStore interface {
+	GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error)
+}
+
 // ProviderStore is the store implementation interface for building custom provider stores
 type ProviderStore interface {
 	ListProviderVersions(ctx context.Context, namespace string, name string) (*ProviderVersions, error)
 	GetProviderVersion(ctx context.Context, namespace string, name string, version string, os string, arch string) (*Provider, error)
 	GetProviderAsset(ctx context.Context, namespace string, name string, tag string, asset string) (io.ReadCloser, error)
 }
+
+// ProviderAddress identifies a provider in a store.
+type ProviderAddress struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// ProviderLister is implemented by provider stores that can list all their providers, for browsing the registry.
+type ProviderLister interface {
+	ListProviders(ctx context.Context) ([]ProviderAddress, error)
+}
+
+// AssetSizer is implemented by assets returned from `ProviderStore.GetProviderAsset` that know their
+// size up front, allowing the registry to set the Content-Length header while streaming them.
+// Assets implementing `io.ReadSeeker` are served with support for HTTP Range requests instead.
+type AssetSizer interface {
+	Size() int64
+}
+
+// StatusReporter is implemented by stores that can report details about their
+// runtime state, like upstream API rate limits. The returned value is included
+// in the health endpoint response and must be JSON serialisable. `ctx` is the context of
+// the health request.
+type StatusReporter interface {
+	Status(ctx context.Context) any
+}
+
+// CacheReloader is implemented by caching module stores, to refresh their caches on demand.
+type CacheReloader interface {
+	ReloadCache(ctx context.Context) error
+}
+
+// ProviderCacheReloader is implemented by caching provider stores, to refresh their caches on demand.
+type ProviderCacheReloader interface {
+	ReloadProviderCache(ctx context.Context) error
+}
+
+// ModuleReloader is implemented by caching module stores that can refresh a single module,
+// which is considerably cheaper than reloading all of them.
+type ModuleReloader interface {
+	ReloadModule(ctx context.Context, namespace, name, provider string) error
+}
+
+// ProviderReloader is implemented by caching provider stores that can refresh a single provider,
+// which is considerably cheaper than reloading all of them.
+type ProviderReloader interface {
+	ReloadProvider(ctx context.Context, namespace, name string) error
+}
+
+// CacheStatsReporter is implemented by caching stores that can describe the contents of their
+// caches. The returned value must be JSON serialisable.
+type CacheStatsReporter interface {
+	CacheStats() any
+}
+
+// Snapshotter is implemented by caching stores that can persist their caches.
+// A snapshot loaded at startup lets the store serve requests immediately, while
+// the caches are refreshed from the backend in the background.
+type Snapshotter interface {
+	SaveSnapshot(w io.Writer) error
+	LoadSnapshot(r io.Reader) error
+}
+
+// IgnoredRelease is a provider release rejected by a store, e.g. because of missing
+// checksums. Stores re-validate ignored releases once they expire.
+type IgnoredRelease struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	Reason    string    `json:"reason"`
+	IgnoredAt time.Time `json:"ignored_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IgnoredReleaseLister is implemented by provider stores that keep track of rejected releases,
+// so that publishers can find out why their provider release is not available.
+type IgnoredReleaseLister interface {
+	IgnoredReleases() []IgnoredRelease
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kinds of errors returned by stores, which the registry maps to HTTP status codes. Check for them with
+// `errors.Is`. Errors of other kinds are treated as internal errors of the store.
+var (
+	// ErrNotFound is returned for modules, providers, versions and files that do not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is returned when the request lacks valid credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the request is authenticated, but not allowed.
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnavailable is returned when the backend of the store can not be reached, or fails. This
+	// includes the backend rejecting the credentials of the store, which the client can do nothing about.
+	ErrUnavailable = errors.New("unavailable")
+	// ErrRateLimited is returned when the backend of the store rejects requests because of rate limits.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// Error is an error of a kind, such as `ErrNotFound`. Its message is the one of the wrapped error,
+// so that stores can classify errors without changing them.
+type Error struct {
+	// Kind is one of the `Err` values of this package.
+	Kind error
+	Err  error
+	// RetryAfter is how long to wait before retrying, for rate limited and unavailable backends. Zero when unknown.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// NotFoundError returns an error of kind `ErrNotFound`, formatted as by `fmt.Errorf`.
+func NotFoundError(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Err: fmt.Errorf(format, args...)}
+}
+
+// UnauthorizedError returns an error of kind `ErrUnauthorized`, formatted as by `fmt.Errorf`.
+func UnauthorizedError(format string, args ...any) error {
+	return &Error{Kind: ErrUnauthorized, Err: fmt.Errorf(format, args...)}
+}
+
+// ForbiddenError returns an error of kind `ErrForbidden`, formatted as by `fmt.Errorf`.
+func ForbiddenError(format string, args ...any) error {
+	return &Error{Kind: ErrForbidden, Err: fmt.Errorf(format, args...)}
+}
+
+// UnavailableError returns an error of kind `ErrUnavailable`, formatted as by `fmt.Errorf`.
+func UnavailableError(format string, args ...any) error {
+	return &Error{Kind: ErrUnavailable, Err: fmt.Errorf(format, args...)}
+}
+
+// RateLimitedError returns an error of kind `ErrRateLimited`, formatted as by `fmt.Errorf`, which
+// may be retried after `retryAfter`.
+func RateLimitedError(retryAfter time.Duration, format string, args ...any) error {
+	return &Error{Kind: ErrRateLimited, Err: fmt.Errorf(format, args...), RetryAfter: retryAfter}
+}
+
+// BackendError classifies `err`, returned by the backend of a store, by the HTTP `status` of the
+// backend response, or zero if there was none. Errors that are already classified, and canceled
+// requests, are returned as is.
+func BackendError(err error, status int) error {
+	var e *Error
+	if err == nil || errors.As(err, &e) || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		return &Error{Kind: ErrNotFound, Err: err}
+	case http.StatusTooManyRequests:
+		return &Error{Kind: ErrRateLimited, Err: err}
+	default:
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+}
+
+// RetryAfter returns how long to wait before retrying after `err`, if known.
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
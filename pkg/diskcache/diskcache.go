@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+// Package diskcache implements a content-addressed file cache on local disk.
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache stores files on local disk, addressed by the hex encoded SHA256 digest of
+// their contents. Content is verified against its digest before it is stored, so that
+// a file in the cache always matches its address.
+// Should not be instantiated directly. Use `NewDiskCache` instead.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	// pruneMut serialises pruning of the cache.
+	pruneMut sync.Mutex
+}
+
+// NewDiskCache returns a cache storing files in `dir`, which is created if missing. When the files
+// exceed `maxSize` bytes, the least recently used files are removed. Set `maxSize` to 0 for no limit.
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir, maxSize: maxSize}, nil
+}
+
+// Open opens the cached file with the digest for reading.
+// Returns an error satisfying `errors.Is(err, os.ErrNotExist)` if the file is not cached.
+func (c *DiskCache) Open(digest string) (*os.File, error) {
+	path, err := c.path(digest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The modification time is the time the file was last used, for pruning
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return f, nil
+}
+
+// prune removes the least recently used files until the files are no larger than the maximum size of the cache.
+func (c *DiskCache) prune() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+	c.pruneMut.Lock()
+	defer c.pruneMut.Unlock()
+
+	type file struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var files []file
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		files = append(files, file{path: path, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to prune cache: %w", err)
+	}
+
+	slices.SortFunc(files, func(a, b file) int { return a.used.Compare(b.used) })
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unable to prune cache: %w", err)
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// Create returns a writer adding the file with the digest to the cache, for caching content
+// while it is streamed elsewhere. The content is added to the cache by `Writer.Commit`.
+func (c *DiskCache) Create(digest string) (*Writer, error) {
+	path, err := c.path(digest)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	// Write to a temporary file first, so that concurrent readers never see partial content
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{cache: c, tmp: tmp, hash: sha256.New(), path: path, digest: strings.ToLower(digest)}, nil
+}
+
+// Writer writes a file to a `DiskCache`. The file is not visible in the cache until committed.
+// Should not be instantiated directly. Use `DiskCache.Create` instead.
+type Writer struct {
+	cache   *DiskCache
+	tmp     *os.File
+	hash    hash.Hash
+	path    string
+	digest  string
+	written int64
+	done    bool
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.tmp.Write(p)
+	w.hash.Write(p[:n])
+	w.written += int64(n)
+	return n, err
+}
+
+// Written returns the number of bytes written so far.
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
+// Commit adds the written content to the cache, unless its SHA256 digest differs from the
+// digest of the file, and prunes the cache if it exceeds its maximum size. The writer can not be
+// used afterwards.
+func (w *Writer) Commit() error {
+	if w.done {
+		return errors.New("cache writer already closed")
+	}
+	w.done = true
+	defer os.Remove(w.tmp.Name())
+
+	if err := w.tmp.Close(); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(w.hash.Sum(nil)); actual != w.digest {
+		return fmt.Errorf("SHA256 digest mismatch: expected %s, got %s", w.digest, actual)
+	}
+	if err := os.Rename(w.tmp.Name(), w.path); err != nil {
+		return err
+	}
+	return w.cache.prune()
+}
+
+// Abort discards the written content. Does nothing if the writer is already committed or aborted.
+func (w *Writer) Abort() {
+	if w.done {
+		return
+	}
+	w.done = true
+	w.tmp.Close()
+	os.Remove(w.tmp.Name())
+}
+
+// path returns the location of the file with the digest. Files are spread over
+// subdirectories named by the first two characters of the digest.
+func (c *DiskCache) path(digest string) (string, error) {
+	digest = strings.ToLower(digest)
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA256 digest '%s'", digest)
+	}
+	return filepath.Join(c.dir, digest[:2], digest), nil
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// store adds `content` to the cache with the SHA256 `digest`.
+func store(cache *DiskCache, digest, content string) error {
+	w, err := cache.Create(digest)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+	if _, err := io.WriteString(w, content); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+func TestDiskCache(t *testing.T) {
+	content := "provider binary"
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, err := NewDiskCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		is := is.New(t)
+		_, err := cache.Open(digest)
+		is.True(errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("store and open", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(store(cache, digest, content))
+
+		f, err := cache.Open(strings.ToUpper(digest))
+		is.NoErr(err)
+		b, err := io.ReadAll(f)
+		f.Close()
+		is.NoErr(err)
+		is.Equal(string(b), content)
+
+		entries, err := os.ReadDir(filepath.Join(dir, digest[:2]))
+		is.NoErr(err)
+		is.Equal(len(entries), 1) // no temporary files left behind
+	})
+
+	t.Run("rejects content not matching digest", func(t *testing.T) {
+		is := is.New(t)
+		other := sha256.Sum256([]byte("other"))
+		err := store(cache, hex.EncodeToString(other[:]), content)
+		is.True(err != nil)
+
+		_, err = cache.Open(hex.EncodeToString(other[:]))
+		is.True(errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		is := is.New(t)
+		content := "streamed provider binary"
+		sum := sha256.Sum256([]byte(content))
+		digest := hex.EncodeToString(sum[:])
+
+		w, err := cache.Create(digest)
+		is.NoErr(err)
+		_, err = io.WriteString(w, content[:8])
+		is.NoErr(err)
+		_, err = cache.Open(digest)
+		is.True(errors.Is(err, os.ErrNotExist)) // not visible until committed
+
+		_, err = io.WriteString(w, content[8:])
+		is.NoErr(err)
+		is.Equal(w.Written(), int64(len(content)))
+		is.NoErr(w.Commit())
+		w.Abort() // no-op after commit
+
+		f, err := cache.Open(digest)
+		is.NoErr(err)
+		b, err := io.ReadAll(f)
+		f.Close()
+		is.NoErr(err)
+		is.Equal(string(b), content)
+	})
+
+	t.Run("aborted writer leaves nothing behind", func(t *testing.T) {
+		is := is.New(t)
+		other := sha256.Sum256([]byte("aborted"))
+		digest := hex.EncodeToString(other[:])
+
+		w, err := cache.Create(digest)
+		is.NoErr(err)
+		_, err = io.WriteString(w, "abor")
+		is.NoErr(err)
+		w.Abort()
+
+		_, err = cache.Open(digest)
+		is.True(errors.Is(err, os.ErrNotExist))
+		entries, err := os.ReadDir(filepath.Join(dir, digest[:2]))
+		is.NoErr(err)
+		is.Equal(len(entries), 0)
+	})
+
+	t.Run("rejects invalid digest", func(t *testing.T) {
+		is := is.New(t)
+		_, err := cache.Open("../../etc/passwd")
+		is.Equal(err.Error(), "invalid SHA256 digest '../../etc/passwd'")
+	})
+}
+
+func TestDiskCachePrune(t *testing.T) {
+	is := is.New(t)
+	cache, err := NewDiskCache(t.TempDir(), 25)
+	is.NoErr(err)
+
+	add := func(content string, added time.Time) string {
+		sum := sha256.Sum256([]byte(content))
+		digest := hex.EncodeToString(sum[:])
+		is.NoErr(store(cache, digest, content))
+		path, err := cache.path(digest)
+		is.NoErr(err)
+		is.NoErr(os.Chtimes(path, added, added))
+		return digest
+	}
+	cached := func(digest string) bool {
+		f, err := cache.Open(digest)
+		if err != nil {
+			is.True(errors.Is(err, os.ErrNotExist))
+			return false
+		}
+		f.Close()
+		return true
+	}
+
+	first := add("first file", time.Now().Add(-3*time.Minute))
+	second := add("second file", time.Now().Add(-2*time.Minute))
+	is.True(cached(first)) // used after the second file was added
+
+	// The third file makes the cache exceed its maximum size, so the least recently used file is removed
+	third := add("third file", time.Now())
+	is.True(!cached(second))
+	is.True(cached(first))
+	is.True(cached(third))
+}
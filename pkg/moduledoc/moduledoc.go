@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+// Package moduledoc extracts documentation from Terraform module source archives:
+// the README, and the inputs, outputs and required providers of the root module.
+package moduledoc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// MaxFileSize is the size of the largest file read from an archive. Larger files are skipped.
+const MaxFileSize = 1 << 20
+
+// FromTarGz reads the documentation of the module at the root of a gzipped tarball.
+// PublishedAt is set to the latest modification time of the files in the archive,
+// which for archives created by Git hosts like GitHub is the commit time.
+func FromTarGz(r io.Reader) (*core.ModuleMetadata, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	dir, err := os.MkdirTemp("", "moduledoc-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var modTime time.Time
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.ModTime.After(modTime) {
+			modTime = hdr.ModTime
+		}
+		if err := extract(dir, hdr.Name, hdr.Size, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	meta, err := inspect(dir)
+	if err != nil {
+		return nil, err
+	}
+	meta.PublishedAt = modTime.UTC()
+	return meta, nil
+}
+
+// FromZip reads the documentation of the module at the root of a zip archive.
+func FromZip(r io.ReaderAt, size int64) (*core.ModuleMetadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "moduledoc-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive: %w", err)
+		}
+		err = extract(dir, f.Name, int64(f.UncompressedSize64), rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return inspect(dir)
+}
+
+// extract writes the file to `dir` if it is a Terraform file or README at the root of the archive.
+func extract(dir, name string, size int64, r io.Reader) error {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if strings.Contains(name, "/") || !isDocFile(name) || size > MaxFileSize {
+		return nil
+	}
+
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, io.LimitReader(r, MaxFileSize)); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to read '%s' from archive: %w", name, err)
+	}
+	return f.Close()
+}
+
+func isDocFile(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json") || readmeRank(name) > 0
+}
+
+// readmeRank ranks README file names, preferring Markdown. Returns 0 for other files.
+func readmeRank(name string) int {
+	switch strings.ToLower(name) {
+	case "readme.md":
+		return 3
+	case "readme.markdown":
+		return 2
+	case "readme", "readme.txt":
+		return 1
+	}
+	return 0
+}
+
+// inspect returns the documentation of the module extracted to `dir`.
+func inspect(dir string) (*core.ModuleMetadata, error) {
+	meta := &core.ModuleMetadata{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	best := 0
+	for _, e := range entries {
+		if rank := readmeRank(e.Name()); rank > best {
+			b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			meta.Readme = string(b)
+			best = rank
+		}
+	}
+
+	if !tfconfig.IsModuleDir(dir) {
+		return meta, nil
+	}
+	// Diagnostics are ignored, as the module is documented on a best effort basis.
+	module, _ := tfconfig.LoadModule(dir)
+
+	for _, v := range module.Variables {
+		meta.Inputs = append(meta.Inputs, core.ModuleInput{
+			Name:        v.Name,
+			Type:        v.Type,
+			Description: v.Description,
+			Default:     v.Default,
+			Required:    v.Required,
+			Sensitive:   v.Sensitive,
+		})
+	}
+	sort.Slice(meta.Inputs, func(i, j int) bool { return meta.Inputs[i].Name < meta.Inputs[j].Name })
+
+	for _, o := range module.Outputs {
+		meta.Outputs = append(meta.Outputs, core.ModuleOutput{
+			Name:        o.Name,
+			Description: o.Description,
+			Sensitive:   o.Sensitive,
+		})
+	}
+	sort.Slice(meta.Outputs, func(i, j int) bool { return meta.Outputs[i].Name < meta.Outputs[j].Name })
+
+	for name, p := range module.RequiredProviders {
+		meta.RequiredProviders = append(meta.RequiredProviders, core.ModuleProviderRequirement{
+			Name:               name,
+			Source:             p.Source,
+			VersionConstraints: p.VersionConstraints,
+		})
+	}
+	sort.Slice(meta.RequiredProviders, func(i, j int) bool {
+		return meta.RequiredProviders[i].Name < meta.RequiredProviders[j].Name
+	})
+
+	return meta, nil
+}
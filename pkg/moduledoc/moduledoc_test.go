@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package moduledoc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+var moduleFiles = map[string]string{
+	"README.md": "# Network\n",
+	"variables.tf": `
+variable "cidr" {
+  type        = string
+  description = "Address space of the network"
+}
+
+variable "name" {
+  type    = string
+  default = "main"
+}
+`,
+	"outputs.tf": `
+output "id" {
+  description = "ID of the network"
+}
+`,
+	"versions.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.0"
+    }
+  }
+}
+`,
+	// Submodules are not documented
+	"modules/subnet/variables.tf": `variable "ignored" {}`,
+}
+
+var expectedMetadata = core.ModuleMetadata{
+	Readme: "# Network\n",
+	Inputs: []core.ModuleInput{
+		{Name: "cidr", Type: "string", Description: "Address space of the network", Required: true},
+		{Name: "name", Type: "string", Default: "main"},
+	},
+	Outputs: []core.ModuleOutput{
+		{Name: "id", Description: "ID of the network"},
+	},
+	RequiredProviders: []core.ModuleProviderRequirement{
+		{Name: "aws", Source: "hashicorp/aws", VersionConstraints: []string{">= 5.0"}},
+	},
+}
+
+func TestFromTarGz(t *testing.T) {
+	is := is.New(t)
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range moduleFiles {
+		is.NoErr(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: modTime}))
+		_, err := tw.Write([]byte(content))
+		is.NoErr(err)
+	}
+	is.NoErr(tw.Close())
+	is.NoErr(gzw.Close())
+
+	meta, err := FromTarGz(&buf)
+	is.NoErr(err)
+
+	expected := expectedMetadata
+	expected.PublishedAt = modTime
+	is.Equal(*meta, expected)
+}
+
+func TestFromZip(t *testing.T) {
+	is := is.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range moduleFiles {
+		w, err := zw.Create(name)
+		is.NoErr(err)
+		_, err = w.Write([]byte(content))
+		is.NoErr(err)
+	}
+	is.NoErr(zw.Close())
+
+	meta, err := FromZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	is.NoErr(err)
+	is.Equal(*meta, expectedMetadata)
+}
+
+func TestWithoutTerraformFiles(t *testing.T) {
+	is := is.New(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("readme")
+	is.NoErr(err)
+	w.Write([]byte("plain readme"))
+	is.NoErr(zw.Close())
+
+	meta, err := FromZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	is.NoErr(err)
+	is.Equal(*meta, core.ModuleMetadata{Readme: "plain readme"})
+}
@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// startReload runs `reload` in the background and responds with 202 Accepted, as reloading
+// can take longer than the write timeout of the server. Responds with 409 Conflict if a
+// reload with the same name is already running.
+func (reg *Registry) startReload(w http.ResponseWriter, name string, reload func(ctx context.Context) error) {
+	reg.reloadMut.Lock()
+	if reg.reloading[name] {
+		reg.reloadMut.Unlock()
+		writeError(w, http.StatusConflict)
+		reg.logger.Debug("startReload: reload already running", zap.String("name", name))
+		return
+	}
+	if reg.reloading == nil {
+		reg.reloading = make(map[string]bool)
+	}
+	reg.reloading[name] = true
+	reg.reloadMut.Unlock()
+
+	go func() {
+		start := time.Now()
+		err := reload(context.Background())
+
+		reg.reloadMut.Lock()
+		delete(reg.reloading, name)
+		reg.reloadMut.Unlock()
+
+		if err != nil {
+			reg.logger.Error("failed to reload store cache", zap.String("name", name), zap.Error(err))
+			return
+		}
+		reg.logger.Info("reloaded store cache", zap.String("name", name), zap.Duration("duration", time.Since(start)))
+		if reg.OnCacheReload != nil {
+			reg.OnCacheReload()
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// cacheReloaders returns the functions reloading the module and provider store caches,
+// for the stores implementing `core.CacheReloader` and `core.ProviderCacheReloader`.
+func (reg *Registry) cacheReloaders(kind string) []func(ctx context.Context) error {
+	var reloaders []func(ctx context.Context) error
+	if reloader, ok := reg.moduleStore.(core.CacheReloader); ok && kind != "providers" {
+		reloaders = append(reloaders, reloader.ReloadCache)
+	}
+	if reloader, ok := reg.providerStore.(core.ProviderCacheReloader); ok && reg.IsProviderEnabled && kind != "modules" {
+		reloaders = append(reloaders, reloader.ReloadProviderCache)
+	}
+	return reloaders
+}
+
+// AdminReload returns a handler that reloads the caches of the module and provider stores in the
+// background. `kind` is either "modules", "providers", or empty to reload both.
+func (reg *Registry) AdminReload(kind string) http.HandlerFunc {
+	name := kind
+	if name == "" {
+		name = "all"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		reloaders := reg.cacheReloaders(kind)
+		if len(reloaders) == 0 {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("AdminReload: store does not support reloading", zap.String("name", name))
+			return
+		}
+
+		reg.startReload(w, name, func(ctx context.Context) error {
+			for _, reload := range reloaders {
+				if err := reload(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// AdminReloadModule returns a handler that reloads a single module in the background.
+// Requires the module store to implement `core.ModuleReloader`.
+func (reg *Registry) AdminReloadModule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			namespace = chi.URLParam(r, "namespace")
+			name      = chi.URLParam(r, "name")
+			provider  = chi.URLParam(r, "provider")
+		)
+
+		reloader, ok := reg.moduleStore.(core.ModuleReloader)
+		if !ok {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("AdminReloadModule: module store does not support reloading single modules")
+			return
+		}
+
+		reg.startReload(w, fmt.Sprintf("modules/%s/%s/%s", namespace, name, provider), func(ctx context.Context) error {
+			return reloader.ReloadModule(ctx, namespace, name, provider)
+		})
+	}
+}
+
+// AdminReloadProvider returns a handler that reloads a single provider in the background.
+// Requires the provider store to implement `core.ProviderReloader`.
+func (reg *Registry) AdminReloadProvider() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			namespace = chi.URLParam(r, "namespace")
+			name      = chi.URLParam(r, "name")
+		)
+
+		reloader, ok := reg.providerStore.(core.ProviderReloader)
+		if !ok || !reg.IsProviderEnabled {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("AdminReloadProvider: provider store does not support reloading single providers")
+			return
+		}
+
+		reg.startReload(w, fmt.Sprintf("providers/%s/%s", namespace, name), func(ctx context.Context) error {
+			return reloader.ReloadProvider(ctx, namespace, name)
+		})
+	}
+}
+
+type CacheStatsResponse struct {
+	Stores map[string]any `json:"stores"`
+}
+
+// AdminCacheStats returns a handler that describes the contents of the store caches.
+// Stores implementing `core.CacheStatsReporter` are included in the response.
+func (reg *Registry) AdminCacheStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := CacheStatsResponse{
+			Stores: make(map[string]any),
+		}
+
+		stores := map[string]any{
+			"modules":   reg.moduleStore,
+			"providers": reg.providerStore,
+		}
+		for name, store := range stores {
+			if reporter, ok := store.(core.CacheStatsReporter); ok {
+				resp.Stores[name] = reporter.CacheStats()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			reg.logger.Error("AdminCacheStats", zap.Error(err))
+		}
+	}
+}
+
+type AuthTokensResponse struct {
+	Descriptions []string `json:"descriptions"`
+}
+
+// AdminAuthTokens returns a handler that lists the descriptions of the loaded auth tokens.
+// The tokens themselves are never returned.
+func (reg *Registry) AdminAuthTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := AuthTokensResponse{
+			Descriptions: make([]string, 0),
+		}
+		for description := range reg.GetAuthTokens() {
+			resp.Descriptions = append(resp.Descriptions, description)
+		}
+		sort.Strings(resp.Descriptions)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			reg.logger.Error("AdminAuthTokens", zap.Error(err))
+		}
+	}
+}
+
+// AdminLogLevel returns a handler that gets the current log level, or changes it on PUT requests
+// with a JSON body like `{"level":"debug"}`. Requires `LogLevel` to be set.
+func (reg *Registry) AdminLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if reg.LogLevel == nil {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("AdminLogLevel: log level is not configurable")
+			return
+		}
+
+		previous := reg.LogLevel.Level()
+		reg.LogLevel.ServeHTTP(w, r)
+		if level := reg.LogLevel.Level(); level != previous {
+			reg.logger.Warn("changed log level", zap.Stringer("from", previous), zap.Stringer("to", level))
+		}
+	}
+}
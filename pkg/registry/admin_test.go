@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// reloadingStore is a module and provider store that records reloads.
+type reloadingStore struct {
+	core.ModuleStore
+	core.ProviderStore
+
+	mut      sync.Mutex
+	reloads  []string
+	release  chan struct{}
+	reloaded chan struct{}
+}
+
+func newReloadingStore() *reloadingStore {
+	return &reloadingStore{reloaded: make(chan struct{}, 10)}
+}
+
+func (s *reloadingStore) record(name string) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mut.Lock()
+	s.reloads = append(s.reloads, name)
+	s.mut.Unlock()
+	if strings.HasPrefix(name, "fail") {
+		return errors.New("reload failed")
+	}
+	return nil
+}
+
+func (s *reloadingStore) ReloadCache(ctx context.Context) error {
+	return s.record("modules")
+}
+
+func (s *reloadingStore) ReloadProviderCache(ctx context.Context) error {
+	return s.record("providers")
+}
+
+func (s *reloadingStore) ReloadModule(ctx context.Context, namespace, name, provider string) error {
+	return s.record(namespace + "/" + name + "/" + provider)
+}
+
+func (s *reloadingStore) ReloadProvider(ctx context.Context, namespace, name string) error {
+	return s.record(namespace + "/" + name)
+}
+
+func (s *reloadingStore) CacheStats() any {
+	return map[string]int{"modules": 2}
+}
+
+func (s *reloadingStore) recorded() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string(nil), s.reloads...)
+}
+
+func setupAdminRegistry(store *reloadingStore) *Registry {
+	reg := &Registry{
+		IsProviderEnabled: true,
+		moduleStore:       store,
+		providerStore:     store,
+		authTokens:        map[string]string{"ci": "valid", "admin": "secret"},
+		adminTokens:       map[string]string{"ops": "admin-secret"},
+		logger:            zap.NewNop(),
+	}
+	reg.OnCacheReload = func() { store.reloaded <- struct{}{} }
+	reg.setupRoutes()
+	return reg
+}
+
+// serveAdmin serves a request to the admin routes with a valid admin token.
+func serveAdmin(reg *Registry, method, path, body string) *http.Response {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	reg.router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func waitReloaded(t *testing.T, store *reloadingStore) {
+	t.Helper()
+	select {
+	case <-store.reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	tests := []struct {
+		path    string
+		reloads []string
+	}{
+		{"/admin/reload", []string{"modules", "providers"}},
+		{"/admin/reload/modules", []string{"modules"}},
+		{"/admin/reload/providers", []string{"providers"}},
+		{"/admin/reload/modules/hashicorp/consul/aws", []string{"hashicorp/consul/aws"}},
+		{"/admin/reload/providers/hashicorp/aws", []string{"hashicorp/aws"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			is := is.New(t)
+			store := newReloadingStore()
+			reg := setupAdminRegistry(store)
+
+			resp := serveAdmin(reg, "POST", tt.path, "")
+			is.Equal(resp.StatusCode, http.StatusAccepted)
+			waitReloaded(t, store)
+			is.Equal(store.recorded(), tt.reloads)
+		})
+	}
+
+	t.Run("rejects concurrent reloads", func(t *testing.T) {
+		is := is.New(t)
+		store := newReloadingStore()
+		store.release = make(chan struct{})
+		reg := setupAdminRegistry(store)
+
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload/modules", "").StatusCode, http.StatusAccepted)
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload/modules", "").StatusCode, http.StatusConflict)
+		// Other reloads are not affected
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload/providers", "").StatusCode, http.StatusAccepted)
+
+		close(store.release)
+		waitReloaded(t, store)
+		waitReloaded(t, store)
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload/modules", "").StatusCode, http.StatusAccepted)
+		waitReloaded(t, store)
+	})
+
+	t.Run("requires reloading stores", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload", "").StatusCode, http.StatusNotFound)
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload/providers/hashicorp/aws", "").StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("skips providers when disabled", func(t *testing.T) {
+		is := is.New(t)
+		store := newReloadingStore()
+		reg := setupAdminRegistry(store)
+		reg.IsProviderEnabled = false
+
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload", "").StatusCode, http.StatusAccepted)
+		waitReloaded(t, store)
+		is.Equal(store.recorded(), []string{"modules"})
+		is.Equal(serveAdmin(reg, "POST", "/admin/reload/providers", "").StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+		req := httptest.NewRequest("POST", "/admin/reload", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusUnauthorized)
+	})
+}
+
+func TestAdminAuth(t *testing.T) {
+	t.Run("rejects api tokens", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+		resp := serveAuthenticated(reg, "GET", "/admin/cache", "")
+		is.Equal(resp.StatusCode, http.StatusUnauthorized)
+		is.Equal(resp.Header.Get("WWW-Authenticate"), `Bearer realm="terraform-registry"`)
+	})
+
+	t.Run("ignores disabled auth", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+		reg.IsAuthDisabled = true
+
+		req := httptest.NewRequest("GET", "/admin/cache", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusUnauthorized)
+		is.Equal(serveAdmin(reg, "GET", "/admin/cache", "").StatusCode, http.StatusOK)
+	})
+
+	t.Run("disabled without admin tokens", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+		reg.IsAuthDisabled = true
+		reg.SetAdminTokens(nil)
+
+		verifyError(t, serveAdmin(reg, "GET", "/admin/cache", ""), http.StatusNotFound)
+		verifyError(t, serveAdmin(reg, "POST", "/admin/reload", ""), http.StatusNotFound)
+		verifyError(t, serveAdmin(reg, "GET", "/admin/providers/ignored", ""), http.StatusNotFound)
+		is.Equal(serveAuthenticated(reg, "GET", "/admin/tokens", "").StatusCode, http.StatusNotFound)
+	})
+}
+
+func TestAdminInspection(t *testing.T) {
+	t.Run("cache stats", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+
+		resp := serveAdmin(reg, "GET", "/admin/cache", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var body struct {
+			Stores map[string]map[string]int `json:"stores"`
+		}
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&body))
+		is.Equal(body.Stores["modules"]["modules"], 2)
+		is.Equal(body.Stores["providers"]["modules"], 2)
+	})
+
+	t.Run("token descriptions", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+
+		resp := serveAdmin(reg, "GET", "/admin/tokens", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var body AuthTokensResponse
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&body))
+		is.Equal(body.Descriptions, []string{"admin", "ci"})
+	})
+
+	t.Run("log level", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupAdminRegistry(newReloadingStore())
+		is.Equal(serveAdmin(reg, "GET", "/admin/log-level", "").StatusCode, http.StatusNotFound)
+
+		level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+		reg.LogLevel = &level
+
+		resp := serveAdmin(reg, "GET", "/admin/log-level", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var body struct {
+			Level string `json:"level"`
+		}
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&body))
+		is.Equal(body.Level, "info")
+
+		resp = serveAdmin(reg, "PUT", "/admin/log-level", `{"level":"debug"}`)
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(level.Level(), zapcore.DebugLevel)
+
+		resp = serveAdmin(reg, "PUT", "/admin/log-level", `{"level":"verbose"}`)
+		is.Equal(resp.StatusCode, http.StatusBadRequest)
+		is.Equal(level.Level(), zapcore.DebugLevel)
+	})
+}
+
+func TestAdminReloadFailure(t *testing.T) {
+	is := is.New(t)
+	store := newReloadingStore()
+	reg := setupAdminRegistry(store)
+
+	is.Equal(serveAdmin(reg, "POST", "/admin/reload/providers/fail/x", "").StatusCode, http.StatusAccepted)
+
+	// Wait for the reload to finish
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		reg.reloadMut.Lock()
+		running := reg.reloading["providers/fail/x"]
+		reg.reloadMut.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for reload")
+		}
+	}
+
+	is.Equal(store.recorded(), []string{"fail/x"})
+	select {
+	case <-store.reloaded:
+		t.Fatal("OnCacheReload called after failed reload")
+	default:
+	}
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// ErrorResponse is the body of error responses, in the format of the public Terraform registry.
+type ErrorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+// writeError responds with `status` and a JSON body describing it. The messages of store errors
+// are only logged, as they can contain details about the backend of the store.
+func writeError(w http.ResponseWriter, status int) {
+	b, _ := json.Marshal(ErrorResponse{Errors: []string{http.StatusText(status)}})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(b, '\n'))
+}
+
+// errorStatus returns the HTTP status code for an error returned by a store.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, core.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, core.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, core.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, core.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeStoreError responds with the status code for `err`, returned by a store, and logs it.
+// Missing modules and providers are expected, and are only logged at debug level.
+func (reg *Registry) writeStoreError(w http.ResponseWriter, msg string, err error) {
+	writeError(w, reg.storeErrorStatus(w, msg, err))
+}
+
+// storeErrorStatus logs an error returned by a store, sets the `Retry-After` header of rate limited
+// responses, and returns the HTTP status of the error.
+func (reg *Registry) storeErrorStatus(w http.ResponseWriter, msg string, err error) int {
+	status := errorStatus(err)
+	if status == http.StatusNotFound {
+		reg.logger.Debug(msg, zap.Error(err))
+	} else {
+		reg.logger.Error(msg, zap.Error(err), zap.Int("status", status))
+	}
+
+	if d := core.RetryAfter(err); d > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+	}
+	return status
+}
+
+// writeUnauthorized responds with 401 Unauthorized, asking for a bearer token as configured in
+// the Terraform CLI credentials.
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="terraform-registry"`)
+	writeError(w, http.StatusUnauthorized)
+}
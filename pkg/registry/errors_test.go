@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// failingModuleStore is a module store failing with `err`.
+type failingModuleStore struct {
+	err error
+}
+
+func (s failingModuleStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
+	return nil, s.err
+}
+
+func (s failingModuleStore) GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*core.ModuleVersion, error) {
+	return nil, s.err
+}
+
+func verifyError(t *testing.T, resp *http.Response, expectedStatus int) {
+	is := is.New(t)
+	is.Equal(resp.StatusCode, expectedStatus)
+	is.Equal(resp.Header.Get("Content-Type"), "application/json")
+
+	var respObj ErrorResponse
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&respObj))
+	is.Equal(respObj.Errors, []string{http.StatusText(expectedStatus)})
+}
+
+func TestStoreErrors(t *testing.T) {
+	testcases := []struct {
+		name       string
+		err        error
+		status     int
+		retryAfter string
+	}{
+		{
+			name:   "not found",
+			err:    core.NotFoundError("module 'hashicorp/consul/aws' not found"),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "forbidden",
+			err:    core.ForbiddenError("module 'hashicorp/consul/aws' is not shared"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unavailable",
+			err:    core.BackendError(errors.New("connection refused"), 0),
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "rate limited",
+			err:        core.RateLimitedError(90*time.Second+time.Millisecond, "rate limit exceeded"),
+			status:     http.StatusTooManyRequests,
+			retryAfter: "91",
+		},
+		{
+			name:   "internal error",
+			err:    errors.New("invalid metadata"),
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			reg := Registry{
+				IsAuthDisabled: true,
+				moduleStore:    failingModuleStore{err: tc.err},
+				logger:         zap.NewNop(),
+			}
+			reg.setupRoutes()
+
+			for _, path := range []string{
+				"/v1/modules/hashicorp/consul/aws/versions",
+				"/v1/modules/hashicorp/consul/aws/1.0.0/download",
+			} {
+				req := httptest.NewRequest("GET", path, nil)
+				w := httptest.NewRecorder()
+				reg.router.ServeHTTP(w, req)
+
+				resp := w.Result()
+				verifyError(t, resp, tc.status)
+				is.Equal(resp.Header.Get("Retry-After"), tc.retryAfter)
+			}
+
+			// The web UI renders its error page with the same status
+			for _, path := range []string{
+				"/ui/modules/hashicorp/consul/aws",
+				"/ui/modules/hashicorp/consul/aws/1.0.0",
+			} {
+				req := httptest.NewRequest("GET", path, nil)
+				w := httptest.NewRecorder()
+				reg.router.ServeHTTP(w, req)
+
+				resp := w.Result()
+				is.Equal(resp.StatusCode, tc.status)
+				is.Equal(resp.Header.Get("Retry-After"), tc.retryAfter)
+			}
+		})
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	t.Run("unauthorized", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupTestRegistry()
+		reg.authTokens = map[string]string{"test": "valid"}
+
+		for _, header := range []string{"", "Bearer invalid", "Basic valid"} {
+			req := httptest.NewRequest("GET", "/v1/modules/hashicorp/consul/aws/versions", nil)
+			req.Header.Set("Authorization", header)
+			w := httptest.NewRecorder()
+			reg.router.ServeHTTP(w, req)
+
+			resp := w.Result()
+			verifyError(t, resp, http.StatusUnauthorized)
+			is.Equal(resp.Header.Get("WWW-Authenticate"), `Bearer realm="terraform-registry"`)
+		}
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		reg := setupTestRegistry()
+		req := httptest.NewRequest("GET", "/unknown", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		verifyError(t, w.Result(), http.StatusNotFound)
+	})
+}
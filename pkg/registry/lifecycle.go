@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// VersionStatus marks a module or provider version as deprecated or yanked.
+// Deprecated versions are listed with a warning. Yanked versions are hidden from
+// version lists, which Terraform resolves all versions through, so they can not be
+// installed by Terraform even when pinned to the exact version.
+type VersionStatus struct {
+	Deprecated bool   `json:"deprecated,omitempty"`
+	Yanked     bool   `json:"yanked,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// VersionLifecycle holds the status of module and provider versions. Modules are keyed by
+// `namespace/name/provider`, providers by `namespace/name`, and then by version.
+//
+// Example:
+//
+//	{
+//	  "modules": {"myorg/network/generic": {"1.2.0": {"deprecated": true, "reason": "Use 2.x"}}},
+//	  "providers": {"myorg/internal": {"0.3.1": {"yanked": true, "reason": "Broken on darwin"}}}
+//	}
+type VersionLifecycle struct {
+	Modules   map[string]map[string]VersionStatus `json:"modules"`
+	Providers map[string]map[string]VersionStatus `json:"providers"`
+}
+
+// copy returns a deep copy of the lifecycle, so that it can't be modified indirectly.
+func (l VersionLifecycle) copy() VersionLifecycle {
+	cp := func(m map[string]map[string]VersionStatus) map[string]map[string]VersionStatus {
+		res := make(map[string]map[string]VersionStatus, len(m))
+		for key, versions := range m {
+			res[key] = make(map[string]VersionStatus, len(versions))
+			for version, status := range versions {
+				res[key][version] = status
+			}
+		}
+		return res
+	}
+	return VersionLifecycle{
+		Modules:   cp(l.Modules),
+		Providers: cp(l.Providers),
+	}
+}
+
+// GetVersionLifecycle gets the status of module and provider versions.
+func (reg *Registry) GetVersionLifecycle() VersionLifecycle {
+	reg.lifecycleMut.RLock()
+	defer reg.lifecycleMut.RUnlock()
+	return reg.lifecycle.copy()
+}
+
+// SetVersionLifecycle sets the status of module and provider versions.
+func (reg *Registry) SetVersionLifecycle(lifecycle VersionLifecycle) {
+	lifecycle = lifecycle.copy()
+
+	reg.lifecyclePersistMut.Lock()
+	defer reg.lifecyclePersistMut.Unlock()
+	reg.lifecycleMut.Lock()
+	reg.lifecycle = lifecycle
+	reg.lifecycleMut.Unlock()
+}
+
+// moduleVersionStatus returns the status of a module version.
+func (reg *Registry) moduleVersionStatus(namespace, name, provider, version string) VersionStatus {
+	reg.lifecycleMut.RLock()
+	defer reg.lifecycleMut.RUnlock()
+	return reg.lifecycle.Modules[fmt.Sprintf("%s/%s/%s", namespace, name, provider)][version]
+}
+
+// providerVersionStatus returns the status of a provider version.
+func (reg *Registry) providerVersionStatus(namespace, name, version string) VersionStatus {
+	reg.lifecycleMut.RLock()
+	defer reg.lifecycleMut.RUnlock()
+	return reg.lifecycle.Providers[fmt.Sprintf("%s/%s", namespace, name)][version]
+}
+
+// updateVersionStatus sets or, if status is nil, clears the status of a version in `kind` (modules
+// or providers), after persisting the change through `OnVersionLifecycleChange` and `OnVersionStatusChange`.
+// The status is left unchanged if they fail.
+func (reg *Registry) updateVersionStatus(kind, key, version string, status *VersionStatus) error {
+	// Changes are persisted one at a time, so that an older lifecycle is never saved last
+	reg.lifecyclePersistMut.Lock()
+	defer reg.lifecyclePersistMut.Unlock()
+
+	lifecycle := reg.GetVersionLifecycle()
+	entries := lifecycle.Modules
+	if kind == "providers" {
+		entries = lifecycle.Providers
+	}
+	if status != nil {
+		if entries[key] == nil {
+			entries[key] = make(map[string]VersionStatus)
+		}
+		entries[key][version] = *status
+	} else {
+		delete(entries[key], version)
+		if len(entries[key]) == 0 {
+			delete(entries, key)
+		}
+	}
+
+	if reg.OnVersionLifecycleChange != nil {
+		if err := reg.OnVersionLifecycleChange(lifecycle.copy()); err != nil {
+			return core.UnavailableError("unable to save version lifecycle: %w", err)
+		}
+	}
+	if reg.OnVersionStatusChange != nil {
+		if err := reg.OnVersionStatusChange(kind, key, version, status); err != nil {
+			return core.UnavailableError("unable to save version status: %w", err)
+		}
+	}
+
+	reg.lifecycleMut.Lock()
+	reg.lifecycle = lifecycle
+	reg.lifecycleMut.Unlock()
+	return nil
+}
+
+// VersionLifecycleList returns a handler that returns the status of all module and provider versions.
+func (reg *Registry) VersionLifecycleList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reg.GetVersionLifecycle()); err != nil {
+			reg.logger.Error("VersionLifecycleList", zap.Error(err))
+		}
+	}
+}
+
+// VersionLifecycleUpdate returns a handler that sets the status of a module or provider version from
+// a JSON encoded `VersionStatus` request body. DELETE requests clear the status.
+func (reg *Registry) VersionLifecycleUpdate(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			key     = fmt.Sprintf("%s/%s", chi.URLParam(r, "namespace"), chi.URLParam(r, "name"))
+			version = chi.URLParam(r, "version")
+		)
+		if kind == "modules" {
+			key = fmt.Sprintf("%s/%s", key, chi.URLParam(r, "provider"))
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := reg.updateVersionStatus(kind, key, version, nil); err != nil {
+				reg.writeStoreError(w, "VersionLifecycleUpdate", err)
+				return
+			}
+			reg.logger.Info("cleared version status", zap.String("kind", kind), zap.String("name", key), zap.String("version", version))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var status VersionStatus
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			writeError(w, http.StatusBadRequest)
+			reg.logger.Debug("VersionLifecycleUpdate: invalid request body", zap.Error(err))
+			return
+		}
+
+		if err := reg.updateVersionStatus(kind, key, version, &status); err != nil {
+			reg.writeStoreError(w, "VersionLifecycleUpdate", err)
+			return
+		}
+		reg.logger.Info("updated version status",
+			zap.String("kind", kind),
+			zap.String("name", key),
+			zap.String("version", version),
+			zap.Bool("deprecated", status.Deprecated),
+			zap.Bool("yanked", status.Yanked),
+		)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// applyProviderLifecycle returns a copy of the provider versions without yanked versions,
+// and with warnings for deprecated versions.
+func (reg *Registry) applyProviderLifecycle(namespace, name string, versions *core.ProviderVersions) *core.ProviderVersions {
+	res := &core.ProviderVersions{
+		Versions: make([]core.ProviderVersion, 0, len(versions.Versions)),
+		Warnings: slices.Clone(versions.Warnings),
+	}
+	for _, v := range versions.Versions {
+		status := reg.providerVersionStatus(namespace, name, v.Version)
+		if status.Yanked {
+			continue
+		}
+		if status.Deprecated {
+			warning := fmt.Sprintf("Version %s of provider %s/%s is deprecated", v.Version, namespace, name)
+			if status.Reason != "" {
+				warning = fmt.Sprintf("%s: %s", warning, status.Reason)
+			}
+			res.Warnings = append(res.Warnings, warning)
+		}
+		res.Versions = append(res.Versions, v)
+	}
+	return res
+}
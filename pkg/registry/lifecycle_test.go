@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	memstore "github.com/nrkno/terraform-registry/pkg/store/memory"
+	"go.uber.org/zap"
+)
+
+// versionsProviderStore is a provider store that only lists versions.
+type versionsProviderStore struct {
+	core.ProviderStore
+	versions *core.ProviderVersions
+}
+
+func (s versionsProviderStore) ListProviderVersions(ctx context.Context, namespace, name string) (*core.ProviderVersions, error) {
+	return s.versions, nil
+}
+
+func setupLifecycleRegistry() *Registry {
+	mstore := memstore.NewMemoryStore()
+	mstore.Set("hashicorp/consul/aws", []*core.ModuleVersion{
+		{Version: "1.1.1", SourceURL: "git::ssh://git@github.com/hashicorp/consul.git?ref=v1.1.1"},
+		{Version: "2.2.2", SourceURL: "git::ssh://git@github.com/hashicorp/consul.git?ref=v2.2.2"},
+		{Version: "3.3.3", SourceURL: "git::ssh://git@github.com/hashicorp/consul.git?ref=v3.3.3"},
+	})
+
+	reg := &Registry{
+		moduleStore: mstore,
+		providerStore: versionsProviderStore{versions: &core.ProviderVersions{
+			Versions: []core.ProviderVersion{{Version: "1.0.0"}, {Version: "1.1.0"}, {Version: "2.0.0"}},
+		}},
+		authTokens:  map[string]string{"test": "valid"},
+		adminTokens: map[string]string{"ops": "admin-secret"},
+		logger:      zap.NewNop(),
+	}
+	reg.setupRoutes()
+	reg.SetVersionLifecycle(VersionLifecycle{
+		Modules: map[string]map[string]VersionStatus{
+			"hashicorp/consul/aws": {
+				"1.1.1": {Yanked: true, Reason: "broken"},
+				"2.2.2": {Deprecated: true, Reason: "Use 3.x"},
+			},
+		},
+		Providers: map[string]map[string]VersionStatus{
+			"hashicorp/aws": {
+				"1.0.0": {Yanked: true},
+				"1.1.0": {Deprecated: true, Reason: "Use 2.x"},
+			},
+		},
+	})
+	return reg
+}
+
+func serveAuthenticated(reg *Registry, method, path, body string) *http.Response {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer valid")
+	w := httptest.NewRecorder()
+	reg.router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestVersionLifecycle(t *testing.T) {
+	t.Run("module versions", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+
+		resp := serveAuthenticated(reg, "GET", "/v1/modules/hashicorp/consul/aws/versions", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+
+		var respObj ModuleVersionsResponse
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&respObj))
+		versions := respObj.Modules[0].Versions
+		is.Equal(len(versions), 2) // yanked version is hidden
+		is.Equal(versions[0].Version, "2.2.2")
+		is.Equal(versions[0].Deprecation.Reason, "Use 3.x")
+		is.Equal(versions[1].Version, "3.3.3")
+		is.Equal(versions[1].Deprecation, nil)
+	})
+
+	t.Run("pinned yanked versions are not resolved", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+
+		// Terraform looks up exactly pinned versions in the version list before downloading them
+		resp := serveAuthenticated(reg, "GET", "/v1/modules/hashicorp/consul/aws/versions", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var modules ModuleVersionsResponse
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&modules))
+		for _, v := range modules.Modules[0].Versions {
+			is.True(v.Version != "1.1.1") // yanked module version is listed
+		}
+
+		resp = serveAuthenticated(reg, "GET", "/v1/providers/hashicorp/aws/versions", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var providers core.ProviderVersions
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&providers))
+		for _, v := range providers.Versions {
+			is.True(v.Version != "1.0.0") // yanked provider version is listed
+		}
+	})
+
+	t.Run("yanked module version can be downloaded directly", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+
+		resp := serveAuthenticated(reg, "GET", "/v1/modules/hashicorp/consul/aws/1.1.1/download", "")
+		is.Equal(resp.StatusCode, http.StatusNoContent)
+		is.Equal(resp.Header.Get("X-Terraform-Get"), "git::ssh://git@github.com/hashicorp/consul.git?ref=v1.1.1")
+	})
+
+	t.Run("provider versions", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+
+		resp := serveAuthenticated(reg, "GET", "/v1/providers/hashicorp/aws/versions", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+
+		var respObj core.ProviderVersions
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&respObj))
+		is.Equal(len(respObj.Versions), 2) // yanked version is hidden
+		is.Equal(respObj.Versions[0].Version, "1.1.0")
+		is.Equal(respObj.Versions[1].Version, "2.0.0")
+		is.Equal(respObj.Warnings, []string{"Version 1.1.0 of provider hashicorp/aws is deprecated: Use 2.x"})
+	})
+
+	t.Run("admin updates", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+
+		var changed []VersionLifecycle
+		reg.OnVersionLifecycleChange = func(l VersionLifecycle) error {
+			changed = append(changed, l)
+			return nil
+		}
+		var statuses []string
+		reg.OnVersionStatusChange = func(kind, key, version string, status *VersionStatus) error {
+			statuses = append(statuses, fmt.Sprintf("%s %s/%s %v", kind, key, version, status))
+			return nil
+		}
+
+		resp := serveAdmin(reg, "PUT", "/admin/lifecycle/modules/hashicorp/consul/aws/3.3.3", `{"yanked": true, "reason": "broken"}`)
+		is.Equal(resp.StatusCode, http.StatusNoContent)
+		resp = serveAdmin(reg, "DELETE", "/admin/lifecycle/modules/hashicorp/consul/aws/1.1.1", "")
+		is.Equal(resp.StatusCode, http.StatusNoContent)
+		resp = serveAdmin(reg, "DELETE", "/admin/lifecycle/providers/hashicorp/aws/1.0.0", "")
+		is.Equal(resp.StatusCode, http.StatusNoContent)
+		resp = serveAdmin(reg, "PUT", "/admin/lifecycle/providers/hashicorp/aws/2.0.0", `not json`)
+		is.Equal(resp.StatusCode, http.StatusBadRequest)
+
+		is.Equal(len(changed), 3)
+		is.Equal(changed[2], reg.GetVersionLifecycle())
+		is.Equal(statuses, []string{
+			"modules hashicorp/consul/aws/3.3.3 &{false true broken}",
+			"modules hashicorp/consul/aws/1.1.1 <nil>",
+			"providers hashicorp/aws/1.0.0 <nil>",
+		})
+
+		resp = serveAdmin(reg, "GET", "/admin/lifecycle", "")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var lifecycle VersionLifecycle
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&lifecycle))
+		is.Equal(lifecycle.Modules["hashicorp/consul/aws"], map[string]VersionStatus{
+			"2.2.2": {Deprecated: true, Reason: "Use 3.x"},
+			"3.3.3": {Yanked: true, Reason: "broken"},
+		})
+		is.Equal(lifecycle.Providers["hashicorp/aws"], map[string]VersionStatus{
+			"1.1.0": {Deprecated: true, Reason: "Use 2.x"},
+		})
+	})
+
+	t.Run("admin update fails when it can not be saved", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+		before := reg.GetVersionLifecycle()
+
+		reg.OnVersionStatusChange = func(kind, key, version string, status *VersionStatus) error {
+			return errors.New("database is down")
+		}
+
+		resp := serveAdmin(reg, "PUT", "/admin/lifecycle/modules/hashicorp/consul/aws/3.3.3", `{"yanked": true}`)
+		is.Equal(resp.StatusCode, http.StatusServiceUnavailable)
+		resp = serveAdmin(reg, "DELETE", "/admin/lifecycle/providers/hashicorp/aws/1.0.0", "")
+		is.Equal(resp.StatusCode, http.StatusServiceUnavailable)
+		is.Equal(reg.GetVersionLifecycle(), before)
+	})
+
+	t.Run("admin requires auth", func(t *testing.T) {
+		is := is.New(t)
+		reg := setupLifecycleRegistry()
+
+		req := httptest.NewRequest("PUT", "/admin/lifecycle/providers/hashicorp/aws/2.0.0", strings.NewReader(`{"yanked": true}`))
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusUnauthorized)
+		is.Equal(reg.providerVersionStatus("hashicorp", "aws", "2.0.0"), VersionStatus{})
+	})
+}
@@ -9,8 +9,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -40,14 +44,39 @@ type Registry struct {
 	// Whether to enable provider registry support
 	IsProviderEnabled bool
 
-	// Secret used to issue JTW for protecting the /download/provider/ route
+	// Secret used to issue JTW for protecting the /download/ routes
 	AssetDownloadAuthSecret []byte
+	// Write timeout for the /download/ routes, overriding the write timeout of the HTTP server,
+	// which is tuned for the short API responses. Zero means no timeout.
+	DownloadTimeout time.Duration
+
+	// Called with the new version lifecycle when it is changed through the admin API,
+	// e.g. to persist it. The change is rejected if it returns an error.
+	OnVersionLifecycleChange func(VersionLifecycle) error
+	// Called with the status of a single version when it is changed through the admin API, or nil
+	// when it is cleared, e.g. to persist only the change. `kind` is `modules` or `providers`, and
+	// `key` the address of the module or provider. The change is rejected if it returns an error.
+	OnVersionStatusChange func(kind, key, version string, status *VersionStatus) error
+	// Called after the store caches are reloaded through the admin API, e.g. to save a snapshot.
+	OnCacheReload func()
+
+	// Log level of the registry logger, which can be changed through the admin API.
+	// Leave nil to disable changing the log level at runtime.
+	LogLevel *zap.AtomicLevel
 
 	router        *chi.Mux
 	authTokens    map[string]string
+	adminTokens   map[string]string
 	moduleStore   core.ModuleStore
 	providerStore core.ProviderStore
 	tokenMut      sync.RWMutex
+	lifecycle     VersionLifecycle
+	lifecycleMut  sync.RWMutex
+	reloading     map[string]bool
+	reloadMut     sync.Mutex
+
+	// Serialises the changes to the version lifecycle while they are persisted
+	lifecyclePersistMut sync.Mutex
 
 	logger *zap.Logger
 }
@@ -102,6 +131,33 @@ func (reg *Registry) SetAuthTokens(authTokens map[string]string) {
 	reg.tokenMut.Unlock()
 }
 
+// GetAdminTokens gets the valid tokens for the administrative routes configured for this instance.
+func (reg *Registry) GetAdminTokens() map[string]string {
+	reg.tokenMut.RLock()
+	defer reg.tokenMut.RUnlock()
+
+	// Make sure map can't be modified indirectly
+	m := make(map[string]string, len(reg.adminTokens))
+	for k, v := range reg.adminTokens {
+		m[k] = v
+	}
+	return m
+}
+
+// SetAdminTokens sets the valid tokens for the administrative routes configured for this instance.
+// The administrative routes are disabled when no admin tokens are set.
+func (reg *Registry) SetAdminTokens(adminTokens map[string]string) {
+	// Make sure map can't be modified indirectly
+	m := make(map[string]string, len(adminTokens))
+	for k, v := range adminTokens {
+		m[k] = v
+	}
+
+	reg.tokenMut.Lock()
+	reg.adminTokens = m
+	reg.tokenMut.Unlock()
+}
+
 // setupRoutes initialises and configures the HTTP router. Must be called before starting the server (`ServeHTTP`).
 func (reg *Registry) setupRoutes() {
 	reg.router = chi.NewRouter()
@@ -116,15 +172,57 @@ func (reg *Registry) setupRoutes() {
 	reg.router.Route("/v1", func(r chi.Router) {
 		r.Use(reg.TokenAuth)
 		r.Get("/modules/{namespace}/{name}/{provider}/versions", reg.ModuleVersions())
+		r.Get("/modules/{namespace}/{name}/{provider}/{version}", reg.ModuleDetails())
 		r.Get("/modules/{namespace}/{name}/{provider}/{version}/download", reg.ModuleDownload())
 		r.Get("/providers/{namespace}/{name}/versions", reg.ProviderVersions())
 		r.Get("/providers/{namespace}/{name}/{version}/download/{os}/{arch}", reg.ProviderDownload())
 	})
 
+	// Administrative routes are protected with separate admin tokens
+	reg.router.Route("/admin", func(r chi.Router) {
+		r.Use(reg.AdminAuth)
+		r.Post("/reload", reg.AdminReload(""))
+		r.Post("/reload/modules", reg.AdminReload("modules"))
+		r.Post("/reload/modules/{namespace}/{name}/{provider}", reg.AdminReloadModule())
+		r.Post("/reload/providers", reg.AdminReload("providers"))
+		r.Post("/reload/providers/{namespace}/{name}", reg.AdminReloadProvider())
+		r.Get("/cache", reg.AdminCacheStats())
+		r.Get("/tokens", reg.AdminAuthTokens())
+		r.Get("/log-level", reg.AdminLogLevel())
+		r.Put("/log-level", reg.AdminLogLevel())
+		r.Get("/providers/ignored", reg.IgnoredProviderReleases())
+		r.Get("/lifecycle", reg.VersionLifecycleList())
+		r.Put("/lifecycle/modules/{namespace}/{name}/{provider}/{version}", reg.VersionLifecycleUpdate("modules"))
+		r.Delete("/lifecycle/modules/{namespace}/{name}/{provider}/{version}", reg.VersionLifecycleUpdate("modules"))
+		r.Put("/lifecycle/providers/{namespace}/{name}/{version}", reg.VersionLifecycleUpdate("providers"))
+		r.Delete("/lifecycle/providers/{namespace}/{name}/{version}", reg.VersionLifecycleUpdate("providers"))
+	})
+
+	// The web UI uses the same tokens as the API routes, which users log in with to get a cookie
+	reg.router.Route("/ui", func(r chi.Router) {
+		r.Post("/login", reg.UILogin())
+		r.Post("/logout", reg.UILogout())
+		r.Group(func(r chi.Router) {
+			r.Use(reg.UIAuth)
+			r.Get("/", reg.UIIndex())
+			r.Get("/namespaces/{namespace}", reg.UINamespace())
+			r.Get("/modules/{namespace}/{name}/{provider}", reg.UIModule())
+			r.Get("/modules/{namespace}/{name}/{provider}/{version}", reg.UIModuleVersion())
+			r.Get("/providers/{namespace}/{name}", reg.UIProvider())
+		})
+	})
+
 	reg.router.Route("/download/provider", func(r chi.Router) {
+		r.Use(reg.DownloadDeadline)
 		r.Use(reg.ProviderDownloadAuth)
 		r.Get("/{namespace}/{name}/{version}/asset/{assetName}", reg.ProviderAssetDownload())
 	})
+
+	reg.router.Route("/download/module", func(r chi.Router) {
+		r.Use(reg.DownloadDeadline)
+		r.Use(reg.ProviderDownloadAuth)
+		r.Get("/{namespace}/{name}/{provider}/{version}", reg.ModuleArchiveDownload())
+	})
 }
 
 // SPDX-SnippetBegin
@@ -179,7 +277,8 @@ func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	reg.router.ServeHTTP(w, r)
 }
 
-// TokenAuth is a middleware function for token header authentication.
+// TokenAuth is a middleware function for token header authentication. Requests without a valid
+// bearer token get 401 Unauthorized.
 func (reg *Registry) TokenAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if reg.IsAuthDisabled {
@@ -187,59 +286,96 @@ func (reg *Registry) TokenAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		header := r.Header.Get("Authorization")
-		if header == "" {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			reg.logger.Debug("TokenAuth: Authorization header missing or empty")
+		token, ok := reg.bearerToken(r)
+		if !ok {
+			writeUnauthorized(w)
 			return
 		}
 
-		auth := strings.SplitN(header, " ", 2)
-		if len(auth) != 2 {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			reg.logger.Debug("TokenAuth: Authorization header present, but invalid")
-			return
+		for _, t := range reg.GetAuthTokens() {
+			if t == token {
+				next.ServeHTTP(w, r)
+				return
+			}
 		}
 
-		tokenType := auth[0]
-		token := auth[1]
+		writeUnauthorized(w)
+	})
+}
 
-		if tokenType != "Bearer" {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			reg.logger.Debug("TokenAuth: unexpected authorization header value prefix",
-				zap.String("actual", tokenType),
-				zap.String("expected", "Bearer"),
-			)
+// AdminAuth is a middleware function for the administrative routes. Requests need a bearer token
+// from the admin tokens, regardless of `IsAuthDisabled`, and the routes are not found when no
+// admin tokens are configured.
+func (reg *Registry) AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens := reg.GetAdminTokens()
+		if len(tokens) == 0 {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("AdminAuth: no admin tokens configured")
 			return
 		}
 
-		for _, t := range reg.GetAuthTokens() {
+		token, ok := reg.bearerToken(r)
+		if !ok {
+			writeUnauthorized(w)
+			return
+		}
+
+		for _, t := range tokens {
 			if t == token {
 				next.ServeHTTP(w, r)
 				return
 			}
 		}
 
-		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		writeUnauthorized(w)
 	})
 }
 
+// bearerToken returns the bearer token in the Authorization header of `r`.
+func (reg *Registry) bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		reg.logger.Debug("bearerToken: Authorization header missing or empty")
+		return "", false
+	}
+
+	auth := strings.SplitN(header, " ", 2)
+	if len(auth) != 2 {
+		reg.logger.Debug("bearerToken: Authorization header present, but invalid")
+		return "", false
+	}
+
+	tokenType := auth[0]
+	token := auth[1]
+
+	if tokenType != "Bearer" {
+		reg.logger.Debug("bearerToken: unexpected authorization header value prefix",
+			zap.String("actual", tokenType),
+			zap.String("expected", "Bearer"),
+		)
+		return "", false
+	}
+
+	return token, true
+}
+
 func (reg *Registry) NotFound() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		writeError(w, http.StatusNotFound)
 	}
 }
 
 func (reg *Registry) MethodNotAllowed() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed)
 	}
 }
 
 func (reg *Registry) Index() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.RequestURI != "/" {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			writeError(w, http.StatusNotFound)
 			return
 		}
 		if _, err := w.Write(WelcomeMessage); err != nil {
@@ -249,18 +385,35 @@ func (reg *Registry) Index() http.HandlerFunc {
 }
 
 type HealthResponse struct {
-	Status string `json:"status"`
+	Status string         `json:"status"`
+	Stores map[string]any `json:"stores,omitempty"`
 }
 
 // Health is the endpoint to be checked to know the runtime health of the registry.
 // In its current implementation it will always report as healthy, i.e. it only
-// reports that the HTTP server still handles requests.
+// reports that the HTTP server still handles requests. Stores implementing
+// `core.StatusReporter` have their status included in the response. A store serving both
+// modules and providers is asked for its status once.
 func (reg *Registry) Health() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		resp := HealthResponse{
 			Status: "OK",
 		}
 
+		if reporter, ok := reg.moduleStore.(core.StatusReporter); ok {
+			resp.Stores = map[string]any{"modules": reporter.Status(r.Context())}
+		}
+		if reporter, ok := reg.providerStore.(core.StatusReporter); ok {
+			if resp.Stores == nil {
+				resp.Stores = make(map[string]any)
+			}
+			if any(reporter) == any(reg.moduleStore) {
+				resp.Stores["providers"] = resp.Stores["modules"]
+			} else {
+				resp.Stores["providers"] = reporter.Status(r.Context())
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		enc := json.NewEncoder(w)
 		if err := enc.Encode(resp); err != nil {
@@ -289,7 +442,7 @@ func (reg *Registry) ServiceDiscovery() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if chi.URLParam(r, "name") != "terraform.json" {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			writeError(w, http.StatusNotFound)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -308,7 +461,13 @@ type ModuleVersionsResponseModule struct {
 }
 
 type ModuleVersionsResponseModuleVersion struct {
-	Version string `json:"version"`
+	Version     string                             `json:"version"`
+	Deprecation *ModuleVersionsResponseDeprecation `json:"deprecation,omitempty"`
+}
+
+// ModuleVersionsResponseDeprecation marks a module version as deprecated. Terraform warns when it is used.
+type ModuleVersionsResponseDeprecation struct {
+	Reason string `json:"reason"`
 }
 
 // ModuleVersions returns a handler that returns a list of available versions for a module.
@@ -323,8 +482,7 @@ func (reg *Registry) ModuleVersions() http.HandlerFunc {
 
 		versions, err := reg.moduleStore.ListModuleVersions(r.Context(), namespace, name, provider)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			reg.logger.Debug("ListModuleVersions", zap.Error(err))
+			reg.writeStoreError(w, "ListModuleVersions", err)
 			return
 		}
 
@@ -334,7 +492,15 @@ func (reg *Registry) ModuleVersions() http.HandlerFunc {
 			},
 		}
 		for _, v := range versions {
-			respObj.Modules[0].Versions = append(respObj.Modules[0].Versions, ModuleVersionsResponseModuleVersion{Version: v.Version})
+			status := reg.moduleVersionStatus(namespace, name, provider, v.Version)
+			if status.Yanked {
+				continue
+			}
+			version := ModuleVersionsResponseModuleVersion{Version: v.Version}
+			if status.Deprecated {
+				version.Deprecation = &ModuleVersionsResponseDeprecation{Reason: status.Reason}
+			}
+			respObj.Modules[0].Versions = append(respObj.Modules[0].Versions, version)
 		}
 
 		b, err := json.Marshal(respObj)
@@ -349,6 +515,53 @@ func (reg *Registry) ModuleVersions() http.HandlerFunc {
 	}
 }
 
+// ModuleDetailsResponse describes a module version, with the metadata known by the store.
+type ModuleDetailsResponse struct {
+	ID          string                             `json:"id"`
+	Namespace   string                             `json:"namespace"`
+	Name        string                             `json:"name"`
+	Provider    string                             `json:"provider"`
+	Version     string                             `json:"version"`
+	Deprecation *ModuleVersionsResponseDeprecation `json:"deprecation,omitempty"`
+	*core.ModuleMetadata
+}
+
+// ModuleDetails returns a handler that returns the details of a specific version of a module,
+// like its README and inputs, as far as they are known by the store.
+func (reg *Registry) ModuleDetails() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			namespace = chi.URLParam(r, "namespace")
+			name      = chi.URLParam(r, "name")
+			provider  = chi.URLParam(r, "provider")
+			version   = chi.URLParam(r, "version")
+		)
+
+		ver, err := reg.moduleStore.GetModuleVersion(r.Context(), namespace, name, provider, version)
+		if err != nil {
+			reg.writeStoreError(w, "GetModuleVersion", err)
+			return
+		}
+
+		respObj := ModuleDetailsResponse{
+			ID:             fmt.Sprintf("%s/%s/%s/%s", namespace, name, provider, ver.Version),
+			Namespace:      namespace,
+			Name:           name,
+			Provider:       provider,
+			Version:        ver.Version,
+			ModuleMetadata: ver.Metadata,
+		}
+		if status := reg.moduleVersionStatus(namespace, name, provider, ver.Version); status.Deprecated {
+			respObj.Deprecation = &ModuleVersionsResponseDeprecation{Reason: status.Reason}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(respObj); err != nil {
+			reg.logger.Error("ModuleDetails", zap.Error(err))
+		}
+	}
+}
+
 // ModuleDownload returns a handler that returns a download link for a specific version of a module.
 // https://www.terraform.io/internals/module-registry-protocol#download-source-code-for-a-specific-module-version
 func (reg *Registry) ModuleDownload() http.HandlerFunc {
@@ -362,16 +575,81 @@ func (reg *Registry) ModuleDownload() http.HandlerFunc {
 
 		ver, err := reg.moduleStore.GetModuleVersion(r.Context(), namespace, name, provider, version)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			reg.logger.Error("GetModuleVersion", zap.Error(err))
+			reg.writeStoreError(w, "GetModuleVersion", err)
 			return
 		}
 
-		w.Header().Set("X-Terraform-Get", ver.SourceURL)
+		sourceURL := ver.SourceURL
+
+		// Module archives served by the registry are protected the same way as provider
+		// assets. Terraform resolves the relative URL against the registry host.
+		if strings.HasPrefix(sourceURL, "/download") && !reg.IsAuthDisabled {
+			tokenString, err := reg.downloadToken()
+			if err != nil {
+				writeError(w, http.StatusInternalServerError)
+				reg.logger.Error("ModuleDownload: unable to create token", zap.Error(err))
+				return
+			}
+
+			u, err := url.Parse(sourceURL)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError)
+				reg.logger.Error("ModuleDownload: invalid source URL", zap.Error(err))
+				return
+			}
+			q := u.Query()
+			q.Set("token", tokenString)
+			u.RawQuery = q.Encode()
+			sourceURL = u.String()
+		}
+
+		w.Header().Set("X-Terraform-Get", sourceURL)
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// ModuleArchiveDownload returns a handler that returns a module source archive.
+// Used for module stores implementing `core.ModuleArchiveStore`, where the module
+// source is not directly available to the clients.
+func (reg *Registry) ModuleArchiveDownload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			namespace = chi.URLParam(r, "namespace")
+			name      = chi.URLParam(r, "name")
+			provider  = chi.URLParam(r, "provider")
+			version   = chi.URLParam(r, "version")
+		)
+
+		store, ok := reg.moduleStore.(core.ModuleArchiveStore)
+		if !ok {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("ModuleArchiveDownload: module store does not serve archives")
+			return
+		}
+
+		archive, err := store.GetModuleArchive(r.Context(), namespace, name, provider, version)
+		if err != nil {
+			reg.writeStoreError(w, "ModuleArchiveDownload", err)
+			return
+		}
+		defer archive.Close()
+
+		// The archive format is set by the store through the source URL, which go-getter also relies on
+		contentType := "application/gzip"
+		if r.URL.Query().Get("archive") == "zip" {
+			contentType = "application/zip"
+		}
+		w.Header().Set("Content-Type", contentType)
+		written, err := io.Copy(w, archive)
+		if err != nil {
+			reg.logger.Error("ModuleArchiveDownload", zap.Error(err))
+			return
+		}
+
+		reg.logger.Debug(fmt.Sprintf("ModuleArchiveDownload: wrote %d bytes to response", written))
+	}
+}
+
 // ProviderVersions returns a handler that returns a list of available versions for a provider.
 // https://developer.hashicorp.com/terraform/internals/provider-registry-protocol#list-available-versions
 func (reg *Registry) ProviderVersions() http.HandlerFunc {
@@ -383,19 +661,15 @@ func (reg *Registry) ProviderVersions() http.HandlerFunc {
 
 		ver, err := reg.providerStore.ListProviderVersions(r.Context(), namespace, name)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			reg.logger.Error("ListProviderVersions", zap.Error(err))
+			reg.writeStoreError(w, "ListProviderVersions", err)
 			return
 		}
+		ver = reg.applyProviderLifecycle(namespace, name, ver)
 
-		err = json.NewEncoder(w).Encode(ver)
-		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ver); err != nil {
 			reg.logger.Error("ListProviderVersions", zap.Error(err))
-			return
 		}
-
-		w.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -413,8 +687,7 @@ func (reg *Registry) ProviderDownload() http.HandlerFunc {
 
 		provider, err := reg.providerStore.GetProviderVersion(r.Context(), namespace, name, version, os, arch)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			reg.logger.Error("GetProviderVersion", zap.Error(err))
+			reg.writeStoreError(w, "GetProviderVersion", err)
 			return
 		}
 
@@ -425,15 +698,9 @@ func (reg *Registry) ProviderDownload() http.HandlerFunc {
 			// Create a copy of the provider before we modify URLs
 			provider = provider.Copy()
 
-			// create a token valid for 10 seconds. Should be more than enough.
-			token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Second * 10)),
-				Issuer:    "terraform-registry",
-			})
-
-			tokenString, err := token.SignedString(reg.AssetDownloadAuthSecret)
+			tokenString, err := reg.downloadToken()
 			if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				writeError(w, http.StatusInternalServerError)
 				reg.logger.Error("GetProviderVersion: unable to create token", zap.Error(err))
 				return
 			}
@@ -443,13 +710,10 @@ func (reg *Registry) ProviderDownload() http.HandlerFunc {
 			provider.SHASumsSignatureURL = fmt.Sprintf("%s?token=%s", provider.SHASumsSignatureURL, tokenString)
 		}
 
-		err = json.NewEncoder(w).Encode(provider)
-		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(provider); err != nil {
 			reg.logger.Error("GetProviderVersion", zap.Error(err))
-			return
 		}
-		w.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -467,24 +731,94 @@ func (reg *Registry) ProviderAssetDownload() http.HandlerFunc {
 
 		asset, err := reg.providerStore.GetProviderAsset(r.Context(), owner, repo, tag, assetName)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			reg.logger.Error("ProviderAssetDownload", zap.Error(err))
+			reg.writeStoreError(w, "ProviderAssetDownload", err)
 			return
 		}
 		defer asset.Close()
 
+		contentType := mime.TypeByExtension(path.Ext(assetName))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+
+		// Seekable assets, e.g. from a disk cache, support Range requests
+		if rs, ok := asset.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, assetName, time.Time{}, rs)
+			return
+		}
+
+		if sizer, ok := asset.(core.AssetSizer); ok && sizer.Size() > 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(sizer.Size(), 10))
+		}
 		written, err := io.Copy(w, asset)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			reg.logger.Error("ProviderAssetDownload", zap.Error(err))
 			return
 		}
 
 		reg.logger.Debug(fmt.Sprintf("ProviderAssetDownload: wrote %d bytes to response", written))
-		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// DownloadDeadline is a middleware function replacing the write deadline of the HTTP server
+// with `DownloadTimeout`, as downloading large assets takes longer than API requests.
+func (reg *Registry) DownloadDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var deadline time.Time
+		if reg.DownloadTimeout > 0 {
+			deadline = time.Now().Add(reg.DownloadTimeout)
+		}
+		err := http.NewResponseController(w).SetWriteDeadline(deadline)
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			reg.logger.Warn("DownloadDeadline: unable to set write deadline", zap.Error(err))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type IgnoredReleasesResponse struct {
+	Releases []core.IgnoredRelease `json:"releases"`
+}
+
+// IgnoredProviderReleases returns a handler that lists the provider releases rejected by the
+// provider store, along with the reason they were rejected. Requires the provider store to
+// implement `core.IgnoredReleaseLister`.
+func (reg *Registry) IgnoredProviderReleases() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lister, ok := reg.providerStore.(core.IgnoredReleaseLister)
+		if !ok {
+			writeError(w, http.StatusNotFound)
+			reg.logger.Debug("IgnoredProviderReleases: provider store does not track ignored releases")
+			return
+		}
+
+		resp := IgnoredReleasesResponse{
+			Releases: lister.IgnoredReleases(),
+		}
+		if resp.Releases == nil {
+			resp.Releases = []core.IgnoredRelease{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			reg.logger.Error("IgnoredProviderReleases", zap.Error(err))
+		}
+	}
+}
+
+// downloadToken issues a short-lived token for the /download/ routes.
+func (reg *Registry) downloadToken() (string, error) {
+	// create a token valid for 10 seconds. Should be more than enough.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Second * 10)),
+		Issuer:    "terraform-registry",
+	})
+	return token.SignedString(reg.AssetDownloadAuthSecret)
+}
+
+// ProviderDownloadAuth is a middleware function protecting the /download/ routes for both
+// provider assets and module archives, using the token issued by the download handlers.
 func (reg *Registry) ProviderDownloadAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if reg.IsAuthDisabled {
@@ -494,7 +828,7 @@ func (reg *Registry) ProviderDownloadAuth(next http.Handler) http.Handler {
 
 		tokenString := r.URL.Query().Get("token")
 		if tokenString == "" {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			writeError(w, http.StatusForbidden)
 			reg.logger.Debug("ProviderDownloadAuth: Token query parameter missing or empty")
 			return
 		}
@@ -513,6 +847,6 @@ func (reg *Registry) ProviderDownloadAuth(next http.Handler) http.Handler {
 			reg.logger.Error("ProviderDownloadAuth: Token not valid")
 		}
 
-		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		writeError(w, http.StatusForbidden)
 	})
 }
@@ -6,15 +6,18 @@ package registry
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
 	"github.com/nrkno/terraform-registry/pkg/core"
@@ -99,7 +102,7 @@ func FuzzTokenAuth(f *testing.F) {
 		if "Bearer "+authToken == authorizationHeader {
 			is.Equal(resp.StatusCode, http.StatusNotFound)
 		} else {
-			is.Equal(resp.StatusCode, http.StatusForbidden)
+			is.Equal(resp.StatusCode, http.StatusUnauthorized)
 		}
 	})
 }
@@ -155,6 +158,58 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+// statusMemoryStore is a memory store that reports its status to the health endpoint. It also
+// poses as a provider store, to be used as both.
+type statusMemoryStore struct {
+	*memstore.MemoryStore
+	core.ProviderStore
+	calls *int
+}
+
+func (s statusMemoryStore) Status(ctx context.Context) any {
+	*s.calls++
+	return map[string]string{"cache": "warm"}
+}
+
+func TestHealthStoreStatus(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	store := statusMemoryStore{MemoryStore: memstore.NewMemoryStore(), calls: &calls}
+	reg := Registry{
+		IsAuthDisabled: true,
+		moduleStore:    store,
+		logger:         zap.NewNop(),
+	}
+	reg.setupRoutes()
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	reg.router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	verifyHealth(t, resp, http.StatusOK, HealthResponse{
+		Status: "OK",
+		Stores: map[string]any{
+			"modules": map[string]any{"cache": "warm"},
+		},
+	})
+
+	// A store serving both modules and providers is asked once
+	calls = 0
+	reg.providerStore = store
+	w = httptest.NewRecorder()
+	reg.router.ServeHTTP(w, req)
+
+	verifyHealth(t, w.Result(), http.StatusOK, HealthResponse{
+		Status: "OK",
+		Stores: map[string]any{
+			"modules":   map[string]any{"cache": "warm"},
+			"providers": map[string]any{"cache": "warm"},
+		},
+	})
+	is.Equal(calls, 1)
+}
+
 func verifyModuleVersions(t *testing.T, resp *http.Response, expectedStatus int, expectedVersion []string) {
 	is := is.New(t)
 	body, err := io.ReadAll(resp.Body)
@@ -305,6 +360,273 @@ func TestModuleDownload(t *testing.T) {
 	}
 }
 
+// archiveMemoryStore is a memory store that serves module archives.
+type archiveMemoryStore struct {
+	*memstore.MemoryStore
+}
+
+func (s archiveMemoryStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	if _, err := s.GetModuleVersion(ctx, namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("archive " + version)), nil
+}
+
+func TestModuleArchiveDownload(t *testing.T) {
+	mstore := memstore.NewMemoryStore()
+	mstore.Set("hashicorp/consul/aws", []*core.ModuleVersion{
+		&core.ModuleVersion{
+			Version:   "1.1.1",
+			SourceURL: "/download/module/hashicorp/consul/aws/1.1.1?archive=tar.gz",
+		},
+	})
+
+	reg := Registry{
+		moduleStore:             archiveMemoryStore{mstore},
+		authTokens:              map[string]string{"test": "valid"},
+		AssetDownloadAuthSecret: []byte("secret"),
+		logger:                  zap.NewNop(),
+	}
+	reg.setupRoutes()
+
+	t.Run("download URL contains token", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/v1/modules/hashicorp/consul/aws/1.1.1/download", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusNoContent)
+
+		u, err := url.Parse(resp.Header.Get("X-Terraform-Get"))
+		is.NoErr(err)
+		is.Equal(u.Path, "/download/module/hashicorp/consul/aws/1.1.1")
+		is.Equal(u.Query().Get("archive"), "tar.gz")
+		is.True(u.Query().Get("token") != "")
+
+		req = httptest.NewRequest("GET", u.String(), nil)
+		w = httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp = w.Result()
+		body, _ := io.ReadAll(resp.Body)
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(resp.Header.Get("Content-Type"), "application/gzip")
+		is.Equal(string(body), "archive 1.1.1")
+	})
+
+	t.Run("archive requires token", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/download/module/hashicorp/consul/aws/1.1.1?archive=tar.gz", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusForbidden)
+	})
+
+	t.Run("zip archive", func(t *testing.T) {
+		is := is.New(t)
+		token, err := reg.downloadToken()
+		is.NoErr(err)
+		req := httptest.NewRequest("GET", "/download/module/hashicorp/consul/aws/1.1.1?archive=zip&token="+token, nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(resp.Header.Get("Content-Type"), "application/zip")
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		is := is.New(t)
+		token, err := reg.downloadToken()
+		is.NoErr(err)
+		req := httptest.NewRequest("GET", "/download/module/hashicorp/consul/aws/9.9.9?token="+token, nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusNotFound)
+	})
+}
+
+// ignoringProviderStore is a provider store that only lists ignored releases.
+type ignoringProviderStore struct {
+	core.ProviderStore
+	releases []core.IgnoredRelease
+}
+
+func (s ignoringProviderStore) IgnoredReleases() []core.IgnoredRelease {
+	return s.releases
+}
+
+func TestIgnoredProviderReleases(t *testing.T) {
+	t.Run("lists ignored releases", func(t *testing.T) {
+		is := is.New(t)
+		reg := Registry{
+			providerStore: ignoringProviderStore{releases: []core.IgnoredRelease{
+				{Namespace: "test-owner", Name: "test", Version: "1.0.0", Reason: "could not find SHA checksums"},
+			}},
+			adminTokens: map[string]string{"ops": "admin-secret"},
+			logger:      zap.NewNop(),
+		}
+		reg.setupRoutes()
+
+		req := httptest.NewRequest("GET", "/admin/providers/ignored", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(resp.Header.Get("Content-Type"), "application/json")
+
+		var respObj IgnoredReleasesResponse
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&respObj))
+		is.Equal(len(respObj.Releases), 1)
+		is.Equal(respObj.Releases[0].Namespace, "test-owner")
+		is.Equal(respObj.Releases[0].Reason, "could not find SHA checksums")
+	})
+
+	t.Run("requires auth", func(t *testing.T) {
+		is := is.New(t)
+		reg := Registry{
+			providerStore: ignoringProviderStore{},
+			authTokens:    map[string]string{"test": "valid"},
+			adminTokens:   map[string]string{"ops": "admin-secret"},
+			logger:        zap.NewNop(),
+		}
+		reg.setupRoutes()
+
+		for _, header := range []string{"", "Bearer valid"} {
+			req := httptest.NewRequest("GET", "/admin/providers/ignored", nil)
+			req.Header.Set("Authorization", header)
+			w := httptest.NewRecorder()
+			reg.router.ServeHTTP(w, req)
+			is.Equal(w.Result().StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("store without ignore list", func(t *testing.T) {
+		is := is.New(t)
+		reg := Registry{
+			adminTokens: map[string]string{"ops": "admin-secret"},
+			logger:      zap.NewNop(),
+		}
+		reg.setupRoutes()
+
+		req := httptest.NewRequest("GET", "/admin/providers/ignored", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusNotFound)
+	})
+}
+
+// assetProviderStore is a provider store that only serves assets.
+type assetProviderStore struct {
+	core.ProviderStore
+	asset func() io.ReadCloser
+}
+
+func (s assetProviderStore) GetProviderAsset(ctx context.Context, namespace, name, tag, asset string) (io.ReadCloser, error) {
+	return s.asset(), nil
+}
+
+// sizedAsset is a streamed asset of known size.
+type sizedAsset struct {
+	io.Reader
+	delay time.Duration
+}
+
+func (a sizedAsset) Read(p []byte) (int, error) {
+	time.Sleep(a.delay)
+	return a.Reader.Read(p)
+}
+
+func (a sizedAsset) Close() error { return nil }
+
+func (a sizedAsset) Size() int64 { return int64(len("provider binary")) }
+
+func TestProviderAssetDownload(t *testing.T) {
+	t.Run("streamed asset", func(t *testing.T) {
+		is := is.New(t)
+		reg := Registry{
+			IsAuthDisabled: true,
+			providerStore: assetProviderStore{asset: func() io.ReadCloser {
+				return sizedAsset{Reader: strings.NewReader("provider binary")}
+			}},
+			logger: zap.NewNop(),
+		}
+		reg.setupRoutes()
+
+		req := httptest.NewRequest("GET", "/download/provider/test-owner/terraform-provider-test/v1.0.0/asset/terraform-provider-test_1.0.0_linux_amd64.zip", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(resp.Header.Get("Content-Type"), "application/zip")
+		is.Equal(resp.Header.Get("Content-Length"), "15")
+		is.Equal(string(body), "provider binary")
+	})
+
+	t.Run("seekable asset supports range", func(t *testing.T) {
+		is := is.New(t)
+		reg := Registry{
+			IsAuthDisabled: true,
+			providerStore: assetProviderStore{asset: func() io.ReadCloser {
+				f, err := os.CreateTemp(t.TempDir(), "asset")
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString("provider binary")
+				f.Seek(0, io.SeekStart)
+				return f
+			}},
+			logger: zap.NewNop(),
+		}
+		reg.setupRoutes()
+
+		req := httptest.NewRequest("GET", "/download/provider/test-owner/terraform-provider-test/v1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS", nil)
+		req.Header.Set("Range", "bytes=9-")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+		is.Equal(resp.StatusCode, http.StatusPartialContent)
+		is.Equal(resp.Header.Get("Content-Type"), "application/octet-stream")
+		is.Equal(resp.Header.Get("Content-Range"), "bytes 9-14/15")
+		is.Equal(string(body), "binary")
+	})
+
+	t.Run("download timeout overrides server write timeout", func(t *testing.T) {
+		is := is.New(t)
+		reg := Registry{
+			IsAuthDisabled:  true,
+			DownloadTimeout: 5 * time.Second,
+			providerStore: assetProviderStore{asset: func() io.ReadCloser {
+				return sizedAsset{Reader: strings.NewReader("provider binary"), delay: 200 * time.Millisecond}
+			}},
+			logger: zap.NewNop(),
+		}
+		reg.setupRoutes()
+
+		srv := httptest.NewUnstartedServer(&reg)
+		srv.Config.WriteTimeout = 100 * time.Millisecond
+		srv.Start()
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/download/provider/test-owner/terraform-provider-test/v1.0.0/asset/terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		is.NoErr(err)
+		is.Equal(string(body), "provider binary")
+	})
+}
+
 func setupTestRegistry() *Registry {
 	mstore := memstore.NewMemoryStore()
 	mstore.Set("hashicorp/consul/aws", []*core.ModuleVersion{
@@ -378,6 +700,11 @@ func verifyRoute(t *testing.T, resp *http.Response, path string, authenticated b
 		t.Logf("Checking provider asset download, path '%s'", path)
 		is.Equal(resp.StatusCode, http.StatusNotFound)
 
+	case url.Path == "/ui" || strings.HasPrefix(url.Path, "/ui/"):
+		t.Logf("Checking web UI, path '%s'", path)
+		if !authenticated {
+			is.True(resp.StatusCode != http.StatusOK)
+		}
 	case authenticated && (url.Path == "/v1" || strings.HasPrefix(url.Path, "/v1/")):
 		t.Logf("Checking authenticated v1, path '%s'", path)
 		t.Logf("Response is '%v'", resp.StatusCode)
@@ -397,7 +724,7 @@ func verifyRoute(t *testing.T, resp *http.Response, path string, authenticated b
 		} else if strings.HasPrefix(path, "/v1#") {
 			is.Equal(resp.StatusCode, http.StatusNotFound)
 		} else {
-			is.Equal(resp.StatusCode, http.StatusForbidden)
+			is.Equal(resp.StatusCode, http.StatusUnauthorized)
 		}
 	default:
 		body, _ := io.ReadAll(resp.Body)
@@ -422,6 +749,8 @@ func FuzzRoutes(f *testing.F) {
 		"/v1/providers/hashicorp/aws/versions",
 		"/v1/providers/hashicorp/aws/2.2.2/download/darwin/arm64",
 		"/v1/providers/does/not/exist/versions",
+		"/ui/",
+		"/ui/modules/hashicorp/consul/aws/2.2.2",
 	} {
 		f.Add(seed)
 	}
@@ -453,3 +782,67 @@ func FuzzRoutes(f *testing.F) {
 		verifyRoute(t, resp, url, true)
 	})
 }
+
+func TestModuleDetails(t *testing.T) {
+	mstore := memstore.NewMemoryStore()
+	mstore.Set("hashicorp/consul/aws", []*core.ModuleVersion{
+		{
+			Version: "1.1.1",
+		},
+		{
+			Version: "2.2.2",
+			Metadata: &core.ModuleMetadata{
+				CommitSHA: "abc123",
+				Readme:    "# Consul",
+				Inputs:    []core.ModuleInput{{Name: "cluster_size", Type: "number", Default: float64(3)}},
+			},
+		},
+	})
+
+	reg := Registry{
+		IsAuthDisabled: true,
+		moduleStore:    mstore,
+		logger:         zap.NewNop(),
+	}
+	reg.setupRoutes()
+
+	t.Run("with metadata", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/v1/modules/hashicorp/consul/aws/2.2.2", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(resp.Header.Get("Content-Type"), "application/json")
+
+		var respObj map[string]any
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&respObj))
+		is.Equal(respObj["id"], "hashicorp/consul/aws/2.2.2")
+		is.Equal(respObj["commit_sha"], "abc123")
+		is.Equal(respObj["readme"], "# Consul")
+		is.Equal(respObj["inputs"], []any{map[string]any{"name": "cluster_size", "type": "number", "default": float64(3), "required": false}})
+	})
+
+	t.Run("without metadata", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/v1/modules/hashicorp/consul/aws/1.1.1", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusOK)
+		var respObj ModuleDetailsResponse
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&respObj))
+		is.Equal(respObj.Version, "1.1.1")
+		is.Equal(respObj.ModuleMetadata, nil)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/v1/modules/hashicorp/consul/aws/9.9.9", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,425 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	goversion "github.com/hashicorp/go-version"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"go.uber.org/zap"
+)
+
+// uiTokenCookie is the cookie holding the auth token of users logged in to the web UI.
+const uiTokenCookie = "terraform_registry_token"
+
+//go:embed templates
+var templateFS embed.FS
+
+// uiTemplates holds a template for each page of the web UI, all sharing the layout template.
+var uiTemplates = func() map[string]*template.Template {
+	funcs := template.FuncMap{
+		"json": func(v any) string {
+			b, _ := json.Marshal(v)
+			return string(b)
+		},
+	}
+	pages := []string{"login", "index", "namespace", "module", "module_version", "provider", "error"}
+	templates := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		templates[page] = template.Must(template.New("layout.html").Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+page+".html"))
+	}
+	return templates
+}()
+
+// markdown renders READMEs. Raw HTML in the Markdown source is not rendered.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// uiPage is the data passed to UI templates.
+type uiPage struct {
+	Title string
+	// Whether the logout button is shown
+	LoggedIn bool
+	Data     any
+}
+
+// renderUI renders a page of the web UI.
+func (reg *Registry) renderUI(w http.ResponseWriter, r *http.Request, status int, page, title string, data any) {
+	_, err := r.Cookie(uiTokenCookie)
+	var buf bytes.Buffer
+	if err := uiTemplates[page].Execute(&buf, uiPage{Title: title, LoggedIn: err == nil, Data: data}); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		reg.logger.Error("renderUI", zap.String("page", page), zap.Error(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		reg.logger.Error("renderUI", zap.Error(err))
+	}
+}
+
+// isValidToken returns whether `token` is one of the configured auth tokens.
+func (reg *Registry) isValidToken(token string) bool {
+	for _, t := range reg.GetAuthTokens() {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// UIAuth is a middleware function authenticating web UI users with the same tokens as `TokenAuth`.
+// The token is read from the login cookie, or from the Authorization header. Users without a valid
+// token are shown the login page.
+func (reg *Registry) UIAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reg.IsAuthDisabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cookie, err := r.Cookie(uiTokenCookie); err == nil && reg.isValidToken(cookie.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token, ok := bearerToken(r); ok && reg.isValidToken(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reg.renderUI(w, r, http.StatusUnauthorized, "login", "Log in", nil)
+	})
+}
+
+// bearerToken returns the token of a bearer Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	tokenType, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	return token, ok && tokenType == "Bearer"
+}
+
+// UILogin returns a handler that logs users in to the web UI with an auth token posted from the login page.
+func (reg *Registry) UILogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PostFormValue("token")
+		if !reg.isValidToken(token) {
+			reg.renderUI(w, r, http.StatusForbidden, "login", "Log in", "Invalid token")
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     uiTokenCookie,
+			Value:    token,
+			Path:     "/ui",
+			Expires:  time.Now().Add(12 * time.Hour),
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+	}
+}
+
+// UILogout returns a handler that logs users out of the web UI.
+func (reg *Registry) UILogout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     uiTokenCookie,
+			Path:     "/ui",
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+	}
+}
+
+// uiNamespace is a namespace listed on the UI index page.
+type uiNamespace struct {
+	Name      string
+	Modules   int
+	Providers int
+}
+
+// uiListing holds all modules and providers of the stores that can list them.
+type uiListing struct {
+	Modules   []core.ModuleAddress
+	Providers []core.ProviderAddress
+	// Whether the stores can list their modules and providers
+	ModulesListable   bool
+	ProvidersListable bool
+}
+
+// listAll lists the modules and providers of the stores implementing `core.ModuleLister` and `core.ProviderLister`.
+func (reg *Registry) listAll(r *http.Request) (uiListing, error) {
+	var (
+		listing uiListing
+		err     error
+	)
+	if lister, ok := reg.moduleStore.(core.ModuleLister); ok {
+		listing.ModulesListable = true
+		if listing.Modules, err = lister.ListModules(r.Context()); err != nil {
+			return listing, err
+		}
+	}
+	if lister, ok := reg.providerStore.(core.ProviderLister); ok && reg.IsProviderEnabled {
+		listing.ProvidersListable = true
+		if listing.Providers, err = lister.ListProviders(r.Context()); err != nil {
+			return listing, err
+		}
+	}
+	return listing, nil
+}
+
+// UIIndex returns a handler that lists the namespaces of the registry.
+func (reg *Registry) UIIndex() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listing, err := reg.listAll(r)
+		if err != nil {
+			reg.renderUI(w, r, http.StatusInternalServerError, "error", http.StatusText(http.StatusInternalServerError), nil)
+			reg.logger.Error("UIIndex", zap.Error(err))
+			return
+		}
+
+		byName := make(map[string]*uiNamespace)
+		namespace := func(name string) *uiNamespace {
+			if byName[name] == nil {
+				byName[name] = &uiNamespace{Name: name}
+			}
+			return byName[name]
+		}
+		for _, m := range listing.Modules {
+			namespace(m.Namespace).Modules++
+		}
+		for _, p := range listing.Providers {
+			namespace(p.Namespace).Providers++
+		}
+
+		namespaces := make([]uiNamespace, 0, len(byName))
+		for _, ns := range byName {
+			namespaces = append(namespaces, *ns)
+		}
+		sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+
+		reg.renderUI(w, r, http.StatusOK, "index", "Namespaces", struct {
+			uiListing
+			Namespaces []uiNamespace
+		}{listing, namespaces})
+	}
+}
+
+// UINamespace returns a handler that lists the modules and providers in a namespace.
+func (reg *Registry) UINamespace() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := chi.URLParam(r, "namespace")
+
+		listing, err := reg.listAll(r)
+		if err != nil {
+			reg.renderUI(w, r, http.StatusInternalServerError, "error", http.StatusText(http.StatusInternalServerError), nil)
+			reg.logger.Error("UINamespace", zap.Error(err))
+			return
+		}
+
+		var (
+			modules   []core.ModuleAddress
+			providers []core.ProviderAddress
+		)
+		for _, m := range listing.Modules {
+			if m.Namespace == namespace {
+				modules = append(modules, m)
+			}
+		}
+		for _, p := range listing.Providers {
+			if p.Namespace == namespace {
+				providers = append(providers, p)
+			}
+		}
+		if len(modules) == 0 && len(providers) == 0 {
+			reg.renderUINotFound(w, r)
+			return
+		}
+
+		reg.renderUI(w, r, http.StatusOK, "namespace", namespace, struct {
+			Namespace string
+			Modules   []core.ModuleAddress
+			Providers []core.ProviderAddress
+		}{namespace, modules, providers})
+	}
+}
+
+// uiModuleVersion is a module version listed in the web UI.
+type uiModuleVersion struct {
+	Version     string
+	Deprecated  bool
+	Reason      string
+	PublishedAt time.Time
+}
+
+// UIModule returns a handler that lists the versions of a module.
+func (reg *Registry) UIModule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := core.ModuleAddress{
+			Namespace: chi.URLParam(r, "namespace"),
+			Name:      chi.URLParam(r, "name"),
+			Provider:  chi.URLParam(r, "provider"),
+		}
+
+		versions, err := reg.moduleStore.ListModuleVersions(r.Context(), addr.Namespace, addr.Name, addr.Provider)
+		if err != nil {
+			reg.renderUIStoreError(w, r, "ListModuleVersions", err)
+			return
+		}
+
+		var (
+			listed      []uiModuleVersion
+			description string
+		)
+		for _, v := range sortVersions(versions, func(v *core.ModuleVersion) string { return v.Version }) {
+			status := reg.moduleVersionStatus(addr.Namespace, addr.Name, addr.Provider, v.Version)
+			if status.Yanked {
+				continue
+			}
+			version := uiModuleVersion{Version: v.Version, Deprecated: status.Deprecated, Reason: status.Reason}
+			if v.Metadata != nil {
+				version.PublishedAt = v.Metadata.PublishedAt
+				if description == "" {
+					description = v.Metadata.Description
+				}
+			}
+			listed = append(listed, version)
+		}
+
+		reg.renderUI(w, r, http.StatusOK, "module", fmt.Sprintf("%s/%s/%s", addr.Namespace, addr.Name, addr.Provider), struct {
+			Address     core.ModuleAddress
+			Description string
+			Versions    []uiModuleVersion
+		}{addr, description, listed})
+	}
+}
+
+// UIModuleVersion returns a handler that shows the details of a module version, with a snippet for using it.
+func (reg *Registry) UIModuleVersion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := core.ModuleAddress{
+			Namespace: chi.URLParam(r, "namespace"),
+			Name:      chi.URLParam(r, "name"),
+			Provider:  chi.URLParam(r, "provider"),
+		}
+		version := chi.URLParam(r, "version")
+
+		ver, err := reg.moduleStore.GetModuleVersion(r.Context(), addr.Namespace, addr.Name, addr.Provider, version)
+		if err != nil {
+			reg.renderUIStoreError(w, r, "GetModuleVersion", err)
+			return
+		}
+
+		meta := ver.Metadata
+		if meta == nil {
+			meta = &core.ModuleMetadata{}
+		}
+		var readme bytes.Buffer
+		if err := markdown.Convert([]byte(meta.Readme), &readme); err != nil {
+			reg.logger.Warn("UIModuleVersion: unable to render README", zap.Error(err))
+		}
+
+		snippet := fmt.Sprintf("module %q {\n  source  = %q\n  version = %q\n}\n",
+			addr.Name, fmt.Sprintf("%s/%s/%s/%s", r.Host, addr.Namespace, addr.Name, addr.Provider), ver.Version)
+
+		reg.renderUI(w, r, http.StatusOK, "module_version", fmt.Sprintf("%s/%s/%s %s", addr.Namespace, addr.Name, addr.Provider, ver.Version), struct {
+			Address  core.ModuleAddress
+			Version  string
+			Status   VersionStatus
+			Metadata *core.ModuleMetadata
+			Readme   template.HTML
+			Snippet  string
+		}{addr, ver.Version, reg.moduleVersionStatus(addr.Namespace, addr.Name, addr.Provider, ver.Version), meta, template.HTML(readme.String()), snippet})
+	}
+}
+
+// UIProvider returns a handler that lists the versions and platforms of a provider, with a snippet for using it.
+func (reg *Registry) UIProvider() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := core.ProviderAddress{
+			Namespace: chi.URLParam(r, "namespace"),
+			Name:      chi.URLParam(r, "name"),
+		}
+		if reg.providerStore == nil || !reg.IsProviderEnabled {
+			reg.renderUINotFound(w, r)
+			return
+		}
+
+		versions, err := reg.providerStore.ListProviderVersions(r.Context(), addr.Namespace, addr.Name)
+		if err != nil {
+			reg.renderUIStoreError(w, r, "ListProviderVersions", err)
+			return
+		}
+		versions = reg.applyProviderLifecycle(addr.Namespace, addr.Name, versions)
+		sorted := sortVersions(versions.Versions, func(v core.ProviderVersion) string { return v.Version })
+
+		var snippet string
+		if len(sorted) > 0 {
+			snippet = fmt.Sprintf("terraform {\n  required_providers {\n    %s = {\n      source  = %q\n      version = %q\n    }\n  }\n}\n",
+				addr.Name, fmt.Sprintf("%s/%s/%s", r.Host, addr.Namespace, addr.Name), sorted[0].Version)
+		}
+
+		reg.renderUI(w, r, http.StatusOK, "provider", fmt.Sprintf("%s/%s", addr.Namespace, addr.Name), struct {
+			Address  core.ProviderAddress
+			Versions []core.ProviderVersion
+			Warnings []string
+			Snippet  string
+		}{addr, sorted, versions.Warnings, snippet})
+	}
+}
+
+// renderUINotFound renders the not found page of the web UI.
+func (reg *Registry) renderUINotFound(w http.ResponseWriter, r *http.Request) {
+	reg.renderUI(w, r, http.StatusNotFound, "error", http.StatusText(http.StatusNotFound), nil)
+}
+
+// renderUIStoreError renders the error page of the web UI for an error returned by a store, with
+// the same status as `writeStoreError`.
+func (reg *Registry) renderUIStoreError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	status := reg.storeErrorStatus(w, msg, err)
+	reg.renderUI(w, r, status, "error", http.StatusText(status), nil)
+}
+
+// sortVersions returns a copy of `items` sorted by version, newest first. Items with versions that
+// are not valid SemVer are sorted last.
+func sortVersions[T any](items []T, version func(T) string) []T {
+	type parsed struct {
+		item    T
+		version *goversion.Version
+	}
+	list := make([]parsed, len(items))
+	for i, item := range items {
+		v, _ := goversion.NewVersion(version(item))
+		list[i] = parsed{item, v}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].version == nil || list[j].version == nil {
+			return list[j].version == nil && list[i].version != nil
+		}
+		return list[i].version.GreaterThan(list[j].version)
+	})
+
+	res := make([]T, len(list))
+	for i, p := range list {
+		res[i] = p.item
+	}
+	return res
+}
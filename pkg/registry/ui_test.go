@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package registry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	memstore "github.com/nrkno/terraform-registry/pkg/store/memory"
+	"go.uber.org/zap"
+)
+
+// listingProviderStore is a provider store that lists its providers.
+type listingProviderStore struct {
+	versionsProviderStore
+}
+
+func (s listingProviderStore) ListProviders(ctx context.Context) ([]core.ProviderAddress, error) {
+	return []core.ProviderAddress{{Namespace: "hashicorp", Name: "aws"}}, nil
+}
+
+func setupUIRegistry() *Registry {
+	mstore := memstore.NewMemoryStore()
+	mstore.Set("hashicorp/consul/aws", []*core.ModuleVersion{
+		{Version: "1.1.1"},
+		{
+			Version: "2.2.2",
+			Metadata: &core.ModuleMetadata{
+				Description: "Consul cluster",
+				Readme:      "# Consul\n\n<script>alert(1)</script>\n",
+				Inputs:      []core.ModuleInput{{Name: "cluster_size", Type: "number", Default: 3}},
+			},
+		},
+	})
+	mstore.Set("other/vpc/aws", []*core.ModuleVersion{{Version: "1.0.0"}})
+
+	reg := &Registry{
+		IsProviderEnabled: true,
+		moduleStore:       mstore,
+		providerStore: listingProviderStore{versionsProviderStore{versions: &core.ProviderVersions{
+			Versions: []core.ProviderVersion{
+				{Version: "1.0.0", Protocols: []string{"5.0"}, Platforms: []core.Platform{{OS: "linux", Arch: "amd64"}}},
+				{Version: "1.1.0", Protocols: []string{"5.0"}, Platforms: []core.Platform{{OS: "darwin", Arch: "arm64"}}},
+			},
+		}}},
+		authTokens: map[string]string{"test": "valid"},
+		logger:     zap.NewNop(),
+	}
+	reg.setupRoutes()
+	reg.SetVersionLifecycle(VersionLifecycle{
+		Modules: map[string]map[string]VersionStatus{
+			"hashicorp/consul/aws": {"1.1.1": {Yanked: true}},
+		},
+	})
+	return reg
+}
+
+func getUIPage(t *testing.T, reg *Registry, path string) (int, string) {
+	req := httptest.NewRequest("GET", path, nil)
+	req.AddCookie(&http.Cookie{Name: uiTokenCookie, Value: "valid"})
+	w := httptest.NewRecorder()
+	reg.router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestUIAuth(t *testing.T) {
+	reg := setupUIRegistry()
+
+	t.Run("shows login page", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/ui/", nil)
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+		is.Equal(resp.StatusCode, http.StatusUnauthorized)
+		is.True(strings.Contains(string(body), `action="/ui/login"`))
+		is.True(!strings.Contains(string(body), "hashicorp"))
+	})
+
+	t.Run("rejects invalid token", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("POST", "/ui/login", strings.NewReader(url.Values{"token": {"invalid"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusForbidden)
+		is.Equal(len(resp.Cookies()), 0)
+	})
+
+	t.Run("logs in with valid token", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("POST", "/ui/login", strings.NewReader(url.Values{"token": {"valid"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		is.Equal(resp.StatusCode, http.StatusSeeOther)
+		is.Equal(resp.Header.Get("Location"), "/ui/")
+		cookies := resp.Cookies()
+		is.Equal(len(cookies), 1)
+		is.Equal(cookies[0].Value, "valid")
+		is.True(cookies[0].HttpOnly)
+
+		status, _ := getUIPage(t, reg, "/ui/")
+		is.Equal(status, http.StatusOK)
+	})
+
+	t.Run("accepts bearer token", func(t *testing.T) {
+		is := is.New(t)
+		req := httptest.NewRequest("GET", "/ui/", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		w := httptest.NewRecorder()
+		reg.router.ServeHTTP(w, req)
+		is.Equal(w.Result().StatusCode, http.StatusOK)
+	})
+}
+
+func TestUIPages(t *testing.T) {
+	reg := setupUIRegistry()
+
+	t.Run("index lists namespaces", func(t *testing.T) {
+		is := is.New(t)
+		status, body := getUIPage(t, reg, "/ui/")
+		is.Equal(status, http.StatusOK)
+		is.True(strings.Contains(body, `href="/ui/namespaces/hashicorp"`))
+		is.True(strings.Contains(body, `href="/ui/namespaces/other"`))
+	})
+
+	t.Run("namespace lists modules and providers", func(t *testing.T) {
+		is := is.New(t)
+		status, body := getUIPage(t, reg, "/ui/namespaces/hashicorp")
+		is.Equal(status, http.StatusOK)
+		is.True(strings.Contains(body, `href="/ui/modules/hashicorp/consul/aws"`))
+		is.True(strings.Contains(body, `href="/ui/providers/hashicorp/aws"`))
+		is.True(!strings.Contains(body, "vpc"))
+
+		status, _ = getUIPage(t, reg, "/ui/namespaces/unknown")
+		is.Equal(status, http.StatusNotFound)
+	})
+
+	t.Run("module lists versions", func(t *testing.T) {
+		is := is.New(t)
+		status, body := getUIPage(t, reg, "/ui/modules/hashicorp/consul/aws")
+		is.Equal(status, http.StatusOK)
+		is.True(strings.Contains(body, "Consul cluster"))
+		is.True(strings.Contains(body, `href="/ui/modules/hashicorp/consul/aws/2.2.2"`))
+		is.True(!strings.Contains(body, "1.1.1")) // yanked
+	})
+
+	t.Run("module version shows snippet and readme", func(t *testing.T) {
+		is := is.New(t)
+		status, body := getUIPage(t, reg, "/ui/modules/hashicorp/consul/aws/2.2.2")
+		is.Equal(status, http.StatusOK)
+		is.True(strings.Contains(body, "source  = &#34;example.com/hashicorp/consul/aws&#34;"))
+		is.True(strings.Contains(body, "version = &#34;2.2.2&#34;"))
+		is.True(strings.Contains(body, "<h1>Consul</h1>"))
+		is.True(!strings.Contains(body, "<script>")) // raw HTML is not rendered
+		is.True(strings.Contains(body, "<code>cluster_size</code>"))
+
+		status, _ = getUIPage(t, reg, "/ui/modules/hashicorp/consul/aws/9.9.9")
+		is.Equal(status, http.StatusNotFound)
+	})
+
+	t.Run("provider lists versions and platforms", func(t *testing.T) {
+		is := is.New(t)
+		status, body := getUIPage(t, reg, "/ui/providers/hashicorp/aws")
+		is.Equal(status, http.StatusOK)
+		is.True(strings.Contains(body, "source  = &#34;example.com/hashicorp/aws&#34;"))
+		is.True(strings.Contains(body, "version = &#34;1.1.0&#34;")) // latest version
+		is.True(strings.Contains(body, "linux_amd64"))
+		is.True(strings.Contains(body, "darwin_arm64"))
+	})
+}
@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package azblob
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	"go.uber.org/zap"
+)
+
+// AzureBlobStore is a module store backed by a container in Azure Blob Storage.
+// Module archives are stored with the same key layout as the S3 store, i.e.
+// `{namespace}/{name}/{provider}/{version}/{version}.zip`.
+type AzureBlobStore struct {
+	service       *service.Client
+	container     *container.Client
+	containerName string
+	// Prefix of the module keys in the container. Either empty or ending with a slash.
+	prefix string
+	logger *zap.Logger
+	mut    sync.RWMutex
+
+	// How clients download module archives. Defaults to `DownloadSAS`, or `DownloadProxy` when
+	// authenticated with a SAS token.
+	downloadMode DownloadMode
+	sasTTL       time.Duration
+
+	// User delegation key for signing SAS URLs with Entra ID credentials, and when it expires.
+	delegation       *service.UserDelegationCredential
+	delegationExpiry time.Time
+	delegationMut    sync.Mutex
+}
+
+// NewAzureBlobStore returns a store for the container `containerName` of the storage account of `client`.
+func NewAzureBlobStore(client *service.Client, containerName string, logger *zap.Logger) *AzureBlobStore {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	s := &AzureBlobStore{
+		service:       client,
+		container:     client.NewContainerClient(containerName),
+		containerName: containerName,
+		downloadMode:  DownloadSAS,
+		sasTTL:        DefaultSASTTL,
+		logger:        logger,
+	}
+	if s.hasSASToken() {
+		s.downloadMode = DownloadProxy
+	}
+	return s
+}
+
+// SetKeyPrefix sets the prefix of the module keys in the container, for containers shared with other content.
+// With the prefix `terraform/modules`, module archives are expected at
+// `terraform/modules/{namespace}/{name}/{provider}/{version}/{version}.zip`.
+func (s *AzureBlobStore) SetKeyPrefix(prefix string) {
+	s.mut.Lock()
+	s.prefix = storeutil.NormalizePrefix(prefix)
+	s.mut.Unlock()
+}
+
+func (s *AzureBlobStore) keyPrefix() string {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.prefix
+}
+
+// blobURL returns the URL of the blob at `key`, without any SAS token of the client.
+// The SDK escapes the slashes of blob names, which are kept as is for readable URLs.
+func (s *AzureBlobStore) blobURL(key string) string {
+	return s.blobURLWithQuery(key, "")
+}
+
+// blobURLWithQuery returns the URL of the blob at `key` with the query `rawQuery`.
+func (s *AzureBlobStore) blobURLWithQuery(key, rawQuery string) string {
+	u, err := url.Parse(s.container.URL())
+	if err != nil {
+		return s.container.NewBlobClient(key).URL()
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	u.RawPath = ""
+	u.RawQuery = rawQuery
+	return u.String()
+}
+
+// moduleVersion returns the module version of the archive at `key`, relative to the key prefix.
+func (s *AzureBlobStore) moduleVersion(prefix, key string, lastModified *time.Time) *core.ModuleVersion {
+	ver := &core.ModuleVersion{
+		Version:   strings.Split(key, "/")[3],
+		SourceURL: s.blobURL(prefix + key),
+	}
+	if lastModified != nil {
+		ver.Metadata = &core.ModuleMetadata{PublishedAt: lastModified.UTC()}
+	}
+	return ver
+}
+
+// listBlobs calls `fn` with the name and modification time of each blob in the container with
+// a name starting with `prefix`.
+func (s *AzureBlobStore) listBlobs(ctx context.Context, prefix string, fn func(name string, lastModified *time.Time)) error {
+	opts := &container.ListBlobsFlatOptions{}
+	if prefix != "" {
+		opts.Prefix = &prefix
+	}
+	pager := s.container.NewListBlobsFlatPager(opts)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return backendError(err)
+		}
+		if page.Segment == nil {
+			continue
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			var lastModified *time.Time
+			if item.Properties != nil {
+				lastModified = item.Properties.LastModified
+			}
+			fn(*item.Name, lastModified)
+		}
+	}
+	return nil
+}
+
+func (s *AzureBlobStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
+	prefix := s.keyPrefix()
+	addr := path.Join(namespace, name, provider)
+
+	vers := make([]*core.ModuleVersion, 0)
+	err := s.listBlobs(ctx, prefix+addr+"/", func(blobName string, lastModified *time.Time) {
+		key := strings.TrimPrefix(blobName, prefix)
+		if storeutil.IsValidModulePath(key) {
+			vers = append(vers, s.moduleVersion(prefix, key, lastModified))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vers) == 0 {
+		return nil, core.NotFoundError("module '%s' not found", addr)
+	}
+
+	return vers, nil
+}
+
+func (s *AzureBlobStore) GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*core.ModuleVersion, error) {
+	prefix := s.keyPrefix()
+	addr := path.Join(namespace, name, provider)
+
+	key := path.Join(addr, version, version+".zip")
+	if !storeutil.IsValidModulePath(key) {
+		s.logger.Warn("invalid module path requested: " + key)
+		return nil, core.NotFoundError("module version path '%s' is not valid", path.Join(addr, version))
+	}
+
+	props, err := s.container.NewBlobClient(prefix+key).GetProperties(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, addr)
+	}
+	if err != nil {
+		return nil, backendError(err)
+	}
+
+	return s.withDownloadURL(ctx, addr, s.moduleVersion(prefix, key, props.LastModified))
+}
+
+// ListModules returns all modules in the container, sorted by address.
+func (s *AzureBlobStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	prefix := s.keyPrefix()
+	modules := storeutil.NewModuleLister(prefix)
+	err := s.listBlobs(ctx, prefix, func(blobName string, _ *time.Time) {
+		modules.Add(blobName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modules.Modules(), nil
+}
+
+// backendError classifies an error returned by the Blob Storage API, by the status code of the response.
+func backendError(err error) error {
+	return storeutil.BackendError(err, func(e *azcore.ResponseError) int { return e.StatusCode })
+}
@@ -0,0 +1,346 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package azblob
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+const (
+	testAccount   = "devstoreaccount1"
+	testContainer = "modules"
+	// Well-known account key of the Azurite storage emulator
+	testAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// fakeBlobServer is a minimal Azurite-like Blob Storage server for the container `modules` of the
+// account `devstoreaccount1`, serving `blobs` by name. Listings are paginated with two blobs per page.
+type fakeBlobServer struct {
+	*httptest.Server
+	blobs map[string]string
+	// Number of requested user delegation keys.
+	delegationKeys atomic.Int32
+}
+
+func newFakeBlobServer(t *testing.T, tls bool, blobs map[string]string) *fakeBlobServer {
+	type properties struct {
+		LastModified  string `xml:"Last-Modified"`
+		Etag          string `xml:"Etag"`
+		ContentLength int    `xml:"Content-Length"`
+	}
+	type blob struct {
+		Name       string
+		Properties properties
+	}
+	type enumerationResults struct {
+		XMLName       xml.Name `xml:"EnumerationResults"`
+		ContainerName string   `xml:"ContainerName,attr"`
+		Prefix        string
+		Blobs         []blob `xml:"Blobs>Blob"`
+		NextMarker    string
+	}
+
+	lastModified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	srv := &fakeBlobServer{blobs: blobs}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path == "/"+testAccount+"/" && query.Get("comp") == "userdelegationkey" {
+			srv.delegationKeys.Add(1)
+			w.Header().Set("Content-Type", "application/xml")
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><UserDelegationKey>`+
+				`<SignedOid>00000000-0000-0000-0000-000000000001</SignedOid><SignedTid>00000000-0000-0000-0000-000000000002</SignedTid>`+
+				`<SignedStart>2025-03-01T12:00:00Z</SignedStart><SignedExpiry>2025-03-01T13:00:00Z</SignedExpiry>`+
+				`<SignedService>b</SignedService><SignedVersion>2020-02-10</SignedVersion>`+
+				`<Value>`+base64.StdEncoding.EncodeToString([]byte("delegation key"))+`</Value></UserDelegationKey>`)
+			return
+		}
+
+		name, ok := strings.CutPrefix(r.URL.Path, "/"+testAccount+"/"+testContainer)
+		if !ok {
+			t.Errorf("unexpected request path '%s'", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		name = strings.TrimPrefix(name, "/")
+
+		switch {
+		case r.Method == http.MethodGet && name == "" && query.Get("comp") == "list":
+			var names []string
+			for key := range blobs {
+				if strings.HasPrefix(key, query.Get("prefix")) {
+					names = append(names, key)
+				}
+			}
+			sort.Strings(names)
+
+			start, _ := strconv.Atoi(query.Get("marker"))
+			res := enumerationResults{ContainerName: testContainer, Prefix: query.Get("prefix")}
+			for i := start; i < len(names) && i < start+2; i++ {
+				res.Blobs = append(res.Blobs, blob{Name: names[i], Properties: properties{LastModified: lastModified, Etag: "0x1", ContentLength: len(blobs[names[i]])}})
+			}
+			if start+2 < len(names) {
+				res.NextMarker = strconv.Itoa(start + 2)
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			xml.NewEncoder(w).Encode(res)
+		case r.Method == http.MethodHead || r.Method == http.MethodGet:
+			content, ok := blobs[name]
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Last-Modified", lastModified)
+			w.Header().Set("ETag", "0x1")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			if r.Method == http.MethodGet {
+				io.WriteString(w, content)
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+
+	if tls {
+		srv.Server = httptest.NewTLSServer(handler)
+	} else {
+		srv.Server = httptest.NewServer(handler)
+	}
+	return srv
+}
+
+var testBlobs = map[string]string{
+	"testnamespace/testname/testprovider/1.0.0/1.0.0.zip":           "archive 1.0.0",
+	"testnamespace/testname/testprovider/1.1.0/1.1.0.zip":           "archive 1.1.0",
+	"testnamespace/testname/testprovider/2.0.0/2.0.0.zip":           "archive 2.0.0",
+	"testnamespace/othername/testprovider/0.1.0/0.1.0.zip":          "archive 0.1.0",
+	"testnamespace/testname/testprovider/README.md":                 "not a module",
+	"terraform/testnamespace/testname/testprovider/3.0.0/3.0.0.zip": "prefixed archive",
+}
+
+// connectionStringStore returns a store authenticated with the account key of a connection string.
+func connectionStringStore(t *testing.T, srv *fakeBlobServer) *AzureBlobStore {
+	t.Helper()
+	client, err := NewClient(ClientOptions{
+		ConnectionString: "DefaultEndpointsProtocol=http;AccountName=" + testAccount + ";AccountKey=" + testAccountKey + ";BlobEndpoint=" + srv.URL + "/" + testAccount + ";",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAzureBlobStore(client, testContainer, zap.NewNop())
+}
+
+func TestListModuleVersions(t *testing.T) {
+	is := is.New(t)
+	srv := newFakeBlobServer(t, false, testBlobs)
+	defer srv.Close()
+	store := connectionStringStore(t, srv)
+
+	vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(len(vers), 3)
+	is.Equal(vers[0].Version, "1.0.0")
+	is.Equal(vers[0].SourceURL, srv.URL+"/devstoreaccount1/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")
+	is.Equal(vers[0].Metadata.PublishedAt, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	is.Equal(vers[2].Version, "2.0.0")
+
+	_, err = store.ListModuleVersions(context.Background(), "unknown", "unknown", "unknown")
+	is.Equal(err.Error(), "module 'unknown/unknown/unknown' not found")
+	is.True(errors.Is(err, core.ErrNotFound))
+}
+
+func TestGetModuleVersion(t *testing.T) {
+	srv := newFakeBlobServer(t, false, testBlobs)
+	defer srv.Close()
+	store := connectionStringStore(t, srv)
+	if err := store.SetDownloadMode(DownloadHTTPS, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("returns matching version", func(t *testing.T) {
+		is := is.New(t)
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		is.Equal(ver.Version, "1.1.0")
+		is.Equal(ver.SourceURL, srv.URL+"/devstoreaccount1/modules/testnamespace/testname/testprovider/1.1.0/1.1.0.zip")
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		is := is.New(t)
+		_, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "9.9.9")
+		is.Equal(err.Error(), "version '9.9.9' not found for module 'testnamespace/testname/testprovider'")
+		is.True(errors.Is(err, core.ErrNotFound))
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		is := is.New(t)
+		_, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.Equal(err.Error(), "module version path 'test-owner/test-repo/generic/1.0.0' is not valid")
+		is.True(errors.Is(err, core.ErrNotFound))
+	})
+}
+
+func TestListModules(t *testing.T) {
+	is := is.New(t)
+	srv := newFakeBlobServer(t, false, testBlobs)
+	defer srv.Close()
+	store := connectionStringStore(t, srv)
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "testnamespace", Name: "othername", Provider: "testprovider"},
+		{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+	})
+}
+
+func TestKeyPrefix(t *testing.T) {
+	is := is.New(t)
+	srv := newFakeBlobServer(t, false, testBlobs)
+	defer srv.Close()
+	store := connectionStringStore(t, srv)
+	store.SetKeyPrefix("/terraform/")
+	is.NoErr(store.SetDownloadMode(DownloadHTTPS, 0))
+
+	vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(len(vers), 1)
+	is.Equal(vers[0].Version, "3.0.0")
+
+	ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "3.0.0")
+	is.NoErr(err)
+	is.Equal(ver.SourceURL, srv.URL+"/devstoreaccount1/modules/terraform/testnamespace/testname/testprovider/3.0.0/3.0.0.zip")
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"}})
+}
+
+// fakeTokenCredential is a token credential with a static token.
+type fakeTokenCredential struct{}
+
+func (fakeTokenCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestDownloadModes(t *testing.T) {
+	t.Run("SAS with account key", func(t *testing.T) {
+		is := is.New(t)
+		srv := newFakeBlobServer(t, false, testBlobs)
+		defer srv.Close()
+		store := connectionStringStore(t, srv)
+		is.NoErr(store.SetDownloadMode(DownloadSAS, 5*time.Minute))
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		u, err := url.Parse(ver.SourceURL)
+		is.NoErr(err)
+		is.Equal(u.Path, "/devstoreaccount1/modules/testnamespace/testname/testprovider/1.1.0/1.1.0.zip")
+		is.Equal(u.Query().Get("sp"), "r")
+		is.True(u.Query().Get("sig") != "")
+		expiry, err := time.Parse(time.RFC3339, u.Query().Get("se"))
+		is.NoErr(err)
+		is.True(expiry.Before(time.Now().Add(6 * time.Minute)))
+	})
+
+	t.Run("SAS token", func(t *testing.T) {
+		is := is.New(t)
+		srv := newFakeBlobServer(t, false, testBlobs)
+		defer srv.Close()
+		client, err := NewClient(ClientOptions{
+			AccountURL: srv.URL + "/" + testAccount,
+			SASToken:   "?sv=2023-11-03&sp=rl&sig=secret",
+		})
+		is.NoErr(err)
+		store := NewAzureBlobStore(client, testContainer, zap.NewNop())
+
+		vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+		is.NoErr(err)
+		is.Equal(len(vers), 3)
+		// Listed source URLs never include the token
+		is.Equal(vers[0].SourceURL, srv.URL+"/devstoreaccount1/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")
+
+		// The token is never handed out, so archives are proxied by default
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "/download/module/testnamespace/testname/testprovider/1.1.0?archive=zip")
+
+		is.True(errors.Is(store.SetDownloadMode(DownloadSAS, 0), errSASTokenSigning))
+		_, err = store.sasURL(context.Background(), "testnamespace/testname/testprovider/1.1.0/1.1.0.zip", time.Now().Add(time.Minute))
+		is.True(errors.Is(err, errSASTokenSigning))
+	})
+
+	t.Run("SAS with user delegation key", func(t *testing.T) {
+		is := is.New(t)
+		srv := newFakeBlobServer(t, true, testBlobs)
+		defer srv.Close()
+		client, err := service.NewClient(srv.URL+"/"+testAccount+"/", fakeTokenCredential{}, &service.ClientOptions{
+			ClientOptions: policy.ClientOptions{Transport: srv.Client()},
+		})
+		is.NoErr(err)
+		store := NewAzureBlobStore(client, testContainer, zap.NewNop())
+
+		for _, version := range []string{"1.0.0", "1.1.0"} {
+			ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", version)
+			is.NoErr(err)
+			u, err := url.Parse(ver.SourceURL)
+			is.NoErr(err)
+			is.Equal(u.Query().Get("skoid"), "00000000-0000-0000-0000-000000000001")
+			is.Equal(u.Query().Get("spr"), "https")
+			is.True(u.Query().Get("sig") != "")
+		}
+		// The user delegation key is reused
+		is.Equal(srv.delegationKeys.Load(), int32(1))
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		is := is.New(t)
+		srv := newFakeBlobServer(t, false, testBlobs)
+		defer srv.Close()
+		store := connectionStringStore(t, srv)
+		is.NoErr(store.SetDownloadMode(DownloadProxy, 0))
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "/download/module/testnamespace/testname/testprovider/1.1.0?archive=zip")
+
+		archive, err := store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		defer archive.Close()
+		body, err := io.ReadAll(archive)
+		is.NoErr(err)
+		is.Equal(string(body), "archive 1.1.0")
+
+		_, err = store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "9.9.9")
+		is.True(err != nil)
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	is := is.New(t)
+	_, err := NewClient(ClientOptions{})
+	is.True(err != nil)
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package azblob
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+)
+
+// ClientOptions configures the Blob Storage client created by `NewClient`.
+type ClientOptions struct {
+	// Connection string of the storage account, with an account key or SAS token.
+	// Takes precedence over the other options.
+	ConnectionString string
+	// URL of the blob service of the storage account, i.e. `https://{account}.blob.core.windows.net`.
+	AccountURL string
+	// SAS token for the storage account or container, with at least read and list permissions.
+	SASToken string
+	// Client ID of a user-assigned managed identity. Without a connection string or SAS token,
+	// the default Azure credential chain is used, which includes environment variables,
+	// workload identity, the system-assigned managed identity and the Azure CLI.
+	ManagedIdentityClientID string
+}
+
+// NewClient returns a Blob Storage client for `NewAzureBlobStore`.
+func NewClient(opts ClientOptions) (*service.Client, error) {
+	if opts.ConnectionString != "" {
+		return service.NewClientFromConnectionString(opts.ConnectionString, nil)
+	}
+	if opts.AccountURL == "" {
+		return nil, errors.New("either a connection string or an account URL is required")
+	}
+
+	if opts.SASToken != "" {
+		return service.NewClientWithNoCredential(strings.TrimSuffix(opts.AccountURL, "/")+"/?"+strings.TrimPrefix(opts.SASToken, "?"), nil)
+	}
+
+	var cred azcore.TokenCredential
+	var err error
+	if opts.ManagedIdentityClientID != "" {
+		cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ID: azidentity.ClientID(opts.ManagedIdentityClientID),
+		})
+	} else {
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return service.NewClient(opts.AccountURL, cred, nil)
+}
@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+)
+
+// DownloadMode selects how clients download module archives.
+type DownloadMode = storeutil.DownloadMode
+
+const (
+	// DownloadHTTPS returns the plain HTTPS URLs of the blobs, for containers with public read access.
+	DownloadHTTPS DownloadMode = "https"
+	// DownloadSAS returns HTTPS URLs with a short-lived read-only SAS token, so clients need no
+	// Azure credentials. The token is signed with the account key of a connection string, or with
+	// a user delegation key when authenticated with Entra ID. Stores authenticated with a SAS token
+	// can not sign tokens, and never hand out their own token.
+	DownloadSAS DownloadMode = "sas"
+	// DownloadProxy returns URLs of the registry `/download/module/` route, which serves the archives
+	// from the container. The route is protected by the download token of the registry.
+	DownloadProxy = storeutil.DownloadProxy
+)
+
+// DefaultSASTTL is how long SAS download URLs are valid.
+const DefaultSASTTL = 15 * time.Minute
+
+// ParseDownloadMode returns the download mode named `s`.
+func ParseDownloadMode(s string) (DownloadMode, error) {
+	return storeutil.ParseDownloadMode(s, DownloadHTTPS, DownloadSAS, DownloadProxy)
+}
+
+// errSASTokenSigning is returned for `DownloadSAS` when the store is authenticated with a SAS token.
+var errSASTokenSigning = errors.New("SAS download URLs require a connection string with an account key or Entra ID credentials, not a SAS token")
+
+// SetDownloadMode sets how clients download module archives. `ttl` is how long SAS URLs are
+// valid, and defaults to `DefaultSASTTL` when zero. `DownloadSAS` is rejected when the store
+// is authenticated with a SAS token.
+func (s *AzureBlobStore) SetDownloadMode(mode DownloadMode, ttl time.Duration) error {
+	if mode == DownloadSAS && s.hasSASToken() {
+		return errSASTokenSigning
+	}
+	if ttl == 0 {
+		ttl = DefaultSASTTL
+	}
+
+	s.mut.Lock()
+	s.downloadMode = mode
+	s.sasTTL = ttl
+	s.mut.Unlock()
+	return nil
+}
+
+// hasSASToken reports whether the client of the store is authenticated with a SAS token, which
+// is then part of the URLs of the client.
+func (s *AzureBlobStore) hasSASToken() bool {
+	u, err := url.Parse(s.container.URL())
+	return err == nil && u.Query().Has("sig")
+}
+
+// archiveKey returns the key of the archive of the module version.
+func (s *AzureBlobStore) archiveKey(address, version string) string {
+	return s.keyPrefix() + path.Join(address, version, version+".zip")
+}
+
+// withDownloadURL returns the module version with the source URL of the download mode.
+func (s *AzureBlobStore) withDownloadURL(ctx context.Context, address string, ver *core.ModuleVersion) (*core.ModuleVersion, error) {
+	s.mut.RLock()
+	mode := s.downloadMode
+	ttl := s.sasTTL
+	s.mut.RUnlock()
+
+	switch mode {
+	case DownloadSAS:
+		u, err := s.sasURL(ctx, s.archiveKey(address, ver.Version), time.Now().Add(ttl))
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign download URL: %w", err)
+		}
+		return storeutil.WithSourceURL(ver, u), nil
+	case DownloadProxy:
+		return storeutil.WithSourceURL(ver, storeutil.ModuleProxyURL(address, ver.Version, "zip")), nil
+	}
+	return ver, nil
+}
+
+// sasURL returns the URL of the blob at `key` with a read-only SAS token valid until `expiry`.
+func (s *AzureBlobStore) sasURL(ctx context.Context, key string, expiry time.Time) (string, error) {
+	blobClient := s.container.NewBlobClient(key)
+
+	signed, err := blobClient.GetSASURL(sas.BlobPermissions{Read: true}, expiry, nil)
+	if err == nil {
+		u, err := url.Parse(signed)
+		if err != nil {
+			return "", err
+		}
+		return s.blobURLWithQuery(key, u.RawQuery), nil
+	}
+	if !errors.Is(err, bloberror.MissingSharedKeyCredential) {
+		return "", err
+	}
+
+	// The SAS token of the client is long-lived, and must not be handed out to clients
+	if s.hasSASToken() {
+		return "", errSASTokenSigning
+	}
+
+	cred, err := s.userDelegationCredential(ctx, expiry)
+	if err != nil {
+		return "", err
+	}
+	params, err := sas.BlobSignatureValues{
+		Protocol:      sas.ProtocolHTTPS,
+		ExpiryTime:    expiry.UTC(),
+		Permissions:   (&sas.BlobPermissions{Read: true}).String(),
+		ContainerName: s.containerName,
+		BlobName:      key,
+	}.SignWithUserDelegation(cred)
+	if err != nil {
+		return "", err
+	}
+	return s.blobURLWithQuery(key, params.Encode()), nil
+}
+
+// userDelegationCredential returns a user delegation key valid until at least `expiry`. Keys are
+// requested with an hour to spare, so they can be reused for later URLs.
+func (s *AzureBlobStore) userDelegationCredential(ctx context.Context, expiry time.Time) (*service.UserDelegationCredential, error) {
+	s.delegationMut.Lock()
+	defer s.delegationMut.Unlock()
+
+	if s.delegation != nil && s.delegationExpiry.After(expiry) {
+		return s.delegation, nil
+	}
+
+	// Allow for clock skew between the registry and the storage service
+	start := time.Now().UTC().Add(-5 * time.Minute)
+	keyExpiry := expiry.UTC().Add(time.Hour)
+	startStr := start.Format(sas.TimeFormat)
+	expiryStr := keyExpiry.Format(sas.TimeFormat)
+
+	cred, err := s.service.GetUserDelegationCredential(ctx, service.KeyInfo{Start: &startStr, Expiry: &expiryStr}, nil)
+	if err != nil {
+		return nil, backendError(fmt.Errorf("unable to get user delegation key: %w", err))
+	}
+	s.delegation = cred
+	s.delegationExpiry = keyExpiry
+	return cred, nil
+}
+
+// GetModuleArchive returns the zip archive of the module version, for serving through the registry.
+func (s *AzureBlobStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	addr := path.Join(namespace, name, provider)
+	key := path.Join(addr, version, version+".zip")
+	if !storeutil.IsValidModulePath(key) {
+		return nil, core.NotFoundError("module version path '%s' is not valid", path.Join(addr, version))
+	}
+
+	resp, err := s.container.NewBlobClient(s.archiveKey(addr, version)).DownloadStream(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, addr)
+	}
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+)
+
+// DownloadMode selects how clients download module archives and provider packages.
+type DownloadMode = storeutil.DownloadMode
+
+const (
+	// DownloadGCS returns `gcs::` module source URLs, downloaded by Terraform with the Google
+	// credentials of the client. Provider packages are downloaded from their public HTTPS URLs,
+	// as Terraform only downloads providers over HTTP.
+	DownloadGCS DownloadMode = "gcs"
+	// DownloadSigned returns short-lived signed HTTPS URLs, so clients need no Google credentials.
+	DownloadSigned DownloadMode = "signed"
+	// DownloadProxy returns URLs of the registry `/download/` routes, which serve the files from
+	// the bucket. The routes are protected by the download token of the registry.
+	DownloadProxy = storeutil.DownloadProxy
+)
+
+// DefaultSignedURLTTL is how long signed download URLs are valid.
+const DefaultSignedURLTTL = 15 * time.Minute
+
+// ParseDownloadMode returns the download mode named `s`.
+func ParseDownloadMode(s string) (DownloadMode, error) {
+	return storeutil.ParseDownloadMode(s, DownloadGCS, DownloadSigned, DownloadProxy)
+}
+
+// SetDownloadMode sets how clients download module archives and provider packages. `ttl` is how
+// long signed URLs are valid, and defaults to `DefaultSignedURLTTL` when zero. URLs are signed with
+// the service account of the client credentials, either with its private key or through the IAM
+// `signBlob` API, which requires the `iam.serviceAccounts.signBlob` permission.
+func (s *GCSStore) SetDownloadMode(mode DownloadMode, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = DefaultSignedURLTTL
+	}
+
+	s.mut.Lock()
+	s.downloadMode = mode
+	s.signedURLTTL = ttl
+	s.mut.Unlock()
+}
+
+func (s *GCSStore) getDownloadMode() (DownloadMode, time.Duration) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.downloadMode, s.signedURLTTL
+}
+
+// signedURL returns a URL for downloading the object at `key` without credentials.
+func (s *GCSStore) signedURL(key string, ttl time.Duration) (string, error) {
+	s.mut.RLock()
+	opts := &storage.SignedURLOptions{
+		Scheme:         storage.SigningSchemeV4,
+		Method:         http.MethodGet,
+		Expires:        time.Now().Add(ttl),
+		GoogleAccessID: s.googleAccessID,
+		PrivateKey:     s.privateKey,
+	}
+	s.mut.RUnlock()
+
+	u, err := s.client.Bucket(s.bucket).SignedURL(key, opts)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign download URL: %w", err)
+	}
+	return u, nil
+}
+
+// publicURL returns the public HTTPS URL of the object at `key`.
+func (s *GCSStore) publicURL(key string) string {
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + s.bucket + "/" + key}
+	return u.String()
+}
+
+// withModuleDownloadURL returns the module version with the source URL of the download mode.
+func (s *GCSStore) withModuleDownloadURL(address string, ver *core.ModuleVersion) (*core.ModuleVersion, error) {
+	mode, ttl := s.getDownloadMode()
+	prefix, _ := s.keyPrefixes()
+
+	switch mode {
+	case DownloadSigned:
+		u, err := s.signedURL(prefix+path.Join(address, ver.Version, ver.Version+".zip"), ttl)
+		if err != nil {
+			return nil, err
+		}
+		return storeutil.WithSourceURL(ver, u), nil
+	case DownloadProxy:
+		return storeutil.WithSourceURL(ver, storeutil.ModuleProxyURL(address, ver.Version, "zip")), nil
+	}
+	return ver, nil
+}
+
+// GetModuleArchive returns the zip archive of the module version, for serving through the registry.
+func (s *GCSStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	prefix, _ := s.keyPrefixes()
+	addr := path.Join(namespace, name, provider)
+	key := path.Join(addr, version, version+".zip")
+	if !storeutil.IsValidModulePath(key) {
+		return nil, core.NotFoundError("module version path '%s' is not valid", path.Join(addr, version))
+	}
+
+	r, err := s.client.Bucket(s.bucket).Object(prefix + key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, addr)
+	}
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return r, nil
+}
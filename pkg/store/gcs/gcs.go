@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStore is a module and provider store backed by a Google Cloud Storage bucket.
+// Modules are stored with the same key layout as the S3 store, i.e.
+// `{namespace}/{name}/{provider}/{version}/{version}.zip`, and providers as the release
+// files built by GoReleaser, i.e. `{namespace}/{name}/{version}/terraform-provider-{name}_{version}_{os}_{arch}.zip`.
+type GCSStore struct {
+	client *storage.Client
+	bucket string
+	// Prefixes of the module and provider keys in the bucket. Either empty or ending with a slash.
+	prefix         string
+	providerPrefix string
+	logger         *zap.Logger
+	mut            sync.RWMutex
+
+	// How clients download module archives and provider packages. Defaults to `DownloadGCS`.
+	downloadMode DownloadMode
+	signedURLTTL time.Duration
+	// Credentials for signing URLs. Detected from the client credentials when empty.
+	googleAccessID string
+	privateKey     []byte
+
+	// Provider releases by the key of their version directory, as the files must be downloaded to be
+	// verified. Releases are verified again when the generations of their files change.
+	releaseCache map[string]*providerRelease
+}
+
+// NewGCSStore returns a store for `bucket`.
+func NewGCSStore(client *storage.Client, bucket string, logger *zap.Logger) *GCSStore {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &GCSStore{
+		client:       client,
+		bucket:       bucket,
+		logger:       logger,
+		downloadMode: DownloadGCS,
+		signedURLTTL: DefaultSignedURLTTL,
+		releaseCache: make(map[string]*providerRelease),
+	}
+}
+
+// SetKeyPrefix sets the prefix of the module keys in the bucket, for buckets shared with other content.
+// With the prefix `terraform/modules`, module archives are expected at
+// `terraform/modules/{namespace}/{name}/{provider}/{version}/{version}.zip`.
+func (s *GCSStore) SetKeyPrefix(prefix string) {
+	s.mut.Lock()
+	s.prefix = storeutil.NormalizePrefix(prefix)
+	s.mut.Unlock()
+}
+
+// SetProviderKeyPrefix sets the prefix of the provider keys in the bucket. With the prefix
+// `terraform/providers`, provider packages are expected at
+// `terraform/providers/{namespace}/{name}/{version}/terraform-provider-{name}_{version}_{os}_{arch}.zip`.
+func (s *GCSStore) SetProviderKeyPrefix(prefix string) {
+	s.mut.Lock()
+	s.providerPrefix = storeutil.NormalizePrefix(prefix)
+	s.mut.Unlock()
+}
+
+func (s *GCSStore) keyPrefixes() (string, string) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.prefix, s.providerPrefix
+}
+
+// listObjects calls `fn` with each object in the bucket with a key starting with `prefix`.
+func (s *GCSStore) listObjects(ctx context.Context, prefix string, fn func(o *storage.ObjectAttrs)) error {
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Generation", "Size", "Updated"}); err != nil {
+		return err
+	}
+
+	it := s.client.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		o, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return backendError(err)
+		}
+		fn(o)
+	}
+}
+
+// moduleVersion returns the module version of the archive at `key`, relative to the key prefix.
+func (s *GCSStore) moduleVersion(prefix, key string, updated time.Time) *core.ModuleVersion {
+	ver := &core.ModuleVersion{
+		Version:   strings.Split(key, "/")[3],
+		SourceURL: fmt.Sprintf("gcs::https://www.googleapis.com/storage/v1/%s/%s", s.bucket, prefix+key),
+	}
+	if !updated.IsZero() {
+		ver.Metadata = &core.ModuleMetadata{PublishedAt: updated.UTC()}
+	}
+	return ver
+}
+
+func (s *GCSStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
+	prefix, _ := s.keyPrefixes()
+	addr := path.Join(namespace, name, provider)
+
+	vers := make([]*core.ModuleVersion, 0)
+	err := s.listObjects(ctx, prefix+addr+"/", func(o *storage.ObjectAttrs) {
+		key := strings.TrimPrefix(o.Name, prefix)
+		if storeutil.IsValidModulePath(key) {
+			vers = append(vers, s.moduleVersion(prefix, key, o.Updated))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vers) == 0 {
+		return nil, core.NotFoundError("module '%s' not found", addr)
+	}
+
+	return vers, nil
+}
+
+func (s *GCSStore) GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*core.ModuleVersion, error) {
+	prefix, _ := s.keyPrefixes()
+	addr := path.Join(namespace, name, provider)
+
+	key := path.Join(addr, version, version+".zip")
+	if !storeutil.IsValidModulePath(key) {
+		s.logger.Warn("invalid module path requested: " + key)
+		return nil, core.NotFoundError("module version path '%s' is not valid", path.Join(addr, version))
+	}
+
+	attrs, err := s.client.Bucket(s.bucket).Object(prefix + key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, addr)
+	}
+	if err != nil {
+		return nil, backendError(err)
+	}
+
+	return s.withModuleDownloadURL(addr, s.moduleVersion(prefix, key, attrs.Updated))
+}
+
+// ListModules returns all modules in the bucket, sorted by address.
+func (s *GCSStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	prefix, _ := s.keyPrefixes()
+	modules := storeutil.NewModuleLister(prefix)
+	err := s.listObjects(ctx, prefix, func(o *storage.ObjectAttrs) {
+		modules.Add(o.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modules.Modules(), nil
+}
+
+// backendError classifies an error returned by the Cloud Storage API, by the status code of the response.
+func backendError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return core.BackendError(err, http.StatusNotFound)
+	}
+	return storeutil.BackendError(err, func(e *googleapi.Error) int { return e.Code })
+}
@@ -0,0 +1,488 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package gcs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storetest"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+)
+
+const testBucket = "test-bucket"
+
+// fakeGCSServer is a minimal Google Cloud Storage JSON and XML API serving objects from memory.
+type fakeGCSServer struct {
+	*httptest.Server
+	mut         sync.Mutex
+	objects     map[string][]byte
+	generations map[string]int64
+	// Number of object downloads.
+	reads atomic.Int32
+}
+
+func (srv *fakeGCSServer) put(name string, content []byte) {
+	srv.mut.Lock()
+	defer srv.mut.Unlock()
+	srv.objects[name] = content
+	srv.generations[name]++
+}
+
+func (srv *fakeGCSServer) remove(name string) {
+	srv.mut.Lock()
+	defer srv.mut.Unlock()
+	delete(srv.objects, name)
+}
+
+func (srv *fakeGCSServer) attrs(name string) map[string]string {
+	return map[string]string{
+		"kind":       "storage#object",
+		"bucket":     testBucket,
+		"name":       name,
+		"generation": strconv.FormatInt(srv.generations[name], 10),
+		"size":       strconv.Itoa(len(srv.objects[name])),
+		"updated":    "2025-03-01T12:00:00Z",
+	}
+}
+
+func newFakeGCSServer(t *testing.T, objects map[string][]byte) *fakeGCSServer {
+	srv := &fakeGCSServer{objects: make(map[string][]byte), generations: make(map[string]int64)}
+	for name, content := range objects {
+		srv.put(name, content)
+	}
+
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mut.Lock()
+		defer srv.mut.Unlock()
+
+		query := r.URL.Query()
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		// Object listings and metadata are read with the JSON API
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/storage/v1/b/"+testBucket+"/o"); ok {
+			if rest == "" {
+				var names []string
+				for name := range srv.objects {
+					if strings.HasPrefix(name, query.Get("prefix")) {
+						names = append(names, name)
+					}
+				}
+				sort.Strings(names)
+
+				start, _ := strconv.Atoi(query.Get("pageToken"))
+				res := map[string]any{"kind": "storage#objects"}
+				var items []map[string]string
+				for i := start; i < len(names) && i < start+2; i++ {
+					items = append(items, srv.attrs(names[i]))
+				}
+				res["items"] = items
+				if start+2 < len(names) {
+					res["nextPageToken"] = strconv.Itoa(start + 2)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(res)
+				return
+			}
+
+			name, _ := url.PathUnescape(strings.TrimPrefix(rest, "/"))
+			if _, ok := srv.objects[name]; !ok {
+				http.Error(w, `{"error":{"code":404,"message":"No such object"}}`, http.StatusNotFound)
+				return
+			}
+			if query.Get("alt") == "media" {
+				srv.reads.Add(1)
+				w.Write(srv.objects[name])
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(srv.attrs(name))
+			return
+		}
+
+		// Object contents are read with the XML API
+		name, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+		if !ok {
+			t.Errorf("unexpected request path '%s'", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		content, ok := srv.objects[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		srv.reads.Add(1)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("X-Goog-Generation", strconv.FormatInt(srv.generations[name], 10))
+		w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newTestStore(t *testing.T, srv *fakeGCSServer) *GCSStore {
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(srv.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return NewGCSStore(client, testBucket, zap.NewNop())
+}
+
+// testProviderRelease returns the files of a signed release of `terraform-provider-test` with packages
+// for `platforms`, keyed by their paths in the version directory `dir`.
+func testProviderRelease(t *testing.T, key *openpgp.Entity, armoredKey []byte, dir string, platforms ...string) map[string][]byte {
+	release := storetest.NewProviderRelease(t, key, strings.TrimPrefix(dir, "v"), platforms...)
+	files := map[string][]byte{
+		dir + "/gpg-public-key.pem": armoredKey,
+	}
+	for _, f := range release.Files() {
+		files[dir+"/"+f.Name] = f.Content
+	}
+	return files
+}
+
+// withPrefix returns the files with their paths prefixed by `prefix`.
+func withPrefix(prefix string, files map[string][]byte) map[string][]byte {
+	res := make(map[string][]byte, len(files))
+	for name, content := range files {
+		res[prefix+name] = content
+	}
+	return res
+}
+
+var testModules = map[string][]byte{
+	"testnamespace/testname/testprovider/1.0.0/1.0.0.zip": []byte("archive 1.0.0"),
+	"testnamespace/testname/testprovider/2.0.0/2.0.0.zip": []byte("archive 2.0.0"),
+	"testnamespace/other/testprovider/0.1.0/0.1.0.zip":    []byte("archive 0.1.0"),
+	"testnamespace/testname/testprovider/latest.txt":      []byte("2.0.0"),
+}
+
+func TestListModuleVersions(t *testing.T) {
+	is := is.New(t)
+	store := newTestStore(t, newFakeGCSServer(t, testModules))
+
+	versions, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(len(versions), 2)
+	is.Equal(versions[0].Version, "1.0.0")
+	is.Equal(versions[0].SourceURL, "gcs::https://www.googleapis.com/storage/v1/test-bucket/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")
+	is.Equal(versions[0].Metadata.PublishedAt, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	is.Equal(versions[1].Version, "2.0.0")
+
+	_, err = store.ListModuleVersions(context.Background(), "testnamespace", "missing", "testprovider")
+	is.Equal(err.Error(), "module 'testnamespace/missing/testprovider' not found")
+	is.True(errors.Is(err, core.ErrNotFound))
+}
+
+func TestGetModuleVersion(t *testing.T) {
+	is := is.New(t)
+	store := newTestStore(t, newFakeGCSServer(t, testModules))
+
+	ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+	is.NoErr(err)
+	is.Equal(ver.Version, "2.0.0")
+	is.Equal(ver.SourceURL, "gcs::https://www.googleapis.com/storage/v1/test-bucket/testnamespace/testname/testprovider/2.0.0/2.0.0.zip")
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "3.0.0")
+	is.Equal(err.Error(), "version '3.0.0' not found for module 'testnamespace/testname/testprovider'")
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "latest")
+	is.Equal(err.Error(), "module version path 'testnamespace/testname/testprovider/latest' is not valid")
+	is.True(errors.Is(err, core.ErrNotFound))
+}
+
+func TestBackendErrors(t *testing.T) {
+	is := is.New(t)
+	srv := &fakeGCSServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":403,"message":"Permission denied"}}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+	store := newTestStore(t, srv)
+
+	// The credentials of the store are rejected, which is not the fault of the client
+	_, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.True(errors.Is(err, core.ErrUnavailable))
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+	is.True(errors.Is(err, core.ErrUnavailable))
+}
+
+func TestListModules(t *testing.T) {
+	is := is.New(t)
+	store := newTestStore(t, newFakeGCSServer(t, testModules))
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "testnamespace", Name: "other", Provider: "testprovider"},
+		{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+	})
+}
+
+func TestKeyPrefix(t *testing.T) {
+	is := is.New(t)
+	key, armoredKey := storetest.NewKey(t)
+	objects := withPrefix("terraform/modules/", testModules)
+	for name, content := range withPrefix("terraform/providers/test/test/", testProviderRelease(t, key, armoredKey, "v1.0.0", "linux_amd64")) {
+		objects[name] = content
+	}
+	objects["unrelated/a/b/1.0.0/1.0.0.zip"] = []byte("archive")
+	store := newTestStore(t, newFakeGCSServer(t, objects))
+	store.SetKeyPrefix("/terraform/modules/")
+	store.SetProviderKeyPrefix("terraform/providers")
+
+	ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+	is.NoErr(err)
+	is.Equal(ver.SourceURL, "gcs::https://www.googleapis.com/storage/v1/test-bucket/terraform/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(len(modules), 2)
+
+	providers, err := store.ListProviders(context.Background())
+	is.NoErr(err)
+	is.Equal(providers, []core.ProviderAddress{{Namespace: "test", Name: "test"}})
+
+	provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "linux", "amd64")
+	is.NoErr(err)
+	is.Equal(provider.DownloadURL, "https://storage.googleapis.com/test-bucket/terraform/providers/test/test/v1.0.0/terraform-provider-test_1.0.0_linux_amd64.zip")
+}
+
+func TestProviders(t *testing.T) {
+	is := is.New(t)
+	key, armoredKey := storetest.NewKey(t)
+	otherKey, _ := storetest.NewKey(t)
+
+	objects := testProviderRelease(t, key, armoredKey, "v1.0.0", "linux_amd64", "darwin_arm64")
+	for name, content := range testProviderRelease(t, key, armoredKey, "1.1.0", "linux_amd64") {
+		objects[name] = content
+	}
+	objects["1.1.0/terraform-provider-test_1.1.0_manifest.json"] = []byte(`{"version":1,"metadata":{"protocol_versions":["6.0"]}}`)
+	// Signed with a key other than the included one
+	for name, content := range testProviderRelease(t, otherKey, armoredKey, "2.0.0", "linux_amd64") {
+		objects[name] = content
+	}
+	// Missing signature
+	for name, content := range testProviderRelease(t, key, armoredKey, "3.0.0", "linux_amd64") {
+		if !strings.HasSuffix(name, ".sig") {
+			objects[name] = content
+		}
+	}
+	objects = withPrefix("test/test/", objects)
+	objects["test/test/notes.txt"] = []byte("not a release")
+	for name, content := range testModules {
+		objects[name] = content
+	}
+	srv := newFakeGCSServer(t, objects)
+	store := newTestStore(t, srv)
+
+	t.Run("list versions", func(t *testing.T) {
+		is := is.New(t)
+
+		versions, err := store.ListProviderVersions(context.Background(), "test", "test")
+		is.NoErr(err)
+		is.Equal(versions.Versions, []core.ProviderVersion{
+			{Version: "1.0.0", Protocols: []string{"5.0"}, Platforms: []core.Platform{{OS: "darwin", Arch: "arm64"}, {OS: "linux", Arch: "amd64"}}},
+			{Version: "1.1.0", Protocols: []string{"6.0"}, Platforms: []core.Platform{{OS: "linux", Arch: "amd64"}}},
+		})
+
+		_, err = store.ListProviderVersions(context.Background(), "test", "missing")
+		is.Equal(err.Error(), "provider 'test/missing' not found")
+		is.True(errors.Is(err, core.ErrNotFound))
+	})
+
+	t.Run("releases are cached", func(t *testing.T) {
+		is := is.New(t)
+
+		reads := srv.reads.Load()
+		_, err := store.ListProviderVersions(context.Background(), "test", "test")
+		is.NoErr(err)
+		is.Equal(srv.reads.Load(), reads)
+
+		// Changed files are downloaded and verified again
+		srv.put("test/test/1.1.0/terraform-provider-test_1.1.0_manifest.json", []byte(`{"version":1,"metadata":{"protocol_versions":["5.0","6.0"]}}`))
+		versions, err := store.ListProviderVersions(context.Background(), "test", "test")
+		is.NoErr(err)
+		is.True(srv.reads.Load() > reads)
+		is.Equal(versions.Versions[1].Protocols, []string{"5.0", "6.0"})
+	})
+
+	t.Run("versions are sorted by semver", func(t *testing.T) {
+		is := is.New(t)
+		key, armoredKey := storetest.NewKey(t)
+		objects := make(map[string][]byte)
+		for _, version := range []string{"1.10.0", "1.9.0", "1.9.0-rc.1"} {
+			for name, content := range testProviderRelease(t, key, armoredKey, version, "linux_amd64") {
+				objects["sorted/test/"+name] = content
+			}
+		}
+		srv := newFakeGCSServer(t, objects)
+		store := newTestStore(t, srv)
+
+		versions, err := store.ListProviderVersions(context.Background(), "sorted", "test")
+		is.NoErr(err)
+		is.Equal(len(versions.Versions), 3)
+		is.Equal(versions.Versions[0].Version, "1.9.0-rc.1")
+		is.Equal(versions.Versions[1].Version, "1.9.0")
+		is.Equal(versions.Versions[2].Version, "1.10.0")
+
+		// Removed releases are evicted from the cache
+		for name := range objects {
+			if strings.HasPrefix(name, "sorted/test/1.10.0/") {
+				srv.remove(name)
+			}
+		}
+		versions, err = store.ListProviderVersions(context.Background(), "sorted", "test")
+		is.NoErr(err)
+		is.Equal(len(versions.Versions), 2)
+		is.Equal(len(store.releaseCache), 2)
+	})
+
+	t.Run("get version", func(t *testing.T) {
+		is := is.New(t)
+
+		provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "darwin", "arm64")
+		is.NoErr(err)
+		is.Equal(provider.Filename, "terraform-provider-test_1.0.0_darwin_arm64.zip")
+		is.Equal(provider.DownloadURL, "https://storage.googleapis.com/test-bucket/test/test/v1.0.0/terraform-provider-test_1.0.0_darwin_arm64.zip")
+		is.Equal(provider.SHASumsURL, "https://storage.googleapis.com/test-bucket/test/test/v1.0.0/terraform-provider-test_1.0.0_SHA256SUMS")
+		is.Equal(provider.SHASumsSignatureURL, "https://storage.googleapis.com/test-bucket/test/test/v1.0.0/terraform-provider-test_1.0.0_SHA256SUMS.sig")
+		sum := sha256.Sum256([]byte("package terraform-provider-test_1.0.0_darwin_arm64.zip"))
+		is.Equal(provider.SHASum, hex.EncodeToString(sum[:]))
+		is.Equal(provider.SigningKeys.GPGPublicKeys, []core.GpgPublicKeys{{KeyID: key.PrimaryKey.KeyIdString(), ASCIIArmor: string(armoredKey)}})
+
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "windows", "amd64")
+		is.Equal(err.Error(), "provider 'test/test/1.0.0' not found for windows_amd64")
+
+		// Releases without a valid signature are not served
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "2.0.0", "linux", "amd64")
+		is.Equal(err.Error(), "provider version '2.0.0' not found")
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "3.0.0", "linux", "amd64")
+		is.Equal(err.Error(), "provider version '3.0.0' not found")
+	})
+
+	t.Run("get asset", func(t *testing.T) {
+		is := is.New(t)
+
+		r, err := store.GetProviderAsset(context.Background(), "test", "test", "v1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		is.NoErr(err)
+		is.Equal(string(b), "package terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.Equal(r.(core.AssetSizer).Size(), int64(len(b)))
+
+		_, err = store.GetProviderAsset(context.Background(), "test", "test", "v1.0.0", "gpg-public-key.pem")
+		is.Equal(err.Error(), "asset 'gpg-public-key.pem' not found for provider 'test/test/v1.0.0'")
+		_, err = store.GetProviderAsset(context.Background(), "test", "test", "2.0.0", "terraform-provider-test_2.0.0_linux_amd64.zip")
+		is.Equal(err.Error(), "provider version '2.0.0' not found")
+	})
+
+	t.Run("list providers", func(t *testing.T) {
+		is := is.New(t)
+
+		providers, err := store.ListProviders(context.Background())
+		is.NoErr(err)
+		is.Equal(providers, []core.ProviderAddress{{Namespace: "test", Name: "test"}})
+
+		modules, err := store.ListModules(context.Background())
+		is.NoErr(err)
+		is.Equal(len(modules), 2)
+	})
+}
+
+func TestDownloadModes(t *testing.T) {
+	key, armoredKey := storetest.NewKey(t)
+	objects := withPrefix("test/test/", testProviderRelease(t, key, armoredKey, "1.0.0", "linux_amd64"))
+	for name, content := range testModules {
+		objects[name] = content
+	}
+	store := newTestStore(t, newFakeGCSServer(t, objects))
+
+	t.Run("signed", func(t *testing.T) {
+		is := is.New(t)
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		is.NoErr(err)
+		store.googleAccessID = "registry@test-project.iam.gserviceaccount.com"
+		store.privateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+		store.SetDownloadMode(DownloadSigned, 5*time.Minute)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		u, err := url.Parse(ver.SourceURL)
+		is.NoErr(err)
+		is.True(strings.HasSuffix(u.Path, "/test-bucket/testnamespace/testname/testprovider/1.0.0/1.0.0.zip"))
+		is.True(u.Query().Get("X-Goog-Expires") != "")
+		is.True(u.Query().Get("X-Goog-Signature") != "")
+
+		provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		u, err = url.Parse(provider.DownloadURL)
+		is.NoErr(err)
+		is.True(strings.HasSuffix(u.Path, "/test-bucket/test/test/1.0.0/terraform-provider-test_1.0.0_linux_amd64.zip"))
+		is.True(u.Query().Get("X-Goog-Signature") != "")
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		is := is.New(t)
+		store.SetDownloadMode(DownloadProxy, 0)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "/download/module/testnamespace/testname/testprovider/1.0.0?archive=zip")
+
+		r, err := store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		is.NoErr(err)
+		is.Equal(string(b), "archive 1.0.0")
+
+		_, err = store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "3.0.0")
+		is.Equal(err.Error(), "version '3.0.0' not found for module 'testnamespace/testname/testprovider'")
+
+		provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		is.Equal(provider.DownloadURL, "/download/provider/test/test/1.0.0/asset/terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.Equal(provider.SHASumsURL, "/download/provider/test/test/1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS")
+		is.Equal(provider.SHASumsSignatureURL, "/download/provider/test/test/1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS.sig")
+	})
+}
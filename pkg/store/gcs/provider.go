@@ -0,0 +1,389 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	goversion "github.com/hashicorp/go-version"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	"go.uber.org/zap"
+)
+
+// maxReleaseFileSize limits the size of the checksum, signature, key and manifest files.
+const maxReleaseFileSize = 1 << 20
+
+// providerRelease is a verified provider release, or the reason it is not valid.
+type providerRelease struct {
+	// Name of the version directory, which may be prefixed with `v`.
+	dir       string
+	version   string
+	semver    *goversion.Version
+	protocols []string
+	platforms []core.Platform
+	// Package file names by `{os}_{arch}`.
+	packages map[string]string
+	sums     map[string]string
+	sumsFile string
+	keys     []core.GpgPublicKeys
+	// Why the release is not valid, if it isn't.
+	invalid string
+	// Names and generations of the files of the release, to detect changes.
+	generations string
+}
+
+// hasFile returns whether `name` is one of the files of the release served to clients.
+func (r *providerRelease) hasFile(name string) bool {
+	if name == r.sumsFile || name == r.sumsFile+".sig" {
+		return true
+	}
+	for _, pkg := range r.packages {
+		if pkg == name {
+			return true
+		}
+	}
+	return false
+}
+
+// providerReleases lists the provider in the bucket, and returns its releases by version directory.
+func (s *GCSStore) providerReleases(ctx context.Context, namespace, name string) (map[string]*providerRelease, error) {
+	_, prefix := s.keyPrefixes()
+	addr := path.Join(namespace, name)
+
+	objects := make(map[string][]*storage.ObjectAttrs)
+	var dirs []string
+	err := s.listObjects(ctx, prefix+addr+"/", func(o *storage.ObjectAttrs) {
+		dir, file, ok := strings.Cut(strings.TrimPrefix(o.Name, prefix+addr+"/"), "/")
+		if !ok || strings.Contains(file, "/") || !storeutil.VersionTagRegex.MatchString(dir) {
+			return
+		}
+		if _, ok := objects[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		objects[dir] = append(objects[dir], o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, core.NotFoundError("provider '%s' not found", addr)
+	}
+
+	releases := make(map[string]*providerRelease, len(dirs))
+	for _, dir := range dirs {
+		release, err := s.loadProviderRelease(ctx, namespace, name, prefix+addr+"/"+dir, objects[dir])
+		if err != nil {
+			return nil, err
+		}
+		releases[dir] = release
+	}
+
+	// Forget releases that have been removed from the bucket
+	s.mut.Lock()
+	for key := range s.releaseCache {
+		dir, ok := strings.CutPrefix(key, prefix+addr+"/")
+		if ok && releases[dir] == nil {
+			delete(s.releaseCache, key)
+		}
+	}
+	s.mut.Unlock()
+
+	return releases, nil
+}
+
+// loadProviderRelease verifies the provider release in the version directory at `key` with `objects`.
+// Releases are cached with the generations of their files, so they are only downloaded again when changed.
+func (s *GCSStore) loadProviderRelease(ctx context.Context, namespace, name, key string, objects []*storage.ObjectAttrs) (*providerRelease, error) {
+	var generations strings.Builder
+	files := make(map[string]*storage.ObjectAttrs, len(objects))
+	for _, o := range objects {
+		files[path.Base(o.Name)] = o
+		fmt.Fprintf(&generations, "%s@%d,", o.Name, o.Generation)
+	}
+
+	s.mut.RLock()
+	release, ok := s.releaseCache[key]
+	s.mut.RUnlock()
+	if ok && release.generations == generations.String() {
+		return release, nil
+	}
+
+	dir := path.Base(key)
+	version := strings.TrimPrefix(dir, "v")
+	release = &providerRelease{
+		dir:         dir,
+		version:     version,
+		semver:      goversion.Must(goversion.NewSemver(version)),
+		packages:    make(map[string]string),
+		sumsFile:    fmt.Sprintf("terraform-provider-%s_%s_SHA256SUMS", name, version),
+		generations: generations.String(),
+	}
+	if err := s.verifyProviderRelease(ctx, release, name, files); err != nil {
+		var invalid storeutil.InvalidReleaseError
+		if !errors.As(err, &invalid) {
+			return nil, err
+		}
+		release.invalid = invalid.Reason
+		s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s/%s] - %s", namespace, name, version, invalid.Reason))
+	}
+
+	s.mut.Lock()
+	s.releaseCache[key] = release
+	s.mut.Unlock()
+
+	return release, nil
+}
+
+// verifyProviderRelease reads the checksums, signature, GPG public key and manifest of the release,
+// and verifies the signature of the checksums.
+func (s *GCSStore) verifyProviderRelease(ctx context.Context, release *providerRelease, name string, files map[string]*storage.ObjectAttrs) error {
+	var keyFile string
+	for file := range files {
+		if strings.Contains(file, "gpg-public-key.pem") {
+			keyFile = file
+		}
+	}
+	if keyFile == "" {
+		return storeutil.InvalidReleaseError{Reason: "unable to get GPG Public Key"}
+	}
+	for _, file := range []string{release.sumsFile, release.sumsFile + ".sig"} {
+		if files[file] == nil {
+			return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("could not find '%s'", file)}
+		}
+	}
+
+	sums, err := s.readObject(ctx, files[release.sumsFile])
+	if err != nil {
+		return err
+	}
+	sig, err := s.readObject(ctx, files[release.sumsFile+".sig"])
+	if err != nil {
+		return err
+	}
+	armor, err := s.readObject(ctx, files[keyFile])
+	if err != nil {
+		return err
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armor))
+	if err != nil || len(keyring) != 1 {
+		return storeutil.InvalidReleaseError{Reason: "unable to get GPG Public Key"}
+	}
+	if _, err := storeutil.CheckDetachedSignature(keyring, sums, sig); err != nil {
+		return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("SHA checksums signature not valid for GPG Public Key '%s': %s", keyring[0].PrimaryKey.KeyIdString(), err)}
+	}
+	release.keys = []core.GpgPublicKeys{{KeyID: keyring[0].PrimaryKey.KeyIdString(), ASCIIArmor: string(armor)}}
+	release.sums = storeutil.ParseSHASums(sums)
+
+	// Provider Protocol version should be set in the manifest. If not present, default is 5.0 according to Terraform docs.
+	// https://developer.hashicorp.com/terraform/registry/providers/publishing
+	release.protocols = []string{"5.0"}
+	if manifestAttrs := files[fmt.Sprintf("terraform-provider-%s_%s_manifest.json", name, release.version)]; manifestAttrs != nil {
+		b, err := s.readObject(ctx, manifestAttrs)
+		if err != nil {
+			return err
+		}
+		manifest := &core.ProviderManifest{}
+		if err := json.Unmarshal(b, manifest); err != nil {
+			return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("unable to decode manifest: %s", err)}
+		}
+		release.protocols = manifest.Metadata.ProtocolVersions
+	}
+
+	packagePrefix := fmt.Sprintf("terraform-provider-%s_%s_", name, release.version)
+	for file := range files {
+		osArch, ok := strings.CutPrefix(file, packagePrefix)
+		if !ok || !strings.HasSuffix(osArch, ".zip") {
+			continue
+		}
+		os, arch, ok := strings.Cut(strings.TrimSuffix(osArch, ".zip"), "_")
+		if !ok {
+			continue
+		}
+		if _, ok := release.sums[file]; !ok {
+			s.logger.Warn("provider package not listed in SHA checksums", zap.String("file", file))
+			continue
+		}
+		release.packages[os+"_"+arch] = file
+		release.platforms = append(release.platforms, core.Platform{OS: os, Arch: arch})
+	}
+	sort.Slice(release.platforms, func(i, j int) bool {
+		return release.platforms[i].OS+"_"+release.platforms[i].Arch < release.platforms[j].OS+"_"+release.platforms[j].Arch
+	})
+	return nil
+}
+
+// readObject returns the contents of a small object.
+func (s *GCSStore) readObject(ctx context.Context, attrs *storage.ObjectAttrs) ([]byte, error) {
+	if attrs.Size > maxReleaseFileSize {
+		return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("'%s' is larger than %d bytes", path.Base(attrs.Name), maxReleaseFileSize)}
+	}
+
+	r, err := s.client.Bucket(s.bucket).Object(attrs.Name).Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, maxReleaseFileSize))
+}
+
+func (s *GCSStore) ListProviderVersions(ctx context.Context, namespace string, name string) (*core.ProviderVersions, error) {
+	releases, err := s.providerReleases(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make([]*providerRelease, 0, len(releases))
+	for _, release := range releases {
+		if release.invalid == "" && len(release.platforms) > 0 {
+			valid = append(valid, release)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i].semver.LessThan(valid[j].semver)
+	})
+
+	versions := &core.ProviderVersions{Versions: make([]core.ProviderVersion, 0, len(valid))}
+	for _, release := range valid {
+		versions.Versions = append(versions.Versions, core.ProviderVersion{
+			Version:   release.version,
+			Protocols: release.protocols,
+			Platforms: release.platforms,
+		})
+	}
+	return versions, nil
+}
+
+// findProviderRelease returns the valid release of the provider version.
+func (s *GCSStore) findProviderRelease(ctx context.Context, namespace, name, version string) (*providerRelease, error) {
+	releases, err := s.providerReleases(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if release.version == strings.TrimPrefix(version, "v") && release.invalid == "" {
+			return release, nil
+		}
+	}
+	return nil, core.NotFoundError("provider version '%s' not found", version)
+}
+
+func (s *GCSStore) GetProviderVersion(ctx context.Context, namespace string, name string, version string, os string, arch string) (*core.Provider, error) {
+	release, err := s.findProviderRelease(ctx, namespace, name, version)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := release.packages[os+"_"+arch]
+	if !ok {
+		return nil, core.NotFoundError("provider '%s/%s/%s' not found for %s_%s", namespace, name, version, os, arch)
+	}
+
+	_, prefix := s.keyPrefixes()
+	dir := prefix + path.Join(namespace, name, release.dir)
+	downloadURL, err := s.providerFileURL(namespace, name, release.dir, dir, file)
+	if err != nil {
+		return nil, err
+	}
+	sumsURL, err := s.providerFileURL(namespace, name, release.dir, dir, release.sumsFile)
+	if err != nil {
+		return nil, err
+	}
+	sigURL, err := s.providerFileURL(namespace, name, release.dir, dir, release.sumsFile+".sig")
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.Provider{
+		Protocols:           release.protocols,
+		OS:                  os,
+		Arch:                arch,
+		Filename:            file,
+		DownloadURL:         downloadURL,
+		SHASumsURL:          sumsURL,
+		SHASumsSignatureURL: sigURL,
+		SHASum:              release.sums[file],
+		SigningKeys:         core.SigningKeys{GPGPublicKeys: release.keys},
+	}, nil
+}
+
+// providerFileURL returns the download URL of the release file `file` in the directory `dir`.
+func (s *GCSStore) providerFileURL(namespace, name, version, dir, file string) (string, error) {
+	mode, ttl := s.getDownloadMode()
+	switch mode {
+	case DownloadSigned:
+		return s.signedURL(dir+"/"+file, ttl)
+	case DownloadProxy:
+		return storeutil.ProviderProxyURL(namespace, name, version, file), nil
+	}
+	return s.publicURL(dir + "/" + file), nil
+}
+
+// sizedReader is a storage reader that knows its size, for the Content-Length of asset downloads.
+type sizedReader struct {
+	*storage.Reader
+}
+
+func (r sizedReader) Size() int64 {
+	return r.Attrs.Size
+}
+
+// GetProviderAsset returns the contents of a file of a verified provider release.
+func (s *GCSStore) GetProviderAsset(ctx context.Context, namespace string, name string, tag string, asset string) (io.ReadCloser, error) {
+	release, err := s.findProviderRelease(ctx, namespace, name, tag)
+	if err != nil {
+		return nil, err
+	}
+	if release.dir != tag || !release.hasFile(asset) {
+		return nil, core.NotFoundError("asset '%s' not found for provider '%s/%s/%s'", asset, namespace, name, tag)
+	}
+
+	_, prefix := s.keyPrefixes()
+	r, err := s.client.Bucket(s.bucket).Object(prefix + path.Join(namespace, name, release.dir, asset)).NewReader(ctx)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return sizedReader{r}, nil
+}
+
+// ListProviders returns all providers in the bucket, sorted by address.
+func (s *GCSStore) ListProviders(ctx context.Context) ([]core.ProviderAddress, error) {
+	_, prefix := s.keyPrefixes()
+	seen := make(map[core.ProviderAddress]bool)
+	providers := make([]core.ProviderAddress, 0)
+
+	err := s.listObjects(ctx, prefix, func(o *storage.ObjectAttrs) {
+		parts := strings.Split(strings.TrimPrefix(o.Name, prefix), "/")
+		if len(parts) != 4 || !storeutil.VersionTagRegex.MatchString(parts[2]) || !strings.HasPrefix(parts[3], "terraform-provider-"+parts[1]+"_") {
+			return
+		}
+		addr := core.ProviderAddress{Namespace: parts[0], Name: parts[1]}
+		if !seen[addr] {
+			seen[addr] = true
+			providers = append(providers, addr)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].Namespace != providers[j].Namespace {
+			return providers[i].Namespace < providers[j].Namespace
+		}
+		return providers[i].Name < providers[j].Name
+	})
+	return providers, nil
+}
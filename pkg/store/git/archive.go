@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// GetModuleArchive returns a gzipped tarball with the module source code of the given version.
+// Local repositories are archived directly, while the tag of remote repositories is first fetched
+// into a bare mirror repository.
+func (s *GitStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	ver, err := s.GetModuleVersion(ctx, namespace, name, provider, version)
+	if err != nil {
+		return nil, err
+	}
+
+	key := path.Join(namespace, name, provider, ver.Version)
+	s.moduleMut.RLock()
+	tag, ok := s.moduleTagCache[key]
+	s.moduleMut.RUnlock()
+	if !ok {
+		return nil, core.NotFoundError("tag not found for module version '%s'", key)
+	}
+
+	dir, ok := localPath(tag.repo.URL)
+	if !ok {
+		dir, err = s.fetchTag(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	archive, err := s.streamGit(ctx, dir, "archive", "--format=tar.gz", tag.sha)
+	if err != nil {
+		return nil, core.UnavailableError("unable to archive module version '%s': %w", key, err)
+	}
+	return archive, nil
+}
+
+// fetchTag fetches the tag into the mirror of its repository, unless the commit is already there,
+// and returns the path of the mirror.
+func (s *GitStore) fetchTag(ctx context.Context, tag tagRef) (string, error) {
+	lock := s.fetchLock(tag.repo.URL)
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir, err := s.mirrorPath(tag.repo.URL)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if _, err := s.runGit(ctx, "", "init", "--bare", "--quiet", dir); err != nil {
+			return "", fmt.Errorf("unable to create mirror of '%s': %w", tag.repo.URL, err)
+		}
+	}
+
+	if _, err := s.runGit(ctx, dir, "cat-file", "-e", tag.sha+"^{commit}"); err == nil {
+		return dir, nil
+	}
+
+	s.logger.Debug("fetching tag",
+		zap.String("url", tag.repo.URL),
+		zap.String("tag", tag.tag),
+	)
+	refspec := fmt.Sprintf("+refs/tags/%s:refs/tags/%s", tag.tag, tag.tag)
+	if _, err := s.runGit(ctx, dir, "fetch", "--quiet", "--no-tags", "--", tag.repo.URL, refspec); err != nil {
+		// The remote was reachable when the tag was listed, so it is most likely down or denying access
+		return "", core.UnavailableError("unable to fetch tag '%s' of '%s': %w", tag.tag, tag.repo.URL, err)
+	}
+	return dir, nil
+}
+
+// fetchLock returns the lock of the mirror of the repository at `url`. Fetches of different
+// repositories run concurrently.
+func (s *GitStore) fetchLock(url string) *sync.Mutex {
+	s.fetchMut.Lock()
+	defer s.fetchMut.Unlock()
+
+	lock, ok := s.fetchLocks[url]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.fetchLocks[url] = lock
+	}
+	return lock
+}
+
+// mirrorPath returns the path of the mirror of the repository at `url`, creating a temporary
+// mirror directory if none is configured.
+func (s *GitStore) mirrorPath(url string) (string, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.mirrorDir == "" {
+		dir, err := os.MkdirTemp("", "terraform-registry-git-")
+		if err != nil {
+			return "", fmt.Errorf("unable to create mirror directory: %w", err)
+		}
+		s.mirrorDir = dir
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(s.mirrorDir, hex.EncodeToString(sum[:])+".git"), nil
+}
@@ -0,0 +1,425 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	"go.uber.org/zap"
+)
+
+// GitStore is a module store backed by plain git repositories, for any git host or local mirrors.
+// Versions are discovered from the SemVer tags of the repositories with `git ls-remote`, so it
+// needs the `git` command, and uses its configuration for credentials, such as credential helpers
+// and SSH keys.
+type GitStore struct {
+	logger *zap.Logger
+	// Path of the git command.
+	git string
+
+	mut              sync.RWMutex
+	repos            []Repository
+	sourceTemplate   string
+	archiveDownloads bool
+	// Directory of the bare repositories fetched for archive downloads of remote repositories.
+	mirrorDir string
+
+	moduleMut        sync.RWMutex
+	moduleCache      map[string][]*core.ModuleVersion
+	moduleTagCache   map[string]tagRef
+	moduleReloadedAt time.Time
+
+	// Locks of the mirrors by repository URL, serialising fetches into each mirror.
+	fetchMut   sync.Mutex
+	fetchLocks map[string]*sync.Mutex
+}
+
+// lsRemoteTimeout limits the time spent listing the tags of a repository, so that an unresponsive
+// host does not hold up the reload of the other repositories.
+const lsRemoteTimeout = time.Minute
+
+// tagRef is a tag of a repository, and the commit it points to.
+type tagRef struct {
+	repo Repository
+	tag  string
+	sha  string
+}
+
+// NewGitStore returns a store for `repos`. The caches are empty until `ReloadCache` is called.
+func NewGitStore(repos []Repository, logger *zap.Logger) *GitStore {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &GitStore{
+		logger:         logger,
+		git:            "git",
+		repos:          repos,
+		sourceTemplate: DefaultSourceTemplate,
+		moduleCache:    make(map[string][]*core.ModuleVersion),
+		moduleTagCache: make(map[string]tagRef),
+		fetchLocks:     make(map[string]*sync.Mutex),
+	}
+}
+
+// SetRepositories sets the repositories of the store. Takes effect on the next cache reload.
+func (s *GitStore) SetRepositories(repos []Repository) {
+	s.mut.Lock()
+	s.repos = repos
+	s.mut.Unlock()
+}
+
+// SetSourceTemplate sets the template used to generate module source URLs, for repositories without
+// their own. Templates may contain the placeholders `{url}`, `{tag}`, `{version}` (the tag without
+// the `v` prefix) and `{sha}` (the commit SHA of the tag). Takes effect on the next cache reload.
+func (s *GitStore) SetSourceTemplate(tmpl string) error {
+	if err := validateSourceTemplate(tmpl); err != nil {
+		return err
+	}
+	if tmpl == "" {
+		tmpl = DefaultSourceTemplate
+	}
+
+	s.mut.Lock()
+	s.sourceTemplate = tmpl
+	s.mut.Unlock()
+	return nil
+}
+
+// SetArchiveDownloads serves module source archives through the registry instead of returning git
+// source URLs, for clients without access to the repositories. Remote repositories are fetched into
+// bare repositories in `mirrorDir`, or a temporary directory when empty. Takes effect on the next
+// cache reload.
+func (s *GitStore) SetArchiveDownloads(enabled bool, mirrorDir string) {
+	s.mut.Lock()
+	s.archiveDownloads = enabled
+	s.mirrorDir = mirrorDir
+	s.mut.Unlock()
+}
+
+// ListModuleVersions returns the cached versions of the module.
+func (s *GitStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
+	key := path.Join(namespace, name, provider)
+
+	s.moduleMut.RLock()
+	defer s.moduleMut.RUnlock()
+
+	versions, ok := s.moduleCache[key]
+	if !ok {
+		return nil, core.NotFoundError("module '%s' not found", key)
+	}
+	return versions, nil
+}
+
+// GetModuleVersion returns the cached version of the module.
+func (s *GitStore) GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*core.ModuleVersion, error) {
+	versions, err := s.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ver := range versions {
+		if ver.Version == version {
+			return ver, nil
+		}
+	}
+	return nil, core.NotFoundError("version '%s' not found for module '%s'", version, path.Join(namespace, name, provider))
+}
+
+// ListModules returns all cached modules, sorted by address.
+func (s *GitStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	s.moduleMut.RLock()
+	defer s.moduleMut.RUnlock()
+
+	modules := make([]core.ModuleAddress, 0, len(s.moduleCache))
+	for key := range s.moduleCache {
+		parts := strings.Split(key, "/")
+		modules = append(modules, core.ModuleAddress{Namespace: parts[0], Name: parts[1], Provider: parts[2]})
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		a, b := modules[i], modules[j]
+		return path.Join(a.Namespace, a.Name, a.Provider) < path.Join(b.Namespace, b.Name, b.Provider)
+	})
+	return modules, nil
+}
+
+// ReloadCache lists the tags of all repositories. Modules that fail to load keep their
+// previously cached versions.
+func (s *GitStore) ReloadCache(ctx context.Context) error {
+	s.mut.RLock()
+	repos := s.repos
+	s.mut.RUnlock()
+
+	fresh := make(map[string][]*core.ModuleVersion)
+	freshTags := make(map[string]tagRef)
+	var errs []error
+	for _, repo := range repos {
+		versions, tags, err := s.loadModule(ctx, repo)
+		if err != nil {
+			s.logger.Warn("failed to load module",
+				zap.String("url", repo.URL),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("module '%s': %w", repo.address(), err))
+
+			s.moduleMut.RLock()
+			versions = s.moduleCache[repo.address()]
+			for _, ver := range versions {
+				tags[path.Join(repo.address(), ver.Version)] = s.moduleTagCache[path.Join(repo.address(), ver.Version)]
+			}
+			s.moduleMut.RUnlock()
+			if versions == nil {
+				continue
+			}
+		}
+
+		fresh[repo.address()] = versions
+		for key, tag := range tags {
+			freshTags[key] = tag
+		}
+	}
+
+	s.moduleMut.Lock()
+	s.moduleCache = fresh
+	s.moduleTagCache = freshTags
+	s.moduleReloadedAt = time.Now().UTC()
+	s.moduleMut.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// ReloadModule lists the tags of the repository of a single module.
+func (s *GitStore) ReloadModule(ctx context.Context, namespace, name, provider string) error {
+	key := path.Join(namespace, name, provider)
+
+	s.mut.RLock()
+	var repo *Repository
+	for i := range s.repos {
+		if s.repos[i].address() == key {
+			repo = &s.repos[i]
+		}
+	}
+	s.mut.RUnlock()
+	if repo == nil {
+		return core.NotFoundError("module '%s' not found", key)
+	}
+
+	versions, tags, err := s.loadModule(ctx, *repo)
+	if err != nil {
+		return err
+	}
+
+	s.moduleMut.Lock()
+	s.moduleCache[key] = versions
+	for k, tag := range tags {
+		s.moduleTagCache[k] = tag
+	}
+	s.moduleMut.Unlock()
+
+	return nil
+}
+
+// CacheStats returns the number of cached modules and when they were reloaded.
+func (s *GitStore) CacheStats() any {
+	s.moduleMut.RLock()
+	defer s.moduleMut.RUnlock()
+
+	return struct {
+		Modules    int       `json:"modules"`
+		ReloadedAt time.Time `json:"reloaded_at"`
+	}{len(s.moduleCache), s.moduleReloadedAt}
+}
+
+// loadModule returns the versions of the module in `repo`, along with the tag of each version.
+func (s *GitStore) loadModule(ctx context.Context, repo Repository) ([]*core.ModuleVersion, map[string]tagRef, error) {
+	refs, err := s.lsRemoteTags(ctx, repo.URL)
+	if err != nil {
+		return nil, map[string]tagRef{}, err
+	}
+
+	s.mut.RLock()
+	tmpl := s.sourceTemplate
+	archiveDownloads := s.archiveDownloads
+	s.mut.RUnlock()
+	if repo.Source != "" {
+		tmpl = repo.Source
+	}
+
+	versions := make([]*core.ModuleVersion, 0)
+	tags := make(map[string]tagRef)
+	for _, ref := range refs {
+		if !storeutil.VersionTagRegex.MatchString(ref.tag) {
+			continue
+		}
+		version := strings.TrimPrefix(ref.tag, "v") // Terraform uses SemVer names without 'v' prefix
+		if _, ok := tags[path.Join(repo.address(), version)]; ok {
+			continue
+		}
+
+		sourceURL := strings.NewReplacer(
+			"{url}", repo.URL,
+			"{tag}", ref.tag,
+			"{version}", version,
+			"{sha}", ref.sha,
+		).Replace(tmpl)
+		if archiveDownloads {
+			sourceURL = storeutil.ModuleProxyURL(repo.address(), version, "tar.gz")
+		}
+
+		versions = append(versions, &core.ModuleVersion{
+			Version:   version,
+			SourceURL: sourceURL,
+			Metadata:  &core.ModuleMetadata{CommitSHA: ref.sha},
+		})
+		ref.repo = repo
+		tags[path.Join(repo.address(), version)] = ref
+	}
+
+	s.logger.Debug("found module",
+		zap.String("name", repo.address()),
+		zap.Int("version_count", len(versions)),
+	)
+
+	return versions, tags, nil
+}
+
+// lsRemoteTags returns the tags of the repository at `url`, with annotated tags peeled to their commits.
+func (s *GitStore) lsRemoteTags(ctx context.Context, url string) ([]tagRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, lsRemoteTimeout)
+	defer cancel()
+
+	out, err := s.runGit(ctx, "", "ls-remote", "--tags", "--", url)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []tagRef
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		sha, ref, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		tag, ok := strings.CutPrefix(ref, "refs/tags/")
+		if !ok {
+			continue
+		}
+		if tag, ok := strings.CutSuffix(tag, "^{}"); ok {
+			if i, ok := index[tag]; ok {
+				refs[i].sha = sha
+			}
+			continue
+		}
+		index[tag] = len(refs)
+		refs = append(refs, tagRef{tag: tag, sha: sha})
+	}
+	return refs, scanner.Err()
+}
+
+// runGit runs the git command with `args` in `dir`, and returns its output.
+func (s *GitStore) runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := s.command(ctx, dir, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// streamGit runs the git command with `args` in `dir`, and returns a reader of its output. Fails
+// if the command exits without output, so failures are returned before any output is served.
+// Later failures are returned by the reader at the end of the output. Closing the reader before
+// the end stops the command.
+func (s *GitStore) streamGit(ctx context.Context, dir string, args ...string) (io.ReadCloser, error) {
+	cmd := s.command(ctx, dir, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	r := &commandReader{Reader: bufio.NewReader(stdout), cmd: cmd, name: args[0]}
+	cmd.Stderr = &r.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	if _, err := r.Peek(1); err != nil {
+		if err := r.wait(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// commandReader reads the output of a started command, and waits for it at the end of the output.
+type commandReader struct {
+	*bufio.Reader
+	cmd    *exec.Cmd
+	name   string
+	stderr bytes.Buffer
+
+	once sync.Once
+	err  error
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		if err := r.wait(); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// Close stops the command unless it has exited, such as when the client disconnects before the
+// end of the output.
+func (r *commandReader) Close() error {
+	_ = r.cmd.Process.Kill()
+	_ = r.wait()
+	return nil
+}
+
+// wait waits for the command to exit, and returns its error with the output on stderr.
+func (r *commandReader) wait() error {
+	r.once.Do(func() {
+		if err := r.cmd.Wait(); err != nil {
+			r.err = fmt.Errorf("git %s: %w: %s", r.name, err, strings.TrimSpace(r.stderr.String()))
+		}
+	})
+	return r.err
+}
+
+func (s *GitStore) command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, s.git, args...)
+	cmd.Dir = dir
+	// Fail instead of waiting for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_SSH_COMMAND="+sshCommand())
+	return cmd
+}
+
+// sshCommand returns the SSH command of git, in batch mode so it never prompts for passwords.
+func sshCommand() string {
+	if cmd := os.Getenv("GIT_SSH_COMMAND"); cmd != "" {
+		return cmd
+	}
+	return "ssh -o BatchMode=yes"
+}
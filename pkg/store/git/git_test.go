@@ -0,0 +1,369 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// git runs the git command in `dir` for test setup, and returns its trimmed output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRepository creates a bare repository at `<root>/<namespace>/<name>.git`, with a commit for each
+// tag. Tags starting with `a` are annotated. Returns the SHAs of the tagged commits.
+func newTestRepository(t *testing.T, root, namespace, name string, tags ...string) (string, map[string]string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	bare := filepath.Join(root, namespace, name+".git")
+	git(t, "", "init", "--quiet", "--bare", bare)
+
+	work := t.TempDir()
+	git(t, work, "init", "--quiet")
+	shas := make(map[string]string)
+	for _, tag := range tags {
+		name, annotated := strings.CutPrefix(tag, "a")
+		err := os.WriteFile(filepath.Join(work, "main.tf"), []byte("# "+name+"\n"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		git(t, work, "add", "main.tf")
+		git(t, work, "commit", "--quiet", "-m", name)
+		if annotated {
+			git(t, work, "tag", "-a", "-m", name, name)
+		} else {
+			git(t, work, "tag", name)
+		}
+		shas[name] = git(t, work, "rev-parse", "HEAD")
+	}
+	git(t, work, "push", "--quiet", "--tags", bare)
+	return bare, shas
+}
+
+func newTestStore(t *testing.T, repos ...Repository) *GitStore {
+	t.Helper()
+	store := NewGitStore(repos, nil)
+	if err := store.ReloadCache(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestParseRepositories(t *testing.T) {
+	is := is.New(t)
+
+	repos, err := ParseRepositories([]byte(`[
+		{"url": "https://git.example.com/infra/vpc.git"},
+		{"url": "git@git.example.com:infra/dns.git", "provider": "aws"},
+		{"url": "/srv/git/mirror.git", "namespace": "infra", "name": "mirror", "source": "git::https://git.example.com/mirror.git?ref={sha}"}
+	]`))
+	is.NoErr(err)
+	is.Equal(repos, []Repository{
+		{URL: "https://git.example.com/infra/vpc.git", Namespace: "infra", Name: "vpc", Provider: "generic"},
+		{URL: "git@git.example.com:infra/dns.git", Namespace: "infra", Name: "dns", Provider: "aws"},
+		{URL: "/srv/git/mirror.git", Namespace: "infra", Name: "mirror", Provider: "generic", Source: "git::https://git.example.com/mirror.git?ref={sha}"},
+	})
+
+	_, err = ParseRepositories([]byte(`[{"url": ""}]`))
+	is.Equal(err.Error(), "repository 0: missing url")
+	_, err = ParseRepositories([]byte(`[{"url": "--upload-pack=touch"}]`))
+	is.Equal(err.Error(), "repository 0: invalid url '--upload-pack=touch'")
+	_, err = ParseRepositories([]byte(`[{"url": "vpc.git"}]`))
+	is.Equal(err.Error(), "repository 'vpc.git': invalid module address 'vpc/generic'")
+	_, err = ParseRepositories([]byte(`[{"url": "/a/infra/vpc.git"}, {"url": "/b/infra/vpc"}]`))
+	is.Equal(err.Error(), "repository '/b/infra/vpc': duplicate module address 'infra/vpc/generic'")
+	_, err = ParseRepositories([]byte(`[{"url": "/infra/vpc.git", "source": "{ref}"}]`))
+	is.Equal(err.Error(), "repository '/infra/vpc.git': unknown placeholder '{ref}', must be one of {url}, {tag}, {version}, {sha}")
+}
+
+func TestListModuleVersions(t *testing.T) {
+	is := is.New(t)
+	root := t.TempDir()
+	url, shas := newTestRepository(t, root, "infra", "vpc", "v1.0.0", "av1.1.0", "2.0.0-rc.1", "latest")
+	store := newTestStore(t, Repository{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic"})
+
+	versions, err := store.ListModuleVersions(context.Background(), "infra", "vpc", "generic")
+	is.NoErr(err)
+	is.Equal(versions, []*core.ModuleVersion{
+		{Version: "2.0.0-rc.1", SourceURL: "git::" + url + "?ref=2.0.0-rc.1", Metadata: &core.ModuleMetadata{CommitSHA: shas["2.0.0-rc.1"]}},
+		{Version: "1.0.0", SourceURL: "git::" + url + "?ref=v1.0.0", Metadata: &core.ModuleMetadata{CommitSHA: shas["v1.0.0"]}},
+		// Annotated tags are peeled to their commit
+		{Version: "1.1.0", SourceURL: "git::" + url + "?ref=v1.1.0", Metadata: &core.ModuleMetadata{CommitSHA: shas["v1.1.0"]}},
+	})
+
+	_, err = store.ListModuleVersions(context.Background(), "infra", "missing", "generic")
+	is.Equal(err.Error(), "module 'infra/missing/generic' not found")
+}
+
+func TestGetModuleVersion(t *testing.T) {
+	is := is.New(t)
+	root := t.TempDir()
+	url, shas := newTestRepository(t, root, "infra", "vpc", "v1.0.0")
+	store := newTestStore(t, Repository{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic", Source: "git::https://git.example.com/vpc.git?ref={sha}"})
+
+	ver, err := store.GetModuleVersion(context.Background(), "infra", "vpc", "generic", "1.0.0")
+	is.NoErr(err)
+	is.Equal(ver.SourceURL, "git::https://git.example.com/vpc.git?ref="+shas["v1.0.0"])
+
+	_, err = store.GetModuleVersion(context.Background(), "infra", "vpc", "generic", "2.0.0")
+	is.Equal(err.Error(), "version '2.0.0' not found for module 'infra/vpc/generic'")
+}
+
+func TestListModules(t *testing.T) {
+	is := is.New(t)
+	root := t.TempDir()
+	vpc, _ := newTestRepository(t, root, "infra", "vpc", "v1.0.0")
+	dns, _ := newTestRepository(t, root, "infra", "dns", "v1.0.0")
+	store := newTestStore(t,
+		Repository{URL: vpc, Namespace: "infra", Name: "vpc", Provider: "generic"},
+		Repository{URL: dns, Namespace: "infra", Name: "dns", Provider: "aws"},
+	)
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "infra", Name: "dns", Provider: "aws"},
+		{Namespace: "infra", Name: "vpc", Provider: "generic"},
+	})
+}
+
+func TestSourceTemplate(t *testing.T) {
+	is := is.New(t)
+	root := t.TempDir()
+	url, shas := newTestRepository(t, root, "infra", "vpc", "v1.0.0")
+	store := NewGitStore([]Repository{{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic"}}, nil)
+
+	err := store.SetSourceTemplate("git::https://mirror.example.com/vpc.git?ref={ref}")
+	is.Equal(err.Error(), "unknown placeholder '{ref}', must be one of {url}, {tag}, {version}, {sha}")
+
+	is.NoErr(store.SetSourceTemplate("https://mirror.example.com/vpc/{version}/{sha}.tar.gz"))
+	is.NoErr(store.ReloadCache(context.Background()))
+	ver, err := store.GetModuleVersion(context.Background(), "infra", "vpc", "generic", "1.0.0")
+	is.NoErr(err)
+	is.Equal(ver.SourceURL, "https://mirror.example.com/vpc/1.0.0/"+shas["v1.0.0"]+".tar.gz")
+}
+
+func TestReloadCache(t *testing.T) {
+	is := is.New(t)
+	root := t.TempDir()
+	url, _ := newTestRepository(t, root, "infra", "vpc", "v1.0.0")
+	store := newTestStore(t, Repository{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic"})
+
+	// New tags are found on reload
+	work := t.TempDir()
+	git(t, work, "clone", "--quiet", url, ".")
+	git(t, work, "commit", "--quiet", "--allow-empty", "-m", "v1.1.0")
+	git(t, work, "tag", "v1.1.0")
+	git(t, work, "push", "--quiet", "--tags", url)
+	is.NoErr(store.ReloadModule(context.Background(), "infra", "vpc", "generic"))
+	versions, err := store.ListModuleVersions(context.Background(), "infra", "vpc", "generic")
+	is.NoErr(err)
+	is.Equal(len(versions), 2)
+
+	// Modules that fail to load keep their versions, while the others are loaded
+	dns, _ := newTestRepository(t, root, "infra", "dns", "v1.0.0")
+	is.NoErr(os.RemoveAll(url))
+	store.SetRepositories([]Repository{
+		{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic"},
+		{URL: dns, Namespace: "infra", Name: "dns", Provider: "generic"},
+	})
+	err = store.ReloadCache(context.Background())
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "module 'infra/vpc/generic': git ls-remote: "))
+	versions, err = store.ListModuleVersions(context.Background(), "infra", "vpc", "generic")
+	is.NoErr(err)
+	is.Equal(len(versions), 2)
+	_, err = store.ListModuleVersions(context.Background(), "infra", "dns", "generic")
+	is.NoErr(err)
+}
+
+func TestSnapshot(t *testing.T) {
+	is := is.New(t)
+	root := t.TempDir()
+	vpc, _ := newTestRepository(t, root, "infra", "vpc", "v1.0.0")
+	dns, _ := newTestRepository(t, root, "infra", "dns", "v1.0.0")
+	repos := []Repository{
+		{URL: vpc, Namespace: "infra", Name: "vpc", Provider: "generic"},
+		{URL: dns, Namespace: "infra", Name: "dns", Provider: "generic"},
+	}
+	source := NewGitStore(repos, nil)
+	source.SetArchiveDownloads(true, t.TempDir())
+	is.NoErr(source.ReloadCache(context.Background()))
+
+	var buf bytes.Buffer
+	is.NoErr(source.SaveSnapshot(&buf))
+
+	// Modules of repositories that are no longer configured are left out
+	target := NewGitStore(repos[:1], nil)
+	target.SetArchiveDownloads(true, t.TempDir())
+	is.NoErr(target.LoadSnapshot(&buf))
+
+	ver, err := target.GetModuleVersion(context.Background(), "infra", "vpc", "generic", "1.0.0")
+	is.NoErr(err)
+	is.Equal(ver.SourceURL, "/download/module/infra/vpc/generic/1.0.0?archive=tar.gz")
+	r, err := target.GetModuleArchive(context.Background(), "infra", "vpc", "generic", "1.0.0")
+	is.NoErr(err)
+	is.Equal(readArchive(t, r), map[string]string{"main.tf": "# v1.0.0\n"})
+	_, err = target.ListModuleVersions(context.Background(), "infra", "dns", "generic")
+	is.True(errors.Is(err, core.ErrNotFound))
+
+	err = target.LoadSnapshot(strings.NewReader(`{"version": 0}`))
+	is.Equal(err.Error(), "unsupported snapshot version 0, expected 1")
+}
+
+func TestModuleArchive(t *testing.T) {
+	root := t.TempDir()
+	url, _ := newTestRepository(t, root, "infra", "vpc", "v1.0.0", "av2.0.0")
+
+	for name, repoURL := range map[string]string{
+		"local":  url,
+		"remote": "file://" + url,
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			store := NewGitStore([]Repository{{URL: repoURL, Namespace: "infra", Name: "vpc", Provider: "generic"}}, nil)
+			store.SetArchiveDownloads(true, t.TempDir())
+			is.NoErr(store.ReloadCache(context.Background()))
+
+			ver, err := store.GetModuleVersion(context.Background(), "infra", "vpc", "generic", "2.0.0")
+			is.NoErr(err)
+			is.Equal(ver.SourceURL, "/download/module/infra/vpc/generic/2.0.0?archive=tar.gz")
+
+			// Twice, to use the existing mirror of remote repositories
+			for range 2 {
+				for _, version := range []string{"1.0.0", "2.0.0"} {
+					r, err := store.GetModuleArchive(context.Background(), "infra", "vpc", "generic", version)
+					is.NoErr(err)
+					files := readArchive(t, r)
+					is.Equal(files, map[string]string{"main.tf": "# v" + version + "\n"})
+				}
+			}
+
+			_, err = store.GetModuleArchive(context.Background(), "infra", "vpc", "generic", "3.0.0")
+			is.Equal(err.Error(), "version '3.0.0' not found for module 'infra/vpc/generic'")
+			is.True(errors.Is(err, core.ErrNotFound))
+		})
+	}
+
+	t.Run("concurrent downloads", func(t *testing.T) {
+		is := is.New(t)
+		store := NewGitStore([]Repository{{URL: "file://" + url, Namespace: "infra", Name: "vpc", Provider: "generic"}}, nil)
+		store.SetArchiveDownloads(true, t.TempDir())
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for range 4 {
+			wg.Go(func() {
+				r, err := store.GetModuleArchive(context.Background(), "infra", "vpc", "generic", "1.0.0")
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer r.Close()
+				_, err = io.Copy(io.Discard, r)
+				errs <- err
+			})
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			is.NoErr(err)
+		}
+	})
+
+	t.Run("closed before the end", func(t *testing.T) {
+		is := is.New(t)
+		store := NewGitStore([]Repository{{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic"}}, nil)
+		store.SetArchiveDownloads(true, "")
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		r, err := store.GetModuleArchive(context.Background(), "infra", "vpc", "generic", "1.0.0")
+		is.NoErr(err)
+		is.NoErr(r.Close())
+	})
+
+	t.Run("missing commit", func(t *testing.T) {
+		is := is.New(t)
+		store := NewGitStore([]Repository{{URL: url, Namespace: "infra", Name: "vpc", Provider: "generic"}}, nil)
+		store.SetArchiveDownloads(true, "")
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		key := "infra/vpc/generic/1.0.0"
+		tag := store.moduleTagCache[key]
+		tag.sha = strings.Repeat("0", 40)
+		store.moduleTagCache[key] = tag
+
+		_, err := store.GetModuleArchive(context.Background(), "infra", "vpc", "generic", "1.0.0")
+		is.True(errors.Is(err, core.ErrUnavailable))
+		is.True(strings.Contains(err.Error(), "git archive"))
+	})
+
+	t.Run("unavailable remote", func(t *testing.T) {
+		is := is.New(t)
+		url, _ := newTestRepository(t, t.TempDir(), "infra", "dns", "v1.0.0")
+		store := NewGitStore([]Repository{{URL: "file://" + url, Namespace: "infra", Name: "dns", Provider: "generic"}}, nil)
+		store.SetArchiveDownloads(true, t.TempDir())
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		is.NoErr(os.RemoveAll(url))
+		_, err := store.GetModuleArchive(context.Background(), "infra", "dns", "generic", "1.0.0")
+		is.True(errors.Is(err, core.ErrUnavailable))
+	})
+}
+
+// readArchive returns the files in the gzipped tarball.
+func readArchive(t *testing.T, r io.ReadCloser) map[string]string {
+	t.Helper()
+	defer r.Close()
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gzr)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(b)
+	}
+}
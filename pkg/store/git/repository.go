@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultSourceTemplate is the module source URL template used when none is configured.
+const DefaultSourceTemplate = "git::{url}?ref={tag}"
+
+var (
+	templatePlaceholderRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+	// Placeholders available in module source URL templates.
+	templatePlaceholders = []string{"{url}", "{tag}", "{version}", "{sha}"}
+
+	addressPartRegex = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// Repository is a git repository with a module, either a remote URL or the path of a local repository.
+type Repository struct {
+	// URL of the repository, such as `https://git.example.com/infra/vpc.git`, `git@git.example.com:infra/vpc.git`
+	// or `/srv/git/infra/vpc.git`. Anything accepted by `git ls-remote` works.
+	URL string `json:"url"`
+	// Namespace and Name of the module default to the last two path segments of the URL,
+	// without the `.git` suffix. Provider defaults to `generic`, as with the GitHub store.
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	// Source overrides the module source URL template of the store for this repository.
+	Source string `json:"source"`
+}
+
+// address returns the module address of the repository.
+func (r Repository) address() string {
+	return path.Join(r.Namespace, r.Name, r.Provider)
+}
+
+// ParseRepositories parses a JSON encoded list of repositories, and fills in the default module addresses.
+func ParseRepositories(b []byte) ([]Repository, error) {
+	var repos []Repository
+	if err := json.Unmarshal(b, &repos); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range repos {
+		repo := &repos[i]
+		if repo.URL == "" {
+			return nil, fmt.Errorf("repository %d: missing url", i)
+		}
+		if strings.HasPrefix(repo.URL, "-") {
+			return nil, fmt.Errorf("repository %d: invalid url '%s'", i, repo.URL)
+		}
+
+		segments := strings.Split(strings.Trim(strings.ReplaceAll(repo.URL, ":", "/"), "/"), "/")
+		if repo.Name == "" {
+			repo.Name = strings.TrimSuffix(segments[len(segments)-1], ".git")
+		}
+		if repo.Namespace == "" && len(segments) > 1 {
+			repo.Namespace = segments[len(segments)-2]
+		}
+		if repo.Provider == "" {
+			repo.Provider = "generic"
+		}
+		for _, part := range []string{repo.Namespace, repo.Name, repo.Provider} {
+			if !addressPartRegex.MatchString(part) {
+				return nil, fmt.Errorf("repository '%s': invalid module address '%s'", repo.URL, repo.address())
+			}
+		}
+		if err := validateSourceTemplate(repo.Source); err != nil {
+			return nil, fmt.Errorf("repository '%s': %w", repo.URL, err)
+		}
+
+		if seen[repo.address()] {
+			return nil, fmt.Errorf("repository '%s': duplicate module address '%s'", repo.URL, repo.address())
+		}
+		seen[repo.address()] = true
+	}
+	return repos, nil
+}
+
+func validateSourceTemplate(tmpl string) error {
+	for _, p := range templatePlaceholderRegex.FindAllString(tmpl, -1) {
+		if !slices.Contains(templatePlaceholders, p) {
+			return fmt.Errorf("unknown placeholder '%s', must be one of %s", p, strings.Join(templatePlaceholders, ", "))
+		}
+	}
+	return nil
+}
+
+// localPath returns the path of the repository if it is on the local file system.
+func localPath(url string) (string, bool) {
+	if p, ok := strings.CutPrefix(url, "file://"); ok {
+		return p, true
+	}
+	if strings.Contains(url, "://") {
+		return "", false
+	}
+	// SCP-like syntax, such as `git@git.example.com:infra/vpc.git`
+	if i := strings.Index(url, ":"); i >= 0 && !strings.Contains(url[:i], "/") {
+		return "", false
+	}
+	return url, true
+}
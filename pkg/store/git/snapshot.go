@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// snapshotVersion is increased whenever the snapshot format changes in an incompatible way.
+// Snapshots of other versions are rejected, and the caches are reloaded from the repositories instead.
+const snapshotVersion = 1
+
+// snapshot is the persisted form of the store caches.
+type snapshot struct {
+	Version   int                              `json:"version"`
+	CreatedAt time.Time                        `json:"created_at"`
+	Modules   map[string][]*core.ModuleVersion `json:"modules"`
+	// Tags of the module versions by `{namespace}/{name}/{provider}/{version}`.
+	ModuleTags map[string]snapshotTag `json:"module_tags"`
+}
+
+// snapshotTag is the persisted form of a `tagRef`. The repository is looked up by module address
+// when loaded, so that only configured repositories are served.
+type snapshotTag struct {
+	Tag string `json:"tag"`
+	SHA string `json:"sha"`
+}
+
+// SaveSnapshot writes the module caches to `w`.
+func (s *GitStore) SaveSnapshot(w io.Writer) error {
+	snap := snapshot{
+		Version:    snapshotVersion,
+		CreatedAt:  time.Now().UTC(),
+		ModuleTags: make(map[string]snapshotTag),
+	}
+
+	s.moduleMut.RLock()
+	snap.Modules = s.moduleCache
+	for key, tag := range s.moduleTagCache {
+		snap.ModuleTags[key] = snapshotTag{Tag: tag.tag, SHA: tag.sha}
+	}
+	s.moduleMut.RUnlock()
+
+	// The cached versions are replaced, never modified, on reload. Encoding them
+	// without holding the lock is therefore safe.
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// LoadSnapshot replaces the module caches with the contents of a snapshot previously written
+// by `SaveSnapshot`. Modules of repositories that are no longer configured are left out.
+func (s *GitStore) LoadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, expected %d", snap.Version, snapshotVersion)
+	}
+
+	s.mut.RLock()
+	repos := s.repos
+	s.mut.RUnlock()
+
+	modules := make(map[string][]*core.ModuleVersion)
+	tags := make(map[string]tagRef)
+	for _, repo := range repos {
+		versions, ok := snap.Modules[repo.address()]
+		if !ok {
+			continue
+		}
+		modules[repo.address()] = versions
+		for _, ver := range versions {
+			key := path.Join(repo.address(), ver.Version)
+			if tag, ok := snap.ModuleTags[key]; ok {
+				tags[key] = tagRef{repo: repo, tag: tag.Tag, sha: tag.SHA}
+			}
+		}
+	}
+
+	s.moduleMut.Lock()
+	s.moduleCache = modules
+	s.moduleTagCache = tags
+	s.moduleReloadedAt = snap.CreatedAt
+	s.moduleMut.Unlock()
+
+	s.logger.Info("loaded cache snapshot",
+		zap.Time("created", snap.CreatedAt),
+		zap.Int("modules", len(modules)),
+	)
+
+	return nil
+}
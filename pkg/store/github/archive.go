@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// SetModuleArchiveDownloads enables or disables serving module source archives through
+// the registry. When enabled, module source URLs point to the registry `/download/module/`
+// route instead of GitHub, and clients only need a registry token to download modules.
+// Takes effect on the next cache reload.
+func (s *GitHubStore) SetModuleArchiveDownloads(enabled bool) {
+	s.moduleMut.Lock()
+	s.archiveDownloads = enabled
+	s.moduleMut.Unlock()
+}
+
+// GetModuleArchive returns a gzipped tarball with the module source code of the given version.
+// The top-level directory added by GitHub is stripped, so that the module is at the root of the archive.
+func (s *GitHubStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	ver, err := s.GetModuleVersion(ctx, namespace, name, provider, version)
+	if err != nil {
+		return nil, err
+	}
+
+	s.moduleMut.RLock()
+	tag, ok := s.moduleTagCache[cacheKey(namespace, name, provider, ver.Version)]
+	s.moduleMut.RUnlock()
+	if !ok {
+		return nil, core.NotFoundError("tag not found for module version '%s'", cacheKey(namespace, name, provider, ver.Version))
+	}
+
+	return s.moduleArchive(ctx, namespace, name, tag)
+}
+
+// moduleArchive returns a gzipped tarball with the source code of the repository at `ref`,
+// with the top-level directory stripped.
+func (s *GitHubStore) moduleArchive(ctx context.Context, owner, repo, ref string) (io.ReadCloser, error) {
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
+	link, resp, err := client.Repositories.GetArchiveLink(ctx, owner, repo, github.Tarball, &github.RepositoryContentGetOptions{Ref: ref}, 1)
+	s.rateLimiterFor(owner).observe(resp, err)
+	if err != nil {
+		return nil, backendError(fmt.Errorf("unable to get archive link: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	archiveResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, backendError(fmt.Errorf("unable to download archive: %w", err))
+	}
+	if archiveResp.StatusCode != http.StatusOK {
+		archiveResp.Body.Close()
+		return nil, core.BackendError(fmt.Errorf("unable to download archive: unexpected status %s", archiveResp.Status), archiveResp.StatusCode)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer archiveResp.Body.Close()
+		pw.CloseWithError(stripArchivePrefix(pw, archiveResp.Body))
+	}()
+	return pr, nil
+}
+
+// moduleArchiveURL returns the registry route serving the module source archive of a version.
+func moduleArchiveURL(owner, name, version string) string {
+	return fmt.Sprintf("/download/module/%s/%s/generic/%s?archive=tar.gz", owner, name, version)
+}
+
+// stripArchivePrefix copies the gzipped tarball in `src` to `dst`, removing the top-level
+// directory from all entries. GitHub puts all files in a `{owner}-{repo}-{sha}/` directory.
+func stripArchivePrefix(dst io.Writer, src io.Reader) error {
+	gzr, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	gzw := gzip.NewWriter(dst)
+	tw := tar.NewWriter(gzw)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// Skip the PAX global header GitHub adds with the commit SHA
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		_, rest, _ := strings.Cut(hdr.Name, "/")
+		if rest == "" {
+			// The top-level directory itself
+			continue
+		}
+		hdr.Name = rest
+		if hdr.Typeflag == tar.TypeLink {
+			_, hdr.Linkname, _ = strings.Cut(hdr.Linkname, "/")
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/nrkno/terraform-registry/pkg/diskcache"
+	"go.uber.org/zap"
+)
+
+// SetAssetCacheDir enables caching of provider release assets served through the registry in `dir`.
+// Assets are addressed by their SHA256 checksum, and only assets verified when indexing the
+// release are cached. Assets are added to the cache while they are streamed to the first client.
+// The least recently used assets are removed when the cache exceeds `maxSize` bytes, unless 0.
+func (s *GitHubStore) SetAssetCacheDir(dir string, maxSize int64) error {
+	cache, err := diskcache.NewDiskCache(dir, maxSize)
+	if err != nil {
+		return err
+	}
+
+	s.providerMut.Lock()
+	s.assetCache = cache
+	s.providerMut.Unlock()
+
+	return nil
+}
+
+// openAsset starts downloading the release asset from `offset`. GitHub redirects asset downloads
+// to a storage host supporting Range requests, which is requested without the credentials of the store.
+func (s *GitHubStore) openAsset(ctx context.Context, owner, repo string, asset *github.ReleaseAsset, offset int64) (io.ReadCloser, error) {
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
+	body, redirectURL, err := client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), nil)
+	s.rateLimiterFor(owner).observe(nil, err)
+	if err != nil {
+		return nil, backendError(fmt.Errorf("error getting asset: %w", err))
+	}
+
+	if redirectURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, redirectURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/octet-stream")
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, backendError(fmt.Errorf("error getting asset: %w", err))
+		}
+		if err := github.CheckResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, backendError(fmt.Errorf("error getting asset: %w", err))
+		}
+		if resp.StatusCode == http.StatusPartialContent {
+			return resp.Body, nil
+		}
+		body = resp.Body
+	}
+
+	// The range was not honoured, so skip to the offset
+	if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+		body.Close()
+		return nil, backendError(fmt.Errorf("error getting asset: %w", err))
+	}
+	return body, nil
+}
+
+// assetReader is a release asset streamed from GitHub. Reading from another offset than the one
+// of the open download downloads the rest of the asset from there, so that Range requests are served
+// without caching the asset. When `cache` is set, the asset is added to the cache if it is read from
+// start to end.
+type assetReader struct {
+	open func(offset int64) (io.ReadCloser, error)
+	body io.ReadCloser
+	// bodyOffset is the offset `body` is at.
+	bodyOffset int64
+	size       int64
+	offset     int64
+	cache      *diskcache.Writer
+	logger     *zap.Logger
+}
+
+func (r *assetReader) Read(p []byte) (int, error) {
+	if r.body != nil && r.bodyOffset != r.offset {
+		r.body.Close()
+		r.body = nil
+	}
+	if r.body == nil {
+		body, err := r.open(r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+		r.bodyOffset = r.offset
+	}
+
+	n, err := r.body.Read(p)
+	r.bodyOffset += int64(n)
+	if r.cache != nil && r.cache.Written() == r.offset {
+		if _, err := r.cache.Write(p[:n]); err != nil {
+			r.logger.Warn("unable to write asset to cache", zap.Error(err))
+			r.cache.Abort()
+			r.cache = nil
+		}
+	}
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *assetReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to negative offset")
+	}
+
+	// The download is only restarted when reading, as `http.ServeContent` seeks to the end to get the size
+	r.offset = offset
+	return offset, nil
+}
+
+// Size implements `core.AssetSizer`.
+func (r *assetReader) Size() int64 {
+	return r.size
+}
+
+// Close stops the download, and adds the asset to the cache if all of it was read.
+func (r *assetReader) Close() error {
+	var err error
+	if r.body != nil {
+		err = r.body.Close()
+	}
+
+	if r.cache != nil {
+		if r.cache.Written() == r.size {
+			if err := r.cache.Commit(); err != nil {
+				r.logger.Warn("unable to cache asset", zap.Error(err))
+			}
+		} else {
+			r.cache.Abort()
+		}
+	}
+	return err
+}
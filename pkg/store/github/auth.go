@@ -7,8 +7,12 @@ package github
 import (
 	"context"
 	"crypto"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,10 +24,13 @@ import (
 type githubTokenSource struct {
 	PrivatePem    []byte
 	ApplicationID string
-	// If installationID is not provided, the first one is chosen
+	// The installation to get tokens for
 	InstallationID int64
 	// Leave Repos as empty list to get token for all repos
 	Repos []string
+	// GitHub Enterprise Server URLs. Leave empty to use github.com.
+	BaseURL   string
+	UploadURL string
 
 	privateKey crypto.PrivateKey
 	token      *oauth2.Token
@@ -42,35 +49,17 @@ func (gts *githubTokenSource) Token() (*oauth2.Token, error) {
 	}
 
 	if gts.privateKey == nil {
-		block, _ := pem.Decode([]byte(gts.PrivatePem))
-		gts.privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		gts.privateKey, err = parsePrivateKey(gts.PrivatePem)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	unsignedToken := jwt.New(jwt.SigningMethodRS256)
-	claims := unsignedToken.Claims.(jwt.MapClaims)
-	claims["iat"] = time.Now().Add(-1 * time.Minute).Unix()
-	claims["exp"] = time.Now().Add(4 * time.Minute).Unix()
-	claims["iss"] = gts.ApplicationID
-
-	signedToken, err := unsignedToken.SignedString(gts.privateKey)
+	tmpClient, err := newAppClient(gts.privateKey, gts.ApplicationID, gts.BaseURL, gts.UploadURL)
 	if err != nil {
 		return nil, err
 	}
 
-	tmpHttpClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: signedToken}))
-	tmpClient := github.NewClient(tmpHttpClient)
-
-	if gts.InstallationID == 0 {
-		installations, _, err := tmpClient.Apps.ListInstallations(context.Background(), &github.ListOptions{})
-		if err != nil {
-			return nil, err
-		}
-		gts.InstallationID = *installations[0].ID
-	}
-
 	installationToken, _, err := tmpClient.Apps.CreateInstallationToken(context.Background(), gts.InstallationID, &github.InstallationTokenOptions{
 		Repositories: gts.Repos,
 	})
@@ -89,14 +78,143 @@ func (gts *githubTokenSource) Token() (*oauth2.Token, error) {
 
 // NewGithubClient creates a github.Client, with an automatically renew token
 // privatePem is the bytestring of the privatekey you download from the github app installation
-// set installationID to 0 if you only have one installation
+// leave baseURL and uploadURL empty to use github.com
 // leave repos empty if you want a token for all repos
-func newGithubClient(privatePem []byte, applicationID string, installationID int64, repos ...string) (client *github.Client) {
+func newGithubClient(privatePem []byte, applicationID string, installationID int64, baseURL, uploadURL string, repos ...string) (*github.Client, error) {
 	httpClient := oauth2.NewClient(context.Background(), &githubTokenSource{
 		PrivatePem:     privatePem,
 		ApplicationID:  applicationID,
 		Repos:          repos,
 		InstallationID: installationID,
+		BaseURL:        baseURL,
+		UploadURL:      uploadURL,
 	})
-	return github.NewClient(httpClient)
+	return withEnterpriseURLs(github.NewClient(httpClient), baseURL, uploadURL)
+}
+
+// newAppClient returns a client authenticated as the GitHub App itself, using a JWT valid for a few
+// minutes. The app can only manage its installations, not access repositories.
+func newAppClient(privateKey crypto.PrivateKey, applicationID, baseURL, uploadURL string) (*github.Client, error) {
+	unsignedToken := jwt.New(jwt.SigningMethodRS256)
+	claims := unsignedToken.Claims.(jwt.MapClaims)
+	claims["iat"] = time.Now().Add(-1 * time.Minute).Unix()
+	claims["exp"] = time.Now().Add(4 * time.Minute).Unix()
+	claims["iss"] = applicationID
+
+	signedToken, err := unsignedToken.SignedString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: signedToken}))
+	return withEnterpriseURLs(github.NewClient(httpClient), baseURL, uploadURL)
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key, in either PKCS#1 or PKCS#8 format.
+// GitHub issues PKCS#1 keys, but keys converted by other tools or stored in a KMS are often PKCS#8.
+func parsePrivateKey(privatePem []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(privatePem)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("private key is neither PKCS#1 nor PKCS#8: %w", err)
+	}
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		return nil, fmt.Errorf("private key must be an RSA key, is %T", key)
+	}
+	return key, nil
+}
+
+// githubApp keeps an authenticated client for each installation of a GitHub App, so that API calls
+// for repositories of an owner are made with a token for the installation on that owner.
+type githubApp struct {
+	PrivatePem    []byte
+	ApplicationID string
+	// GitHub Enterprise Server URLs. Leave empty to use github.com.
+	BaseURL   string
+	UploadURL string
+
+	// Clients by lowercase installation account login
+	clients map[string]*github.Client
+	mut     sync.RWMutex
+}
+
+// discover lists the installations of the app, and creates a client for new installations.
+// Clients for installations that have been removed are discarded.
+func (app *githubApp) discover(ctx context.Context) error {
+	privateKey, err := parsePrivateKey(app.PrivatePem)
+	if err != nil {
+		return err
+	}
+	appClient, err := newAppClient(privateKey, app.ApplicationID, app.BaseURL, app.UploadURL)
+	if err != nil {
+		return err
+	}
+
+	var installations []*github.Installation
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := appClient.Apps.ListInstallations(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("unable to list GitHub App installations: %w", err)
+		}
+		installations = append(installations, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	app.mut.RLock()
+	previous := app.clients
+	app.mut.RUnlock()
+
+	clients := make(map[string]*github.Client, len(installations))
+	for _, installation := range installations {
+		owner := strings.ToLower(installation.GetAccount().GetLogin())
+		if client, ok := previous[owner]; ok {
+			clients[owner] = client
+			continue
+		}
+		client, err := newGithubClient(app.PrivatePem, app.ApplicationID, installation.GetID(), app.BaseURL, app.UploadURL)
+		if err != nil {
+			return err
+		}
+		clients[owner] = client
+	}
+
+	app.mut.Lock()
+	app.clients = clients
+	app.mut.Unlock()
+
+	return nil
+}
+
+// clientFor returns the client for the installation on the owner, if any.
+func (app *githubApp) clientFor(owner string) (*github.Client, bool) {
+	app.mut.RLock()
+	defer app.mut.RUnlock()
+
+	client, ok := app.clients[strings.ToLower(owner)]
+	return client, ok
+}
+
+// owners returns the lowercase account logins of all installations, sorted.
+func (app *githubApp) owners() []string {
+	app.mut.RLock()
+	defer app.mut.RUnlock()
+
+	owners := make([]string, 0, len(app.clients))
+	for owner := range app.clients {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return owners
 }
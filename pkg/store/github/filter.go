@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+)
+
+// RepositoryFilter selects the repositories to discover modules or providers in.
+type RepositoryFilter struct {
+	// Owners (orgs or users) to discover repositories from. Repositories of any of
+	// the owners are included. Leave empty for all.
+	Owners []string
+	// Topics to filter repositories by. Repositories having any of the topics are
+	// included, unless MatchAllTopics is set. Leave empty for all.
+	Topics []string
+	// MatchAllTopics requires repositories to have all of Topics instead of any of them.
+	MatchAllTopics bool
+	// ExcludeTopics excludes repositories having any of these topics.
+	ExcludeTopics []string
+	// ExcludeRepos excludes repositories by their full name, i.e. `owner/repo`.
+	ExcludeRepos []string
+	// ExcludeArchived excludes archived repositories.
+	ExcludeArchived bool
+	// IncludeForks includes forked repositories, which are excluded by default.
+	IncludeForks bool
+}
+
+// IsEmpty returns true if neither owners nor topics are set, i.e. the filter would match all of GitHub.
+func (f RepositoryFilter) IsEmpty() bool {
+	return len(f.Owners) == 0 && len(f.Topics) == 0
+}
+
+// searchQuery is a repository search query, along with the owner it is limited to, if any.
+type searchQuery struct {
+	owner string
+	query string
+}
+
+// queries returns the search queries needed to find all repositories matching the filter.
+// GitHub combines qualifiers with AND, so owners and topics matched with OR are split
+// into separate queries.
+func (f RepositoryFilter) queries() []searchQuery {
+	var common []string
+	for _, topic := range f.ExcludeTopics {
+		common = append(common, fmt.Sprintf(`-topic:"%s"`, topic))
+	}
+	if f.ExcludeArchived {
+		common = append(common, "archived:false")
+	}
+	if f.IncludeForks {
+		common = append(common, "fork:true")
+	}
+
+	topicGroups := [][]string{nil}
+	if len(f.Topics) > 0 {
+		if f.MatchAllTopics {
+			topicGroups = [][]string{f.Topics}
+		} else {
+			topicGroups = nil
+			for _, topic := range f.Topics {
+				topicGroups = append(topicGroups, []string{topic})
+			}
+		}
+	}
+
+	owners := f.Owners
+	if len(owners) == 0 {
+		owners = []string{""}
+	}
+
+	var queries []searchQuery
+	for _, owner := range owners {
+		for _, topics := range topicGroups {
+			var filters []string
+			if owner != "" {
+				filters = append(filters, fmt.Sprintf(`org:"%s"`, owner))
+			}
+			for _, topic := range topics {
+				filters = append(filters, fmt.Sprintf(`topic:"%s"`, topic))
+			}
+			filters = append(filters, common...)
+			queries = append(queries, searchQuery{owner: owner, query: strings.Join(filters, " ")})
+		}
+	}
+	return queries
+}
+
+// matches returns whether the repository passes the exclusion rules of the filter.
+// The search queries already apply most of the rules, but not all of them can be
+// expressed as search qualifiers.
+func (f RepositoryFilter) matches(repo *github.Repository) bool {
+	for _, name := range f.ExcludeRepos {
+		if strings.EqualFold(name, repo.GetFullName()) {
+			return false
+		}
+	}
+	for _, topic := range f.ExcludeTopics {
+		if slices.Contains(repo.Topics, topic) {
+			return false
+		}
+	}
+	if f.ExcludeArchived && repo.GetArchived() {
+		return false
+	}
+	if !f.IncludeForks && repo.GetFork() {
+		return false
+	}
+	return true
+}
+
+// includes returns whether a search with the filter would find the repository.
+func (f RepositoryFilter) includes(repo *github.Repository) bool {
+	owner, _, _ := strings.Cut(repo.GetFullName(), "/")
+	if len(f.Owners) > 0 && !slices.ContainsFunc(f.Owners, func(o string) bool { return strings.EqualFold(o, owner) }) {
+		return false
+	}
+	if len(f.Topics) > 0 {
+		missing := func(topic string) bool { return !slices.Contains(repo.Topics, topic) }
+		if f.MatchAllTopics && slices.ContainsFunc(f.Topics, missing) {
+			return false
+		}
+		if !f.MatchAllTopics && !slices.ContainsFunc(repo.Topics, func(topic string) bool { return slices.Contains(f.Topics, topic) }) {
+			return false
+		}
+	}
+	return f.matches(repo)
+}
@@ -5,15 +5,17 @@
 package github
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +24,8 @@ import (
 	"github.com/google/go-github/v76/github"
 	goversion "github.com/hashicorp/go-version"
 	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/diskcache"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 )
@@ -35,86 +39,225 @@ type SHASum struct {
 	FileName string
 }
 
-func parseSHASumsFile(r io.Reader) map[string]string {
-	sums := make(map[string]string)
-
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Fields(line)
-
-		hash := parts[0]
-		fileName := parts[1]
-
-		sums[fileName] = hash
-	}
-	return sums
-}
-
 // GitHubStore is a store implementation using GitHub as a backend.
 // Should not be instantiated directly. Use `NewGitHubStore` instead.
 type GitHubStore struct {
-	// Org to filter repositories by. Leave empty for all.
-	ownerFilter string
-	// Topic to filter repositories by. Leave empty for all.
-	topicFilter string
-	// Topic to filter provider repositories by. Leave empty for all.
-	providerOwnerFilter string
-	// Topic to filter provider repositories by. Leave empty for all.
-	providerTopicFilter string
+	// Filter for module repositories.
+	moduleFilter RepositoryFilter
+	// Filter for provider repositories.
+	providerFilter RepositoryFilter
+	// Hostname used in module source URLs. Defaults to github.com.
+	sourceHost string
+	// Templates used to generate module source URLs.
+	sourceTemplates SourceTemplates
+	// Whether module source archives are served through the registry.
+	archiveDownloads bool
+	// Whether documentation is extracted from module source archives.
+	moduleDocs bool
+
+	// Policy selecting module and provider versions.
+	versionPolicy VersionPolicy
 
 	client                *github.Client
+	app                   *githubApp
 	moduleCache           map[string][]*core.ModuleVersion
+	moduleTagCache        map[string]string
+	moduleDocCache        map[string]*core.ModuleMetadata
 	providerVersionsCache map[string]*core.ProviderVersions
 	providerCache         map[string]*core.Provider
-	providerIgnoreCache   sync.Map
+	providerIgnored       ignoreList
+	assetDigestCache      map[int64]string
+	providerAssetDigests  map[string]string
+	providerReleaseIDs    map[string]int64
+	assetCache            *diskcache.DiskCache
+	trustedKeyring        openpgp.EntityList
+	moduleReloadedAt      time.Time
+	providerReloadedAt    time.Time
 	moduleMut             sync.RWMutex
 	providerMut           sync.RWMutex
+	// Serialise reloads, so that a reload of a single repository isn't overwritten by a full reload.
+	moduleReloadMut   sync.Mutex
+	providerReloadMut sync.Mutex
+	rateLimits        rateLimits
 
 	logger *zap.Logger
 }
 
-// Parameters for github authentication, either set AccessToken, or PrivatePem and ApplicationID
+// Parameters for github authentication, either set AccessToken, or PrivatePem and ApplicationID.
+// Set BaseURL, and optionally UploadURL, to use a GitHub Enterprise Server instance instead of github.com.
 type GithubAuthParams struct {
 	AccessToken   string
 	PrivatePem    []byte
 	ApplicationID string
+
+	// BaseURL is the GitHub Enterprise Server API URL, e.g. `https://github.example.com/api/v3/`.
+	// The `/api/v3/` suffix is added automatically if missing. Leave empty to use github.com.
+	BaseURL string
+	// UploadURL is the GitHub Enterprise Server upload URL, e.g. `https://github.example.com/api/uploads/`.
+	// Defaults to BaseURL when left empty.
+	UploadURL string
 }
 
-func NewGitHubStore(ownerFilter, topicFilter, providerOwnerFilter, providerTopicFilter string, authParams GithubAuthParams, logger *zap.Logger) (*GitHubStore, error) {
-	var client *github.Client
+func NewGitHubStore(moduleFilter, providerFilter RepositoryFilter, authParams GithubAuthParams, logger *zap.Logger) (*GitHubStore, error) {
+	var (
+		client *github.Client
+		app    *githubApp
+		err    error
+	)
 	if authParams.AccessToken != "" {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: authParams.AccessToken},
 		)
 		c := oauth2.NewClient(context.TODO(), ts)
-		client = github.NewClient(c)
+		client, err = withEnterpriseURLs(github.NewClient(c), authParams.BaseURL, authParams.UploadURL)
 	} else if authParams.ApplicationID != "" && authParams.PrivatePem != nil {
-		client = newGithubClient(authParams.PrivatePem, authParams.ApplicationID, 0)
+		if _, err := parsePrivateKey(authParams.PrivatePem); err != nil {
+			return nil, fmt.Errorf("invalid GitHub App private key: %w", err)
+		}
+		app = &githubApp{
+			PrivatePem:    authParams.PrivatePem,
+			ApplicationID: authParams.ApplicationID,
+			BaseURL:       authParams.BaseURL,
+			UploadURL:     authParams.UploadURL,
+		}
+		// API calls are made with the client of the installation on the owner of each repository,
+		// so this client is not authenticated, and only holds the API URLs.
+		client, err = withEnterpriseURLs(github.NewClient(nil), authParams.BaseURL, authParams.UploadURL)
 	} else {
 		return nil, fmt.Errorf("either GithubAuthParams AccessToken or ApplicationID and PrivatePem must be set")
 	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub Enterprise Server URL: %w", err)
+	}
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
-	limits, _, err := client.RateLimit.Get(context.TODO())
-	if err != nil {
-		return nil, fmt.Errorf("failed initializing github client, err: %s", err)
+	// Invalid credentials are fatal, but the store must be able to start while GitHub
+	// is unavailable, e.g. to serve from a cache snapshot.
+	var errResp *github.ErrorResponse
+	if app != nil {
+		err := app.discover(context.TODO())
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("failed initializing github client, err: %s", err)
+		} else if err != nil {
+			logger.Warn("unable to discover GitHub App installations", zap.Error(err))
+		} else {
+			logger.Debug("discovered GitHub App installations", zap.Strings("owners", app.owners()))
+		}
 	}
-	logger.Debug(fmt.Sprintf("succesfully initiated github client, hourly rate limit: %d", limits.GetCore().Limit))
 
-	return &GitHubStore{
-		ownerFilter:           ownerFilter,
-		topicFilter:           topicFilter,
-		providerOwnerFilter:   providerOwnerFilter,
-		providerTopicFilter:   providerTopicFilter,
+	store := &GitHubStore{
+		moduleFilter:          moduleFilter,
+		providerFilter:        providerFilter,
 		client:                client,
+		app:                   app,
 		moduleCache:           make(map[string][]*core.ModuleVersion),
 		providerVersionsCache: make(map[string]*core.ProviderVersions),
 		providerCache:         make(map[string]*core.Provider),
 		logger:                logger,
-	}, nil
+	}
+	if authParams.BaseURL != "" {
+		store.sourceHost = client.BaseURL.Hostname()
+	}
+
+	// The rate limits of a GitHub App apply per installation
+	for _, owner := range store.rateLimitOwners() {
+		rateLimitClient := client
+		if app != nil {
+			rateLimitClient, _ = app.clientFor(owner)
+		}
+		limits, _, err := rateLimitClient.RateLimit.Get(context.TODO())
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("failed initializing github client, err: %s", err)
+		} else if err != nil {
+			logger.Warn("unable to reach GitHub while initializing github client", zap.Error(err))
+			break
+		}
+		logger.Debug(fmt.Sprintf("succesfully initiated github client, hourly rate limit: %d", limits.GetCore().Limit), zap.String("owner", owner))
+		store.rateLimiterFor(owner).setRates(map[string]*github.Rate{
+			"core":   limits.GetCore(),
+			"search": limits.GetSearch(),
+		})
+	}
+
+	return store, nil
+}
+
+// RateLimitStatus returns the last known GitHub API rate limit state. With a GitHub App, the state
+// of each installation is returned.
+func (s *GitHubStore) RateLimitStatus() RateLimitStatus {
+	if s.app == nil {
+		return s.rateLimiterFor("").status()
+	}
+
+	status := RateLimitStatus{Installations: make(map[string]RateLimitStatus)}
+	for _, owner := range s.app.owners() {
+		status.Installations[owner] = s.rateLimiterFor(owner).status()
+	}
+	if until := s.RateLimitedUntil(); !until.IsZero() {
+		status.PausedUntil = &until
+	}
+	return status
+}
+
+// RateLimitedUntil returns the point in time until which the store avoids calling
+// the GitHub API because of rate limiting. With a GitHub App, that is until the first
+// installation is resumed once all of them are paused. The zero value means no limit is in effect.
+// Callers reloading the caches on an interval should wait until this time has passed.
+func (s *GitHubStore) RateLimitedUntil() time.Time {
+	var until time.Time
+	for i, owner := range s.rateLimitOwners() {
+		paused := s.rateLimiterFor(owner).pausedUntil()
+		if paused.IsZero() {
+			return time.Time{}
+		}
+		if i == 0 || paused.Before(until) {
+			until = paused
+		}
+	}
+	return until
+}
+
+// Status returns the rate limit state, to be included in the registry health output.
+func (s *GitHubStore) Status(ctx context.Context) any {
+	return s.RateLimitStatus()
+}
+
+// rateLimiterFor returns the rate limits of API calls on repositories of the owner. With a GitHub App,
+// each installation has its own rate limits.
+func (s *GitHubStore) rateLimiterFor(owner string) *rateLimiter {
+	if s.app == nil {
+		return s.rateLimits.get("")
+	}
+	return s.rateLimits.get(strings.ToLower(owner))
+}
+
+// rateLimitOwners returns the owners with rate limits of their own: the owners of the installations
+// of the GitHub App, or "" when authenticated with a token.
+func (s *GitHubStore) rateLimitOwners() []string {
+	if s.app == nil {
+		return []string{""}
+	}
+	return s.app.owners()
+}
+
+// checkRateLimit returns an error wrapping a `RateLimitedError` if API calls on repositories
+// of the owner are paused until a rate limit window resets.
+func (s *GitHubStore) checkRateLimit(owner string) error {
+	if until := s.rateLimiterFor(owner).pausedUntil(); !until.IsZero() {
+		return &core.Error{Kind: core.ErrRateLimited, Err: &RateLimitedError{Until: until}, RetryAfter: time.Until(until)}
+	}
+	return nil
+}
+
+// checkReloadRateLimit returns a `RateLimitedError` if the calls of all installations are paused,
+// so that a full reload can't be made. Installations that are paused are skipped by full reloads.
+func (s *GitHubStore) checkReloadRateLimit() error {
+	if until := s.RateLimitedUntil(); !until.IsZero() {
+		return &RateLimitedError{Until: until}
+	}
+	return nil
 }
 
 // ListModuleVersions returns a list of module versions.
@@ -125,7 +268,7 @@ func (s *GitHubStore) ListModuleVersions(ctx context.Context, namespace, name, p
 	key := cacheKey(namespace, name, provider)
 	versions, ok := s.moduleCache[key]
 	if !ok {
-		return nil, fmt.Errorf("module '%s' not found", key)
+		return nil, core.NotFoundError("module '%s' not found", key)
 	}
 
 	return versions, nil
@@ -139,7 +282,7 @@ func (s *GitHubStore) GetModuleVersion(ctx context.Context, namespace, name, pro
 	key := cacheKey(namespace, name, provider)
 	versions, ok := s.moduleCache[key]
 	if !ok {
-		return nil, fmt.Errorf("module '%s' not found", key)
+		return nil, core.NotFoundError("module '%s' not found", key)
 	}
 
 	for _, v := range versions {
@@ -148,7 +291,39 @@ func (s *GitHubStore) GetModuleVersion(ctx context.Context, namespace, name, pro
 		}
 	}
 
-	return nil, fmt.Errorf("version '%s' not found for module '%s'", version, key)
+	return nil, core.NotFoundError("version '%s' not found for module '%s'", version, key)
+}
+
+// ListModules returns all modules, sorted by address.
+func (s *GitHubStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	s.moduleMut.RLock()
+	defer s.moduleMut.RUnlock()
+
+	modules := make([]core.ModuleAddress, 0, len(s.moduleCache))
+	for key := range s.moduleCache {
+		parts := strings.SplitN(key, "/", 3)
+		modules = append(modules, core.ModuleAddress{Namespace: parts[0], Name: parts[1], Provider: parts[2]})
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return cacheKey(modules[i].Namespace, modules[i].Name, modules[i].Provider) < cacheKey(modules[j].Namespace, modules[j].Name, modules[j].Provider)
+	})
+	return modules, nil
+}
+
+// ListProviders returns all providers, sorted by address.
+func (s *GitHubStore) ListProviders(ctx context.Context) ([]core.ProviderAddress, error) {
+	s.providerMut.RLock()
+	defer s.providerMut.RUnlock()
+
+	providers := make([]core.ProviderAddress, 0, len(s.providerVersionsCache))
+	for key := range s.providerVersionsCache {
+		namespace, name, _ := strings.Cut(key, "/")
+		providers = append(providers, core.ProviderAddress{Namespace: namespace, Name: name})
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return cacheKey(providers[i].Namespace, providers[i].Name) < cacheKey(providers[j].Namespace, providers[j].Name)
+	})
+	return providers, nil
 }
 
 func (s *GitHubStore) ListProviderVersions(ctx context.Context, namespace string, name string) (*core.ProviderVersions, error) {
@@ -158,7 +333,7 @@ func (s *GitHubStore) ListProviderVersions(ctx context.Context, namespace string
 	key := cacheKey(namespace, name)
 	versions, ok := s.providerVersionsCache[key]
 	if !ok {
-		return nil, fmt.Errorf("provider '%s' not found", key)
+		return nil, core.NotFoundError("provider '%s' not found", key)
 	}
 
 	return versions, nil
@@ -171,225 +346,365 @@ func (s *GitHubStore) GetProviderVersion(ctx context.Context, namespace string,
 	key := cacheKey(namespace, name, version, os, arch)
 	provider, ok := s.providerCache[key]
 	if !ok {
-		return nil, fmt.Errorf("provider '%s' not found", key)
+		return nil, core.NotFoundError("provider '%s' not found", key)
 	}
 
 	return provider, nil
 }
 
-func (s *GitHubStore) GetProviderAsset(ctx context.Context, owner string, repo string, tag string, assetName string) (io.ReadCloser, error) {
-	nameKey := strings.TrimPrefix(repo, "terraform-provider-")
-	key := cacheKey(owner, nameKey)
-	versions, ok := s.providerVersionsCache[key]
+// GetProviderAsset returns the contents of a provider release asset. Assets are streamed from GitHub,
+// with support for seeking, so that Range requests are served from GitHub. When an asset cache is
+// configured, verified assets are served from the cache, and added to it on first download.
+// Releases are looked up by version rather than tag, as the tag may differ from the version, and
+// draft releases have no tag.
+func (s *GitHubStore) GetProviderAsset(ctx context.Context, owner string, repo string, version string, assetName string) (io.ReadCloser, error) {
+	key := cacheKey(owner, repo, version)
+
+	s.providerMut.RLock()
+	releaseID, ok := s.providerReleaseIDs[key]
+	digest := s.providerAssetDigests[cacheKey(key, assetName)]
+	cache := s.assetCache
+	s.providerMut.RUnlock()
+
 	if !ok {
-		return nil, fmt.Errorf("provider '%s' not found", key)
+		return nil, core.NotFoundError("provider version '%s' not found", key)
 	}
 
-	found := false
-	for _, version := range versions.Versions {
-		if strings.TrimPrefix(tag, "v") == version.Version {
-			found = true
+	cacheable := cache != nil && digest != ""
+	if cacheable {
+		f, err := cache.Open(digest)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("unable to read asset from cache", zap.String("asset", assetName), zap.Error(err))
 		}
 	}
 
-	if !found {
-		return nil, fmt.Errorf("provider version '%s' not found", tag)
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
 	}
-
-	releases, _, err := s.client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+	release, resp, err := client.Repositories.GetRelease(ctx, owner, repo, releaseID)
+	s.rateLimiterFor(owner).observe(resp, err)
 	if err != nil {
 		s.logger.Error(err.Error())
-		return nil, err
+		return nil, backendError(err)
 	}
 
-	asset, err := s.findAsset(ctx, owner, repo, releases, assetName)
+	var asset *github.ReleaseAsset
+	for _, a := range release.Assets {
+		if a.GetName() == assetName {
+			asset = a
+			break
+		}
+	}
+	if asset == nil {
+		return nil, core.NotFoundError("asset '%s' not found", assetName)
+	}
+
+	// Start the download, to fail before the registry responds when GitHub is not able to serve the asset
+	body, err := s.openAsset(ctx, owner, repo, asset, 0)
 	if err != nil {
+		s.logger.Error(err.Error())
 		return nil, err
 	}
 
-	return asset, nil
-}
-
-func (s *GitHubStore) findAsset(ctx context.Context, owner string, repo string, byTag *github.RepositoryRelease, assetName string) (io.ReadCloser, error) {
-	var (
-		err          error
-		releaseAsset io.ReadCloser
-	)
+	// GitHub reports a size of zero when it is not known, which can't be served as a seekable asset
+	if asset.GetSize() <= 0 {
+		return body, nil
+	}
 
-	for _, asset := range byTag.Assets {
-		if asset.GetName() == assetName {
-			releaseAsset, _, err = s.client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), http.DefaultClient)
-			if err != nil {
-				s.logger.Error(err.Error())
-				return nil, fmt.Errorf("error getting asset: %s", err)
-			} else {
-				break
-			}
+	reader := &assetReader{
+		open: func(offset int64) (io.ReadCloser, error) {
+			return s.openAsset(ctx, owner, repo, asset, offset)
+		},
+		body:   body,
+		size:   int64(asset.GetSize()),
+		logger: s.logger,
+	}
+	if cacheable {
+		reader.cache, err = cache.Create(digest)
+		if err != nil {
+			s.logger.Warn("unable to cache asset", zap.String("asset", assetName), zap.Error(err))
 		}
 	}
-	return releaseAsset, nil
+	return reader, nil
 }
 
 // ReloadProviderCache queries the GitHub API and reloads the local providerCache of provider versions.
 // Should be called at least once after initialisation and probably on regular
 // intervals afterward to keep providerCache up-to-date.
 func (s *GitHubStore) ReloadProviderCache(ctx context.Context) error {
-	var rateLimitErr *github.RateLimitError
+	if err := s.checkReloadRateLimit(); err != nil {
+		return err
+	}
+	s.providerReloadMut.Lock()
+	defer s.providerReloadMut.Unlock()
+	s.refreshInstallations(ctx)
 
-	repos, err := s.searchProviderRepositories(ctx)
+	repos, paused, err := s.searchRepositories(ctx, s.providerFilter)
 	if err != nil {
 		return err
 	}
 
 	if len(repos) == 0 {
 		s.logger.Warn("could not find any provider repos matching filter",
-			zap.String("topic", s.providerTopicFilter),
-			zap.String("owner", s.providerOwnerFilter))
+			zap.Strings("topics", s.providerFilter.Topics),
+			zap.Strings("owners", s.providerFilter.Owners))
 	}
 
+	// Expired releases are validated again below
+	s.providerIgnored.prune()
+
 	providerVersionsCache := make(map[string]*core.ProviderVersions)
 	providerCache := make(map[string]*core.Provider)
+	providerAssetDigests := make(map[string]string)
+	providerReleaseIDs := make(map[string]int64)
+
+	s.providerMut.RLock()
+	assetDigests := newDigestCache(s.assetDigestCache)
+	s.providerMut.RUnlock()
+
+	policy := s.getVersionPolicy()
 
 	for _, repo := range repos {
-		owner, name, err := getOwnerRepoName(repo)
+		loaded, err := s.loadProvider(ctx, repo, policy, assetDigests)
 		if err != nil {
 			return err
 		}
-
-		// HashiCorp (and thus we) require that all provider repositories must match the pattern
-		// terraform-provider-{NAME}. Only lowercase repository names are supported.
-		if !strings.HasPrefix(name, "terraform-provider-") {
+		if loaded == nil {
 			continue
 		}
-		nameKey := strings.TrimPrefix(name, "terraform-provider-")
+		providerVersionsCache[loaded.key] = loaded.versions
+		maps.Copy(providerCache, loaded.providers)
+		maps.Copy(providerAssetDigests, loaded.digests)
+		maps.Copy(providerReleaseIDs, loaded.releaseIDs)
+	}
 
-		start := time.Now()
-		releases, err := s.listAllRepoReleases(ctx, owner, name)
-		if err != nil {
-			return err
+	// The providers of installations waiting for their rate limit to reset are kept as they were
+	s.providerMut.RLock()
+	keepOwners(providerVersionsCache, s.providerVersionsCache, paused)
+	keepOwners(providerCache, s.providerCache, paused)
+	keepOwners(providerAssetDigests, s.providerAssetDigests, paused)
+	keepOwners(providerReleaseIDs, s.providerReleaseIDs, paused)
+	s.providerMut.RUnlock()
+	if len(paused) > 0 {
+		assetDigests.fresh = mergeMaps(maps.Clone(assetDigests.previous), assetDigests.fresh)
+	}
+
+	// This cleans up modules that are no longer available and
+	// reduces write lock duration by not modifying the caches directly
+	// on each iteration.
+	s.providerMut.Lock()
+	s.providerCache = providerCache
+	s.providerVersionsCache = providerVersionsCache
+	s.assetDigestCache = assetDigests.fresh
+	s.providerAssetDigests = providerAssetDigests
+	s.providerReleaseIDs = providerReleaseIDs
+	s.providerReloadedAt = time.Now().UTC()
+	s.providerMut.Unlock()
+
+	return nil
+}
+
+// providerRepository holds the cache entries loaded from a provider repository.
+type providerRepository struct {
+	key        string
+	versions   *core.ProviderVersions
+	providers  map[string]*core.Provider
+	digests    map[string]string
+	releaseIDs map[string]int64
+}
+
+// loadProvider validates the releases of the provider in `repo` and returns its cache entries.
+// Returns nil if the repository is not a provider repository.
+func (s *GitHubStore) loadProvider(ctx context.Context, repo *github.Repository, policy VersionPolicy, assetDigests *digestCache) (*providerRepository, error) {
+	owner, name, err := getOwnerRepoName(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// HashiCorp (and thus we) require that all provider repositories must match the pattern
+	// terraform-provider-{NAME}. Only lowercase repository names are supported.
+	if !strings.HasPrefix(name, "terraform-provider-") {
+		return nil, nil
+	}
+	nameKey := strings.TrimPrefix(name, "terraform-provider-")
+
+	start := time.Now()
+	releases, err := s.listAllRepoReleases(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		semvers        []*goversion.Version
+		semverReleases []*github.RepositoryRelease
+	)
+	for _, release := range releases {
+		if v, ok := policy.releaseVersion(owner, release); ok {
+			semvers = append(semvers, v)
+			semverReleases = append(semverReleases, release)
 		}
+	}
 
-		var versions []core.ProviderVersion
-		for _, release := range releases {
-			var platforms []core.Platform
-			version := strings.TrimPrefix(release.GetName(), "v")
+	keepMajor := policy.keepMajor(semvers)
+	loaded := &providerRepository{
+		key:        cacheKey(owner, nameKey),
+		providers:  make(map[string]*core.Provider),
+		digests:    make(map[string]string),
+		releaseIDs: make(map[string]int64),
+	}
+	var versions []core.ProviderVersion
+	for i, release := range semverReleases {
+		if !keepMajor(semvers[i]) {
+			continue
+		}
 
-			if _, ok := s.providerIgnoreCache.Load(cacheKey(nameKey, version)); ok {
-				s.logger.Debug(fmt.Sprintf("ignoring release [%s/%s], previously found to be not valid", nameKey, version))
-				continue
-			}
+		var platforms []core.Platform
+		version := semvers[i].Original()
 
-			SHASums, SHASumURL, SHASumFileName, err := s.getSHA256Sums(ctx, owner, name, release.Assets)
-			if err != nil {
-				if errors.As(err, &rateLimitErr) {
-					return err
-				}
-				s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s] - could not find SHA checksums: %s", nameKey, version, err))
-				s.providerIgnoreCache.Store(cacheKey(nameKey, version), true)
-				continue
-			}
+		if entry, ok := s.providerIgnored.lookup(owner, nameKey, version); ok {
+			s.logger.Debug(fmt.Sprintf("ignoring release [%s/%s/%s], previously found to be not valid", owner, nameKey, version),
+				zap.String("reason", entry.Reason),
+				zap.Time("until", entry.ExpiresAt),
+			)
+			continue
+		}
 
-			// not considered a valid release if a shasum file was not part of the release
-			if SHASumURL == "" {
-				if errors.As(err, &rateLimitErr) {
-					return err
-				}
-				s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s] - could not find SHA checksums", nameKey, version))
-				s.providerIgnoreCache.Store(cacheKey(nameKey, version), true)
-				continue
+		SHASums, SHASumURL, SHASumFileName, SHASumContent, err := s.getSHA256Sums(ctx, owner, name, release.Assets)
+		// not considered a valid release if a shasum file was not part of the release
+		if err == nil && SHASumURL == "" {
+			err = storeutil.InvalidReleaseError{Reason: "could not find SHA checksums"}
+		}
+		if err != nil {
+			if err := s.skipProviderRelease(owner, nameKey, version, err); err != nil {
+				return nil, err
 			}
+			continue
+		}
 
-			providerProtocols, err := s.getProviderProtocols(ctx, owner, name, release.Assets)
-			if err != nil {
-				if errors.As(err, &rateLimitErr) {
-					return err
-				}
-				s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s] - unable to identify provider protocol", nameKey, version))
-				s.providerIgnoreCache.Store(cacheKey(nameKey, version), true)
-				continue
+		providerProtocols, err := s.getProviderProtocols(ctx, owner, name, release.Assets)
+		if err != nil {
+			if err := s.skipProviderRelease(owner, nameKey, version, err); err != nil {
+				return nil, err
 			}
+			continue
+		}
 
-			keys, err := s.getGPGPublicKey(ctx, release, owner, name)
-			if err != nil || len(keys) != 1 {
-				if errors.As(err, &rateLimitErr) {
-					return err
-				}
-				s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s] - unable to get GPG Public Key", nameKey, version))
-				s.providerIgnoreCache.Store(cacheKey(nameKey, version), true)
-				continue
+		keys, err := s.getGPGPublicKey(ctx, release, owner, name)
+		if err == nil && len(keys) != 1 {
+			err = storeutil.InvalidReleaseError{Reason: "unable to get GPG Public Key"}
+		}
+		if err != nil {
+			if err := s.skipProviderRelease(owner, nameKey, version, err); err != nil {
+				return nil, err
 			}
+			continue
+		}
 
-			for _, asset := range release.Assets {
-				platform, ok := extractOsArch(asset.GetName())
+		verified, err := s.verifyProviderRelease(ctx, owner, name, release, keys[0].ASCIIArmor, SHASums, SHASumFileName, SHASumContent, assetDigests)
+		if err != nil {
+			if err := s.skipProviderRelease(owner, nameKey, version, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for assetName, digest := range verified {
+			loaded.digests[cacheKey(owner, name, version, assetName)] = digest
+		}
+		loaded.releaseIDs[cacheKey(owner, name, version)] = release.GetID()
 
-				// if asset does not contain os/arch info, it is not a provider binary
-				if !ok {
-					continue
-				}
+		for _, asset := range release.Assets {
+			platform, ok := extractOsArch(asset.GetName())
 
-				platforms = append(platforms, platform)
+			// if asset does not contain os/arch info, it is not a provider binary
+			if !ok {
+				continue
+			}
 
-				downloadUrl := asset.GetBrowserDownloadURL()
-				SHASumSigURL := SHASumURL + ".sig"
-				if repo.GetPrivate() {
-					downloadUrl = fmt.Sprintf("/download/provider/%s/%s/v%s/asset/%s", owner, name, version, asset.GetName())
-					SHASumURL = fmt.Sprintf("/download/provider/%s/%s/v%s/asset/%s", owner, name, version, SHASumFileName)
-					SHASumSigURL = fmt.Sprintf("/download/provider/%s/%s/v%s/asset/%s", owner, name, version, SHASumFileName+".sig")
-				}
+			platforms = append(platforms, platform)
 
-				p := &core.Provider{
-					Protocols:           providerProtocols,
-					OS:                  platform.OS,
-					Arch:                platform.Arch,
-					Filename:            asset.GetName(),
-					DownloadURL:         downloadUrl,
-					SHASumsURL:          SHASumURL,
-					SHASumsSignatureURL: SHASumSigURL,
-					SHASum:              SHASums[asset.GetName()],
-					SigningKeys:         core.SigningKeys{GPGPublicKeys: keys},
-				}
+			downloadUrl := asset.GetBrowserDownloadURL()
+			SHASumSigURL := SHASumURL + ".sig"
+			if repo.GetPrivate() {
+				downloadUrl = storeutil.ProviderProxyURL(owner, name, version, asset.GetName())
+				SHASumURL = storeutil.ProviderProxyURL(owner, name, version, SHASumFileName)
+				SHASumSigURL = storeutil.ProviderProxyURL(owner, name, version, SHASumFileName+".sig")
+			}
 
-				// update the fresh providerCache
-				providerCache[cacheKey(owner, nameKey, version, platform.OS, platform.Arch)] = p
+			p := &core.Provider{
+				Protocols:           providerProtocols,
+				OS:                  platform.OS,
+				Arch:                platform.Arch,
+				Filename:            asset.GetName(),
+				DownloadURL:         downloadUrl,
+				SHASumsURL:          SHASumURL,
+				SHASumsSignatureURL: SHASumSigURL,
+				SHASum:              SHASums[asset.GetName()],
+				SigningKeys:         core.SigningKeys{GPGPublicKeys: keys},
 			}
 
-			if len(platforms) > 0 {
-				pv := core.ProviderVersion{
-					Version:   version,
-					Protocols: providerProtocols,
-					Platforms: platforms,
-				}
-				versions = append(versions, pv)
+			loaded.providers[cacheKey(owner, nameKey, version, platform.OS, platform.Arch)] = p
+		}
+
+		if len(platforms) > 0 {
+			pv := core.ProviderVersion{
+				Version:   version,
+				Protocols: providerProtocols,
+				Platforms: platforms,
 			}
+			versions = append(versions, pv)
 		}
+	}
 
-		duration := time.Since(start)
-		s.logger.Debug("found provider",
-			zap.String("name", fmt.Sprintf("%s/%s", owner, nameKey)),
-			zap.Int("versions", len(versions)),
-			zap.Duration("duration", duration),
-		)
+	duration := time.Since(start)
+	s.logger.Debug("found provider",
+		zap.String("name", fmt.Sprintf("%s/%s", owner, nameKey)),
+		zap.Int("versions", len(versions)),
+		zap.Duration("duration", duration),
+	)
 
-		// update the fresh providerVersionCache
-		providerVersionsCache[cacheKey(owner, nameKey)] = &core.ProviderVersions{Versions: versions}
+	loaded.versions = &core.ProviderVersions{Versions: versions}
+	return loaded, nil
+}
+
+// skipProviderRelease leaves the release out of the current reload because of `err`. Releases found
+// to be invalid are ignored until re-validation, while releases that could not be read, e.g. because
+// GitHub is unavailable, are retried on the next reload. Rate limit errors are returned, to stop the reload.
+func (s *GitHubStore) skipProviderRelease(owner, name, version string, err error) error {
+	if isRateLimitError(err) {
+		return err
 	}
 
-	// This cleans up modules that are no longer available and
-	// reduces write lock duration by not modifying the caches directly
-	// on each iteration.
-	s.providerMut.Lock()
-	s.providerCache = providerCache
-	s.providerVersionsCache = providerVersionsCache
-	s.providerMut.Unlock()
+	var invalid storeutil.InvalidReleaseError
+	if errors.As(err, &invalid) {
+		s.ignoreProviderRelease(owner, name, version, invalid.Reason)
+		return nil
+	}
 
+	s.logger.Warn(fmt.Sprintf("unable to validate release [%s/%s/%s], retrying on next reload", owner, name, version), zap.Error(err))
 	return nil
 }
 
+// ignoreProviderRelease logs why a provider release is not valid, and ignores it until re-validation.
+func (s *GitHubStore) ignoreProviderRelease(owner, name, version, reason string) {
+	s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s/%s] - %s", owner, name, version, reason))
+	s.providerIgnored.ignore(owner, name, version, reason)
+}
+
 func (s *GitHubStore) getGPGPublicKey(ctx context.Context, release *github.RepositoryRelease, owner string, name string) ([]core.GpgPublicKeys, error) {
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
+
 	var keys []core.GpgPublicKeys
 	for _, asset := range release.Assets {
 		if strings.Contains(asset.GetName(), "gpg-public-key.pem") {
-			releaseAsset, _, err := s.client.Repositories.DownloadReleaseAsset(ctx, owner, name, asset.GetID(), http.DefaultClient)
+			releaseAsset, _, err := client.Repositories.DownloadReleaseAsset(ctx, owner, name, asset.GetID(), http.DefaultClient)
+			s.rateLimiterFor(owner).observe(nil, err)
 			if err != nil {
 				return nil, err
 			}
@@ -402,11 +717,11 @@ func (s *GitHubStore) getGPGPublicKey(ctx context.Context, release *github.Repos
 
 			els, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(all))
 			if err != nil {
-				return nil, err
+				return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("unable to read GPG Public Key: %s", err)}
 			}
 
 			if len(els) != 1 {
-				return nil, fmt.Errorf("GPG Key contains %d entities, wanted 1", len(els))
+				return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("GPG Key contains %d entities, wanted 1", len(els))}
 			}
 
 			key := els[0]
@@ -422,49 +737,48 @@ func (s *GitHubStore) getGPGPublicKey(ctx context.Context, release *github.Repos
 // Should be called at least once after initialisation and probably on regular
 // intervals afterward to keep moduleCache up-to-date.
 func (s *GitHubStore) ReloadCache(ctx context.Context) error {
-	repos, err := s.searchModuleRepositories(ctx)
+	if err := s.checkReloadRateLimit(); err != nil {
+		return err
+	}
+	s.moduleReloadMut.Lock()
+	defer s.moduleReloadMut.Unlock()
+	s.refreshInstallations(ctx)
+
+	repos, paused, err := s.searchRepositories(ctx, s.moduleFilter)
 	if err != nil {
 		return err
 	}
 
 	if len(repos) == 0 {
 		s.logger.Warn("could not find any module repos matching filter",
-			zap.String("topic", s.topicFilter),
-			zap.String("owner", s.ownerFilter))
+			zap.Strings("topics", s.moduleFilter.Topics),
+			zap.Strings("owners", s.moduleFilter.Owners))
 	}
 
 	fresh := make(map[string][]*core.ModuleVersion)
+	freshTags := make(map[string]string)
+	policy := s.getVersionPolicy()
 
-	for _, repo := range repos {
-		owner, name, err := getOwnerRepoName(repo)
-		if err != nil {
-			return err
-		}
-
-		key := fmt.Sprintf("%s/%s/generic", owner, name)
+	s.moduleMut.RLock()
+	docs := newDocCache(s.moduleDocCache)
+	s.moduleMut.RUnlock()
 
-		tags, err := s.listAllRepoTags(ctx, owner, name)
+	for _, repo := range repos {
+		key, versions, tags, err := s.loadModule(ctx, repo, policy, docs)
 		if err != nil {
 			return err
 		}
-
-		versions := make([]*core.ModuleVersion, 0)
-		for _, tag := range tags {
-			version := strings.TrimPrefix(tag.GetName(), "v") // Terraform uses SemVer names without 'v' prefix
-			if _, err := goversion.NewSemver(version); err == nil {
-				versions = append(versions, &core.ModuleVersion{
-					Version:   version,
-					SourceURL: fmt.Sprintf("git::ssh://git@github.com/%s/%s.git?ref=%s", owner, name, tag.GetName()),
-				})
-			}
-		}
-
-		s.logger.Debug("found module",
-			zap.String("name", key),
-			zap.Int("version_count", len(versions)),
-		)
-
 		fresh[key] = versions
+		maps.Copy(freshTags, tags)
+	}
+
+	// The modules of installations waiting for their rate limit to reset are kept as they were
+	s.moduleMut.RLock()
+	keepOwners(fresh, s.moduleCache, paused)
+	keepOwners(freshTags, s.moduleTagCache, paused)
+	s.moduleMut.RUnlock()
+	if len(paused) > 0 {
+		docs.fresh = mergeMaps(maps.Clone(docs.previous), docs.fresh)
 	}
 
 	// This cleans up modules that are no longer available and
@@ -472,21 +786,78 @@ func (s *GitHubStore) ReloadCache(ctx context.Context) error {
 	// on each iteration.
 	s.moduleMut.Lock()
 	s.moduleCache = fresh
+	s.moduleTagCache = freshTags
+	s.moduleDocCache = docs.fresh
+	s.moduleReloadedAt = time.Now().UTC()
 	s.moduleMut.Unlock()
 
 	return nil
 }
 
+// loadModule returns the cache key and versions of the module in `repo`, along with the tag of each version.
+func (s *GitHubStore) loadModule(ctx context.Context, repo *github.Repository, policy VersionPolicy, docs *docCache) (string, []*core.ModuleVersion, map[string]string, error) {
+	owner, name, err := getOwnerRepoName(repo)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	key := fmt.Sprintf("%s/%s/generic", owner, name)
+
+	tags, err := s.listAllRepoTags(ctx, owner, name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var (
+		semvers    []*goversion.Version
+		semverTags []*github.RepositoryTag
+	)
+	for _, tag := range tags {
+		if v, ok := policy.version(owner, tag.GetName()); ok {
+			semvers = append(semvers, v)
+			semverTags = append(semverTags, tag)
+		}
+	}
+
+	keepMajor := policy.keepMajor(semvers)
+	versions := make([]*core.ModuleVersion, 0)
+	versionTags := make(map[string]string)
+	for i, tag := range semverTags {
+		if !keepMajor(semvers[i]) {
+			continue
+		}
+		version := strings.TrimPrefix(tag.GetName(), "v") // Terraform uses SemVer names without 'v' prefix
+		versions = append(versions, &core.ModuleVersion{
+			Version:   version,
+			SourceURL: s.moduleSourceURL(owner, name, repo, tag),
+			Metadata:  s.moduleMetadata(ctx, owner, name, repo, tag, docs),
+		})
+		versionTags[cacheKey(key, version)] = tag.GetName()
+	}
+
+	s.logger.Debug("found module",
+		zap.String("name", key),
+		zap.Int("version_count", len(versions)),
+	)
+
+	return key, versions, versionTags, nil
+}
+
 // listAllRepoTags lists all tags for the specified repository.
 // When an error is returned, the tags fetched up until the point of error
 // is also returned.
 func (s *GitHubStore) listAllRepoTags(ctx context.Context, owner, repo string) ([]*github.RepositoryTag, error) {
 	var allTags []*github.RepositoryTag
 
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
 	opts := &github.ListOptions{PerPage: 100}
 
 	for {
-		tags, resp, err := s.client.Repositories.ListTags(ctx, owner, repo, opts)
+		tags, resp, err := client.Repositories.ListTags(ctx, owner, repo, opts)
+		s.rateLimiterFor(owner).observe(resp, err)
 		if err != nil {
 			return allTags, err
 		}
@@ -504,11 +875,16 @@ func (s *GitHubStore) listAllRepoTags(ctx context.Context, owner, repo string) (
 func (s *GitHubStore) listAllRepoReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
 	var allReleases []*github.RepositoryRelease
 
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
 	opts := &github.ListOptions{
 		PerPage: 100,
 	}
 	for {
-		releases, resp, err := s.client.Repositories.ListReleases(ctx, owner, repo, opts)
+		releases, resp, err := client.Repositories.ListReleases(ctx, owner, repo, opts)
+		s.rateLimiterFor(owner).observe(resp, err)
 		if err != nil {
 			return allReleases, err
 		}
@@ -520,56 +896,133 @@ func (s *GitHubStore) listAllRepoReleases(ctx context.Context, owner, repo strin
 	}
 	return allReleases, nil
 }
-func (s *GitHubStore) searchModuleRepositories(ctx context.Context) ([]*github.Repository, error) {
-	var filters []string
 
-	if s.ownerFilter != "" {
-		filters = append(filters, fmt.Sprintf(`org:"%s"`, s.ownerFilter))
+// searchRepositories fetches all repositories matching the filter. The owners of GitHub App installations
+// waiting for their rate limit to reset are skipped, and returned in `paused` by lowercase login.
+// When an error is returned, the repositories fetched up until the point of error
+// is also returned.
+func (s *GitHubStore) searchRepositories(ctx context.Context, filter RepositoryFilter) ([]*github.Repository, map[string]bool, error) {
+	var (
+		allRepos []*github.Repository
+		seen     = make(map[string]bool)
+		paused   = make(map[string]bool)
+	)
+
+	for _, query := range filter.queries() {
+		clients, err := s.searchClients(query.owner, paused)
+		if err != nil {
+			return allRepos, paused, err
+		}
+		for _, c := range clients {
+			opts := &github.SearchOptions{}
+			opts.ListOptions.PerPage = 100
+
+			for {
+				result, resp, err := c.client.Search.Repositories(ctx, query.query, opts)
+				s.rateLimiterFor(c.owner).observe(resp, err)
+				if err != nil {
+					return allRepos, paused, err
+				}
+
+				for _, repo := range result.Repositories {
+					if seen[repo.GetFullName()] || !filter.matches(repo) {
+						continue
+					}
+					seen[repo.GetFullName()] = true
+					allRepos = append(allRepos, repo)
+				}
+
+				if resp.NextPage == 0 {
+					break
+				}
+				opts.Page = resp.NextPage
+			}
+		}
 	}
-	if s.topicFilter != "" {
-		filters = append(filters, fmt.Sprintf(`topic:"%s"`, s.topicFilter))
+
+	for owner := range paused {
+		s.logger.Warn("GitHub App installation rate limited, keeping its cached repositories", zap.String("owner", owner))
 	}
-	return s.searchRepositories(ctx, filters)
+	return allRepos, paused, nil
 }
 
-func (s *GitHubStore) searchProviderRepositories(ctx context.Context) ([]*github.Repository, error) {
-	var filters []string
-
-	if s.ownerFilter != "" {
-		filters = append(filters, fmt.Sprintf(`org:"%s"`, s.providerOwnerFilter))
+// clientFor returns the client to use for API calls on repositories of the owner. With a GitHub App,
+// that is the client of the installation on the owner, as installation tokens only give access to
+// the repositories of the installation. Fails while the calls of the owner are paused by rate limiting.
+func (s *GitHubStore) clientFor(owner string) (*github.Client, error) {
+	if err := s.checkRateLimit(owner); err != nil {
+		return nil, err
+	}
+	if s.app == nil {
+		return s.client, nil
 	}
-	if s.topicFilter != "" {
-		filters = append(filters, fmt.Sprintf(`topic:"%s"`, s.providerTopicFilter))
+	if client, ok := s.app.clientFor(owner); ok {
+		return client, nil
 	}
-	return s.searchRepositories(ctx, filters)
+	return nil, core.UnavailableError("GitHub App %s is not installed on owner '%s'", s.app.ApplicationID, owner)
 }
 
-// searchRepositories fetches all repositories matching the configured filters.
-// When an error is returned, the repositories fetched up until the point of error
-// is also returned.
-func (s *GitHubStore) searchRepositories(ctx context.Context, filters []string) ([]*github.Repository, error) {
-	var (
-		allRepos []*github.Repository
-	)
+// searchClient is a client to search repositories with, and the owner of its installation.
+type searchClient struct {
+	owner  string
+	client *github.Client
+}
 
-	opts := &github.SearchOptions{}
-	opts.ListOptions.PerPage = 100
+// searchClients returns the clients to search repositories of the owner with. Searches without an
+// owner are made with the clients of all installations of the GitHub App. Installations waiting for
+// their rate limit to reset are added to `paused` instead.
+func (s *GitHubStore) searchClients(owner string, paused map[string]bool) ([]searchClient, error) {
+	owners := []string{owner}
+	if owner == "" && s.app != nil {
+		owners = s.app.owners()
+		if len(owners) == 0 {
+			return nil, core.UnavailableError("GitHub App %s has no installations", s.app.ApplicationID)
+		}
+	}
 
-	for {
-		result, resp, err := s.client.Search.Repositories(ctx, strings.Join(filters, " "), opts)
+	var clients []searchClient
+	for _, o := range owners {
+		client, err := s.clientFor(o)
+		if s.app != nil && errors.Is(err, core.ErrRateLimited) {
+			paused[strings.ToLower(o)] = true
+			continue
+		}
 		if err != nil {
-			return allRepos, err
+			return nil, err
 		}
+		clients = append(clients, searchClient{owner: o, client: client})
+	}
+	return clients, nil
+}
 
-		allRepos = append(allRepos, result.Repositories...)
+// refreshInstallations discovers GitHub App installations added or removed since the last reload.
+func (s *GitHubStore) refreshInstallations(ctx context.Context) {
+	if s.app == nil {
+		return
+	}
+	if err := s.app.discover(ctx); err != nil {
+		s.logger.Warn("unable to refresh GitHub App installations", zap.Error(err))
+	}
+}
 
-		if resp.NextPage == 0 {
-			break
-		}
-		opts.Page = resp.NextPage
+// gitHost returns the hostname to use in module source URLs.
+func (s *GitHubStore) gitHost() string {
+	if s.sourceHost == "" {
+		return "github.com"
 	}
+	return s.sourceHost
+}
 
-	return allRepos, nil
+// withEnterpriseURLs configures the client to use a GitHub Enterprise Server instance.
+// The client is returned unmodified if baseURL is empty.
+func withEnterpriseURLs(client *github.Client, baseURL, uploadURL string) (*github.Client, error) {
+	if baseURL == "" {
+		return client, nil
+	}
+	if uploadURL == "" {
+		uploadURL = baseURL
+	}
+	return client.WithEnterpriseURLs(baseURL, uploadURL)
 }
 
 func cacheKey(s ...string) string {
@@ -602,17 +1055,22 @@ func (s *GitHubStore) getProviderProtocols(ctx context.Context, owner string, re
 
 	for _, asset := range assets {
 		if strings.Contains(asset.GetName(), "manifest.json") {
+			client, err := s.clientFor(owner)
+			if err != nil {
+				return nil, err
+			}
 
-			responseBody, _, err := s.client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), http.DefaultClient)
+			responseBody, _, err := client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), http.DefaultClient)
+			s.rateLimiterFor(owner).observe(nil, err)
 			if err != nil {
-				return nil, fmt.Errorf("unable to get manifest: %s", err)
+				return nil, fmt.Errorf("unable to get manifest: %w", err)
 			}
 
 			manifest := &core.ProviderManifest{}
 			err = json.NewDecoder(responseBody).Decode(manifest)
 			responseBody.Close()
 			if err != nil {
-				return nil, fmt.Errorf("unable to decode manifest: %s", err)
+				return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("unable to decode manifest: %s", err)}
 			}
 
 			providerProtocols = manifest.Metadata.ProtocolVersions
@@ -622,30 +1080,42 @@ func (s *GitHubStore) getProviderProtocols(ctx context.Context, owner string, re
 	return providerProtocols, nil
 }
 
-// A file named SHA256SUMS containing sums must be included in the release. Look for the file, and download it
-func (s *GitHubStore) getSHA256Sums(ctx context.Context, owner string, repo string, assets []*github.ReleaseAsset) (map[string]string, string, string, error) {
+// A file named SHA256SUMS containing sums must be included in the release. Look for the file, and download it.
+// The contents are returned along with the parsed sums, for verifying the signature of the file.
+func (s *GitHubStore) getSHA256Sums(ctx context.Context, owner string, repo string, assets []*github.ReleaseAsset) (map[string]string, string, string, []byte, error) {
 	var (
 		SHASums        map[string]string
 		SHASumURL      string
 		SHASumFileName string
+		SHASumContent  []byte
 	)
 
 	for _, asset := range assets {
 		if strings.Contains(asset.GetName(), "SHA256SUMS") && !strings.HasSuffix(asset.GetName(), ".sig") {
-			responseBody, _, err := s.client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), http.DefaultClient)
+			client, err := s.clientFor(owner)
 			if err != nil {
-				return nil, "", "", fmt.Errorf("unable to get SHA checksums: %s", err)
+				return nil, "", "", nil, err
+			}
+			responseBody, _, err := client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), http.DefaultClient)
+			s.rateLimiterFor(owner).observe(nil, err)
+			if err != nil {
+				return nil, "", "", nil, fmt.Errorf("unable to get SHA checksums: %w", err)
 			}
 
-			SHASums = parseSHASumsFile(responseBody)
+			SHASumContent, err = io.ReadAll(responseBody)
+			responseBody.Close()
+			if err != nil {
+				return nil, "", "", nil, fmt.Errorf("unable to get SHA checksums: %w", err)
+			}
+
+			SHASums = storeutil.ParseSHASums(SHASumContent)
 			SHASumURL = asset.GetBrowserDownloadURL()
 			SHASumFileName = asset.GetName()
-			responseBody.Close()
 			break
 		}
 	}
 
-	return SHASums, SHASumURL, SHASumFileName, nil
+	return SHASums, SHASumURL, SHASumFileName, SHASumContent, nil
 }
 
 // Splitting owner from FullName to avoid getting it from GetOwner().GetName(),
@@ -5,15 +5,40 @@
 package github
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/google/go-github/v76/github"
 	"github.com/matryer/is"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storetest"
 	"go.uber.org/zap"
 )
 
@@ -32,11 +57,10 @@ func TestGithubStore(t *testing.T) {
 
 		c := github.NewClient(mockedHTTPClient)
 		store := &GitHubStore{
-			ownerFilter: "test-owner",
-			topicFilter: "test-topic",
-			client:      c,
-			moduleCache: make(map[string][]*core.ModuleVersion),
-			logger:      zap.NewNop(),
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"test-topic"}},
+			client:       c,
+			moduleCache:  make(map[string][]*core.ModuleVersion),
+			logger:       zap.NewNop(),
 		}
 
 		err := store.ReloadCache(context.Background())
@@ -59,11 +83,10 @@ func TestGithubStore(t *testing.T) {
 		)
 		c := github.NewClient(mockedHTTPClient)
 		store := &GitHubStore{
-			ownerFilter: "test-owner",
-			topicFilter: "test-topic",
-			client:      c,
-			moduleCache: make(map[string][]*core.ModuleVersion),
-			logger:      zap.NewNop(),
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"test-topic"}},
+			client:       c,
+			moduleCache:  make(map[string][]*core.ModuleVersion),
+			logger:       zap.NewNop(),
 		}
 		store.client = c
 		err := store.ReloadCache(context.Background())
@@ -106,34 +129,1281 @@ func TestGetModuleVersion(t *testing.T) {
 
 	c := github.NewClient(mockedHTTPClient)
 	store := &GitHubStore{
-		ownerFilter: "test-owner",
-		topicFilter: "test-topic",
-		client:      c,
+		moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"test-topic"}},
+		client:       c,
+		moduleCache:  make(map[string][]*core.ModuleVersion),
+		logger:       zap.NewNop(),
+	}
+
+	err := store.ReloadCache(context.Background())
+	if err != nil {
+		t.Fatal("Could not ReloadCache")
+	}
+
+	t.Run("returns matching version", func(t *testing.T) {
+		is := is.New(t)
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.True(err == nil)
+		is.Equal(ver.Version, "1.0.0")
+		is.Equal(ver.SourceURL, "git::ssh://git@github.com/test-owner/test-repo.git?ref=v1.0.0")
+	})
+
+	t.Run("errs when missing", func(t *testing.T) {
+		is := is.New(t)
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.1")
+		is.True(err != nil)
+		is.True(ver == nil)
+		is.Equal(err.Error(), "version '1.0.1' not found for module 'test-owner/test-repo/generic'")
+	})
+
+}
+
+func TestEnterpriseServer(t *testing.T) {
+	t.Run("configures enterprise URLs", func(t *testing.T) {
+		is := is.New(t)
+		c, err := withEnterpriseURLs(github.NewClient(nil), "https://github.example.com", "")
+		is.NoErr(err)
+		is.Equal(c.BaseURL.String(), "https://github.example.com/api/v3/")
+		is.Equal(c.UploadURL.String(), "https://github.example.com/api/uploads/")
+	})
+
+	t.Run("keeps github.com when base URL is empty", func(t *testing.T) {
+		is := is.New(t)
+		c, err := withEnterpriseURLs(github.NewClient(nil), "", "")
+		is.NoErr(err)
+		is.Equal(c.BaseURL.String(), "https://api.github.com/")
+	})
+
+	t.Run("uses enterprise host in module source URLs", func(t *testing.T) {
+		is := is.New(t)
+		result := new(github.RepositoriesSearchResult)
+		result.Repositories = []*github.Repository{
+			{FullName: github.Ptr("test-owner/test-repo")},
+		}
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(mock.GetSearchRepositories, result),
+			mock.WithRequestMatch(
+				mock.GetReposTagsByOwnerByRepo,
+				[]github.RepositoryTag{{Name: github.Ptr("v1.0.0")}},
+			),
+		)
+		store := &GitHubStore{
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+			sourceHost:   "github.example.com",
+			client:       github.NewClient(mockedHTTPClient),
+			moduleCache:  make(map[string][]*core.ModuleVersion),
+			logger:       zap.NewNop(),
+		}
+
+		err := store.ReloadCache(context.Background())
+		is.NoErr(err)
+
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "git::ssh://git@github.example.com/test-owner/test-repo.git?ref=v1.0.0")
+	})
+}
+
+func TestGitHubAppInstallations(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1Pem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	pkcs8Pem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+
+	t.Run("parses private keys", func(t *testing.T) {
+		is := is.New(t)
+
+		key, err := parsePrivateKey(pkcs1Pem)
+		is.NoErr(err)
+		is.True(rsaKey.Equal(key))
+
+		key, err = parsePrivateKey(pkcs8Pem)
+		is.NoErr(err)
+		is.True(rsaKey.Equal(key))
+
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		is.NoErr(err)
+		ecPkcs8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+		is.NoErr(err)
+		_, err = parsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPkcs8}))
+		is.Equal(err.Error(), "private key must be an RSA key, is *ecdsa.PrivateKey")
+
+		_, err = parsePrivateKey([]byte("not a key"))
+		is.Equal(err.Error(), "private key is not PEM encoded")
+	})
+
+	t.Run("routes API calls to the installation of the owner", func(t *testing.T) {
+		is := is.New(t)
+
+		// Each installation gets its own token, which must only be used for its own owner
+		installations := []*github.Installation{
+			{ID: github.Ptr(int64(1)), Account: &github.User{Login: github.Ptr("Org-A")}},
+			{ID: github.Ptr(int64(2)), Account: &github.User{Login: github.Ptr("org-b")}},
+		}
+		tokenOwners := map[string]string{"Bearer token-1": "org-a", "Bearer token-2": "org-b"}
+
+		var (
+			mut        sync.Mutex
+			mismatches []string
+		)
+		checkOwner := func(r *http.Request, owner string) {
+			mut.Lock()
+			defer mut.Unlock()
+			if tokenOwners[r.Header.Get("Authorization")] != owner {
+				mismatches = append(mismatches, fmt.Sprintf("%s %s with %s", r.Method, r.URL, r.Header.Get("Authorization")))
+			}
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/v3/app/installations", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(mock.MustMarshal(installations))
+		})
+		mux.HandleFunc("POST /api/v3/app/installations/{id}/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write(mock.MustMarshal(github.InstallationToken{
+				Token:     github.Ptr("token-" + r.PathValue("id")),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			}))
+		})
+		mux.HandleFunc("GET /api/v3/rate_limit", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"resources": {"core": {"limit": 5000, "remaining": 5000}}}`))
+		})
+		mux.HandleFunc("GET /api/v3/search/repositories", func(w http.ResponseWriter, r *http.Request) {
+			owner := strings.TrimSuffix(strings.TrimPrefix(r.URL.Query().Get("q"), `org:"`), `"`)
+			checkOwner(r, strings.ToLower(owner))
+			w.Write(mock.MustMarshal(github.RepositoriesSearchResult{
+				Repositories: []*github.Repository{{FullName: github.Ptr(owner + "/module")}},
+			}))
+		})
+		mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}/tags", func(w http.ResponseWriter, r *http.Request) {
+			checkOwner(r, strings.ToLower(r.PathValue("owner")))
+			w.Write(mock.MustMarshal([]github.RepositoryTag{{Name: github.Ptr("v1.0.0")}}))
+		})
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		store, err := NewGitHubStore(
+			RepositoryFilter{Owners: []string{"Org-A", "org-b"}},
+			RepositoryFilter{},
+			GithubAuthParams{ApplicationID: "1", PrivatePem: pkcs8Pem, BaseURL: srv.URL},
+			zap.NewNop(),
+		)
+		is.NoErr(err)
+		is.Equal(store.app.owners(), []string{"org-a", "org-b"})
+
+		is.NoErr(store.ReloadCache(context.Background()))
+		is.Equal(mismatches, []string(nil))
+
+		for _, owner := range []string{"Org-A", "org-b"} {
+			_, err := store.GetModuleVersion(context.Background(), owner, "module", "generic", "1.0.0")
+			is.NoErr(err)
+		}
+
+		// Owners without an installation are not accessed with the token of another installation
+		store, err = NewGitHubStore(
+			RepositoryFilter{Owners: []string{"org-a", "org-c"}},
+			RepositoryFilter{},
+			GithubAuthParams{ApplicationID: "1", PrivatePem: pkcs8Pem, BaseURL: srv.URL},
+			zap.NewNop(),
+		)
+		is.NoErr(err)
+		err = store.ReloadCache(context.Background())
+		is.True(errors.Is(err, core.ErrUnavailable))
+		is.Equal(err.Error(), "GitHub App 1 is not installed on owner 'org-c'")
+		is.Equal(mismatches, []string(nil))
+	})
+
+	t.Run("rate limits apply per installation", func(t *testing.T) {
+		is := is.New(t)
+		installations := []*github.Installation{
+			{ID: github.Ptr(int64(1)), Account: &github.User{Login: github.Ptr("org-a")}},
+			{ID: github.Ptr(int64(2)), Account: &github.User{Login: github.Ptr("org-b")}},
+		}
+		reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+		var (
+			mut      sync.Mutex
+			limited  bool
+			searches = make(map[string]int)
+			tags     = []github.RepositoryTag{{Name: github.Ptr("v1.0.0")}}
+		)
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/v3/app/installations", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(mock.MustMarshal(installations))
+		})
+		mux.HandleFunc("POST /api/v3/app/installations/{id}/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write(mock.MustMarshal(github.InstallationToken{
+				Token:     github.Ptr("token-" + r.PathValue("id")),
+				ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+			}))
+		})
+		mux.HandleFunc("GET /api/v3/rate_limit", func(w http.ResponseWriter, r *http.Request) {
+			remaining := map[string]int{"Bearer token-1": 5000, "Bearer token-2": 4000}[r.Header.Get("Authorization")]
+			fmt.Fprintf(w, `{"resources": {"core": {"limit": 5000, "remaining": %d}}}`, remaining)
+		})
+		mux.HandleFunc("GET /api/v3/search/repositories", func(w http.ResponseWriter, r *http.Request) {
+			owner := strings.TrimSuffix(strings.TrimPrefix(r.URL.Query().Get("q"), `org:"`), `"`)
+			mut.Lock()
+			defer mut.Unlock()
+			searches[owner]++
+			if owner == "org-b" && limited {
+				w.Header().Set("X-RateLimit-Limit", "30")
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+				w.Header().Set("X-RateLimit-Resource", "search")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+				return
+			}
+			w.Write(mock.MustMarshal(github.RepositoriesSearchResult{
+				Repositories: []*github.Repository{{FullName: github.Ptr(owner + "/module")}},
+			}))
+		})
+		mux.HandleFunc("GET /api/v3/repos/{owner}/{repo}/tags", func(w http.ResponseWriter, r *http.Request) {
+			mut.Lock()
+			defer mut.Unlock()
+			w.Write(mock.MustMarshal(tags))
+		})
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		store, err := NewGitHubStore(
+			RepositoryFilter{Owners: []string{"org-a", "org-b"}},
+			RepositoryFilter{},
+			GithubAuthParams{ApplicationID: "1", PrivatePem: pkcs8Pem, BaseURL: srv.URL},
+			zap.NewNop(),
+		)
+		is.NoErr(err)
+
+		// The rate limits of each installation are looked up on startup
+		status := store.RateLimitStatus()
+		is.Equal(status.Installations["org-a"].Resources["core"].Remaining, 5000)
+		is.Equal(status.Installations["org-b"].Resources["core"].Remaining, 4000)
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		mut.Lock()
+		limited = true
+		mut.Unlock()
+		err = store.ReloadCache(context.Background())
+		is.True(isRateLimitError(err))
+
+		// Only the exhausted installation is paused
+		is.True(store.RateLimitedUntil().IsZero())
+		status = store.RateLimitStatus()
+		is.True(status.PausedUntil == nil)
+		is.True(status.Installations["org-a"].PausedUntil == nil)
+		is.Equal(*status.Installations["org-b"].PausedUntil, reset)
+
+		// Reloads go on for the other installations, and keep the modules of the paused one
+		mut.Lock()
+		tags = append(tags, github.RepositoryTag{Name: github.Ptr("v1.1.0")})
+		searchesB := searches["org-b"]
+		mut.Unlock()
+		is.NoErr(store.ReloadCache(context.Background()))
+		_, err = store.GetModuleVersion(context.Background(), "org-a", "module", "generic", "1.1.0")
+		is.NoErr(err)
+		_, err = store.GetModuleVersion(context.Background(), "org-b", "module", "generic", "1.0.0")
+		is.NoErr(err)
+		_, err = store.GetModuleVersion(context.Background(), "org-b", "module", "generic", "1.1.0")
+		is.True(errors.Is(err, core.ErrNotFound))
+		is.Equal(searches["org-b"], searchesB)
+
+		err = store.ReloadModule(context.Background(), "org-b", "module", "generic")
+		is.True(errors.Is(err, core.ErrRateLimited))
+		var limitedErr *RateLimitedError
+		is.True(errors.As(err, &limitedErr))
+		is.Equal(limitedErr.Until, reset)
+	})
+}
+
+func TestModuleSourceTemplates(t *testing.T) {
+	result := new(github.RepositoriesSearchResult)
+	result.Repositories = []*github.Repository{
+		{FullName: github.Ptr("test-owner/test-repo")},
+		{FullName: github.Ptr("other-owner/other-repo")},
+		{FullName: github.Ptr("other-owner/ci-repo"), Topics: []string{"terraform-module", "ci"}},
+	}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetSearchRepositories, result),
+		mock.WithRequestMatchHandler(
+			mock.GetReposTagsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(mock.MustMarshal([]github.RepositoryTag{
+					{Name: github.Ptr("v1.0.0"), Commit: &github.Commit{SHA: github.Ptr("abc123")}},
+				}))
+			}),
+		),
+	)
+	store := &GitHubStore{
+		client:      github.NewClient(mockedHTTPClient),
 		moduleCache: make(map[string][]*core.ModuleVersion),
 		logger:      zap.NewNop(),
 	}
 
-	err := store.ReloadCache(context.Background())
-	if err != nil {
-		t.Fatal("Could not ReloadCache")
+	err := store.SetModuleSourceTemplates(SourceTemplates{
+		Default: "git::https://{host}/{owner}/{repo}.git?ref={tag}",
+		Owners: map[string]string{
+			"Other-Owner": "https://{host}/{owner}/{repo}/archive/{sha}.tar.gz",
+		},
+		Topics: map[string]string{
+			"ci": "git::https://{host}/{owner}/{repo}.git?ref={sha}",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReloadCache(context.Background()); err != nil {
+		t.Fatal("Could not ReloadCache")
+	}
+
+	testcases := []struct {
+		name      string
+		namespace string
+		repo      string
+		sourceURL string
+	}{
+		{"default template", "test-owner", "test-repo", "git::https://github.com/test-owner/test-repo.git?ref=v1.0.0"},
+		{"owner template", "other-owner", "other-repo", "https://github.com/other-owner/other-repo/archive/abc123.tar.gz"},
+		{"topic template", "other-owner", "ci-repo", "git::https://github.com/other-owner/ci-repo.git?ref=abc123"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			ver, err := store.GetModuleVersion(context.Background(), tc.namespace, tc.repo, "generic", "1.0.0")
+			is.NoErr(err)
+			is.Equal(ver.SourceURL, tc.sourceURL)
+		})
+	}
+
+	t.Run("rejects unknown placeholders", func(t *testing.T) {
+		is := is.New(t)
+		err := store.SetModuleSourceTemplates(SourceTemplates{
+			Topics: map[string]string{"ci": "git::https://{host}/{org}/{repo}.git"},
+		})
+		is.True(err != nil)
+		is.Equal(err.Error(), "template for topic 'ci': unknown placeholder '{org}', must be one of {host}, {owner}, {repo}, {tag}, {version}, {sha}")
+	})
+
+	t.Run("rejects owners differing only in case", func(t *testing.T) {
+		is := is.New(t)
+		err := store.SetModuleSourceTemplates(SourceTemplates{
+			Owners: map[string]string{
+				"Other-Owner": "git::https://{host}/{owner}/{repo}.git",
+				"other-owner": "git::ssh://git@{host}/{owner}/{repo}.git",
+			},
+		})
+		is.True(err != nil)
+		is.Equal(err.Error(), "templates for owners 'Other-Owner' and 'other-owner' differ only in case")
+	})
+}
+
+// makeTarball returns a gzipped tarball with the given file names and contents.
+func makeTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}
+		if content == "" {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gzw.Close()
+	return buf.Bytes()
+}
+
+// readTarball returns the file names and contents of a gzipped tarball.
+func readTarball(t *testing.T, r io.Reader) map[string]string {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gzr)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(b)
+	}
+	return files
+}
+
+func TestModuleArchive(t *testing.T) {
+	tarball := makeTarball(t, map[string]string{
+		"test-owner-test-repo-abc123/":               "",
+		"test-owner-test-repo-abc123/main.tf":        "# main",
+		"test-owner-test-repo-abc123/sub/outputs.tf": "# outputs",
+	})
+	codeload := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test-owner/test-repo/legacy.tar.gz/refs/tags/v1.0.0" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(tarball)
+	}))
+	defer codeload.Close()
+
+	result := new(github.RepositoriesSearchResult)
+	result.Repositories = []*github.Repository{
+		{FullName: github.Ptr("test-owner/test-repo")},
+	}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetSearchRepositories, result),
+		mock.WithRequestMatch(
+			mock.GetReposTagsByOwnerByRepo,
+			[]github.RepositoryTag{{Name: github.Ptr("v1.0.0")}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposTarballByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", codeload.URL+"/test-owner/test-repo/legacy.tar.gz/refs/tags/v1.0.0")
+				w.WriteHeader(http.StatusFound)
+			}),
+		),
+	)
+	store := &GitHubStore{
+		moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+		client:       github.NewClient(mockedHTTPClient),
+		moduleCache:  make(map[string][]*core.ModuleVersion),
+		logger:       zap.NewNop(),
+	}
+	store.SetModuleArchiveDownloads(true)
+
+	if err := store.ReloadCache(context.Background()); err != nil {
+		t.Fatal("Could not ReloadCache")
+	}
+
+	t.Run("source URL points to registry", func(t *testing.T) {
+		is := is.New(t)
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "/download/module/test-owner/test-repo/generic/1.0.0?archive=tar.gz")
+	})
+
+	t.Run("archive has top-level directory stripped", func(t *testing.T) {
+		is := is.New(t)
+		archive, err := store.GetModuleArchive(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		defer archive.Close()
+
+		files := readTarball(t, archive)
+		is.Equal(files, map[string]string{
+			"main.tf":        "# main",
+			"sub/outputs.tf": "# outputs",
+		})
+	})
+
+	t.Run("errs when version is missing", func(t *testing.T) {
+		is := is.New(t)
+		_, err := store.GetModuleArchive(context.Background(), "test-owner", "test-repo", "generic", "2.0.0")
+		is.True(errors.Is(err, core.ErrNotFound))
+	})
+}
+
+func TestModuleDocs(t *testing.T) {
+	tarball := makeTarball(t, map[string]string{
+		"test-owner-test-repo-abc123/":          "",
+		"test-owner-test-repo-abc123/README.md": "# Test module",
+		"test-owner-test-repo-abc123/main.tf":   `variable "name" {}`,
+	})
+	var downloads int
+	codeload := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(tarball)
+	}))
+	defer codeload.Close()
+
+	result := new(github.RepositoriesSearchResult)
+	result.Repositories = []*github.Repository{
+		{FullName: github.Ptr("test-owner/test-repo"), Description: github.Ptr("Test module")},
+	}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetSearchRepositories, result, result, result),
+		mock.WithRequestMatch(
+			mock.GetReposTagsByOwnerByRepo,
+			[]github.RepositoryTag{{Name: github.Ptr("v1.0.0"), Commit: &github.Commit{SHA: github.Ptr("abc123")}}},
+			[]github.RepositoryTag{{Name: github.Ptr("v1.0.0"), Commit: &github.Commit{SHA: github.Ptr("abc123")}}},
+			[]github.RepositoryTag{{Name: github.Ptr("v1.0.0"), Commit: &github.Commit{SHA: github.Ptr("abc123")}}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposTarballByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", codeload.URL+"/test-owner/test-repo/legacy.tar.gz/refs/tags/v1.0.0")
+				w.WriteHeader(http.StatusFound)
+			}),
+		),
+	)
+	store := &GitHubStore{
+		moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+		client:       github.NewClient(mockedHTTPClient),
+		logger:       zap.NewNop(),
+	}
+
+	t.Run("without docs", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		is.Equal(*ver.Metadata, core.ModuleMetadata{CommitSHA: "abc123", Description: "Test module"})
+		is.Equal(downloads, 0)
+	})
+
+	t.Run("with docs", func(t *testing.T) {
+		is := is.New(t)
+		store.SetModuleDocs(true)
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.Metadata.CommitSHA, "abc123")
+		is.Equal(ver.Metadata.Description, "Test module")
+		is.Equal(ver.Metadata.Readme, "# Test module")
+		is.Equal(ver.Metadata.Inputs, []core.ModuleInput{{Name: "name", Required: true}})
+	})
+
+	t.Run("docs are cached by commit", func(t *testing.T) {
+		is := is.New(t)
+		seen := downloads
+		is.NoErr(store.ReloadCache(context.Background()))
+		is.Equal(downloads, seen) // not downloaded again
+
+		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.Metadata.Readme, "# Test module")
+
+		var buf bytes.Buffer
+		is.NoErr(store.SaveSnapshot(&buf))
+
+		restored := &GitHubStore{logger: zap.NewNop()}
+		is.NoErr(restored.LoadSnapshot(&buf))
+		is.Equal(restored.moduleDocCache["abc123"].Readme, "# Test module")
+		is.Equal(len(store.moduleDocCache), 1)
+	})
+}
+
+func TestRepositoryFilter(t *testing.T) {
+	t.Run("queries", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			filter  RepositoryFilter
+			queries []string
+		}{
+			{
+				"single owner and topic",
+				RepositoryFilter{Owners: []string{"a"}, Topics: []string{"x"}},
+				[]string{`org:"a" topic:"x"`},
+			},
+			{
+				"owners and topics are OR'd",
+				RepositoryFilter{Owners: []string{"a", "b"}, Topics: []string{"x", "y"}},
+				[]string{`org:"a" topic:"x"`, `org:"a" topic:"y"`, `org:"b" topic:"x"`, `org:"b" topic:"y"`},
+			},
+			{
+				"topics are AND'd",
+				RepositoryFilter{Owners: []string{"a", "b"}, Topics: []string{"x", "y"}, MatchAllTopics: true},
+				[]string{`org:"a" topic:"x" topic:"y"`, `org:"b" topic:"x" topic:"y"`},
+			},
+			{
+				"exclusions",
+				RepositoryFilter{Topics: []string{"x"}, ExcludeTopics: []string{"deprecated"}, ExcludeArchived: true, IncludeForks: true},
+				[]string{`topic:"x" -topic:"deprecated" archived:false fork:true`},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				is := is.New(t)
+				var queries []string
+				for _, q := range tt.filter.queries() {
+					queries = append(queries, q.query)
+				}
+				is.Equal(queries, tt.queries)
+			})
+		}
+	})
+
+	t.Run("matches", func(t *testing.T) {
+		filter := RepositoryFilter{
+			ExcludeTopics:   []string{"deprecated"},
+			ExcludeRepos:    []string{"a/excluded"},
+			ExcludeArchived: true,
+		}
+		tests := []struct {
+			name    string
+			repo    *github.Repository
+			matches bool
+		}{
+			{"plain", &github.Repository{FullName: github.Ptr("a/repo")}, true},
+			{"excluded by name", &github.Repository{FullName: github.Ptr("A/Excluded")}, false},
+			{"excluded by topic", &github.Repository{FullName: github.Ptr("a/repo"), Topics: []string{"deprecated"}}, false},
+			{"archived", &github.Repository{FullName: github.Ptr("a/repo"), Archived: github.Ptr(true)}, false},
+			{"fork", &github.Repository{FullName: github.Ptr("a/repo"), Fork: github.Ptr(true)}, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				is := is.New(t)
+				is.Equal(filter.matches(tt.repo), tt.matches)
+			})
+		}
+	})
+
+	t.Run("includes", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			filter   RepositoryFilter
+			repo     *github.Repository
+			includes bool
+		}{
+			{"any topic", RepositoryFilter{Owners: []string{"a"}, Topics: []string{"x", "y"}}, &github.Repository{FullName: github.Ptr("A/repo"), Topics: []string{"y"}}, true},
+			{"other owner", RepositoryFilter{Owners: []string{"a"}}, &github.Repository{FullName: github.Ptr("b/repo")}, false},
+			{"missing topic", RepositoryFilter{Topics: []string{"x"}}, &github.Repository{FullName: github.Ptr("a/repo"), Topics: []string{"y"}}, false},
+			{"all topics", RepositoryFilter{Topics: []string{"x", "y"}, MatchAllTopics: true}, &github.Repository{FullName: github.Ptr("a/repo"), Topics: []string{"x", "y"}}, true},
+			{"not all topics", RepositoryFilter{Topics: []string{"x", "y"}, MatchAllTopics: true}, &github.Repository{FullName: github.Ptr("a/repo"), Topics: []string{"x"}}, false},
+			{"excluded", RepositoryFilter{Topics: []string{"x"}, ExcludeArchived: true}, &github.Repository{FullName: github.Ptr("a/repo"), Topics: []string{"x"}, Archived: github.Ptr(true)}, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				is := is.New(t)
+				is.Equal(tt.filter.includes(tt.repo), tt.includes)
+			})
+		}
+	})
+
+	t.Run("searches each owner and merges results", func(t *testing.T) {
+		is := is.New(t)
+		var queries []string
+		ownerRepos := map[string]string{`org:"a"`: "a/repo", `org:"b"`: "b/repo"}
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetSearchRepositories,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					queries = append(queries, r.URL.Query().Get("q"))
+					result := &github.RepositoriesSearchResult{
+						Repositories: []*github.Repository{
+							{FullName: github.Ptr("shared/repo")},
+							{FullName: github.Ptr(ownerRepos[r.URL.Query().Get("q")])},
+						},
+					}
+					w.Write(mock.MustMarshal(result))
+				}),
+			),
+		)
+		store := &GitHubStore{
+			client: github.NewClient(mockedHTTPClient),
+			logger: zap.NewNop(),
+		}
+
+		repos, _, err := store.searchRepositories(context.Background(), RepositoryFilter{
+			Owners:       []string{"a", "b"},
+			ExcludeRepos: []string{"b/repo"},
+		})
+		is.NoErr(err)
+		is.Equal(queries, []string{`org:"a"`, `org:"b"`})
+		is.Equal(len(repos), 2)
+		is.Equal(repos[0].GetFullName(), "shared/repo")
+		is.Equal(repos[1].GetFullName(), "a/repo")
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	source := &GitHubStore{
+		moduleCache: map[string][]*core.ModuleVersion{
+			"test-owner/test-repo/generic": {
+				{Version: "1.0.0", SourceURL: "git::ssh://git@github.com/test-owner/test-repo.git?ref=v1.0.0"},
+			},
+		},
+		moduleTagCache: map[string]string{
+			"test-owner/test-repo/generic/1.0.0": "v1.0.0",
+		},
+		providerVersionsCache: map[string]*core.ProviderVersions{
+			"test-owner/test": {Versions: []core.ProviderVersion{{Version: "1.0.0"}}},
+		},
+		providerCache: map[string]*core.Provider{
+			"test-owner/test/1.0.0/linux/amd64": {OS: "linux", Arch: "amd64"},
+		},
+		providerReleaseIDs: map[string]int64{
+			"test-owner/terraform-provider-test/1.0.0": 42,
+		},
+		logger: zap.NewNop(),
+	}
+	source.providerIgnored.ignore("test-owner", "test", "0.9.0", "could not find SHA checksums")
+
+	t.Run("round trip", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		is.NoErr(source.SaveSnapshot(&buf))
+
+		target := &GitHubStore{logger: zap.NewNop()}
+		is.NoErr(target.LoadSnapshot(&buf))
+
+		ver, err := target.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "git::ssh://git@github.com/test-owner/test-repo.git?ref=v1.0.0")
+		is.Equal(target.moduleTagCache["test-owner/test-repo/generic/1.0.0"], "v1.0.0")
+
+		versions, err := target.ListProviderVersions(context.Background(), "test-owner", "test")
+		is.NoErr(err)
+		is.Equal(len(versions.Versions), 1)
+
+		provider, err := target.GetProviderVersion(context.Background(), "test-owner", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		is.Equal(provider.Arch, "amd64")
+		is.Equal(target.providerReleaseIDs["test-owner/terraform-provider-test/1.0.0"], int64(42))
+
+		entry, ignored := target.providerIgnored.lookup("test-owner", "test", "0.9.0")
+		is.True(ignored)
+		is.Equal(entry.Reason, "could not find SHA checksums")
+	})
+
+	t.Run("rejects unknown version", func(t *testing.T) {
+		is := is.New(t)
+		target := &GitHubStore{logger: zap.NewNop()}
+		err := target.LoadSnapshot(bytes.NewBufferString(`{"version": 0}`))
+		is.True(err != nil)
+		is.Equal(err.Error(), "unsupported snapshot version 0, expected 5")
+		is.True(target.moduleCache == nil)
+	})
+
+	t.Run("rejects invalid snapshot", func(t *testing.T) {
+		is := is.New(t)
+		target := &GitHubStore{logger: zap.NewNop()}
+		is.True(target.LoadSnapshot(bytes.NewBufferString(`{`)) != nil)
+	})
+}
+
+func TestProviderIgnoreList(t *testing.T) {
+	t.Run("is owner-qualified", func(t *testing.T) {
+		is := is.New(t)
+		var l ignoreList
+		l.ignore("owner-a", "test", "1.0.0", "could not find SHA checksums")
+
+		_, ok := l.lookup("owner-a", "test", "1.0.0")
+		is.True(ok)
+		_, ok = l.lookup("owner-b", "test", "1.0.0")
+		is.True(!ok)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		is := is.New(t)
+		l := ignoreList{ttl: time.Millisecond}
+		l.ignore("owner", "test", "1.0.0", "could not find SHA checksums")
+		time.Sleep(5 * time.Millisecond)
+
+		_, ok := l.lookup("owner", "test", "1.0.0")
+		is.True(!ok)
+		is.Equal(len(l.list()), 0)
+
+		l.prune()
+		is.Equal(len(l.entries), 0)
+	})
+
+	t.Run("defaults ttl", func(t *testing.T) {
+		is := is.New(t)
+		var l ignoreList
+		l.ignore("owner", "test", "1.0.0", "could not find SHA checksums")
+		entry, ok := l.lookup("owner", "test", "1.0.0")
+		is.True(ok)
+		is.Equal(entry.ExpiresAt.Sub(entry.IgnoredAt), DefaultProviderIgnoreTTL)
+	})
+
+	t.Run("lists sorted with reasons", func(t *testing.T) {
+		is := is.New(t)
+		store := &GitHubStore{logger: zap.NewNop()}
+		store.ignoreProviderRelease("owner-b", "test", "1.0.0", "unable to get GPG Public Key")
+		store.ignoreProviderRelease("owner-a", "test", "1.0.0", "could not find SHA checksums")
+
+		ignored := store.IgnoredReleases()
+		is.Equal(len(ignored), 2)
+		is.Equal(ignored[0].Namespace, "owner-a")
+		is.Equal(ignored[0].Reason, "could not find SHA checksums")
+		is.Equal(ignored[1].Namespace, "owner-b")
+		is.Equal(ignored[1].Reason, "unable to get GPG Public Key")
+	})
+
+	t.Run("restore skips expired entries", func(t *testing.T) {
+		is := is.New(t)
+		var l ignoreList
+		now := time.Now()
+		l.restore([]core.IgnoredRelease{
+			{Namespace: "owner", Name: "test", Version: "1.0.0", ExpiresAt: now.Add(time.Hour)},
+			{Namespace: "owner", Name: "test", Version: "0.9.0", ExpiresAt: now.Add(-time.Hour)},
+		})
+		is.Equal(len(l.list()), 1)
+		is.Equal(l.list()[0].Version, "1.0.0")
+	})
+}
+
+// unavailableAsset is the content of assets the mocked GitHub API fails to download.
+var unavailableAsset = []byte("unavailable")
+
+// assetStorage serves release assets like the storage host GitHub redirects asset downloads to,
+// with support for Range requests.
+type assetStorage struct {
+	*httptest.Server
+	assets map[string][]byte
+	ranges []string
+	mut    sync.Mutex
+}
+
+func newAssetStorage(t *testing.T, assets map[string][]byte) *assetStorage {
+	storage := &assetStorage{assets: assets}
+	storage.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := storage.assets[path.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if bytes.Equal(content, unavailableAsset) {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+
+		storage.mut.Lock()
+		storage.ranges = append(storage.ranges, r.Header.Get("Range"))
+		storage.mut.Unlock()
+
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(storage.Close)
+	return storage
+}
+
+// requestedRanges returns the Range headers of the asset downloads so far.
+func (s *assetStorage) requestedRanges() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return slices.Clone(s.ranges)
+}
+
+// newProviderReleaseStore returns a store with a mocked GitHub API serving a single provider
+// release `test-owner/terraform-provider-test` v1.0.0 with the given assets, tagged `release-1`
+// in a private repository. Assets listed in `digests` are reported with the given digest by the API.
+func newProviderReleaseStore(t *testing.T, assets map[string][]byte, digests map[string]string) *GitHubStore {
+	store, _ := newProviderReleaseStoreWithStorage(t, assets, digests)
+	return store
+}
+
+// newProviderReleaseStoreWithStorage is like `newProviderReleaseStore`, but also returns the
+// storage host the asset downloads are redirected to.
+func newProviderReleaseStoreWithStorage(t *testing.T, assets map[string][]byte, digests map[string]string) (*GitHubStore, *assetStorage) {
+	var names []string
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	storage := newAssetStorage(t, assets)
+
+	release := &github.RepositoryRelease{ID: github.Ptr(int64(42)), Name: github.Ptr("v1.0.0"), TagName: github.Ptr("release-1")}
+	for i, name := range names {
+		asset := &github.ReleaseAsset{
+			ID:                 github.Ptr(int64(i + 1)),
+			Name:               github.Ptr(name),
+			Size:               github.Ptr(len(assets[name])),
+			BrowserDownloadURL: github.Ptr("https://github.com/test-owner/terraform-provider-test/releases/download/release-1/" + name),
+		}
+		if digest, ok := digests[name]; ok {
+			asset.Digest = github.Ptr(digest)
+		}
+		release.Assets = append(release.Assets, asset)
+	}
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetSearchRepositories,
+			github.RepositoriesSearchResult{
+				Total: github.Ptr(1),
+				Repositories: []*github.Repository{
+					{Name: github.Ptr("terraform-provider-test"), FullName: github.Ptr("test-owner/terraform-provider-test"), Private: github.Ptr(true)},
+				},
+			},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposReleasesByOwnerByRepo,
+			[]*github.RepositoryRelease{release},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesByOwnerByRepoByReleaseId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if path.Base(r.URL.Path) != "42" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write(mock.MustMarshal(release))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesAssetsByOwnerByRepoByAssetId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id, err := strconv.Atoi(path.Base(r.URL.Path))
+				if err != nil || id < 1 || id > len(names) {
+					http.NotFound(w, r)
+					return
+				}
+				http.Redirect(w, r, storage.URL+"/"+names[id-1], http.StatusFound)
+			}),
+		),
+	)
+
+	return &GitHubStore{
+		providerFilter: RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"terraform-provider"}},
+		client:         github.NewClient(mockedHTTPClient),
+		logger:         zap.NewNop(),
+	}, storage
+}
+
+func TestProviderReleaseVerification(t *testing.T) {
+	key, publicKey := storetest.NewKey(t)
+	otherKey, otherPublicKey := storetest.NewKey(t)
+
+	zip := []byte("provider binary")
+	zipSum := sha256.Sum256(zip)
+	sums := []byte(fmt.Sprintf("%s  terraform-provider-test_1.0.0_linux_amd64.zip\n", hex.EncodeToString(zipSum[:])))
+
+	sign := func(key *openpgp.Entity, data []byte) []byte {
+		var buf bytes.Buffer
+		if err := openpgp.DetachSign(&buf, key, bytes.NewReader(data), nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	releaseAssets := func(overrides map[string][]byte) map[string][]byte {
+		assets := map[string][]byte{
+			"terraform-provider-test_1.0.0_linux_amd64.zip":    zip,
+			"terraform-provider-test_1.0.0_SHA256SUMS":         sums,
+			"terraform-provider-test_1.0.0_SHA256SUMS.sig":     sign(key, sums),
+			"terraform-provider-test_1.0.0_gpg-public-key.pem": publicKey,
+			"terraform-provider-test_1.0.0_manifest.json":      []byte(`{"version": 1, "metadata": {"protocol_versions": ["6.0"]}}`),
+		}
+		for name, content := range overrides {
+			if content == nil {
+				delete(assets, name)
+			} else {
+				assets[name] = content
+			}
+		}
+		return assets
 	}
 
-	t.Run("returns matching version", func(t *testing.T) {
+	// ignoredReason reloads the store, and returns the reason the release was ignored, if any.
+	ignoredReason := func(t *testing.T, store *GitHubStore) string {
+		if err := store.ReloadProviderCache(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		entry, ok := store.providerIgnored.lookup("test-owner", "test", "1.0.0")
+		if !ok {
+			return ""
+		}
+		return entry.Reason
+	}
+
+	t.Run("valid release", func(t *testing.T) {
 		is := is.New(t)
-		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.0")
-		is.True(err == nil)
-		is.Equal(ver.Version, "1.0.0")
-		is.Equal(ver.SourceURL, "git::ssh://git@github.com/test-owner/test-repo.git?ref=v1.0.0")
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.Equal(ignoredReason(t, store), "")
+
+		provider, err := store.GetProviderVersion(context.Background(), "test-owner", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		is.Equal(provider.SHASum, hex.EncodeToString(zipSum[:]))
+		is.Equal(provider.Protocols, []string{"6.0"})
+		is.Equal(len(store.assetDigestCache), 1) // digest computed by downloading the asset
+		for _, digest := range store.assetDigestCache {
+			is.Equal(digest, hex.EncodeToString(zipSum[:]))
+		}
 	})
 
-	t.Run("errs when missing", func(t *testing.T) {
+	t.Run("valid release with armored signature and asset digests", func(t *testing.T) {
 		is := is.New(t)
-		ver, err := store.GetModuleVersion(context.Background(), "test-owner", "test-repo", "generic", "1.0.1")
-		is.True(err != nil)
-		is.True(ver == nil)
-		is.Equal(err.Error(), "version '1.0.1' not found for module 'test-owner/test-repo/generic'")
+		var sig bytes.Buffer
+		is.NoErr(openpgp.ArmoredDetachSign(&sig, key, bytes.NewReader(sums), nil))
+
+		store := newProviderReleaseStore(t,
+			releaseAssets(map[string][]byte{"terraform-provider-test_1.0.0_SHA256SUMS.sig": sig.Bytes()}),
+			map[string]string{"terraform-provider-test_1.0.0_linux_amd64.zip": "sha256:" + hex.EncodeToString(zipSum[:])},
+		)
+		is.Equal(ignoredReason(t, store), "")
+		is.Equal(len(store.assetDigestCache), 0) // digest reported by GitHub, not downloaded
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(map[string][]byte{"terraform-provider-test_1.0.0_SHA256SUMS.sig": nil}), nil)
+		is.Equal(ignoredReason(t, store), "could not find SHA checksums signature 'terraform-provider-test_1.0.0_SHA256SUMS.sig'")
+	})
+
+	t.Run("signed by other key", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(map[string][]byte{"terraform-provider-test_1.0.0_SHA256SUMS.sig": sign(otherKey, sums)}), nil)
+		is.True(strings.HasPrefix(ignoredReason(t, store), "SHA checksums signature not valid for GPG Public Key"))
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(map[string][]byte{"terraform-provider-test_1.0.0_linux_amd64.zip": []byte("tampered")}), nil)
+		is.True(strings.HasPrefix(ignoredReason(t, store), "SHA checksum mismatch for 'terraform-provider-test_1.0.0_linux_amd64.zip'"))
+	})
+
+	t.Run("missing checksum", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(map[string][]byte{"terraform-provider-test_1.0.0_darwin_arm64.zip": zip}), nil)
+		is.Equal(ignoredReason(t, store), "could not find SHA checksum for 'terraform-provider-test_1.0.0_darwin_arm64.zip'")
+	})
+
+	t.Run("unavailable assets are retried", func(t *testing.T) {
+		for _, name := range []string{
+			"terraform-provider-test_1.0.0_SHA256SUMS",
+			"terraform-provider-test_1.0.0_SHA256SUMS.sig",
+			"terraform-provider-test_1.0.0_gpg-public-key.pem",
+			"terraform-provider-test_1.0.0_manifest.json",
+			"terraform-provider-test_1.0.0_linux_amd64.zip",
+		} {
+			t.Run(name, func(t *testing.T) {
+				is := is.New(t)
+				store := newProviderReleaseStore(t, releaseAssets(map[string][]byte{name: unavailableAsset}), nil)
+				is.Equal(ignoredReason(t, store), "")
+
+				_, err := store.GetProviderVersion(context.Background(), "test-owner", "test", "1.0.0", "linux", "amd64")
+				is.True(errors.Is(err, core.ErrNotFound))
+			})
+		}
+	})
+
+	t.Run("invalid GPG Public Key", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(map[string][]byte{"terraform-provider-test_1.0.0_gpg-public-key.pem": []byte("not a key")}), nil)
+		is.True(strings.HasPrefix(ignoredReason(t, store), "unable to read GPG Public Key"))
+	})
+
+	t.Run("asset cache", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.NoErr(store.SetAssetCacheDir(t.TempDir(), 0))
+		is.Equal(ignoredReason(t, store), "")
+
+		for i := range 2 {
+			asset, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+			is.NoErr(err)
+			_, cached := asset.(*os.File)
+			is.Equal(cached, i > 0) // streamed from GitHub while cached on first download
+			b, err := io.ReadAll(asset)
+			asset.Close()
+			is.NoErr(err)
+			is.Equal(b, zip)
+		}
+
+		_, err := store.assetCache.Open(hex.EncodeToString(zipSum[:]))
+		is.NoErr(err)
+	})
+
+	t.Run("partially read asset is not cached", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.NoErr(store.SetAssetCacheDir(t.TempDir(), 0))
+		is.Equal(ignoredReason(t, store), "")
+
+		asset, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		_, err = io.ReadFull(asset, make([]byte, 4))
+		is.NoErr(err)
+		is.NoErr(asset.Close())
+
+		_, err = store.assetCache.Open(hex.EncodeToString(zipSum[:]))
+		is.True(errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("asset without cache", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.Equal(ignoredReason(t, store), "")
+
+		asset, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_SHA256SUMS")
+		is.NoErr(err)
+		is.Equal(asset.(core.AssetSizer).Size(), int64(len(sums)))
+		b, err := io.ReadAll(asset)
+		asset.Close()
+		is.NoErr(err)
+		is.Equal(b, sums)
+
+		_, err = store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "missing.zip")
+		is.Equal(err.Error(), "asset 'missing.zip' not found")
+	})
+
+	t.Run("asset of release tagged differently from its version", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.Equal(ignoredReason(t, store), "")
+
+		provider, err := store.GetProviderVersion(context.Background(), "test-owner", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		is.Equal(provider.DownloadURL, "/download/provider/test-owner/terraform-provider-test/1.0.0/asset/terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.Equal(provider.SHASumsURL, "/download/provider/test-owner/terraform-provider-test/1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS")
+
+		asset, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		b, err := io.ReadAll(asset)
+		asset.Close()
+		is.NoErr(err)
+		is.Equal(b, zip)
+
+		_, err = store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "release-1", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.True(errors.Is(err, core.ErrNotFound))
+	})
+
+	t.Run("asset ranges are requested from GitHub", func(t *testing.T) {
+		is := is.New(t)
+		store, storage := newProviderReleaseStoreWithStorage(t, releaseAssets(nil), nil)
+		is.Equal(ignoredReason(t, store), "")
+
+		asset, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		defer asset.Close()
+
+		rs, ok := asset.(io.ReadSeeker)
+		is.True(ok)
+		size, err := rs.Seek(0, io.SeekEnd)
+		is.NoErr(err)
+		is.Equal(size, int64(len(zip)))
+
+		_, err = rs.Seek(9, io.SeekStart)
+		is.NoErr(err)
+		b, err := io.ReadAll(rs)
+		is.NoErr(err)
+		is.Equal(b, zip[9:])
+
+		ranges := storage.requestedRanges()
+		is.Equal(ranges[len(ranges)-2:], []string{"", "bytes=9-"})
+	})
+
+	t.Run("asset is downloaded once when served", func(t *testing.T) {
+		is := is.New(t)
+		store, storage := newProviderReleaseStoreWithStorage(t, releaseAssets(nil), nil)
+		is.Equal(ignoredReason(t, store), "")
+		downloads := len(storage.requestedRanges())
+
+		asset, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		defer asset.Close()
+
+		// ServeContent seeks to the end for the size, and back to the start. The content type is
+		// known from the name, as set by the registry, so the asset is not sniffed.
+		w := httptest.NewRecorder()
+		http.ServeContent(w, httptest.NewRequest(http.MethodGet, "/", nil), "terraform-provider-test_1.0.0_linux_amd64.zip", time.Time{}, asset.(io.ReadSeeker))
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Body.Bytes(), zip)
+		is.Equal(storage.requestedRanges()[downloads:], []string{""})
+	})
+
+	t.Run("unavailable asset", func(t *testing.T) {
+		is := is.New(t)
+		store, storage := newProviderReleaseStoreWithStorage(t, releaseAssets(nil), nil)
+		is.Equal(ignoredReason(t, store), "")
+
+		storage.assets["terraform-provider-test_1.0.0_linux_amd64.zip"] = unavailableAsset
+		_, err := store.GetProviderAsset(context.Background(), "test-owner", "terraform-provider-test", "1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.True(errors.Is(err, core.ErrUnavailable))
+	})
+
+	t.Run("trusted keyring", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.NoErr(store.SetTrustedKeyring(bytes.NewReader(publicKey)))
+		is.Equal(ignoredReason(t, store), "")
 	})
 
+	t.Run("key not in trusted keyring", func(t *testing.T) {
+		is := is.New(t)
+		store := newProviderReleaseStore(t, releaseAssets(nil), nil)
+		is.NoErr(store.SetTrustedKeyring(bytes.NewReader(otherPublicKey)))
+		is.Equal(ignoredReason(t, store), fmt.Sprintf("GPG Public Key %s is not in the trusted keyring", key.PrimaryKey.KeyIdString()))
+	})
+}
+
+func TestVersionPolicy(t *testing.T) {
+	t.Run("provider releases", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			policy  VersionPolicy
+			release *github.RepositoryRelease
+			version string
+		}{
+			{"version from release name", VersionPolicy{}, &github.RepositoryRelease{TagName: github.Ptr("release-1"), Name: github.Ptr("v1.0.0")}, "1.0.0"},
+			{"version from tag", VersionPolicy{VersionFromTag: true}, &github.RepositoryRelease{TagName: github.Ptr("v1.0.0"), Name: github.Ptr("First release")}, "1.0.0"},
+			{"not semver", VersionPolicy{}, &github.RepositoryRelease{Name: github.Ptr("latest")}, ""},
+			{"draft excluded", VersionPolicy{}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0"), Draft: github.Ptr(true)}, ""},
+			{"draft included", VersionPolicy{IncludeDrafts: true}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0"), Draft: github.Ptr(true)}, "1.0.0"},
+			{"pre-release included", VersionPolicy{}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0"), Prerelease: github.Ptr(true)}, "1.0.0"},
+			{"pre-release excluded", VersionPolicy{ExcludePreReleases: true}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0"), Prerelease: github.Ptr(true)}, ""},
+			{"pre-release suffix excluded", VersionPolicy{ExcludePreReleases: true}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0-rc1")}, ""},
+			{"pre-release included for namespace", VersionPolicy{ExcludePreReleases: true, PreReleaseNamespaces: map[string]bool{"Test-Owner": true}}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0-rc1")}, "1.0.0-rc1"},
+			{"pre-release excluded for namespace", VersionPolicy{PreReleaseNamespaces: map[string]bool{"test-owner": false}}, &github.RepositoryRelease{Name: github.Ptr("v1.0.0-rc1")}, ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				is := is.New(t)
+				v, ok := tt.policy.releaseVersion("test-owner", tt.release)
+				is.Equal(ok, tt.version != "")
+				if ok {
+					is.Equal(v.Original(), tt.version)
+				}
+			})
+		}
+	})
+
+	t.Run("module versions", func(t *testing.T) {
+		is := is.New(t)
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(
+				mock.GetSearchRepositories,
+				github.RepositoriesSearchResult{Repositories: []*github.Repository{{FullName: github.Ptr("test-owner/test-repo")}}},
+			),
+			mock.WithRequestMatch(
+				mock.GetReposTagsByOwnerByRepo,
+				[]github.RepositoryTag{
+					{Name: github.Ptr("v3.1.0")},
+					{Name: github.Ptr("v3.0.0-beta1")},
+					{Name: github.Ptr("v2.0.0")},
+					{Name: github.Ptr("v1.0.0")},
+					{Name: github.Ptr("latest")},
+				},
+			),
+		)
+		store := &GitHubStore{
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+			client:       github.NewClient(mockedHTTPClient),
+			logger:       zap.NewNop(),
+		}
+		store.SetVersionPolicy(VersionPolicy{ExcludePreReleases: true, MaxMajorVersions: 2})
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		versions, err := store.ListModuleVersions(context.Background(), "test-owner", "test-repo", "generic")
+		is.NoErr(err)
+		var actual []string
+		for _, v := range versions {
+			actual = append(actual, v.Version)
+		}
+		is.Equal(actual, []string{"3.1.0", "2.0.0"})
+	})
 }
 
 func TestListModuleVersions(t *testing.T) {
@@ -172,11 +1442,10 @@ func TestListModuleVersions(t *testing.T) {
 
 	c := github.NewClient(mockedHTTPClient)
 	store := &GitHubStore{
-		ownerFilter: "test-owner",
-		topicFilter: "test-topic",
-		client:      c,
-		moduleCache: make(map[string][]*core.ModuleVersion),
-		logger:      zap.NewNop(),
+		moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"test-topic"}},
+		client:       c,
+		moduleCache:  make(map[string][]*core.ModuleVersion),
+		logger:       zap.NewNop(),
 	}
 
 	err := store.ReloadCache(context.Background())
@@ -194,6 +1463,13 @@ func TestListModuleVersions(t *testing.T) {
 		is.Equal(versions[2].Version, "2.0.0")
 	})
 
+	t.Run("lists modules", func(t *testing.T) {
+		is := is.New(t)
+		modules, err := store.ListModules(context.Background())
+		is.NoErr(err)
+		is.Equal(modules, []core.ModuleAddress{{Namespace: "test-owner", Name: "test-repo", Provider: "generic"}})
+	})
+
 	t.Run("errs when missing", func(t *testing.T) {
 		is := is.New(t)
 		versions, err := store.ListModuleVersions(context.Background(), "wrong", "wrong", "wrong")
@@ -203,6 +1479,280 @@ func TestListModuleVersions(t *testing.T) {
 
 }
 
+func TestReloadRepository(t *testing.T) {
+	// newStore returns a store with a cached module and provider in addition to those of `test-owner/test-repo`
+	// and `test-owner/terraform-provider-test`, and a mocked API serving `repo` for the repository requests.
+	newStore := func(repo *github.Repository) *GitHubStore {
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetReposByOwnerByRepo,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if repo == nil {
+						mock.WriteError(w, http.StatusNotFound, "Not Found")
+						return
+					}
+					w.Write(mock.MustMarshal(repo))
+				}),
+			),
+			mock.WithRequestMatch(
+				mock.GetReposTagsByOwnerByRepo,
+				[]github.RepositoryTag{{Name: github.Ptr("v1.0.0")}, {Name: github.Ptr("v1.1.0")}},
+			),
+		)
+
+		store := &GitHubStore{
+			moduleFilter:   RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"test-topic"}},
+			providerFilter: RepositoryFilter{Owners: []string{"test-owner"}, Topics: []string{"terraform-provider"}},
+			client:         github.NewClient(mockedHTTPClient),
+			moduleCache: map[string][]*core.ModuleVersion{
+				"test-owner/test-repo/generic":  {{Version: "0.1.0"}},
+				"test-owner/other-repo/generic": {{Version: "2.0.0"}},
+			},
+			moduleTagCache: map[string]string{
+				"test-owner/test-repo/generic/0.1.0":  "v0.1.0",
+				"test-owner/other-repo/generic/2.0.0": "v2.0.0",
+			},
+			providerVersionsCache: map[string]*core.ProviderVersions{
+				"test-owner/test":  {Versions: []core.ProviderVersion{{Version: "1.0.0"}}},
+				"test-owner/other": {Versions: []core.ProviderVersion{{Version: "1.0.0"}}},
+			},
+			providerCache: map[string]*core.Provider{
+				"test-owner/test/1.0.0/linux/amd64":  {},
+				"test-owner/other/1.0.0/linux/amd64": {},
+			},
+			providerAssetDigests: map[string]string{
+				"test-owner/terraform-provider-test/v1.0.0/terraform-provider-test_1.0.0_linux_amd64.zip":   "a",
+				"test-owner/terraform-provider-other/v1.0.0/terraform-provider-other_1.0.0_linux_amd64.zip": "b",
+			},
+			logger: zap.NewNop(),
+		}
+		store.providerIgnored.ignore("test-owner", "test", "0.9.0", "could not find SHA checksums")
+		return store
+	}
+
+	moduleRepo := &github.Repository{FullName: github.Ptr("test-owner/test-repo"), Topics: []string{"test-topic"}}
+
+	t.Run("reloads single module", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(moduleRepo)
+		is.NoErr(store.ReloadModule(context.Background(), "test-owner", "test-repo", "generic"))
+
+		versions, err := store.ListModuleVersions(context.Background(), "test-owner", "test-repo", "generic")
+		is.NoErr(err)
+		is.Equal(len(versions), 2)
+		is.Equal(versions[0].Version, "1.0.0")
+		is.Equal(store.moduleTagCache, map[string]string{
+			"test-owner/test-repo/generic/1.0.0":  "v1.0.0",
+			"test-owner/test-repo/generic/1.1.0":  "v1.1.0",
+			"test-owner/other-repo/generic/2.0.0": "v2.0.0",
+		})
+
+		// Other modules are kept
+		_, err = store.GetModuleVersion(context.Background(), "test-owner", "other-repo", "generic", "2.0.0")
+		is.NoErr(err)
+	})
+
+	t.Run("removes modules not matching filter", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(&github.Repository{FullName: github.Ptr("test-owner/test-repo"), Topics: []string{"unrelated"}})
+		is.NoErr(store.ReloadModule(context.Background(), "test-owner", "test-repo", "generic"))
+
+		_, err := store.ListModuleVersions(context.Background(), "test-owner", "test-repo", "generic")
+		is.True(err != nil)
+		is.Equal(len(store.moduleCache), 1)
+		is.Equal(len(store.moduleTagCache), 1)
+	})
+
+	t.Run("removes missing modules", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(nil)
+		is.NoErr(store.ReloadModule(context.Background(), "test-owner", "test-repo", "generic"))
+		is.Equal(len(store.moduleCache), 1)
+	})
+
+	t.Run("normalises the casing of reloaded modules", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(moduleRepo)
+		is.NoErr(store.ReloadModule(context.Background(), "Test-Owner", "TEST-REPO", "generic"))
+		is.Equal(len(store.moduleCache), 2)
+		is.Equal(store.moduleTagCache, map[string]string{
+			"test-owner/test-repo/generic/1.0.0":  "v1.0.0",
+			"test-owner/test-repo/generic/1.1.0":  "v1.1.0",
+			"test-owner/other-repo/generic/2.0.0": "v2.0.0",
+		})
+
+		store = newStore(nil)
+		is.NoErr(store.ReloadModule(context.Background(), "Test-Owner", "TEST-REPO", "generic"))
+		is.Equal(len(store.moduleCache), 1)
+		is.Equal(len(store.moduleTagCache), 1)
+	})
+
+	t.Run("errs on unknown module provider", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(moduleRepo)
+		err := store.ReloadModule(context.Background(), "test-owner", "test-repo", "aws")
+		is.Equal(err.Error(), "module 'test-owner/test-repo/aws' not found")
+	})
+
+	t.Run("removes missing providers and forgets ignored releases", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(nil)
+		is.NoErr(store.ReloadProvider(context.Background(), "test-owner", "test"))
+
+		_, err := store.ListProviderVersions(context.Background(), "test-owner", "test")
+		is.True(err != nil)
+		_, err = store.ListProviderVersions(context.Background(), "test-owner", "other")
+		is.NoErr(err)
+		is.Equal(len(store.providerCache), 1)
+		is.Equal(len(store.providerAssetDigests), 1)
+		is.Equal(len(store.IgnoredReleases()), 0)
+	})
+
+	t.Run("normalises the casing of reloaded providers", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(nil)
+		is.NoErr(store.ReloadProvider(context.Background(), "Test-Owner", "Test"))
+		is.Equal(len(store.providerVersionsCache), 1)
+		is.Equal(len(store.providerCache), 1)
+		is.Equal(len(store.providerAssetDigests), 1)
+		is.Equal(len(store.IgnoredReleases()), 0)
+	})
+
+	t.Run("reports cache stats", func(t *testing.T) {
+		is := is.New(t)
+		store := newStore(nil)
+		stats := store.CacheStats().(CacheStats)
+		is.Equal(stats.Modules, 2)
+		is.Equal(stats.ModuleVersions, 2)
+		is.Equal(stats.Providers, 2)
+		is.Equal(stats.ProviderVersions, 2)
+		is.Equal(stats.ProviderPlatforms, 2)
+		is.Equal(stats.IgnoredReleases, 1)
+		is.True(stats.ModulesReloadedAt.IsZero())
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("pauses reloads when primary rate limit is exhausted", func(t *testing.T) {
+		is := is.New(t)
+		reset := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+		requests := 0
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetSearchRepositories,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.Header().Set("X-RateLimit-Limit", "30")
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+					w.Header().Set("X-RateLimit-Resource", "search")
+					mock.WriteError(w, http.StatusForbidden, "API rate limit exceeded")
+				}),
+			),
+		)
+		store := &GitHubStore{
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+			client:       github.NewClient(mockedHTTPClient),
+			moduleCache:  make(map[string][]*core.ModuleVersion),
+			logger:       zap.NewNop(),
+		}
+
+		err := store.ReloadCache(context.Background())
+		is.True(isRateLimitError(err))
+		is.Equal(store.RateLimitedUntil(), reset)
+
+		status := store.RateLimitStatus()
+		is.Equal(status.Resources["search"].Remaining, 0)
+		is.Equal(*status.PausedUntil, reset)
+
+		// Subsequent reloads must not call the API until the limit resets
+		err = store.ReloadCache(context.Background())
+		var limitedErr *RateLimitedError
+		is.True(errors.As(err, &limitedErr))
+		is.Equal(limitedErr.Until, reset)
+		is.Equal(requests, 1)
+	})
+
+	t.Run("backs off on secondary rate limit", func(t *testing.T) {
+		is := is.New(t)
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetSearchRepositories,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Retry-After", "120")
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"message": "You have exceeded a secondary rate limit", "documentation_url": "https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits"}`))
+				}),
+			),
+		)
+		store := &GitHubStore{
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+			client:       github.NewClient(mockedHTTPClient),
+			moduleCache:  make(map[string][]*core.ModuleVersion),
+			logger:       zap.NewNop(),
+		}
+
+		err := store.ReloadCache(context.Background())
+		is.True(isRateLimitError(err))
+
+		until := store.RateLimitedUntil()
+		is.True(time.Until(until) > 110*time.Second)
+		is.True(time.Until(until) <= 120*time.Second)
+	})
+
+	t.Run("classifies API errors", func(t *testing.T) {
+		is := is.New(t)
+		reset := time.Now().Add(30 * time.Minute)
+
+		err := backendError(&github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: reset}}})
+		is.True(errors.Is(err, core.ErrRateLimited))
+		is.True(core.RetryAfter(err) > 29*time.Minute)
+
+		retryAfter := 2 * time.Minute
+		err = backendError(&github.AbuseRateLimitError{RetryAfter: &retryAfter})
+		is.True(errors.Is(err, core.ErrRateLimited))
+		is.Equal(core.RetryAfter(err), retryAfter)
+
+		err = backendError(&github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}})
+		is.True(errors.Is(err, core.ErrNotFound))
+
+		err = backendError(&github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}})
+		is.True(errors.Is(err, core.ErrUnavailable))
+	})
+
+	t.Run("tracks remaining quota from responses", func(t *testing.T) {
+		is := is.New(t)
+		reset := time.Now().Add(time.Hour).Truncate(time.Second)
+		emptyResult := new(github.RepositoriesSearchResult)
+		mockedHTTPClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetSearchRepositories,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-RateLimit-Limit", "30")
+					w.Header().Set("X-RateLimit-Remaining", "29")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+					w.Header().Set("X-RateLimit-Resource", "search")
+					w.Write(mock.MustMarshal(emptyResult))
+				}),
+			),
+		)
+		store := &GitHubStore{
+			moduleFilter: RepositoryFilter{Owners: []string{"test-owner"}},
+			client:       github.NewClient(mockedHTTPClient),
+			moduleCache:  make(map[string][]*core.ModuleVersion),
+			logger:       zap.NewNop(),
+		}
+
+		err := store.ReloadCache(context.Background())
+		is.NoErr(err)
+		is.True(store.RateLimitedUntil().IsZero())
+
+		status := store.RateLimitStatus()
+		is.Equal(status.Resources["search"], RateLimitResource{Limit: 30, Remaining: 29, Reset: reset})
+		is.True(status.PausedUntil == nil)
+	})
+}
+
 func Test_extractOsArch(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// DefaultProviderIgnoreTTL is how long invalid provider releases are ignored before they are re-validated.
+const DefaultProviderIgnoreTTL = time.Hour
+
+// ignoreList keeps track of provider releases found to be invalid, so that their
+// assets are not downloaded and validated again on every reload. Entries expire
+// after a TTL, giving publishers the chance to fix a release without a restart.
+type ignoreList struct {
+	// How long releases are ignored. Uses `DefaultProviderIgnoreTTL` when zero.
+	ttl     time.Duration
+	entries map[string]core.IgnoredRelease
+	mut     sync.RWMutex
+}
+
+// ignore adds the release to the list, replacing any existing entry.
+func (l *ignoreList) ignore(owner, name, version, reason string) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	ttl := l.ttl
+	if ttl == 0 {
+		ttl = DefaultProviderIgnoreTTL
+	}
+	if l.entries == nil {
+		l.entries = make(map[string]core.IgnoredRelease)
+	}
+
+	now := time.Now().UTC()
+	l.entries[cacheKey(owner, name, version)] = core.IgnoredRelease{
+		Namespace: owner,
+		Name:      name,
+		Version:   version,
+		Reason:    reason,
+		IgnoredAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// lookup returns the entry for the release, unless it is missing or expired.
+func (l *ignoreList) lookup(owner, name, version string) (core.IgnoredRelease, bool) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	entry, ok := l.entries[cacheKey(owner, name, version)]
+	if !ok || !time.Now().Before(entry.ExpiresAt) {
+		return core.IgnoredRelease{}, false
+	}
+	return entry, true
+}
+
+// prune removes expired entries.
+func (l *ignoreList) prune() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	now := time.Now()
+	for key, entry := range l.entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// forget removes the entries of all releases of the provider, so that they are validated again.
+func (l *ignoreList) forget(owner, name string) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for key, entry := range l.entries {
+		if strings.EqualFold(entry.Namespace, owner) && strings.EqualFold(entry.Name, name) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// list returns all unexpired entries, sorted by owner, name and version.
+func (l *ignoreList) list() []core.IgnoredRelease {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	now := time.Now()
+	entries := make([]core.IgnoredRelease, 0, len(l.entries))
+	for _, entry := range l.entries {
+		if now.Before(entry.ExpiresAt) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return cacheKey(a.Namespace, a.Name, a.Version) < cacheKey(b.Namespace, b.Name, b.Version)
+	})
+	return entries
+}
+
+// restore adds previously listed entries, keeping their timestamps. Expired entries are skipped.
+func (l *ignoreList) restore(entries []core.IgnoredRelease) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.entries == nil {
+		l.entries = make(map[string]core.IgnoredRelease)
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if now.Before(entry.ExpiresAt) {
+			l.entries[cacheKey(entry.Namespace, entry.Name, entry.Version)] = entry
+		}
+	}
+}
+
+// SetProviderIgnoreTTL sets how long invalid provider releases are ignored before they are
+// re-validated. Applies to releases ignored after the call.
+func (s *GitHubStore) SetProviderIgnoreTTL(ttl time.Duration) {
+	s.providerIgnored.mut.Lock()
+	s.providerIgnored.ttl = ttl
+	s.providerIgnored.mut.Unlock()
+}
+
+// IgnoredReleases returns the provider releases currently ignored, with the reason they were rejected.
+func (s *GitHubStore) IgnoredReleases() []core.IgnoredRelease {
+	return s.providerIgnored.list()
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/moduledoc"
+	"go.uber.org/zap"
+)
+
+// SetModuleDocs enables or disables extracting the README, inputs, outputs and required providers
+// of module versions from their source archives. Each tagged commit is only downloaded once, but
+// enabling this makes the first cache reload considerably slower for repositories with many tags.
+// Takes effect on the next cache reload.
+func (s *GitHubStore) SetModuleDocs(enabled bool) {
+	s.moduleMut.Lock()
+	s.moduleDocs = enabled
+	s.moduleMut.Unlock()
+}
+
+// docCache holds the documentation extracted from module source archives by commit SHA.
+// Documentation found in the previous reload is reused, and the documentation of the
+// commits still tagged is collected in fresh, which replaces the cache afterwards.
+type docCache struct {
+	previous map[string]*core.ModuleMetadata
+	fresh    map[string]*core.ModuleMetadata
+}
+
+func newDocCache(previous map[string]*core.ModuleMetadata) *docCache {
+	return &docCache{
+		previous: previous,
+		fresh:    make(map[string]*core.ModuleMetadata),
+	}
+}
+
+// moduleMetadata returns the metadata of the module version at `tag`. The documentation is only
+// included when enabled with `SetModuleDocs`, and omitted if the source archive can't be read.
+// Returns nil if nothing is known about the version.
+func (s *GitHubStore) moduleMetadata(ctx context.Context, owner, name string, repo *github.Repository, tag *github.RepositoryTag, docs *docCache) *core.ModuleMetadata {
+	meta := &core.ModuleMetadata{}
+
+	s.moduleMut.RLock()
+	enabled := s.moduleDocs
+	s.moduleMut.RUnlock()
+
+	sha := tag.GetCommit().GetSHA()
+	if enabled && sha != "" {
+		doc, ok := docs.previous[sha]
+		if !ok {
+			var err error
+			doc, err = s.moduleDoc(ctx, owner, name, tag.GetName())
+			if err != nil {
+				s.logger.Warn("unable to extract module documentation",
+					zap.String("repo", owner+"/"+name),
+					zap.String("tag", tag.GetName()),
+					zap.Error(err),
+				)
+			}
+		}
+		if doc != nil {
+			docs.fresh[sha] = doc
+			*meta = *doc
+		}
+	}
+
+	meta.CommitSHA = sha
+	meta.Description = repo.GetDescription()
+
+	if meta.CommitSHA == "" && meta.Description == "" && meta.PublishedAt.IsZero() {
+		return nil
+	}
+	return meta
+}
+
+// moduleDoc downloads the source archive of the module at `ref` and extracts its documentation.
+func (s *GitHubStore) moduleDoc(ctx context.Context, owner, name, ref string) (*core.ModuleMetadata, error) {
+	archive, err := s.moduleArchive(ctx, owner, name, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	return moduledoc.FromTarGz(archive)
+}
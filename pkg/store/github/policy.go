@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+	goversion "github.com/hashicorp/go-version"
+)
+
+// VersionPolicy selects the module tags and provider releases published as versions.
+type VersionPolicy struct {
+	// IncludeDrafts includes draft provider releases, which are excluded by default.
+	IncludeDrafts bool
+	// ExcludePreReleases excludes pre-release versions, i.e. provider releases marked as pre-releases
+	// and versions with a SemVer pre-release suffix like `1.0.0-rc1`.
+	ExcludePreReleases bool
+	// PreReleaseNamespaces overrides ExcludePreReleases per namespace. Set a namespace to true to
+	// include its pre-releases, or false to exclude them.
+	PreReleaseNamespaces map[string]bool
+	// VersionFromTag takes provider versions from the release tags instead of the release names.
+	VersionFromTag bool
+	// MaxMajorVersions hides versions older than the given number of major versions, counting
+	// from the latest major version. E.g. 2 keeps versions of the latest and the previous major.
+	// Zero keeps all versions.
+	MaxMajorVersions int
+}
+
+// includePreReleases returns whether pre-release versions are included for the namespace.
+func (p VersionPolicy) includePreReleases(namespace string) bool {
+	for ns, include := range p.PreReleaseNamespaces {
+		if strings.EqualFold(ns, namespace) {
+			return include
+		}
+	}
+	return !p.ExcludePreReleases
+}
+
+// releaseVersion returns the SemVer version of the provider release, or false if the release is
+// excluded by the policy or not versioned with SemVer.
+func (p VersionPolicy) releaseVersion(owner string, release *github.RepositoryRelease) (*goversion.Version, bool) {
+	if release.GetDraft() && !p.IncludeDrafts {
+		return nil, false
+	}
+	if release.GetPrerelease() && !p.includePreReleases(owner) {
+		return nil, false
+	}
+
+	source := release.GetName()
+	if p.VersionFromTag {
+		source = release.GetTagName()
+	}
+	return p.version(owner, source)
+}
+
+// version parses a tag or release name as a SemVer version, or returns false if the version is
+// excluded by the policy or not valid SemVer.
+func (p VersionPolicy) version(owner, name string) (*goversion.Version, bool) {
+	// Terraform uses SemVer names without 'v' prefix
+	v, err := goversion.NewSemver(strings.TrimPrefix(name, "v"))
+	if err != nil {
+		return nil, false
+	}
+	if v.Prerelease() != "" && !p.includePreReleases(owner) {
+		return nil, false
+	}
+	return v, true
+}
+
+// keepMajor returns a function reporting whether a version is within the MaxMajorVersions latest
+// major versions of `versions`.
+func (p VersionPolicy) keepMajor(versions []*goversion.Version) func(*goversion.Version) bool {
+	if p.MaxMajorVersions <= 0 || len(versions) == 0 {
+		return func(*goversion.Version) bool { return true }
+	}
+
+	latest := 0
+	for _, v := range versions {
+		latest = max(latest, v.Segments()[0])
+	}
+	return func(v *goversion.Version) bool {
+		return v.Segments()[0] > latest-p.MaxMajorVersions
+	}
+}
+
+// SetVersionPolicy sets the policy selecting module and provider versions.
+// Takes effect on the next cache reload.
+func (s *GitHubStore) SetVersionPolicy(policy VersionPolicy) {
+	s.moduleMut.Lock()
+	s.versionPolicy = policy
+	s.moduleMut.Unlock()
+}
+
+// getVersionPolicy returns the current version policy.
+func (s *GitHubStore) getVersionPolicy() VersionPolicy {
+	s.moduleMut.RLock()
+	defer s.moduleMut.RUnlock()
+	return s.versionPolicy
+}
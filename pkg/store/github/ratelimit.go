@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// defaultSecondaryRateLimitBackoff is used when GitHub responds with a
+// secondary (abuse) rate limit error without a `Retry-After` header.
+const defaultSecondaryRateLimitBackoff = time.Minute
+
+// RateLimitStatus describes the last known GitHub API rate limit state of a store.
+type RateLimitStatus struct {
+	// Resources holds the last seen rate limit for each API resource, e.g. `core` and `search`.
+	Resources map[string]RateLimitResource `json:"resources,omitempty"`
+	// PausedUntil is set when API calls are paused because of an exhausted
+	// primary rate limit or a secondary rate limit. With a GitHub App, it is set
+	// when the calls of all installations are paused.
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	// Installations holds the state of each GitHub App installation by owner, as the
+	// rate limits apply per installation.
+	Installations map[string]RateLimitStatus `json:"installations,omitempty"`
+}
+
+// RateLimitResource is the rate limit for a single GitHub API resource.
+type RateLimitResource struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// RateLimitedError is returned when a reload is not attempted because the
+// store is waiting for a rate limit window to reset.
+type RateLimitedError struct {
+	Until time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("github rate limit in effect until %s", e.Until.Format(time.RFC3339))
+}
+
+// backendError classifies an error returned by the GitHub API. Rate limit errors are retried after
+// the rate limit window resets.
+func backendError(err error) error {
+	var (
+		rateLimitErr  *github.RateLimitError
+		abuseLimitErr *github.AbuseRateLimitError
+		errResp       *github.ErrorResponse
+	)
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return &core.Error{Kind: core.ErrRateLimited, Err: err, RetryAfter: time.Until(rateLimitErr.Rate.Reset.Time)}
+	case errors.As(err, &abuseLimitErr):
+		return &core.Error{Kind: core.ErrRateLimited, Err: err, RetryAfter: secondaryRateLimitBackoff(abuseLimitErr)}
+	case errors.As(err, &errResp) && errResp.Response != nil:
+		return core.BackendError(err, errResp.Response.StatusCode)
+	}
+	return core.BackendError(err, 0)
+}
+
+// secondaryRateLimitBackoff returns how long to wait before calling the API again after a secondary rate limit error.
+func secondaryRateLimitBackoff(err *github.AbuseRateLimitError) time.Duration {
+	if err.RetryAfter != nil {
+		return *err.RetryAfter
+	}
+	return defaultSecondaryRateLimitBackoff
+}
+
+// rateLimits keeps track of the rate limits of each GitHub App installation by the lowercase login
+// of its owner. Stores authenticated with a token have a single rate limit, kept for the owner "".
+type rateLimits struct {
+	limiters map[string]*rateLimiter
+	mut      sync.Mutex
+}
+
+// get returns the rate limits of the owner.
+func (r *rateLimits) get(owner string) *rateLimiter {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.limiters == nil {
+		r.limiters = make(map[string]*rateLimiter)
+	}
+	rl, ok := r.limiters[owner]
+	if !ok {
+		rl = &rateLimiter{}
+		r.limiters[owner] = rl
+	}
+	return rl
+}
+
+// rateLimiter keeps track of the GitHub API rate limits observed in responses.
+type rateLimiter struct {
+	resources      map[string]github.Rate
+	secondaryUntil time.Time
+	mut            sync.RWMutex
+}
+
+// observe records the rate limit details of an API response and error, if any.
+func (rl *rateLimiter) observe(resp *github.Response, err error) {
+	rl.mut.Lock()
+	defer rl.mut.Unlock()
+
+	if resp != nil && resp.Rate.Limit > 0 {
+		rl.setRate(resp.Rate.Resource, resp.Rate)
+	}
+
+	var (
+		rateLimitErr  *github.RateLimitError
+		abuseLimitErr *github.AbuseRateLimitError
+	)
+	switch {
+	case errors.As(err, &rateLimitErr):
+		rl.setRate(rateLimitErr.Rate.Resource, rateLimitErr.Rate)
+	case errors.As(err, &abuseLimitErr):
+		if until := time.Now().Add(secondaryRateLimitBackoff(abuseLimitErr)); until.After(rl.secondaryUntil) {
+			rl.secondaryUntil = until
+		}
+	}
+}
+
+// setRates records the given rates by resource name, e.g. from an explicit rate limit lookup.
+func (rl *rateLimiter) setRates(rates map[string]*github.Rate) {
+	rl.mut.Lock()
+	defer rl.mut.Unlock()
+
+	for resource, rate := range rates {
+		if rate != nil {
+			rl.setRate(resource, *rate)
+		}
+	}
+}
+
+// setRate must be called with the write lock held.
+func (rl *rateLimiter) setRate(resource string, rate github.Rate) {
+	if rl.resources == nil {
+		rl.resources = make(map[string]github.Rate)
+	}
+	if resource == "" {
+		resource = "core"
+	}
+	rl.resources[resource] = rate
+}
+
+// pausedUntil returns the point in time until which API calls should be avoided.
+// The zero value is returned when there is no active limit.
+func (rl *rateLimiter) pausedUntil() time.Time {
+	rl.mut.RLock()
+	defer rl.mut.RUnlock()
+
+	now := time.Now()
+	var until time.Time
+	if rl.secondaryUntil.After(now) {
+		until = rl.secondaryUntil
+	}
+	for _, rate := range rl.resources {
+		if rate.Remaining == 0 && rate.Reset.After(now) && rate.Reset.After(until) {
+			until = rate.Reset.Time
+		}
+	}
+	return until
+}
+
+// status returns a copy of the current rate limit state.
+func (rl *rateLimiter) status() RateLimitStatus {
+	status := RateLimitStatus{
+		Resources: make(map[string]RateLimitResource),
+	}
+	if until := rl.pausedUntil(); !until.IsZero() {
+		status.PausedUntil = &until
+	}
+
+	rl.mut.RLock()
+	defer rl.mut.RUnlock()
+	for name, rate := range rl.resources {
+		status.Resources[name] = RateLimitResource{
+			Limit:     rate.Limit,
+			Remaining: rate.Remaining,
+			Reset:     rate.Reset.Time,
+		}
+	}
+	return status
+}
+
+// isRateLimitError returns whether err is a primary or secondary GitHub rate limit error.
+func isRateLimitError(err error) bool {
+	var (
+		rateLimitErr  *github.RateLimitError
+		abuseLimitErr *github.AbuseRateLimitError
+	)
+	return errors.As(err, &rateLimitErr) || errors.As(err, &abuseLimitErr)
+}
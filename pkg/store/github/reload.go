@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// ReloadModule reloads the cached versions of a single module, e.g. right after a new version
+// is tagged. The module is removed from the cache if its repository no longer exists or no
+// longer matches the module filter.
+func (s *GitHubStore) ReloadModule(ctx context.Context, namespace, name, provider string) error {
+	if provider != "generic" {
+		return core.NotFoundError("module '%s' not found", cacheKey(namespace, name, provider))
+	}
+	if err := s.checkRateLimit(namespace); err != nil {
+		return err
+	}
+	s.moduleReloadMut.Lock()
+	defer s.moduleReloadMut.Unlock()
+
+	repo, err := s.getRepository(ctx, s.moduleFilter, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	s.moduleMut.RLock()
+	docs := newDocCache(s.moduleDocCache)
+	s.moduleMut.RUnlock()
+
+	key := cacheKey(namespace, name, provider)
+	var (
+		versions []*core.ModuleVersion
+		tags     map[string]string
+	)
+	if repo != nil {
+		key, versions, tags, err = s.loadModule(ctx, repo, s.getVersionPolicy(), docs)
+		if err != nil {
+			return err
+		}
+	} else {
+		s.logger.Info("removing module from cache, repository not found or excluded by filter", zap.String("name", key))
+	}
+
+	// The caches are replaced rather than modified, as they may be shared with snapshots.
+	s.moduleMut.Lock()
+	defer s.moduleMut.Unlock()
+
+	moduleCache := cloneWithout(s.moduleCache, key)
+	moduleTagCache := cloneWithout(s.moduleTagCache, key)
+	moduleDocCache := maps.Clone(s.moduleDocCache)
+	if repo != nil {
+		moduleCache[key] = versions
+		maps.Copy(moduleTagCache, tags)
+		moduleDocCache = mergeMaps(moduleDocCache, docs.fresh)
+	}
+	s.moduleCache = moduleCache
+	s.moduleTagCache = moduleTagCache
+	s.moduleDocCache = moduleDocCache
+
+	return nil
+}
+
+// ReloadProvider reloads the cached versions of a single provider, e.g. right after a new release
+// is published. Releases of the provider that were previously ignored are validated again.
+// The provider is removed from the cache if its repository no longer exists or no longer
+// matches the provider filter.
+func (s *GitHubStore) ReloadProvider(ctx context.Context, namespace, name string) error {
+	if err := s.checkRateLimit(namespace); err != nil {
+		return err
+	}
+	s.providerReloadMut.Lock()
+	defer s.providerReloadMut.Unlock()
+
+	repoName := "terraform-provider-" + name
+	repo, err := s.getRepository(ctx, s.providerFilter, namespace, repoName)
+	if err != nil {
+		return err
+	}
+
+	s.providerIgnored.forget(namespace, name)
+
+	s.providerMut.RLock()
+	assetDigests := newDigestCache(s.assetDigestCache)
+	s.providerMut.RUnlock()
+
+	var loaded *providerRepository
+	if repo != nil {
+		loaded, err = s.loadProvider(ctx, repo, s.getVersionPolicy(), assetDigests)
+		if err != nil {
+			return err
+		}
+	}
+	if loaded == nil {
+		key := cacheKey(namespace, name)
+		s.logger.Info("removing provider from cache, repository not found or excluded by filter", zap.String("name", key))
+		loaded = &providerRepository{key: key}
+	}
+
+	s.providerMut.Lock()
+	defer s.providerMut.Unlock()
+
+	owner, _, _ := strings.Cut(loaded.key, "/")
+	providerVersionsCache := cloneWithout(s.providerVersionsCache, loaded.key)
+	providerCache := cloneWithout(s.providerCache, loaded.key)
+	providerAssetDigests := cloneWithout(s.providerAssetDigests, cacheKey(owner, repoName))
+	providerReleaseIDs := cloneWithout(s.providerReleaseIDs, cacheKey(owner, repoName))
+	assetDigestCache := maps.Clone(s.assetDigestCache)
+	if loaded.versions != nil {
+		providerVersionsCache[loaded.key] = loaded.versions
+		maps.Copy(providerCache, loaded.providers)
+		maps.Copy(providerAssetDigests, loaded.digests)
+		maps.Copy(providerReleaseIDs, loaded.releaseIDs)
+		assetDigestCache = mergeMaps(assetDigestCache, assetDigests.fresh)
+	}
+	s.providerVersionsCache = providerVersionsCache
+	s.providerCache = providerCache
+	s.providerAssetDigests = providerAssetDigests
+	s.providerReleaseIDs = providerReleaseIDs
+	s.assetDigestCache = assetDigestCache
+
+	return nil
+}
+
+// getRepository returns the repository `owner/name`, or nil if it doesn't exist or doesn't match the filter.
+func (s *GitHubStore) getRepository(ctx context.Context, filter RepositoryFilter, owner, name string) (*github.Repository, error) {
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
+	repo, resp, err := client.Repositories.Get(ctx, owner, name)
+	s.rateLimiterFor(owner).observe(resp, err)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !filter.includes(repo) {
+		return nil, nil
+	}
+	return repo, nil
+}
+
+// keepOwners copies the entries of `previous` with keys of the `owners`, by lowercase login, to `fresh`.
+func keepOwners[V any](fresh, previous map[string]V, owners map[string]bool) {
+	if len(owners) == 0 {
+		return
+	}
+	for key, v := range previous {
+		owner, _, _ := strings.Cut(key, "/")
+		if owners[strings.ToLower(owner)] {
+			fresh[key] = v
+		}
+	}
+}
+
+// cloneWithout returns a copy of the cache without `key` and the entries prefixed by it. Keys are
+// compared case-insensitively, as GitHub owner and repository names are, so that reloading a
+// repository requested with other casing than it is cached with replaces the cached entries.
+func cloneWithout[V any](cache map[string]V, key string) map[string]V {
+	clone := make(map[string]V, len(cache))
+	for k, v := range cache {
+		if !strings.EqualFold(k, key) && !hasPrefixFold(k, key+"/") {
+			clone[k] = v
+		}
+	}
+	return clone
+}
+
+// hasPrefixFold reports whether `s` begins with `prefix`, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// mergeMaps copies the entries of src to dst, which is allocated if nil.
+func mergeMaps[K comparable, V any](dst, src map[K]V) map[K]V {
+	if dst == nil {
+		dst = make(map[K]V, len(src))
+	}
+	maps.Copy(dst, src)
+	return dst
+}
+
+// CacheStats describes the contents of the store caches.
+type CacheStats struct {
+	Modules             int       `json:"modules"`
+	ModuleVersions      int       `json:"module_versions"`
+	ModuleDocs          int       `json:"module_docs"`
+	ModulesReloadedAt   time.Time `json:"modules_reloaded_at,omitzero"`
+	Providers           int       `json:"providers"`
+	ProviderVersions    int       `json:"provider_versions"`
+	ProviderPlatforms   int       `json:"provider_platforms"`
+	IgnoredReleases     int       `json:"ignored_releases"`
+	AssetDigests        int       `json:"asset_digests"`
+	ProvidersReloadedAt time.Time `json:"providers_reloaded_at,omitzero"`
+}
+
+// CacheStats returns the number of entries in the store caches, and when they were last fully reloaded.
+func (s *GitHubStore) CacheStats() any {
+	var stats CacheStats
+
+	s.moduleMut.RLock()
+	stats.Modules = len(s.moduleCache)
+	for _, versions := range s.moduleCache {
+		stats.ModuleVersions += len(versions)
+	}
+	stats.ModuleDocs = len(s.moduleDocCache)
+	stats.ModulesReloadedAt = s.moduleReloadedAt
+	s.moduleMut.RUnlock()
+
+	s.providerMut.RLock()
+	stats.Providers = len(s.providerVersionsCache)
+	for _, versions := range s.providerVersionsCache {
+		stats.ProviderVersions += len(versions.Versions)
+	}
+	stats.ProviderPlatforms = len(s.providerCache)
+	stats.AssetDigests = len(s.assetDigestCache)
+	stats.ProvidersReloadedAt = s.providerReloadedAt
+	s.providerMut.RUnlock()
+
+	stats.IgnoredReleases = len(s.providerIgnored.list())
+	return stats
+}
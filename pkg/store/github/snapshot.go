@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"go.uber.org/zap"
+)
+
+// snapshotVersion is increased whenever the snapshot format changes in an incompatible way.
+// Snapshots of other versions are rejected, and the caches are reloaded from GitHub instead.
+const snapshotVersion = 5
+
+// snapshot is the persisted form of the store caches.
+type snapshot struct {
+	Version    int                              `json:"version"`
+	CreatedAt  time.Time                        `json:"created_at"`
+	Modules    map[string][]*core.ModuleVersion `json:"modules"`
+	ModuleTags map[string]string                `json:"module_tags"`
+	// Documentation extracted from module source archives by commit SHA.
+	ModuleDocs       map[string]*core.ModuleMetadata   `json:"module_docs"`
+	ProviderVersions map[string]*core.ProviderVersions `json:"provider_versions"`
+	Providers        map[string]*core.Provider         `json:"providers"`
+	ProviderIgnored  []core.IgnoredRelease             `json:"provider_ignored"`
+	// SHA256 digests of verified provider assets, used to serve them from the asset cache.
+	ProviderAssetDigests map[string]string `json:"provider_asset_digests"`
+	// IDs of the provider releases by version, used to serve their assets.
+	ProviderReleaseIDs map[string]int64 `json:"provider_release_ids"`
+}
+
+// SaveSnapshot writes the module and provider caches to `w`.
+func (s *GitHubStore) SaveSnapshot(w io.Writer) error {
+	snap := snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.moduleMut.RLock()
+	snap.Modules = s.moduleCache
+	snap.ModuleTags = s.moduleTagCache
+	snap.ModuleDocs = s.moduleDocCache
+	s.moduleMut.RUnlock()
+
+	s.providerMut.RLock()
+	snap.ProviderVersions = s.providerVersionsCache
+	snap.Providers = s.providerCache
+	snap.ProviderAssetDigests = s.providerAssetDigests
+	snap.ProviderReleaseIDs = s.providerReleaseIDs
+	s.providerMut.RUnlock()
+
+	snap.ProviderIgnored = s.providerIgnored.list()
+
+	// The caches are replaced, never modified, on reload. Encoding them
+	// without holding the locks is therefore safe.
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// LoadSnapshot replaces the module and provider caches with the contents of a
+// snapshot previously written by `SaveSnapshot`.
+func (s *GitHubStore) LoadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, expected %d", snap.Version, snapshotVersion)
+	}
+	if snap.Modules == nil {
+		snap.Modules = make(map[string][]*core.ModuleVersion)
+	}
+	if snap.ProviderVersions == nil {
+		snap.ProviderVersions = make(map[string]*core.ProviderVersions)
+	}
+	if snap.Providers == nil {
+		snap.Providers = make(map[string]*core.Provider)
+	}
+
+	s.moduleMut.Lock()
+	s.moduleCache = snap.Modules
+	s.moduleTagCache = snap.ModuleTags
+	s.moduleDocCache = snap.ModuleDocs
+	s.moduleMut.Unlock()
+
+	s.providerMut.Lock()
+	s.providerVersionsCache = snap.ProviderVersions
+	s.providerCache = snap.Providers
+	s.providerAssetDigests = snap.ProviderAssetDigests
+	s.providerReleaseIDs = snap.ProviderReleaseIDs
+	s.providerMut.Unlock()
+
+	s.providerIgnored.restore(snap.ProviderIgnored)
+
+	s.logger.Info("loaded cache snapshot",
+		zap.Time("created", snap.CreatedAt),
+		zap.Int("modules", len(snap.Modules)),
+		zap.Int("providers", len(snap.ProviderVersions)),
+	)
+
+	return nil
+}
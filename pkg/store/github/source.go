@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+)
+
+// DefaultModuleSourceTemplate is the module source URL template used when none is configured.
+const DefaultModuleSourceTemplate = "git::ssh://git@{host}/{owner}/{repo}.git?ref={tag}"
+
+var (
+	templatePlaceholderRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+	// Placeholders available in module source URL templates.
+	templatePlaceholders = []string{"{host}", "{owner}", "{repo}", "{tag}", "{version}", "{sha}"}
+)
+
+// SourceTemplates configures how module source URLs are generated.
+// Templates may contain the placeholders `{host}`, `{owner}`, `{repo}`, `{tag}`,
+// `{version}` (the tag without the `v` prefix) and `{sha}` (the commit SHA of the tag).
+//
+// Example: `git::https://{host}/{owner}/{repo}.git?ref={tag}`
+type SourceTemplates struct {
+	// Default template for all modules. Uses `DefaultModuleSourceTemplate` when empty.
+	Default string `json:"default"`
+	// Owners maps repository owners to templates overriding the default. Owners are matched
+	// case-insensitively, so the keys must not differ only in case.
+	Owners map[string]string `json:"owners"`
+	// Topics maps repository topics to templates overriding the default and owner templates.
+	// If a repository has several topics with templates, the first topic listed on the repository wins.
+	Topics map[string]string `json:"topics"`
+}
+
+// Validate returns an error if any of the templates contain unknown placeholders.
+func (t SourceTemplates) Validate() error {
+	if err := validateSourceTemplate(t.Default); err != nil {
+		return fmt.Errorf("default template: %w", err)
+	}
+	seen := make(map[string]string, len(t.Owners))
+	for _, owner := range slices.Sorted(maps.Keys(t.Owners)) {
+		if other, ok := seen[strings.ToLower(owner)]; ok {
+			return fmt.Errorf("templates for owners '%s' and '%s' differ only in case", other, owner)
+		}
+		seen[strings.ToLower(owner)] = owner
+		if err := validateSourceTemplate(t.Owners[owner]); err != nil {
+			return fmt.Errorf("template for owner '%s': %w", owner, err)
+		}
+	}
+	for topic, tmpl := range t.Topics {
+		if err := validateSourceTemplate(tmpl); err != nil {
+			return fmt.Errorf("template for topic '%s': %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// templateFor returns the template to use for the repository. Expects the owner keys to be
+// lowercased by `SetModuleSourceTemplates`.
+func (t SourceTemplates) templateFor(owner string, repo *github.Repository) string {
+	for _, topic := range repo.Topics {
+		if tmpl, ok := t.Topics[topic]; ok {
+			return tmpl
+		}
+	}
+	if tmpl, ok := t.Owners[strings.ToLower(owner)]; ok {
+		return tmpl
+	}
+	if t.Default != "" {
+		return t.Default
+	}
+	return DefaultModuleSourceTemplate
+}
+
+// SetModuleSourceTemplates sets the templates used to generate module source URLs.
+// Takes effect on the next cache reload.
+func (s *GitHubStore) SetModuleSourceTemplates(templates SourceTemplates) error {
+	if err := templates.Validate(); err != nil {
+		return err
+	}
+
+	owners := make(map[string]string, len(templates.Owners))
+	for owner, tmpl := range templates.Owners {
+		owners[strings.ToLower(owner)] = tmpl
+	}
+	templates.Owners = owners
+
+	s.moduleMut.Lock()
+	s.sourceTemplates = templates
+	s.moduleMut.Unlock()
+
+	return nil
+}
+
+// moduleSourceURL renders the module source URL for a tag in the repository.
+func (s *GitHubStore) moduleSourceURL(owner, name string, repo *github.Repository, tag *github.RepositoryTag) string {
+	s.moduleMut.RLock()
+	tmpl := s.sourceTemplates.templateFor(owner, repo)
+	archiveDownloads := s.archiveDownloads
+	s.moduleMut.RUnlock()
+
+	if archiveDownloads {
+		return moduleArchiveURL(owner, name, strings.TrimPrefix(tag.GetName(), "v"))
+	}
+
+	return strings.NewReplacer(
+		"{host}", s.gitHost(),
+		"{owner}", owner,
+		"{repo}", name,
+		"{tag}", tag.GetName(),
+		"{version}", strings.TrimPrefix(tag.GetName(), "v"),
+		"{sha}", tag.GetCommit().GetSHA(),
+	).Replace(tmpl)
+}
+
+func validateSourceTemplate(tmpl string) error {
+	for _, p := range templatePlaceholderRegex.FindAllString(tmpl, -1) {
+		if !slices.Contains(templatePlaceholders, p) {
+			return fmt.Errorf("unknown placeholder '%s', must be one of %s", p, strings.Join(templatePlaceholders, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/go-github/v76/github"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+)
+
+// SetTrustedKeyring pins the GPG keys allowed to sign provider releases, from an ASCII armored
+// keyring. When set, releases signed with a key not in the keyring are rejected, even if the
+// release includes a matching public key. This prevents anyone with write access to a provider
+// repository from publishing releases signed with their own key.
+func (s *GitHubStore) SetTrustedKeyring(r io.Reader) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(r)
+	if err != nil {
+		return fmt.Errorf("unable to read trusted keyring: %w", err)
+	}
+
+	s.providerMut.Lock()
+	s.trustedKeyring = keyring
+	s.providerMut.Unlock()
+
+	return nil
+}
+
+// verifyProviderRelease verifies that the SHA256SUMS file of the release is signed by the release
+// GPG key, and that the checksums of all platform archives match the ones in the file. Returns an
+// `storeutil.InvalidReleaseError` describing why the release is not valid, an error if its assets could not be
+// downloaded, or the SHA256 digests of the verified assets by name.
+func (s *GitHubStore) verifyProviderRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease, keyArmor string, sums map[string]string, sumsFileName string, sumsContent []byte, digests *digestCache) (map[string]string, error) {
+	var sigAsset *github.ReleaseAsset
+	for _, asset := range release.Assets {
+		if asset.GetName() == sumsFileName+".sig" {
+			sigAsset = asset
+			break
+		}
+	}
+	if sigAsset == nil {
+		return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("could not find SHA checksums signature '%s.sig'", sumsFileName)}
+	}
+
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return nil, err
+	}
+	sigBody, _, err := client.Repositories.DownloadReleaseAsset(ctx, owner, repo, sigAsset.GetID(), http.DefaultClient)
+	s.rateLimiterFor(owner).observe(nil, err)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get SHA checksums signature: %w", err)
+	}
+	sig, err := io.ReadAll(sigBody)
+	sigBody.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get SHA checksums signature: %w", err)
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(keyArmor))
+	if err != nil {
+		return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("unable to read GPG Public Key: %s", err)}
+	}
+
+	signer, err := storeutil.CheckDetachedSignature(keyring, sumsContent, sig)
+	if err != nil {
+		return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("SHA checksums signature not valid for GPG Public Key: %s", err)}
+	}
+
+	s.providerMut.RLock()
+	trusted := s.trustedKeyring
+	s.providerMut.RUnlock()
+	if trusted != nil && !keyringContains(trusted, signer) {
+		return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("GPG Public Key %s is not in the trusted keyring", signer.PrimaryKey.KeyIdString())}
+	}
+
+	verified := map[string]string{
+		sumsFileName:       sha256Hex(sumsContent),
+		sigAsset.GetName(): sha256Hex(sig),
+	}
+	for _, asset := range release.Assets {
+		if _, ok := extractOsArch(asset.GetName()); !ok {
+			continue
+		}
+
+		expected, ok := sums[asset.GetName()]
+		if !ok {
+			return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("could not find SHA checksum for '%s'", asset.GetName())}
+		}
+
+		actual, err := s.assetDigest(ctx, owner, repo, asset, digests)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get SHA checksum for '%s': %w", asset.GetName(), err)
+		}
+		if !strings.EqualFold(expected, actual) {
+			return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("SHA checksum mismatch for '%s': expected %s, got %s", asset.GetName(), expected, actual)}
+		}
+		verified[asset.GetName()] = strings.ToLower(actual)
+	}
+
+	return verified, nil
+}
+
+// keyringContains returns whether the primary key of the entity is in the keyring.
+func keyringContains(keyring openpgp.EntityList, entity *openpgp.Entity) bool {
+	for _, e := range keyring {
+		if bytes.Equal(e.PrimaryKey.Fingerprint, entity.PrimaryKey.Fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// digestCache holds the SHA256 digests of release assets by asset ID. Digests found in
+// the previous reload are reused, and the ones found during the current reload are
+// collected in fresh, which replaces the cache afterwards.
+type digestCache struct {
+	previous map[int64]string
+	fresh    map[int64]string
+}
+
+func newDigestCache(previous map[int64]string) *digestCache {
+	return &digestCache{
+		previous: previous,
+		fresh:    make(map[int64]string),
+	}
+}
+
+// assetDigest returns the hex encoded SHA256 digest of the release asset. GitHub reports the digest
+// of assets uploaded after mid 2025. For older assets the digest is computed by downloading the asset,
+// and cached for later reloads.
+func (s *GitHubStore) assetDigest(ctx context.Context, owner, repo string, asset *github.ReleaseAsset, digests *digestCache) (string, error) {
+	if digest, ok := strings.CutPrefix(asset.GetDigest(), "sha256:"); ok {
+		return digest, nil
+	}
+	if digest, ok := digests.previous[asset.GetID()]; ok {
+		digests.fresh[asset.GetID()] = digest
+		return digest, nil
+	}
+
+	client, err := s.clientFor(owner)
+	if err != nil {
+		return "", err
+	}
+	body, _, err := client.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID(), http.DefaultClient)
+	s.rateLimiterFor(owner).observe(nil, err)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	digests.fresh[asset.GetID()] = digest
+	return digest, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
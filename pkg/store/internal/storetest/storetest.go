@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+// Package storetest holds the test fixtures shared by the store tests.
+package storetest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// NewKey returns a new GPG key, and its ASCII armored public key.
+func NewKey(t testing.TB) (*openpgp.Entity, []byte) {
+	t.Helper()
+	key, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	return key, buf.Bytes()
+}
+
+// File is a file of a provider release.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// Package is the provider package of a platform.
+type Package struct {
+	File
+	OS   string
+	Arch string
+}
+
+// ProviderRelease is a signed release of `terraform-provider-test`.
+type ProviderRelease struct {
+	Version   string
+	Packages  []Package
+	SHASums   File
+	Signature File
+}
+
+// NewProviderRelease returns a release of `terraform-provider-test` signed by `key`, with
+// packages for `platforms`, like `linux_amd64`.
+func NewProviderRelease(t testing.TB, key *openpgp.Entity, version string, platforms ...string) ProviderRelease {
+	t.Helper()
+	release := ProviderRelease{Version: version}
+
+	var sums bytes.Buffer
+	for _, platform := range platforms {
+		os, arch, _ := strings.Cut(platform, "_")
+		name := fmt.Sprintf("terraform-provider-test_%s_%s.zip", version, platform)
+		content := []byte("package " + name)
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+		release.Packages = append(release.Packages, Package{File: File{Name: name, Content: content}, OS: os, Arch: arch})
+	}
+	release.SHASums = File{Name: fmt.Sprintf("terraform-provider-test_%s_SHA256SUMS", version), Content: sums.Bytes()}
+
+	var sig bytes.Buffer
+	if err := openpgp.DetachSign(&sig, key, bytes.NewReader(sums.Bytes()), nil); err != nil {
+		t.Fatal(err)
+	}
+	release.Signature = File{Name: release.SHASums.Name + ".sig", Content: sig.Bytes()}
+
+	return release
+}
+
+// Files returns the packages, checksums and signature of the release.
+func (r ProviderRelease) Files() []File {
+	files := make([]File, 0, len(r.Packages)+2)
+	for _, p := range r.Packages {
+		files = append(files, p.File)
+	}
+	return append(files, r.SHASums, r.Signature)
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package storeutil
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// DownloadMode selects how clients download module archives and provider packages. The modes
+// supported depend on the store.
+type DownloadMode string
+
+// DownloadProxy returns URLs of the registry `/download/` routes, which serve the files from
+// the store. The routes are protected by the download token of the registry.
+const DownloadProxy DownloadMode = "proxy"
+
+// ParseDownloadMode returns the download mode named `s`, which must be one of `modes`.
+func ParseDownloadMode(s string, modes ...DownloadMode) (DownloadMode, error) {
+	if mode := DownloadMode(s); slices.Contains(modes, mode) {
+		return mode, nil
+	}
+
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = string(mode)
+	}
+	return "", fmt.Errorf("invalid download mode '%s', expected one of: %s", s, strings.Join(names, ", "))
+}
+
+// ModuleProxyURL returns the URL of the source archive of the module version on the registry
+// `/download/module/` route. `archive` is the format of the archive, `zip` or `tar.gz`.
+func ModuleProxyURL(address, version, archive string) string {
+	return fmt.Sprintf("/download/module/%s/%s?archive=%s", address, version, archive)
+}
+
+// ProviderProxyURL returns the URL of the provider release file on the registry `/download/provider/` route.
+func ProviderProxyURL(namespace, name, version, file string) string {
+	return fmt.Sprintf("/download/provider/%s/%s/%s/asset/%s", namespace, name, version, file)
+}
+
+// WithSourceURL returns a copy of the module version with the source URL `sourceURL`.
+// Cached versions are never modified, as download URLs may be signed for each request.
+func WithSourceURL(ver *core.ModuleVersion, sourceURL string) *core.ModuleVersion {
+	res := *ver
+	res.SourceURL = sourceURL
+	return &res
+}
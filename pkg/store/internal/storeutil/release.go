@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package storeutil
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// InvalidReleaseError is returned for provider releases that are not valid, as opposed to
+// failing to read them.
+type InvalidReleaseError struct {
+	Reason string
+}
+
+func (e InvalidReleaseError) Error() string {
+	return e.Reason
+}
+
+// CheckDetachedSignature verifies the signature of `signed`, which may be ASCII armored or binary,
+// and returns the key that made it.
+func CheckDetachedSignature(keyring openpgp.EntityList, signed, sig []byte) (*openpgp.Entity, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		return openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(sig), nil)
+	}
+	return openpgp.CheckDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(sig), nil)
+}
+
+// ParseSHASums returns the checksums of a `SHA256SUMS` file by file name. Lines that are not
+// a checksum followed by a file name are skipped.
+func ParseSHASums(b []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 2 {
+			sums[parts[1]] = parts[0]
+		}
+	}
+	return sums
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+// Package storeutil holds the helpers shared by the module and provider stores.
+package storeutil
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// SemverRegExp matches a SemVer version, without anchors.
+// https://semver.org/#is-there-a-suggested-regular-expression-regex-to-check-a-semver-string
+const SemverRegExp = `(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?`
+
+var (
+	// VersionRegex matches a SemVer version.
+	VersionRegex = regexp.MustCompile(`^` + SemverRegExp + `$`)
+	// VersionTagRegex matches a SemVer version, optionally prefixed with `v` as in Git tags.
+	VersionTagRegex = regexp.MustCompile(`^v?` + SemverRegExp + `$`)
+
+	modulePathRegex = regexp.MustCompile(`^\w+/\w+/\w+/` + SemverRegExp + `(/|$)`)
+)
+
+// IsValidModulePath reports whether `path` is the directory of a module version, i.e.
+// `{namespace}/{name}/{provider}/{version}`, or a file in it.
+func IsValidModulePath(path string) bool {
+	return modulePathRegex.MatchString(path)
+}
+
+// NormalizePrefix returns the key prefix without leading slashes, and with a trailing slash unless empty.
+func NormalizePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// ModuleLister collects the addresses of the modules in a listing of the keys in a bucket or container.
+type ModuleLister struct {
+	prefix  string
+	seen    map[core.ModuleAddress]bool
+	modules []core.ModuleAddress
+}
+
+// NewModuleLister returns a lister of the modules with keys starting with `prefix`.
+func NewModuleLister(prefix string) *ModuleLister {
+	return &ModuleLister{
+		prefix:  prefix,
+		seen:    make(map[core.ModuleAddress]bool),
+		modules: make([]core.ModuleAddress, 0),
+	}
+}
+
+// Add adds the module of the object at `key`, unless it is not in a module version directory.
+func (l *ModuleLister) Add(key string) {
+	key = strings.TrimPrefix(key, l.prefix)
+	if !IsValidModulePath(key) {
+		return
+	}
+	parts := strings.Split(key, "/")
+	addr := core.ModuleAddress{Namespace: parts[0], Name: parts[1], Provider: parts[2]}
+	if !l.seen[addr] {
+		l.seen[addr] = true
+		l.modules = append(l.modules, addr)
+	}
+}
+
+// Modules returns the modules added. Storage services list keys in lexicographical order,
+// so the modules are already sorted.
+func (l *ModuleLister) Modules() []core.ModuleAddress {
+	return l.modules
+}
+
+// BackendError classifies an error returned by a storage service, by the status code of the
+// response in the error chain of type `E`, as returned by `status`.
+func BackendError[E error](err error, status func(E) int) error {
+	var resp E
+	if errors.As(err, &resp) {
+		return core.BackendError(err, status(resp))
+	}
+	return core.BackendError(err, 0)
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package storeutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storetest"
+)
+
+func TestIsValidModulePath(t *testing.T) {
+	tests := []struct {
+		path  string
+		valid bool
+	}{
+		{"testnamespace/testname/testprovider/1.0.0", true},
+		{"testnamespace/testname/testprovider/1.0.0/1.0.0.zip", true},
+		{"testnamespace/testname/testprovider/1.0.0-rc.1+build.5/1.0.0-rc.1+build.5.zip", true},
+		{"testnamespace/testname/testprovider/latest.txt", false},
+		{"testnamespace/testname/testprovider/1.0.0.zip", false},
+		{"testnamespace/testname/1.0.0/1.0.0.zip", false},
+		{"testnamespace/testname/testprovider/v1.0.0/v1.0.0.zip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(IsValidModulePath(tt.path), tt.valid)
+		})
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	is := is.New(t)
+	is.Equal(NormalizePrefix(""), "")
+	is.Equal(NormalizePrefix("/"), "")
+	is.Equal(NormalizePrefix("terraform"), "terraform/")
+	is.Equal(NormalizePrefix("/terraform/modules/"), "terraform/modules/")
+}
+
+func TestModuleLister(t *testing.T) {
+	is := is.New(t)
+	l := NewModuleLister("terraform/")
+	for _, key := range []string{
+		"terraform/testnamespace/other/testprovider/0.1.0/0.1.0.zip",
+		"terraform/testnamespace/testname/testprovider/1.0.0/1.0.0.zip",
+		"terraform/testnamespace/testname/testprovider/2.0.0/2.0.0.zip",
+		"terraform/testnamespace/testname/testprovider/latest.txt",
+	} {
+		l.Add(key)
+	}
+	is.Equal(l.Modules(), []core.ModuleAddress{
+		{Namespace: "testnamespace", Name: "other", Provider: "testprovider"},
+		{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+	})
+
+	is.Equal(NewModuleLister("").Modules(), []core.ModuleAddress{})
+}
+
+// statusError is an error of a storage service with the status code of the response.
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return http.StatusText(e.status)
+}
+
+func TestBackendError(t *testing.T) {
+	status := func(e *statusError) int { return e.status }
+
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"not found", fmt.Errorf("get: %w", &statusError{http.StatusNotFound}), core.ErrNotFound},
+		// The credentials of the store are rejected, which is not the fault of the client
+		{"forbidden", &statusError{http.StatusForbidden}, core.ErrUnavailable},
+		{"throttled", &statusError{http.StatusTooManyRequests}, core.ErrRateLimited},
+		{"no response", errors.New("connection refused"), core.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			err := BackendError(tt.err, status)
+			is.True(errors.Is(err, tt.kind))
+			is.True(errors.Is(err, tt.err))
+		})
+	}
+}
+
+func TestParseDownloadMode(t *testing.T) {
+	is := is.New(t)
+	modes := []DownloadMode{"s3", "presigned", DownloadProxy}
+
+	for _, s := range []string{"s3", "presigned", "proxy"} {
+		mode, err := ParseDownloadMode(s, modes...)
+		is.NoErr(err)
+		is.Equal(string(mode), s)
+	}
+
+	_, err := ParseDownloadMode("signed", modes...)
+	is.Equal(err.Error(), "invalid download mode 'signed', expected one of: s3, presigned, proxy")
+}
+
+func TestProxyURLs(t *testing.T) {
+	is := is.New(t)
+	is.Equal(ModuleProxyURL("testnamespace/testname/testprovider", "1.0.0", "zip"), "/download/module/testnamespace/testname/testprovider/1.0.0?archive=zip")
+	is.Equal(ModuleProxyURL("testnamespace/testname/testprovider", "1.0.0", "tar.gz"), "/download/module/testnamespace/testname/testprovider/1.0.0?archive=tar.gz")
+	is.Equal(ProviderProxyURL("testnamespace", "test", "1.0.0", "terraform-provider-test_1.0.0_SHA256SUMS"), "/download/provider/testnamespace/test/1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS")
+
+	ver := &core.ModuleVersion{Version: "1.0.0", SourceURL: "s3::https://example.com/1.0.0.zip"}
+	res := WithSourceURL(ver, "/download/module/a/b/c/1.0.0?archive=zip")
+	is.Equal(res.SourceURL, "/download/module/a/b/c/1.0.0?archive=zip")
+	is.Equal(ver.SourceURL, "s3::https://example.com/1.0.0.zip") // the cached version is not modified
+}
+
+func TestCheckDetachedSignature(t *testing.T) {
+	key, _ := storetest.NewKey(t)
+	other, _ := storetest.NewKey(t)
+	release := storetest.NewProviderRelease(t, key, "1.0.0", "linux_amd64")
+	keyring := openpgp.EntityList{key}
+
+	t.Run("binary", func(t *testing.T) {
+		is := is.New(t)
+		signer, err := CheckDetachedSignature(keyring, release.SHASums.Content, release.Signature.Content)
+		is.NoErr(err)
+		is.Equal(signer, key)
+	})
+
+	t.Run("armored", func(t *testing.T) {
+		is := is.New(t)
+		var sig bytes.Buffer
+		is.NoErr(openpgp.ArmoredDetachSign(&sig, key, bytes.NewReader(release.SHASums.Content), nil))
+		signer, err := CheckDetachedSignature(keyring, release.SHASums.Content, sig.Bytes())
+		is.NoErr(err)
+		is.Equal(signer, key)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		is := is.New(t)
+		_, err := CheckDetachedSignature(openpgp.EntityList{other}, release.SHASums.Content, release.Signature.Content)
+		is.True(err != nil)
+	})
+
+	t.Run("modified content", func(t *testing.T) {
+		is := is.New(t)
+		_, err := CheckDetachedSignature(keyring, append(release.SHASums.Content, '\n'), release.Signature.Content)
+		is.True(err != nil)
+	})
+}
+
+func TestParseSHASums(t *testing.T) {
+	is := is.New(t)
+	sums := ParseSHASums([]byte("abc123  terraform-provider-test_1.0.0_linux_amd64.zip\n" +
+		"not a checksum line\n" +
+		"def456  terraform-provider-test_1.0.0_darwin_arm64.zip\n"))
+	is.Equal(sums, map[string]string{
+		"terraform-provider-test_1.0.0_linux_amd64.zip":  "abc123",
+		"terraform-provider-test_1.0.0_darwin_arm64.zip": "def456",
+	})
+}
@@ -7,6 +7,8 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/nrkno/terraform-registry/pkg/core"
@@ -44,12 +46,34 @@ func (s *MemoryStore) Set(key string, m []*core.ModuleVersion) {
 	s.store[key] = m
 }
 
+// ListModules returns all modules with keys in the `namespace/name/provider` format, sorted by address.
+func (s *MemoryStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	keys := make([]string, 0, len(s.store))
+	for key := range s.store {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	modules := make([]core.ModuleAddress, 0, len(keys))
+	for _, key := range keys {
+		parts := strings.Split(key, "/")
+		if len(parts) != 3 {
+			continue
+		}
+		modules = append(modules, core.ModuleAddress{Namespace: parts[0], Name: parts[1], Provider: parts[2]})
+	}
+	return modules, nil
+}
+
 // ListModuleVersions returns a list of module versions.
 func (s *MemoryStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
 	key := fmt.Sprintf("%s/%s/%s", namespace, name, provider)
 	versions := s.Get(key)
 	if versions == nil {
-		return nil, fmt.Errorf("module '%s' not found", key)
+		return nil, core.NotFoundError("module '%s' not found", key)
 	}
 
 	return versions, nil
@@ -60,7 +84,7 @@ func (s *MemoryStore) GetModuleVersion(ctx context.Context, namespace, name, pro
 	key := fmt.Sprintf("%s/%s/%s", namespace, name, provider)
 	versions := s.Get(key)
 	if versions == nil {
-		return nil, fmt.Errorf("module '%s' not found", key)
+		return nil, core.NotFoundError("module '%s' not found", key)
 	}
 
 	for _, v := range versions {
@@ -69,5 +93,5 @@ func (s *MemoryStore) GetModuleVersion(ctx context.Context, namespace, name, pro
 		}
 	}
 
-	return nil, fmt.Errorf("version '%s' not found for module '%s'", version, key)
+	return nil, core.NotFoundError("version '%s' not found for module '%s'", version, key)
 }
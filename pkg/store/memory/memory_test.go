@@ -91,3 +91,19 @@ func TestGetModuleVersion(t *testing.T) {
 		is.Equal(ver, nil)
 	})
 }
+
+func TestListModules(t *testing.T) {
+	is := is.New(t)
+
+	s := NewMemoryStore()
+	s.Set("foo/qux/baz", []*core.ModuleVersion{{Version: "1"}})
+	s.Set("foo/bar/baz", []*core.ModuleVersion{{Version: "1"}})
+	s.Set("invalid", []*core.ModuleVersion{{Version: "1"}})
+
+	modules, err := s.ListModules(context.TODO())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "foo", Name: "bar", Provider: "baz"},
+		{Namespace: "foo", Name: "qux", Provider: "baz"},
+	})
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package oci
+
+import (
+	"fmt"
+
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// ClientOptions configures the client of the OCI registry.
+type ClientOptions struct {
+	// Host of the registry, such as `harbor.example.com` or `localhost:5000`.
+	Host string
+	// PlainHTTP uses HTTP instead of HTTPS, for local registries.
+	PlainHTTP bool
+	// Username and Password of the registry, such as a Harbor robot account. When empty, the
+	// credentials are read from the Docker configuration and its credential helpers, as set up by
+	// `docker login` or `oras login`. Anonymous access is used when there are none.
+	Username string
+	Password string
+	// UserAgent of the requests.
+	UserAgent string
+}
+
+// NewClient returns a client of the OCI registry.
+func NewClient(opts ClientOptions) (*remote.Registry, error) {
+	reg, err := remote.NewRegistry(opts.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCI registry host '%s': %w", opts.Host, err)
+	}
+	reg.PlainHTTP = opts.PlainHTTP
+
+	client := &auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.NewCache(),
+	}
+	if opts.UserAgent != "" {
+		client.SetUserAgent(opts.UserAgent)
+	}
+
+	if opts.Username != "" || opts.Password != "" {
+		client.Credential = auth.StaticCredential(reg.Reference.Registry, auth.Credential{
+			Username: opts.Username,
+			Password: opts.Password,
+		})
+	} else {
+		store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to read Docker credentials: %w", err)
+		}
+		client.Credential = credentials.Credential(store)
+	}
+
+	reg.Client = client
+	return reg, nil
+}
@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// DownloadMode selects how clients download module archives and provider packages.
+type DownloadMode = storeutil.DownloadMode
+
+const (
+	// DownloadProxy returns URLs of the registry `/download/` routes, which serve the layers from
+	// the OCI registry. The routes are protected by the download token of the registry.
+	DownloadProxy = storeutil.DownloadProxy
+	// DownloadRedirect returns the locations the OCI registry redirects layer downloads to, such
+	// as presigned URLs of its storage backend, so clients download directly from the storage.
+	// Falls back to `DownloadProxy` for layers the OCI registry serves itself.
+	DownloadRedirect DownloadMode = "redirect"
+)
+
+// ParseDownloadMode returns the download mode named `s`.
+func ParseDownloadMode(s string) (DownloadMode, error) {
+	return storeutil.ParseDownloadMode(s, DownloadProxy, DownloadRedirect)
+}
+
+// SetDownloadMode sets how clients download module archives and provider packages.
+func (s *OCIStore) SetDownloadMode(mode DownloadMode) {
+	s.mut.Lock()
+	s.downloadMode = mode
+	s.mut.Unlock()
+}
+
+func (s *OCIStore) getDownloadMode() DownloadMode {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.downloadMode
+}
+
+// withModuleDownloadURL returns the module version with the source URL of the download mode.
+func (s *OCIStore) withModuleDownloadURL(ctx context.Context, repo *remote.Repository, layer ocispec.Descriptor, ver *core.ModuleVersion) (*core.ModuleVersion, error) {
+	if s.getDownloadMode() != DownloadRedirect {
+		return ver, nil
+	}
+
+	u, err := s.blobRedirect(ctx, repo, layer)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return ver, nil
+	}
+	// The archive type can not be detected from the storage location, which has no file extension
+	q := u.Query()
+	q.Set("archive", "zip")
+	u.RawQuery = q.Encode()
+
+	res := *ver
+	res.SourceURL = u.String()
+	return &res, nil
+}
+
+// providerFileURL returns the download URL of the release file `file` in the layer `layer`.
+func (s *OCIStore) providerFileURL(ctx context.Context, repo *remote.Repository, layer ocispec.Descriptor, namespace, name, tag, file string) (string, error) {
+	if s.getDownloadMode() == DownloadRedirect {
+		u, err := s.blobRedirect(ctx, repo, layer)
+		if err != nil {
+			return "", err
+		}
+		if u != nil {
+			return u.String(), nil
+		}
+	}
+	return storeutil.ProviderProxyURL(namespace, name, tag, file), nil
+}
+
+// blobRedirect returns the location the OCI registry redirects downloads of the blob to, or nil
+// if the OCI registry serves the blob itself.
+func (s *OCIStore) blobRedirect(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor) (*url.URL, error) {
+	client := s.noRedirectClient()
+	if client == nil {
+		return nil, nil
+	}
+
+	ref := registry.Reference{Registry: repo.Reference.Registry, Repository: repo.Reference.Repository}
+	scheme := "https"
+	if repo.PlainHTTP {
+		scheme = "http"
+	}
+	blobURL := &url.URL{Scheme: scheme, Host: ref.Host(), Path: path.Join("/v2", ref.Repository, "blobs", desc.Digest.String())}
+
+	ctx = auth.AppendRepositoryScope(ctx, ref, auth.ActionPull)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil, nil
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		location, err := resp.Location()
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect for blob '%s': %w", desc.Digest, err)
+		}
+		return location, nil
+	}
+	return nil, core.BackendError(fmt.Errorf("unexpected status '%s' for blob '%s'", resp.Status, desc.Digest), resp.StatusCode)
+}
+
+// noRedirectClient returns a copy of the client of the OCI registry that does not follow redirects,
+// sharing its credentials and tokens. Returns nil for clients other than `auth.Client`.
+func (s *OCIStore) noRedirectClient() *auth.Client {
+	client := auth.DefaultClient
+	if s.registry.Client != nil {
+		var ok bool
+		if client, ok = s.registry.Client.(*auth.Client); !ok {
+			s.logger.Warn("unable to resolve blob redirects with the OCI registry client, falling back to proxy downloads")
+			return nil
+		}
+	}
+
+	res := *client
+	httpClient := http.Client{}
+	if client.Client != nil {
+		httpClient = *client.Client
+	}
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res.Client = &httpClient
+	return &res
+}
+
+// sizedReader is a blob reader that knows its size, for the Content-Length of asset downloads.
+type sizedReader struct {
+	io.ReadCloser
+	size int64
+}
+
+func (r sizedReader) Size() int64 {
+	return r.size
+}
+
+// fetchBlob returns the contents of the layer.
+func fetchBlob(ctx context.Context, repo *remote.Repository, layer ocispec.Descriptor) (io.ReadCloser, error) {
+	rc, err := repo.Blobs().Fetch(ctx, layer)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return sizedReader{rc, layer.Size}, nil
+}
+
+// GetModuleArchive returns the zip archive of the module version, for serving through the registry.
+func (s *OCIStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	repo, layer, _, err := s.moduleArchive(ctx, path.Join(namespace, name, provider), version)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("proxying module archive", zap.String("digest", layer.Digest.String()))
+	return fetchBlob(ctx, repo, layer)
+}
@@ -0,0 +1,332 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+const (
+	// ModuleArtifactType is the artifact type of module manifests.
+	ModuleArtifactType = "application/vnd.terraform.module.v1"
+	// ModuleLayerMediaType is the media type of the module zip archive layer.
+	ModuleLayerMediaType = "application/vnd.terraform.module.v1+zip"
+	// ProviderArtifactType is the artifact type of provider manifests.
+	ProviderArtifactType = "application/vnd.terraform.provider.v1"
+	// ProviderLayerMediaType is the media type of the provider package layers.
+	ProviderLayerMediaType = "application/vnd.terraform.provider.v1+zip"
+
+	// AnnotationProtocols lists the provider protocol versions of a provider manifest, separated by commas.
+	AnnotationProtocols = "io.terraform.provider.protocols"
+)
+
+// maxManifestSize limits the size of manifests, and of the checksum, signature, key and
+// manifest files of provider releases.
+const maxManifestSize = 4 << 20
+
+// tagDigestTTL is how long the manifest digest of a provider release tag is cached, so that listing
+// the versions of a provider does not resolve every tag on each request.
+const tagDigestTTL = time.Minute
+
+// OCIStore is a module and provider store backed by repositories in an OCI registry.
+// Each module is a repository `{namespace}/{name}/{provider}`, and each provider a repository
+// `{namespace}/{name}`, with a tag per version. Module manifests have a single zip archive layer,
+// and provider manifests have a layer per release file built by GoReleaser, named by the
+// `org.opencontainers.image.title` annotation as done by `oras push`.
+type OCIStore struct {
+	registry *remote.Registry
+	// Prefixes of the module and provider repositories. Either empty or ending with a slash.
+	prefix         string
+	providerPrefix string
+	logger         *zap.Logger
+	mut            sync.RWMutex
+
+	// How clients download module archives and provider packages. Defaults to `DownloadProxy`.
+	downloadMode DownloadMode
+
+	// Provider releases by manifest digest. Manifests are immutable, so releases are never stale.
+	releaseCache map[string]*providerRelease
+	// Manifest digests of provider release tags by `{repository}:{tag}`. Tags may be moved, so
+	// they expire after `tagDigestTTL`.
+	tagDigests map[string]tagDigest
+}
+
+// tagDigest is the cached manifest digest of a tag.
+type tagDigest struct {
+	digest  string
+	expires time.Time
+}
+
+// NewOCIStore returns a store for the repositories of `reg`.
+func NewOCIStore(reg *remote.Registry, logger *zap.Logger) *OCIStore {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &OCIStore{
+		registry:     reg,
+		logger:       logger,
+		downloadMode: DownloadProxy,
+		releaseCache: make(map[string]*providerRelease),
+		tagDigests:   make(map[string]tagDigest),
+	}
+}
+
+// SetRepositoryPrefix sets the prefix of the module repositories, such as a Harbor project.
+// With the prefix `terraform/modules`, modules are expected in the repositories
+// `terraform/modules/{namespace}/{name}/{provider}`.
+func (s *OCIStore) SetRepositoryPrefix(prefix string) {
+	s.mut.Lock()
+	s.prefix = storeutil.NormalizePrefix(prefix)
+	s.mut.Unlock()
+}
+
+// SetProviderRepositoryPrefix sets the prefix of the provider repositories. With the prefix
+// `terraform/providers`, providers are expected in the repositories `terraform/providers/{namespace}/{name}`.
+func (s *OCIStore) SetProviderRepositoryPrefix(prefix string) {
+	s.mut.Lock()
+	s.providerPrefix = storeutil.NormalizePrefix(prefix)
+	s.mut.Unlock()
+}
+
+func (s *OCIStore) repositoryPrefixes() (string, string) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.prefix, s.providerPrefix
+}
+
+// repository returns the repository `name` of the registry.
+func (s *OCIStore) repository(ctx context.Context, name string) (*remote.Repository, error) {
+	repo, err := s.registry.Repository(ctx, name)
+	if err != nil {
+		// Repository names are only parsed, so an invalid name can not exist
+		return nil, core.NotFoundError("repository '%s' is not valid: %w", name, err)
+	}
+	return repo.(*remote.Repository), nil
+}
+
+// isNotFound returns whether `err` is a missing repository, tag or blob.
+func isNotFound(err error) bool {
+	var errResp *errcode.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.StatusCode == http.StatusNotFound
+	}
+	return errors.Is(err, errdef.ErrNotFound)
+}
+
+// backendError classifies an error returned by the OCI registry, by the status code of the response.
+func backendError(err error) error {
+	if errors.Is(err, errdef.ErrNotFound) {
+		return core.BackendError(err, http.StatusNotFound)
+	}
+	return storeutil.BackendError(err, func(e *errcode.ErrorResponse) int { return e.StatusCode })
+}
+
+// versionTags returns the tags of the repository that are SemVer versions, optionally prefixed
+// with `v`, by version.
+func (s *OCIStore) versionTags(ctx context.Context, repo *remote.Repository) (map[string]string, []string, error) {
+	tags := make(map[string]string)
+	var versions []string
+	err := repo.Tags(ctx, "", func(page []string) error {
+		for _, tag := range page {
+			if !isVersionTag(tag) {
+				continue
+			}
+			version := strings.TrimPrefix(tag, "v")
+			if _, ok := tags[version]; !ok {
+				versions = append(versions, version)
+			}
+			tags[version] = tag
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, backendError(err)
+	}
+	return tags, versions, nil
+}
+
+// fetchManifest returns the image manifest tagged `tag` in the repository.
+func fetchManifest(ctx context.Context, repo *remote.Repository, tag string) (ocispec.Descriptor, *ocispec.Manifest, error) {
+	desc, rc, err := repo.FetchReference(ctx, tag)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, backendError(err)
+	}
+	defer rc.Close()
+
+	if desc.MediaType != ocispec.MediaTypeImageManifest {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unsupported manifest media type '%s'", desc.MediaType)
+	}
+	b, err := io.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return ocispec.Descriptor{}, nil, backendError(err)
+	}
+	manifest := &ocispec.Manifest{}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("unable to decode manifest: %w", err)
+	}
+	return desc, manifest, nil
+}
+
+func (s *OCIStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
+	prefix, _ := s.repositoryPrefixes()
+	addr := path.Join(namespace, name, provider)
+	if !moduleAddressRegex.MatchString(addr) {
+		return nil, core.NotFoundError("module address '%s' is not valid", addr)
+	}
+
+	repo, err := s.repository(ctx, prefix+addr)
+	if err != nil {
+		return nil, err
+	}
+	_, versions, err := s.versionTags(ctx, repo)
+	if isNotFound(err) {
+		return nil, core.NotFoundError("module '%s' not found", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vers := make([]*core.ModuleVersion, 0, len(versions))
+	for _, version := range versions {
+		vers = append(vers, &core.ModuleVersion{
+			Version:   version,
+			SourceURL: storeutil.ModuleProxyURL(addr, version, "zip"),
+		})
+	}
+	return vers, nil
+}
+
+func (s *OCIStore) GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*core.ModuleVersion, error) {
+	addr := path.Join(namespace, name, provider)
+	repo, layer, manifest, err := s.moduleArchive(ctx, addr, version)
+	if err != nil {
+		return nil, err
+	}
+
+	ver := &core.ModuleVersion{
+		Version:   strings.TrimPrefix(version, "v"),
+		SourceURL: storeutil.ModuleProxyURL(addr, strings.TrimPrefix(version, "v"), "zip"),
+		Metadata:  moduleMetadata(manifest.Annotations),
+	}
+	return s.withModuleDownloadURL(ctx, repo, layer, ver)
+}
+
+// moduleArchive returns the repository, zip archive layer and manifest of the module version.
+func (s *OCIStore) moduleArchive(ctx context.Context, addr, version string) (*remote.Repository, ocispec.Descriptor, *ocispec.Manifest, error) {
+	prefix, _ := s.repositoryPrefixes()
+	if !moduleAddressRegex.MatchString(addr) || !isVersionTag(version) {
+		s.logger.Warn("invalid module path requested: " + path.Join(addr, version))
+		return nil, ocispec.Descriptor{}, nil, core.NotFoundError("module version path '%s' is not valid", path.Join(addr, version))
+	}
+
+	repo, err := s.repository(ctx, prefix+addr)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, nil, err
+	}
+
+	// Versions may be tagged with or without a `v` prefix
+	version = strings.TrimPrefix(version, "v")
+	_, manifest, err := fetchManifest(ctx, repo, version)
+	if isNotFound(err) {
+		_, manifest, err = fetchManifest(ctx, repo, "v"+version)
+	}
+	if isNotFound(err) {
+		return nil, ocispec.Descriptor{}, nil, core.NotFoundError("version '%s' not found for module '%s'", version, addr)
+	}
+	if err != nil {
+		return nil, ocispec.Descriptor{}, nil, err
+	}
+	if manifest.ArtifactType != "" && manifest.ArtifactType != ModuleArtifactType {
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("version '%s' of module '%s' has artifact type '%s', expected '%s'", version, addr, manifest.ArtifactType, ModuleArtifactType)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == ModuleLayerMediaType || strings.HasSuffix(layer.Annotations[ocispec.AnnotationTitle], ".zip") {
+			return repo, layer, manifest, nil
+		}
+	}
+	return nil, ocispec.Descriptor{}, nil, fmt.Errorf("no module archive layer in version '%s' of module '%s'", version, addr)
+}
+
+// moduleMetadata returns the module metadata of the pre-defined OCI annotations of a manifest.
+func moduleMetadata(annotations map[string]string) *core.ModuleMetadata {
+	meta := &core.ModuleMetadata{
+		CommitSHA:   annotations[ocispec.AnnotationRevision],
+		Description: annotations[ocispec.AnnotationDescription],
+	}
+	if created, err := time.Parse(time.RFC3339, annotations[ocispec.AnnotationCreated]); err == nil {
+		meta.PublishedAt = created.UTC()
+	}
+	if meta.CommitSHA == "" && meta.Description == "" && meta.PublishedAt.IsZero() {
+		return nil
+	}
+	return meta
+}
+
+// ListModules returns all module repositories in the registry, sorted by address.
+// Requires the catalog API of the registry, which may be limited to administrators.
+func (s *OCIStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	prefix, _ := s.repositoryPrefixes()
+	modules := make([]core.ModuleAddress, 0)
+
+	err := s.registry.Repositories(ctx, "", func(repos []string) error {
+		for _, repo := range repos {
+			addr, ok := strings.CutPrefix(repo, prefix)
+			if !ok || !moduleAddressRegex.MatchString(addr) {
+				continue
+			}
+			parts := strings.Split(addr, "/")
+			modules = append(modules, core.ModuleAddress{Namespace: parts[0], Name: parts[1], Provider: parts[2]})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, backendError(err)
+	}
+
+	// Not all registries list repositories in lexical order, as required by the distribution spec
+	sort.Slice(modules, func(i, j int) bool {
+		a, b := modules[i], modules[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Provider < b.Provider
+	})
+	return modules, nil
+}
+
+// isVersionTag returns whether `tag` is a SemVer version, optionally prefixed with `v`.
+// Tags can not contain `+`, so versions with build metadata are not supported.
+func isVersionTag(tag string) bool {
+	return !strings.Contains(tag, "+") && storeutil.VersionTagRegex.MatchString(tag)
+}
+
+var (
+	moduleAddressRegex   = regexp.MustCompile(`^[a-z0-9_-]+/[a-z0-9_-]+/[a-z0-9_-]+$`)
+	providerAddressRegex = regexp.MustCompile(`^[a-z0-9_-]+/[a-z0-9_-]+$`)
+)
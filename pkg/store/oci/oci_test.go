@@ -0,0 +1,418 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storetest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// testRegistry is an in-process OCI distribution server. With `redirect` set, blob downloads are
+// redirected to `/storage/`, like registries backed by object storage.
+type testRegistry struct {
+	*httptest.Server
+	redirect atomic.Bool
+	mut      sync.Mutex
+	blobs    map[string][]byte
+	// Number of requests for manifests by tag.
+	tagRequests atomic.Int64
+}
+
+var (
+	blobPathRegex = regexp.MustCompile(`^/v2/.+/blobs/(sha256:[a-f0-9]{64})$`)
+	tagPathRegex  = regexp.MustCompile(`^/v2/.+/manifests/[^:]+$`)
+)
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{blobs: make(map[string][]byte)}
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+
+	reg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if digest, ok := strings.CutPrefix(r.URL.Path, "/storage/"); ok {
+			reg.mut.Lock()
+			b, ok := reg.blobs[digest]
+			reg.mut.Unlock()
+			if !ok || r.URL.Query().Get("signature") != "test" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(b)
+			return
+		}
+		if tagPathRegex.MatchString(r.URL.Path) {
+			reg.tagRequests.Add(1)
+		}
+		if m := blobPathRegex.FindStringSubmatch(r.URL.Path); m != nil && r.Method == http.MethodGet && reg.redirect.Load() {
+			http.Redirect(w, r, "/storage/"+m[1]+"?signature=test", http.StatusTemporaryRedirect)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(reg.Close)
+
+	return reg
+}
+
+func (reg *testRegistry) host() string {
+	u, _ := url.Parse(reg.URL)
+	return u.Host
+}
+
+// testFile is a layer of a test artifact.
+type testFile struct {
+	title     string
+	mediaType string
+	content   []byte
+}
+
+// push pushes an artifact with the files as layers to the repository, tagged `tag`.
+func (reg *testRegistry) push(t *testing.T, repository, tag, artifactType string, annotations map[string]string, files ...testFile) {
+	repo, err := remote.NewRepository(reg.host() + "/" + repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.PlainHTTP = true
+	ctx := context.Background()
+
+	var layers []ocispec.Descriptor
+	for _, file := range files {
+		mediaType := file.mediaType
+		if mediaType == "" {
+			mediaType = "application/octet-stream"
+		}
+		desc := content.NewDescriptorFromBytes(mediaType, file.content)
+		desc.Annotations = map[string]string{ocispec.AnnotationTitle: file.title}
+		if err := repo.Push(ctx, desc, bytes.NewReader(file.content)); err != nil {
+			t.Fatal(err)
+		}
+		reg.mut.Lock()
+		reg.blobs[desc.Digest.String()] = file.content
+		reg.mut.Unlock()
+		layers = append(layers, desc)
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if _, ok := annotations[ocispec.AnnotationCreated]; !ok {
+		annotations[ocispec.AnnotationCreated] = "2025-03-01T12:00:00Z"
+	}
+	// Artifacts without an artifact type are packed as image manifests with an unknown config type
+	version := oras.PackManifestVersion1_1
+	if artifactType == "" {
+		version = oras.PackManifestVersion1_0
+	}
+	manifest, err := oras.PackManifest(ctx, repo, version, artifactType, oras.PackManifestOptions{
+		Layers:              layers,
+		ManifestAnnotations: annotations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Tag(ctx, manifest, tag); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestStore(t *testing.T, reg *testRegistry) *OCIStore {
+	client, err := NewClient(ClientOptions{Host: reg.host(), PlainHTTP: true, Username: "test", Password: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewOCIStore(client, zap.NewNop())
+}
+
+// testProviderRelease returns the files of a signed release of `terraform-provider-test` with
+// packages for `platforms`.
+func testProviderRelease(t *testing.T, key *openpgp.Entity, armoredKey []byte, version string, platforms ...string) []testFile {
+	release := storetest.NewProviderRelease(t, key, version, platforms...)
+	files := []testFile{{title: "gpg-public-key.pem", content: armoredKey}}
+	for _, p := range release.Packages {
+		files = append(files, testFile{title: p.Name, mediaType: ProviderLayerMediaType, content: p.Content})
+	}
+	return append(files,
+		testFile{title: release.SHASums.Name, content: release.SHASums.Content},
+		testFile{title: release.Signature.Name, content: release.Signature.Content})
+}
+
+func pushTestModules(t *testing.T, reg *testRegistry, prefix string) {
+	annotations := map[string]string{
+		ocispec.AnnotationRevision:    "0123456789abcdef",
+		ocispec.AnnotationDescription: "A test module",
+	}
+	reg.push(t, prefix+"testnamespace/testname/testprovider", "1.0.0", ModuleArtifactType, annotations,
+		testFile{title: "module.zip", mediaType: ModuleLayerMediaType, content: []byte("archive 1.0.0")})
+	reg.push(t, prefix+"testnamespace/testname/testprovider", "v2.0.0", "", nil,
+		testFile{title: "README.md", content: []byte("readme")},
+		testFile{title: "testname-2.0.0.zip", content: []byte("archive 2.0.0")})
+	reg.push(t, prefix+"testnamespace/testname/testprovider", "latest", ModuleArtifactType, nil,
+		testFile{title: "module.zip", mediaType: ModuleLayerMediaType, content: []byte("archive 2.0.0")})
+	reg.push(t, prefix+"testnamespace/testname/testprovider", "3.0.0", "application/vnd.example.other", nil,
+		testFile{title: "module.zip", mediaType: ModuleLayerMediaType, content: []byte("archive 3.0.0")})
+	reg.push(t, prefix+"testnamespace/other/testprovider", "0.1.0", ModuleArtifactType, nil,
+		testFile{title: "module.zip", mediaType: ModuleLayerMediaType, content: []byte("archive 0.1.0")})
+}
+
+func TestListModuleVersions(t *testing.T) {
+	is := is.New(t)
+	reg := newTestRegistry(t)
+	pushTestModules(t, reg, "")
+	store := newTestStore(t, reg)
+
+	versions, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(versions, []*core.ModuleVersion{
+		{Version: "1.0.0", SourceURL: "/download/module/testnamespace/testname/testprovider/1.0.0?archive=zip"},
+		{Version: "3.0.0", SourceURL: "/download/module/testnamespace/testname/testprovider/3.0.0?archive=zip"},
+		{Version: "2.0.0", SourceURL: "/download/module/testnamespace/testname/testprovider/2.0.0?archive=zip"},
+	})
+
+	_, err = store.ListModuleVersions(context.Background(), "testnamespace", "missing", "testprovider")
+	is.Equal(err.Error(), "module 'testnamespace/missing/testprovider' not found")
+	is.True(errors.Is(err, core.ErrNotFound))
+}
+
+func TestGetModuleVersion(t *testing.T) {
+	is := is.New(t)
+	reg := newTestRegistry(t)
+	pushTestModules(t, reg, "")
+	store := newTestStore(t, reg)
+
+	ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+	is.NoErr(err)
+	is.Equal(ver, &core.ModuleVersion{
+		Version:   "1.0.0",
+		SourceURL: "/download/module/testnamespace/testname/testprovider/1.0.0?archive=zip",
+		Metadata: &core.ModuleMetadata{
+			PublishedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+			CommitSHA:   "0123456789abcdef",
+			Description: "A test module",
+		},
+	})
+
+	// Tagged with a `v` prefix, and archive layer found by title
+	ver, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+	is.NoErr(err)
+	is.Equal(ver.Version, "2.0.0")
+	r, err := store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+	is.NoErr(err)
+	b, err := io.ReadAll(r)
+	r.Close()
+	is.NoErr(err)
+	is.Equal(string(b), "archive 2.0.0")
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "3.0.0")
+	is.Equal(err.Error(), "version '3.0.0' of module 'testnamespace/testname/testprovider' has artifact type 'application/vnd.example.other', expected 'application/vnd.terraform.module.v1'")
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "4.0.0")
+	is.Equal(err.Error(), "version '4.0.0' not found for module 'testnamespace/testname/testprovider'")
+	is.True(errors.Is(err, core.ErrNotFound))
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "latest")
+	is.Equal(err.Error(), "module version path 'testnamespace/testname/testprovider/latest' is not valid")
+}
+
+func TestBackendErrors(t *testing.T) {
+	is := is.New(t)
+	reg := &testRegistry{}
+	reg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"errors":[{"code":"DENIED","message":"requested access to the resource is denied"}]}`)
+	}))
+	defer reg.Close()
+	store := newTestStore(t, reg)
+
+	// The credentials of the store are rejected, which is not the fault of the client
+	_, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.True(errors.Is(err, core.ErrUnavailable))
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+	is.True(errors.Is(err, core.ErrUnavailable))
+}
+
+func TestListModules(t *testing.T) {
+	is := is.New(t)
+	reg := newTestRegistry(t)
+	pushTestModules(t, reg, "terraform/")
+	key, armoredKey := storetest.NewKey(t)
+	reg.push(t, "terraform/test/test", "1.0.0", ProviderArtifactType, nil, testProviderRelease(t, key, armoredKey, "1.0.0", "linux_amd64")...)
+	reg.push(t, "other/a/b/c", "1.0.0", ModuleArtifactType, nil, testFile{title: "module.zip", content: []byte("archive")})
+	store := newTestStore(t, reg)
+	store.SetRepositoryPrefix("/terraform/")
+	store.SetProviderRepositoryPrefix("terraform")
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "testnamespace", Name: "other", Provider: "testprovider"},
+		{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+	})
+
+	providers, err := store.ListProviders(context.Background())
+	is.NoErr(err)
+	is.Equal(providers, []core.ProviderAddress{{Namespace: "test", Name: "test"}})
+
+	ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "other", "testprovider", "0.1.0")
+	is.NoErr(err)
+	is.Equal(ver.Version, "0.1.0")
+}
+
+func TestProviders(t *testing.T) {
+	is := is.New(t)
+	reg := newTestRegistry(t)
+	key, armoredKey := storetest.NewKey(t)
+	otherKey, _ := storetest.NewKey(t)
+
+	reg.push(t, "test/test", "v1.0.0", ProviderArtifactType, nil, testProviderRelease(t, key, armoredKey, "1.0.0", "linux_amd64", "darwin_arm64")...)
+	reg.push(t, "test/test", "1.1.0", "", map[string]string{AnnotationProtocols: "5.0,6.0"}, testProviderRelease(t, key, armoredKey, "1.1.0", "linux_amd64")...)
+	reg.push(t, "test/test", "1.2.0", ProviderArtifactType, nil, append(testProviderRelease(t, key, armoredKey, "1.2.0", "linux_amd64"),
+		testFile{title: "terraform-provider-test_1.2.0_manifest.json", content: []byte(`{"version":1,"metadata":{"protocol_versions":["6.0"]}}`)},
+		// Not listed in the checksums
+		testFile{title: "terraform-provider-test_1.2.0_windows_amd64.zip", content: []byte("package")},
+	)...)
+	// Signed with a key other than the included one
+	reg.push(t, "test/test", "2.0.0", ProviderArtifactType, nil, testProviderRelease(t, otherKey, armoredKey, "2.0.0", "linux_amd64")...)
+	reg.push(t, "test/test", "latest", ProviderArtifactType, nil, testProviderRelease(t, key, armoredKey, "1.0.0", "linux_amd64")...)
+	store := newTestStore(t, reg)
+
+	t.Run("list versions", func(t *testing.T) {
+		is := is.New(t)
+
+		versions, err := store.ListProviderVersions(context.Background(), "test", "test")
+		is.NoErr(err)
+		is.Equal(versions.Versions, []core.ProviderVersion{
+			{Version: "1.1.0", Protocols: []string{"5.0", "6.0"}, Platforms: []core.Platform{{OS: "linux", Arch: "amd64"}}},
+			{Version: "1.2.0", Protocols: []string{"6.0"}, Platforms: []core.Platform{{OS: "linux", Arch: "amd64"}}},
+			{Version: "1.0.0", Protocols: []string{"5.0"}, Platforms: []core.Platform{{OS: "darwin", Arch: "arm64"}, {OS: "linux", Arch: "amd64"}}},
+		})
+
+		_, err = store.ListProviderVersions(context.Background(), "test", "missing")
+		is.Equal(err.Error(), "provider 'test/missing' not found")
+	})
+
+	t.Run("tags are resolved once", func(t *testing.T) {
+		is := is.New(t)
+		store := newTestStore(t, reg)
+
+		reg.tagRequests.Store(0)
+		_, err := store.ListProviderVersions(context.Background(), "test", "test")
+		is.NoErr(err)
+		is.Equal(reg.tagRequests.Load(), int64(4))
+
+		_, err = store.ListProviderVersions(context.Background(), "test", "test")
+		is.NoErr(err)
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "1.1.0", "linux", "amd64")
+		is.NoErr(err)
+		is.Equal(reg.tagRequests.Load(), int64(4))
+	})
+
+	t.Run("get version", func(t *testing.T) {
+		is := is.New(t)
+
+		provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "darwin", "arm64")
+		is.NoErr(err)
+		is.Equal(provider.Filename, "terraform-provider-test_1.0.0_darwin_arm64.zip")
+		is.Equal(provider.DownloadURL, "/download/provider/test/test/v1.0.0/asset/terraform-provider-test_1.0.0_darwin_arm64.zip")
+		is.Equal(provider.SHASumsURL, "/download/provider/test/test/v1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS")
+		is.Equal(provider.SHASumsSignatureURL, "/download/provider/test/test/v1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS.sig")
+		sum := sha256.Sum256([]byte("package terraform-provider-test_1.0.0_darwin_arm64.zip"))
+		is.Equal(provider.SHASum, hex.EncodeToString(sum[:]))
+		is.Equal(provider.SigningKeys.GPGPublicKeys, []core.GpgPublicKeys{{KeyID: key.PrimaryKey.KeyIdString(), ASCIIArmor: string(armoredKey)}})
+
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "1.2.0", "windows", "amd64")
+		is.Equal(err.Error(), "provider 'test/test/1.2.0' not found for windows_amd64")
+
+		// Releases without a valid signature are not served
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "2.0.0", "linux", "amd64")
+		is.Equal(err.Error(), "provider version '2.0.0' not found")
+		_, err = store.GetProviderVersion(context.Background(), "test", "test", "3.0.0", "linux", "amd64")
+		is.Equal(err.Error(), "provider version '3.0.0' not found")
+	})
+
+	t.Run("get asset", func(t *testing.T) {
+		is := is.New(t)
+
+		r, err := store.GetProviderAsset(context.Background(), "test", "test", "v1.0.0", "terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.NoErr(err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		is.NoErr(err)
+		is.Equal(string(b), "package terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.Equal(r.(core.AssetSizer).Size(), int64(len(b)))
+
+		_, err = store.GetProviderAsset(context.Background(), "test", "test", "v1.0.0", "gpg-public-key.pem")
+		is.Equal(err.Error(), "asset 'gpg-public-key.pem' not found for provider 'test/test/v1.0.0'")
+		_, err = store.GetProviderAsset(context.Background(), "test", "test", "1.2.0", "terraform-provider-test_1.2.0_windows_amd64.zip")
+		is.Equal(err.Error(), "asset 'terraform-provider-test_1.2.0_windows_amd64.zip' not found for provider 'test/test/1.2.0'")
+	})
+}
+
+func TestDownloadModes(t *testing.T) {
+	reg := newTestRegistry(t)
+	pushTestModules(t, reg, "")
+	key, armoredKey := storetest.NewKey(t)
+	reg.push(t, "test/test", "1.0.0", ProviderArtifactType, nil, testProviderRelease(t, key, armoredKey, "1.0.0", "linux_amd64")...)
+	store := newTestStore(t, reg)
+	store.SetDownloadMode(DownloadRedirect)
+
+	t.Run("registry serving blobs", func(t *testing.T) {
+		is := is.New(t)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "/download/module/testnamespace/testname/testprovider/1.0.0?archive=zip")
+
+		provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		is.Equal(provider.DownloadURL, "/download/provider/test/test/1.0.0/asset/terraform-provider-test_1.0.0_linux_amd64.zip")
+	})
+
+	t.Run("registry redirecting blobs", func(t *testing.T) {
+		is := is.New(t)
+		reg.redirect.Store(true)
+		defer reg.redirect.Store(false)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		archive := content.NewDescriptorFromBytes(ModuleLayerMediaType, []byte("archive 1.0.0"))
+		is.Equal(ver.SourceURL, reg.URL+"/storage/"+archive.Digest.String()+"?archive=zip&signature=test")
+
+		provider, err := store.GetProviderVersion(context.Background(), "test", "test", "1.0.0", "linux", "amd64")
+		is.NoErr(err)
+		resp, err := http.Get(provider.DownloadURL)
+		is.NoErr(err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+		is.Equal(string(b), "package terraform-provider-test_1.0.0_linux_amd64.zip")
+		is.True(strings.HasPrefix(provider.SHASumsURL, reg.URL+"/storage/sha256:"))
+	})
+}
@@ -0,0 +1,368 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// providerRelease is a verified provider release, or the reason it is not valid.
+type providerRelease struct {
+	version   string
+	protocols []string
+	platforms []core.Platform
+	// Package layers by `{os}_{arch}`.
+	packages map[string]ocispec.Descriptor
+	sums     ocispec.Descriptor
+	sig      ocispec.Descriptor
+	keys     []core.GpgPublicKeys
+	// Why the release is not valid, if it isn't.
+	invalid string
+}
+
+// layer returns the layer of the release file `name` served to clients.
+func (r *providerRelease) layer(name string) (ocispec.Descriptor, bool) {
+	for _, layer := range []ocispec.Descriptor{r.sums, r.sig} {
+		if layer.Annotations[ocispec.AnnotationTitle] == name {
+			return layer, true
+		}
+	}
+	for _, layer := range r.packages {
+		if layer.Annotations[ocispec.AnnotationTitle] == name {
+			return layer, true
+		}
+	}
+	return ocispec.Descriptor{}, false
+}
+
+// providerRepository returns the repository of the provider.
+func (s *OCIStore) providerRepository(ctx context.Context, namespace, name string) (*remote.Repository, error) {
+	_, prefix := s.repositoryPrefixes()
+	addr := path.Join(namespace, name)
+	if !providerAddressRegex.MatchString(addr) {
+		return nil, core.NotFoundError("provider address '%s' is not valid", addr)
+	}
+	return s.repository(ctx, prefix+addr)
+}
+
+// resolveTag returns the manifest digest of the provider release tag, which is cached for `tagDigestTTL`.
+func (s *OCIStore) resolveTag(ctx context.Context, repo *remote.Repository, tag string) (string, error) {
+	key := repo.Reference.Repository + ":" + tag
+	s.mut.RLock()
+	cached, ok := s.tagDigests[key]
+	s.mut.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.digest, nil
+	}
+
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return "", backendError(err)
+	}
+	s.mut.Lock()
+	s.tagDigests[key] = tagDigest{digest: desc.Digest.String(), expires: time.Now().Add(tagDigestTTL)}
+	s.mut.Unlock()
+	return desc.Digest.String(), nil
+}
+
+// providerRelease returns the release tagged `tag` in the repository of the provider.
+// Releases are cached by manifest digest, so their files are only downloaded once.
+func (s *OCIStore) providerRelease(ctx context.Context, repo *remote.Repository, namespace, name, tag string) (*providerRelease, error) {
+	digest, err := s.resolveTag(ctx, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mut.RLock()
+	release, ok := s.releaseCache[digest]
+	s.mut.RUnlock()
+	if ok {
+		return release, nil
+	}
+
+	_, manifest, err := fetchManifest(ctx, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	release = &providerRelease{
+		version:  strings.TrimPrefix(tag, "v"),
+		packages: make(map[string]ocispec.Descriptor),
+	}
+	if err := s.verifyProviderRelease(ctx, repo, release, name, manifest); err != nil {
+		var invalid storeutil.InvalidReleaseError
+		if !errors.As(err, &invalid) {
+			return nil, err
+		}
+		release.invalid = invalid.Reason
+		s.logger.Warn(fmt.Sprintf("not a valid release [%s/%s/%s] - %s", namespace, name, release.version, invalid.Reason))
+	}
+
+	s.mut.Lock()
+	s.releaseCache[digest] = release
+	s.mut.Unlock()
+
+	return release, nil
+}
+
+// verifyProviderRelease reads the checksums, signature and GPG public key of the release, verifies
+// the signature of the checksums, and that the digests of the package layers match the checksums.
+func (s *OCIStore) verifyProviderRelease(ctx context.Context, repo *remote.Repository, release *providerRelease, name string, manifest *ocispec.Manifest) error {
+	if manifest.ArtifactType != "" && manifest.ArtifactType != ProviderArtifactType {
+		return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("artifact type '%s', expected '%s'", manifest.ArtifactType, ProviderArtifactType)}
+	}
+
+	sumsFile := fmt.Sprintf("terraform-provider-%s_%s_SHA256SUMS", name, release.version)
+	manifestFile := fmt.Sprintf("terraform-provider-%s_%s_manifest.json", name, release.version)
+	layers := make(map[string]ocispec.Descriptor, len(manifest.Layers))
+	var keyLayer *ocispec.Descriptor
+	for _, layer := range manifest.Layers {
+		title := layer.Annotations[ocispec.AnnotationTitle]
+		layers[title] = layer
+		if strings.Contains(title, "gpg-public-key.pem") {
+			keyLayer = &layer
+		}
+	}
+	if keyLayer == nil {
+		return storeutil.InvalidReleaseError{Reason: "unable to get GPG Public Key"}
+	}
+	for _, file := range []string{sumsFile, sumsFile + ".sig"} {
+		if _, ok := layers[file]; !ok {
+			return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("could not find '%s'", file)}
+		}
+	}
+	release.sums = layers[sumsFile]
+	release.sig = layers[sumsFile+".sig"]
+
+	sums, err := fetchSmallBlob(ctx, repo, release.sums)
+	if err != nil {
+		return err
+	}
+	sig, err := fetchSmallBlob(ctx, repo, release.sig)
+	if err != nil {
+		return err
+	}
+	armor, err := fetchSmallBlob(ctx, repo, *keyLayer)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armor))
+	if err != nil || len(keyring) != 1 {
+		return storeutil.InvalidReleaseError{Reason: "unable to get GPG Public Key"}
+	}
+	if _, err := storeutil.CheckDetachedSignature(keyring, sums, sig); err != nil {
+		return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("SHA checksums signature not valid for GPG Public Key '%s': %s", keyring[0].PrimaryKey.KeyIdString(), err)}
+	}
+	release.keys = []core.GpgPublicKeys{{KeyID: keyring[0].PrimaryKey.KeyIdString(), ASCIIArmor: string(armor)}}
+
+	// Provider Protocol version should be set in the annotations or the manifest file. If not present,
+	// default is 5.0 according to Terraform docs.
+	// https://developer.hashicorp.com/terraform/registry/providers/publishing
+	release.protocols = []string{"5.0"}
+	if protocols := manifest.Annotations[AnnotationProtocols]; protocols != "" {
+		release.protocols = strings.Split(protocols, ",")
+	} else if layer, ok := layers[manifestFile]; ok {
+		b, err := fetchSmallBlob(ctx, repo, layer)
+		if err != nil {
+			return err
+		}
+		providerManifest := &core.ProviderManifest{}
+		if err := json.Unmarshal(b, providerManifest); err != nil {
+			return storeutil.InvalidReleaseError{Reason: fmt.Sprintf("unable to decode manifest: %s", err)}
+		}
+		release.protocols = providerManifest.Metadata.ProtocolVersions
+	}
+
+	checksums := storeutil.ParseSHASums(sums)
+	packagePrefix := fmt.Sprintf("terraform-provider-%s_%s_", name, release.version)
+	for title, layer := range layers {
+		osArch, ok := strings.CutPrefix(title, packagePrefix)
+		if !ok || !strings.HasSuffix(osArch, ".zip") {
+			continue
+		}
+		os, arch, ok := strings.Cut(strings.TrimSuffix(osArch, ".zip"), "_")
+		if !ok {
+			continue
+		}
+		// The layer digest is the SHA-256 checksum of the package
+		if checksums[title] == "" || "sha256:"+checksums[title] != layer.Digest.String() {
+			s.logger.Warn("provider package does not match the SHA checksums", zap.String("file", title))
+			continue
+		}
+		release.packages[os+"_"+arch] = layer
+		release.platforms = append(release.platforms, core.Platform{OS: os, Arch: arch})
+	}
+	sort.Slice(release.platforms, func(i, j int) bool {
+		return release.platforms[i].OS+"_"+release.platforms[i].Arch < release.platforms[j].OS+"_"+release.platforms[j].Arch
+	})
+	return nil
+}
+
+// fetchSmallBlob returns the verified contents of a small layer.
+func fetchSmallBlob(ctx context.Context, repo *remote.Repository, layer ocispec.Descriptor) ([]byte, error) {
+	if layer.Size > maxManifestSize {
+		return nil, storeutil.InvalidReleaseError{Reason: fmt.Sprintf("'%s' is larger than %d bytes", layer.Annotations[ocispec.AnnotationTitle], maxManifestSize)}
+	}
+	b, err := content.FetchAll(ctx, repo.Blobs(), layer)
+	return b, backendError(err)
+}
+
+func (s *OCIStore) ListProviderVersions(ctx context.Context, namespace string, name string) (*core.ProviderVersions, error) {
+	repo, err := s.providerRepository(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	tags, versions, err := s.versionTags(ctx, repo)
+	if isNotFound(err) {
+		return nil, core.NotFoundError("provider '%s/%s' not found", namespace, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &core.ProviderVersions{Versions: make([]core.ProviderVersion, 0, len(versions))}
+	for _, version := range versions {
+		release, err := s.providerRelease(ctx, repo, namespace, name, tags[version])
+		if err != nil {
+			return nil, err
+		}
+		if release.invalid != "" || len(release.platforms) == 0 {
+			continue
+		}
+		res.Versions = append(res.Versions, core.ProviderVersion{
+			Version:   release.version,
+			Protocols: release.protocols,
+			Platforms: release.platforms,
+		})
+	}
+	return res, nil
+}
+
+// findProviderRelease returns the repository and valid release of the provider version, and its tag.
+func (s *OCIStore) findProviderRelease(ctx context.Context, namespace, name, version string) (*remote.Repository, *providerRelease, string, error) {
+	if !isVersionTag(version) {
+		return nil, nil, "", core.NotFoundError("provider version '%s' not found", version)
+	}
+	repo, err := s.providerRepository(ctx, namespace, name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// Versions may be tagged with or without a `v` prefix
+	tag := strings.TrimPrefix(version, "v")
+	release, err := s.providerRelease(ctx, repo, namespace, name, tag)
+	if isNotFound(err) {
+		tag = "v" + tag
+		release, err = s.providerRelease(ctx, repo, namespace, name, tag)
+	}
+	if isNotFound(err) {
+		return nil, nil, "", core.NotFoundError("provider version '%s' not found", version)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if release.invalid != "" {
+		return nil, nil, "", core.NotFoundError("provider version '%s' not found", version)
+	}
+	return repo, release, tag, nil
+}
+
+func (s *OCIStore) GetProviderVersion(ctx context.Context, namespace string, name string, version string, os string, arch string) (*core.Provider, error) {
+	repo, release, tag, err := s.findProviderRelease(ctx, namespace, name, version)
+	if err != nil {
+		return nil, err
+	}
+	pkg, ok := release.packages[os+"_"+arch]
+	if !ok {
+		return nil, core.NotFoundError("provider '%s/%s/%s' not found for %s_%s", namespace, name, version, os, arch)
+	}
+
+	file := pkg.Annotations[ocispec.AnnotationTitle]
+	downloadURL, err := s.providerFileURL(ctx, repo, pkg, namespace, name, tag, file)
+	if err != nil {
+		return nil, err
+	}
+	sumsURL, err := s.providerFileURL(ctx, repo, release.sums, namespace, name, tag, release.sums.Annotations[ocispec.AnnotationTitle])
+	if err != nil {
+		return nil, err
+	}
+	sigURL, err := s.providerFileURL(ctx, repo, release.sig, namespace, name, tag, release.sig.Annotations[ocispec.AnnotationTitle])
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.Provider{
+		Protocols:           release.protocols,
+		OS:                  os,
+		Arch:                arch,
+		Filename:            file,
+		DownloadURL:         downloadURL,
+		SHASumsURL:          sumsURL,
+		SHASumsSignatureURL: sigURL,
+		SHASum:              pkg.Digest.Encoded(),
+		SigningKeys:         core.SigningKeys{GPGPublicKeys: release.keys},
+	}, nil
+}
+
+// GetProviderAsset returns the contents of a file of a verified provider release.
+func (s *OCIStore) GetProviderAsset(ctx context.Context, namespace string, name string, tag string, asset string) (io.ReadCloser, error) {
+	repo, release, _, err := s.findProviderRelease(ctx, namespace, name, tag)
+	if err != nil {
+		return nil, err
+	}
+	layer, ok := release.layer(asset)
+	if !ok {
+		return nil, core.NotFoundError("asset '%s' not found for provider '%s/%s/%s'", asset, namespace, name, tag)
+	}
+	return fetchBlob(ctx, repo, layer)
+}
+
+// ListProviders returns all provider repositories in the registry, sorted by address.
+// Requires the catalog API of the registry, which may be limited to administrators.
+func (s *OCIStore) ListProviders(ctx context.Context) ([]core.ProviderAddress, error) {
+	_, prefix := s.repositoryPrefixes()
+	providers := make([]core.ProviderAddress, 0)
+
+	err := s.registry.Repositories(ctx, "", func(repos []string) error {
+		for _, repo := range repos {
+			addr, ok := strings.CutPrefix(repo, prefix)
+			if !ok || !providerAddressRegex.MatchString(addr) {
+				continue
+			}
+			namespace, name, _ := strings.Cut(addr, "/")
+			providers = append(providers, core.ProviderAddress{Namespace: namespace, Name: name})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, backendError(err)
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].Namespace != providers[j].Namespace {
+			return providers[i].Namespace < providers[j].Namespace
+		}
+		return providers[i].Name < providers[j].Name
+	})
+	return providers, nil
+}
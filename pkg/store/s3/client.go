@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package s3
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DefaultRoleSessionName is the session name used when assuming roles.
+const DefaultRoleSessionName = "terraform-registry"
+
+// ClientOptions configures the S3 client created by `NewClient`.
+type ClientOptions struct {
+	// Region such as us-east-1.
+	Region string
+	// URL of an S3-compatible service, such as MinIO. Empty to use AWS.
+	Endpoint string
+	// Whether to use path-style addressing, with the bucket name in the path instead of the hostname.
+	PathStyle bool
+
+	// Named profile in the shared AWS config files. Defaults to the `AWS_PROFILE` environment variable.
+	Profile string
+	// ARN of a role to assume. Without `WebIdentityTokenFile`, the role is assumed with the
+	// credentials of the default credential chain.
+	RoleARN string
+	// Session name used when assuming `RoleARN`. Defaults to `DefaultRoleSessionName`.
+	RoleSessionName string
+	// External ID used when assuming `RoleARN`, as required by some cross-account roles.
+	ExternalID string
+	// Path of an OIDC token file, such as the service account token mounted by EKS (IRSA), which is
+	// exchanged for credentials of `RoleARN`. The default credential chain already picks up
+	// `AWS_WEB_IDENTITY_TOKEN_FILE` and `AWS_ROLE_ARN` from the environment.
+	WebIdentityTokenFile string
+}
+
+// LoadConfig returns the AWS config of the options. Credentials are resolved by the default
+// credential chain, i.e. environment variables, shared config files, web identity, ECS and EC2
+// instance roles, optionally followed by assuming `RoleARN`. Credentials are retrieved on first use.
+func LoadConfig(ctx context.Context, opts ClientOptions) (aws.Config, error) {
+	if opts.WebIdentityTokenFile != "" && opts.RoleARN == "" {
+		return aws.Config{}, errors.New("web identity token file requires a role ARN")
+	}
+	if opts.RoleSessionName == "" {
+		opts.RoleSessionName = DefaultRoleSessionName
+	}
+
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithRegion(opts.Region),
+	}
+	if opts.Profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(opts.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	switch {
+	case opts.WebIdentityTokenFile != "":
+		provider := stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(cfg), opts.RoleARN, stscreds.IdentityTokenFile(opts.WebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = opts.RoleSessionName
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	case opts.RoleARN != "":
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), opts.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = opts.RoleSessionName
+			if opts.ExternalID != "" {
+				o.ExternalID = aws.String(opts.ExternalID)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return cfg, nil
+}
+
+// NewClient returns an S3 client for `NewS3Store`, configured by `LoadConfig`.
+func NewClient(ctx context.Context, opts ClientOptions) (*s3.Client, error) {
+	cfg, err := LoadConfig(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+		o.UsePathStyle = opts.PathStyle
+	}), nil
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+)
+
+// DownloadMode selects how clients download module archives.
+type DownloadMode = storeutil.DownloadMode
+
+const (
+	// DownloadS3 returns `s3::` source URLs, downloaded by Terraform with the AWS credentials of the client.
+	DownloadS3 DownloadMode = "s3"
+	// DownloadPresigned returns short-lived presigned HTTPS URLs, so clients need no AWS credentials.
+	DownloadPresigned DownloadMode = "presigned"
+	// DownloadProxy returns URLs of the registry `/download/module/` route, which serves the archives
+	// from the bucket. The route is protected by the download token of the registry.
+	DownloadProxy = storeutil.DownloadProxy
+)
+
+// DefaultPresignTTL is how long presigned download URLs are valid.
+const DefaultPresignTTL = 15 * time.Minute
+
+// ParseDownloadMode returns the download mode named `s`.
+func ParseDownloadMode(s string) (DownloadMode, error) {
+	return storeutil.ParseDownloadMode(s, DownloadS3, DownloadPresigned, DownloadProxy)
+}
+
+// SetDownloadMode sets how clients download module archives. `ttl` is how long presigned URLs are
+// valid, and defaults to `DefaultPresignTTL` when zero.
+func (s *S3Store) SetDownloadMode(mode DownloadMode, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = DefaultPresignTTL
+	}
+
+	s.mut.Lock()
+	s.downloadMode = mode
+	s.presignTTL = ttl
+	s.mut.Unlock()
+}
+
+// archiveKey returns the key of the archive of the module version.
+func (s *S3Store) archiveKey(address, version string) string {
+	return s.keyPrefix() + path.Join(address, version, version+".zip")
+}
+
+// withDownloadURL returns the module version with the source URL of the download mode.
+// The versions are cached with `s3::` source URLs, which are replaced on every lookup,
+// as presigned URLs expire.
+func (s *S3Store) withDownloadURL(ctx context.Context, address string, ver *core.ModuleVersion) (*core.ModuleVersion, error) {
+	s.mut.RLock()
+	mode := s.downloadMode
+	ttl := s.presignTTL
+	s.mut.RUnlock()
+
+	switch mode {
+	case DownloadPresigned:
+		if s.presigner == nil {
+			return nil, errors.New("unable to presign download URL: store has no presigner")
+		}
+		req, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.archiveKey(address, ver.Version)),
+		}, s3.WithPresignExpires(ttl))
+		if err != nil {
+			return nil, fmt.Errorf("unable to presign download URL: %w", err)
+		}
+		return storeutil.WithSourceURL(ver, req.URL), nil
+	case DownloadProxy:
+		return storeutil.WithSourceURL(ver, storeutil.ModuleProxyURL(address, ver.Version, "zip")), nil
+	}
+	return ver, nil
+}
+
+// GetModuleArchive returns the zip archive of the module version, for serving through the registry.
+func (s *S3Store) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	if _, err := s.GetModuleVersion(ctx, namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.archiveKey(path.Join(namespace, name, provider), version)),
+	})
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return out.Body, nil
+}
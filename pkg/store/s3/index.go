@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+)
+
+// ErrIndexDisabled is returned when reloading the index while it is not enabled.
+var ErrIndexDisabled = errors.New("index is not enabled")
+
+// SetIndexEnabled enables or disables serving lookups from an in-memory index of the bucket,
+// instead of listing the bucket on every request. The index is built by `ReloadCache`, which
+// should be called on regular intervals to pick up new versions. Lookups fall back to listing
+// the bucket until the index is built.
+func (s *S3Store) SetIndexEnabled(enabled bool) {
+	s.mut.Lock()
+	s.indexEnabled = enabled
+	if !enabled {
+		s.index = nil
+		s.indexedAt = time.Time{}
+	}
+	s.mut.Unlock()
+}
+
+// getIndex returns the index, and whether it has been built.
+func (s *S3Store) getIndex() (map[string][]*core.ModuleVersion, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.index, s.index != nil
+}
+
+// ReloadCache lists all modules in the bucket and replaces the index. Returns `ErrIndexDisabled`
+// unless the index is enabled with `SetIndexEnabled`.
+func (s *S3Store) ReloadCache(ctx context.Context) error {
+	s.mut.RLock()
+	enabled := s.indexEnabled
+	prefix := s.prefix
+	s.mut.RUnlock()
+	if !enabled {
+		return ErrIndexDisabled
+	}
+
+	index := make(map[string][]*core.ModuleVersion)
+	seen := make(map[string]bool)
+	err := s.listObjects(ctx, prefix, func(o types.Object) {
+		key := strings.TrimPrefix(aws.ToString(o.Key), prefix)
+		if !storeutil.IsValidModulePath(key) {
+			return
+		}
+		seen[prefix+key] = true
+		addr := moduleAddress(key)
+		index[addr] = append(index[addr], s.moduleVersion(ctx, prefix, key, o.ETag, o.LastModified))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if !s.indexEnabled {
+		return ErrIndexDisabled
+	}
+	s.index = index
+	s.indexedAt = time.Now().UTC()
+
+	// Forget the documentation of archives that were removed or replaced
+	for cacheKey := range s.docCache {
+		key, _, _ := strings.Cut(cacheKey, "@")
+		if !seen[key] {
+			delete(s.docCache, cacheKey)
+		}
+	}
+
+	return nil
+}
+
+// ReloadModule lists the versions of a single module and updates the index, e.g. right after
+// a new version is uploaded. Returns `ErrIndexDisabled` unless the index is enabled with
+// `SetIndexEnabled`.
+func (s *S3Store) ReloadModule(ctx context.Context, namespace, name, provider string) error {
+	s.mut.RLock()
+	enabled := s.indexEnabled
+	s.mut.RUnlock()
+	if !enabled {
+		return ErrIndexDisabled
+	}
+
+	addr := path.Join(namespace, name, provider)
+	vers, err := s.fetchModuleVersions(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.index == nil {
+		// Built by the next full reload
+		return nil
+	}
+
+	// The index is replaced rather than modified, as it is read without holding the lock.
+	index := make(map[string][]*core.ModuleVersion, len(s.index))
+	for k, v := range s.index {
+		index[k] = v
+	}
+	if len(vers) > 0 {
+		index[addr] = vers
+	} else {
+		delete(index, addr)
+	}
+	s.index = index
+
+	return nil
+}
+
+// moduleAddress returns the address of the module archive at `key`, relative to the key prefix.
+func moduleAddress(key string) string {
+	parts := strings.SplitN(key, "/", 4)
+	return path.Join(parts[0], parts[1], parts[2])
+}
+
+// CacheStats describes the contents of the index.
+type CacheStats struct {
+	Indexed        bool      `json:"indexed"`
+	Modules        int       `json:"modules"`
+	ModuleVersions int       `json:"module_versions"`
+	ModuleDocs     int       `json:"module_docs"`
+	IndexedAt      time.Time `json:"indexed_at,omitzero"`
+}
+
+// CacheStats returns the number of modules in the index, and when it was last fully reloaded.
+func (s *S3Store) CacheStats() any {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	stats := CacheStats{
+		Indexed:    s.index != nil,
+		Modules:    len(s.index),
+		ModuleDocs: len(s.docCache),
+		IndexedAt:  s.indexedAt,
+	}
+	for _, vers := range s.index {
+		stats.ModuleVersions += len(vers)
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package s3
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/moduledoc"
+	"go.uber.org/zap"
+)
+
+// SetModuleDocs enables or disables extracting the README, inputs, outputs and required providers
+// of module versions from their archives. Each archive is only downloaded once, unless it is replaced.
+func (s *S3Store) SetModuleDocs(enabled bool) {
+	s.mut.Lock()
+	s.moduleDocs = enabled
+	s.mut.Unlock()
+}
+
+// moduleMetadata returns the metadata of the module archive at `key`. The documentation is only
+// included when enabled with `SetModuleDocs`, and omitted if the archive can't be read.
+func (s *S3Store) moduleMetadata(ctx context.Context, key string, etag *string, lastModified *time.Time) *core.ModuleMetadata {
+	meta := &core.ModuleMetadata{}
+	cacheKey := key + "@" + aws.ToString(etag)
+
+	s.mut.RLock()
+	enabled := s.moduleDocs
+	doc, ok := s.docCache[cacheKey]
+	s.mut.RUnlock()
+
+	if enabled {
+		if !ok {
+			var err error
+			doc, err = s.moduleDoc(ctx, key)
+			if err != nil {
+				s.logger.Warn("unable to extract module documentation",
+					zap.String("key", key),
+					zap.Error(err),
+				)
+			} else {
+				s.mut.Lock()
+				s.docCache[cacheKey] = doc
+				s.mut.Unlock()
+			}
+		}
+		if doc != nil {
+			*meta = *doc
+		}
+	}
+
+	meta.PublishedAt = aws.ToTime(lastModified).UTC()
+	if meta.PublishedAt.IsZero() && meta.Readme == "" && meta.Inputs == nil && meta.Outputs == nil && meta.RequiredProviders == nil {
+		return nil
+	}
+	return meta
+}
+
+// moduleDoc downloads the module archive at `key` and extracts its documentation.
+func (s *S3Store) moduleDoc(ctx context.Context, key string) (*core.ModuleMetadata, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	// Zip archives are read from the end, so the archive is buffered in a temporary file
+	f, err := os.CreateTemp("", "module-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, out.Body)
+	if err != nil {
+		return nil, err
+	}
+	return moduledoc.FromZip(f, size)
+}
@@ -7,23 +7,32 @@ package s3
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"path"
-	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
 	"go.uber.org/zap"
 )
 
-// S3API defines the subset of S3 client methods used by S3Store
+// S3API defines the subset of S3 client methods used by S3Store. It is implemented by `*s3.Client`.
 type S3API interface {
-	ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
-	HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+	ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// S3Presigner defines the S3 presign client methods used by S3Store. It is implemented by `*s3.PresignClient`.
+type S3Presigner interface {
+	PresignGetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 }
 
 // S3StoreInterface defines the interface for S3Store
@@ -34,83 +43,232 @@ type S3StoreInterface interface {
 
 // S3Store implements S3StoreInterface
 type S3Store struct {
-	client s3iface.S3API
-	cache  map[string][]*core.ModuleVersion
-	region string
-	bucket string
-	logger *zap.Logger
-	mut    sync.Mutex
+	client S3API
+	// Signs download URLs with `DownloadPresigned`. Only set for `*s3.Client`.
+	presigner S3Presigner
+	cache     map[string][]*core.ModuleVersion
+	region    string
+	bucket    string
+	// Prefix of the module keys in the bucket. Either empty or ending with a slash.
+	prefix string
+	// Base URL of the module source URLs, without a trailing slash. Defaults to the AWS bucket URL.
+	sourceURLBase string
+	logger        *zap.Logger
+	mut           sync.RWMutex
+
+	// Whether lookups are served from an index of the bucket, built by `ReloadCache`.
+	indexEnabled bool
+	// Modules by address, or nil until the index is built.
+	index     map[string][]*core.ModuleVersion
+	indexedAt time.Time
+
+	// How clients download module archives. Defaults to `DownloadS3`.
+	downloadMode DownloadMode
+	presignTTL   time.Duration
+
+	// Whether documentation is extracted from module archives.
+	moduleDocs bool
+	// Documentation extracted from module archives by object key and ETag.
+	docCache map[string]*core.ModuleMetadata
 }
 
-func NewS3Store(client s3iface.S3API, region string, bucket string, logger *zap.Logger) *S3Store {
+func NewS3Store(client S3API, region string, bucket string, logger *zap.Logger) *S3Store {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
+	var presigner S3Presigner
+	if c, ok := client.(*s3.Client); ok {
+		presigner = s3.NewPresignClient(c)
+	}
+
 	return &S3Store{
-		client: client,
-		cache:  make(map[string][]*core.ModuleVersion),
-		region: region,
-		bucket: bucket,
-		logger: logger,
+		client:    client,
+		presigner: presigner,
+		cache:     make(map[string][]*core.ModuleVersion),
+		docCache:  make(map[string]*core.ModuleMetadata),
+		region:    region,
+		bucket:    bucket,
+		logger:    logger,
+	}
+}
+
+// SetKeyPrefix sets the prefix of the module keys in the bucket, for buckets shared with other content.
+// With the prefix `terraform/modules`, module archives are expected at
+// `terraform/modules/{namespace}/{name}/{provider}/{version}/{version}.zip`.
+func (s *S3Store) SetKeyPrefix(prefix string) {
+	s.mut.Lock()
+	s.prefix = storeutil.NormalizePrefix(prefix)
+	s.mut.Unlock()
+}
+
+// SetSourceURLBase sets the base URL of the module source URLs returned to Terraform, which is
+// followed by the object key. Use this for S3-compatible services like MinIO, e.g. with
+// `https://minio.example.com/bucket`, see `EndpointSourceURLBase`. Defaults to the virtual-hosted
+// style URL of the bucket on AWS, i.e. `https://{bucket}.s3.{region}.amazonaws.com`.
+func (s *S3Store) SetSourceURLBase(base string) {
+	s.mut.Lock()
+	s.sourceURLBase = strings.TrimSuffix(base, "/")
+	s.mut.Unlock()
+}
+
+// sourceURL returns the module source URL of the object at `key`.
+func (s *S3Store) sourceURL(key string) string {
+	s.mut.RLock()
+	base := s.sourceURLBase
+	s.mut.RUnlock()
+
+	if base == "" {
+		base = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.bucket, s.region)
 	}
+	return fmt.Sprintf("s3::%s/%s", base, key)
+}
+
+// EndpointSourceURLBase returns the base URL of the objects in `bucket` on a custom S3 endpoint,
+// like `https://minio.example.com`, for use with `SetSourceURLBase`. The bucket is always part of
+// the path, as Terraform only reads the bucket from the hostname of AWS URLs, regardless of the
+// addressing style used by the registry.
+func EndpointSourceURLBase(endpoint, bucket string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("endpoint '%s' is not an absolute URL", endpoint)
+	}
+
+	u.Path = path.Join("/", u.Path, bucket)
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+func (s *S3Store) keyPrefix() string {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.prefix
 }
 
 func (s *S3Store) ListModuleVersions(ctx context.Context, namespace, name, system string) ([]*core.ModuleVersion, error) {
-	addr := filepath.Join(namespace, name, system)
+	addr := path.Join(namespace, name, system)
+	if index, ok := s.getIndex(); ok {
+		vers := index[addr]
+		if len(vers) == 0 {
+			return nil, core.NotFoundError("module '%s' not found", addr)
+		}
+		return vers, nil
+	}
+
 	vers, err := s.fetchModuleVersions(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
+	if len(vers) == 0 {
+		return nil, core.NotFoundError("module '%s' not found", addr)
+	}
 
 	return vers, nil
 }
 
 func (s *S3Store) fetchModuleVersions(ctx context.Context, address string) ([]*core.ModuleVersion, error) {
+	prefix := s.keyPrefix()
+
+	vers := make([]*core.ModuleVersion, 0)
+	err := s.listObjects(ctx, prefix+address+"/", func(o types.Object) {
+		key := strings.TrimPrefix(aws.ToString(o.Key), prefix)
+		if storeutil.IsValidModulePath(key) {
+			vers = append(vers, s.moduleVersion(ctx, prefix, key, o.ETag, o.LastModified))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.cache[address] = vers
+	s.mut.Unlock()
+
+	return vers, nil
+}
+
+// moduleVersion returns the module version of the archive at `key`, relative to the key prefix.
+func (s *S3Store) moduleVersion(ctx context.Context, prefix, key string, etag *string, lastModified *time.Time) *core.ModuleVersion {
+	return &core.ModuleVersion{
+		Version:   strings.Split(key, "/")[3],
+		SourceURL: s.sourceURL(prefix + key),
+		Metadata:  s.moduleMetadata(ctx, prefix+key, etag, lastModified),
+	}
+}
 
-	p := address + "/"
+// listObjects calls `fn` with each object in the bucket with a key starting with `prefix`.
+func (s *S3Store) listObjects(ctx context.Context, prefix string, fn func(o types.Object)) error {
 	in := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(p),
 	}
-	out, err := s.client.ListObjectsV2WithContext(ctx, in)
-	if err != nil {
-		return nil, err
+	if prefix != "" {
+		in.Prefix = aws.String(prefix)
 	}
-
-	vers := make([]*core.ModuleVersion, 0)
-	for _, o := range out.Contents {
-		path := o.Key
-		if isValidModuleSourcePath(*path) {
-			vers = append(vers, &core.ModuleVersion{
-				Version:   strings.Split(*path, "/")[3],
-				SourceURL: fmt.Sprintf("s3::https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, *path),
-			})
+	pages := s3.NewListObjectsV2Paginator(s.client, in)
+	for pages.HasMorePages() {
+		out, err := pages.NextPage(ctx)
+		if err != nil {
+			return backendError(err)
+		}
+		for _, o := range out.Contents {
+			fn(o)
 		}
 	}
+	return nil
+}
 
-	s.cache[address] = vers
+// ListModules returns all modules in the bucket, sorted by address.
+func (s *S3Store) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	if index, ok := s.getIndex(); ok {
+		modules := make([]core.ModuleAddress, 0, len(index))
+		for addr := range index {
+			parts := strings.Split(addr, "/")
+			modules = append(modules, core.ModuleAddress{Namespace: parts[0], Name: parts[1], Provider: parts[2]})
+		}
+		sort.Slice(modules, func(i, j int) bool {
+			return path.Join(modules[i].Namespace, modules[i].Name, modules[i].Provider) < path.Join(modules[j].Namespace, modules[j].Name, modules[j].Provider)
+		})
+		return modules, nil
+	}
 
-	return vers, nil
+	prefix := s.keyPrefix()
+	modules := storeutil.NewModuleLister(prefix)
+	err := s.listObjects(ctx, prefix, func(o types.Object) {
+		modules.Add(aws.ToString(o.Key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modules.Modules(), nil
 }
 
 func (s *S3Store) GetModuleVersion(ctx context.Context, namespace, name, system, version string) (*core.ModuleVersion, error) {
-	addr := filepath.Join(namespace, name, system)
+	addr := path.Join(namespace, name, system)
+	if index, ok := s.getIndex(); ok {
+		for _, v := range index[addr] {
+			if v.Version == version {
+				return s.withDownloadURL(ctx, addr, v)
+			}
+		}
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, addr)
+	}
+
 	ver, err := s.fetchModuleVersion(ctx, addr, version)
 	if err != nil {
 		return nil, err
 	}
 
-	return ver, nil
+	return s.withDownloadURL(ctx, addr, ver)
 }
 
 func (s *S3Store) fetchModuleVersion(ctx context.Context, address, version string) (*core.ModuleVersion, error) {
-	s.mut.Lock()
-	defer s.mut.Unlock()
-
+	s.mut.RLock()
 	vers := s.cache[address]
+	prefix := s.prefix
+	s.mut.RUnlock()
+
 	for _, o := range vers {
 		if o.Version == version {
 			return o, nil
@@ -119,32 +277,34 @@ func (s *S3Store) fetchModuleVersion(ctx context.Context, address, version strin
 
 	path := path.Join(address, version)
 	keySuffix := version + ".zip"
-	if !isValidModuleSourcePath(path) {
+	if !storeutil.IsValidModulePath(path) {
 		s.logger.Warn("invalid module path requested: " + path)
-		return nil, fmt.Errorf("module version path '%s' is not valid", path)
+		return nil, core.NotFoundError("module version path '%s' is not valid", path)
 	}
-	_, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(path + "/" + keySuffix),
+		Key:    aws.String(prefix + path + "/" + keySuffix),
 	})
 	if err != nil {
-		return nil, err
+		return nil, backendError(err)
 	}
 
-	ver := &core.ModuleVersion{
-		Version:   version,
-		SourceURL: fmt.Sprintf("s3::https://%s.s3.%s.amazonaws.com/%s/%s", s.bucket, s.region, path, keySuffix),
-	}
+	ver := s.moduleVersion(ctx, prefix, path+"/"+keySuffix, head.ETag, head.LastModified)
 
-	s.cache[address] = append(vers, ver)
+	s.mut.Lock()
+	s.cache[address] = append(s.cache[address], ver)
+	s.mut.Unlock()
 
 	return ver, nil
 }
 
-func isValidModuleSourcePath(path string) bool {
-	// https://semver.org/#is-there-a-suggested-regular-expression-regex-to-check-a-semver-string
-	verRegExp := `(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?`
-	addrRegExp := `\w+/\w+/\w+`
-	r := regexp.MustCompile("^" + addrRegExp + "/" + verRegExp)
-	return r.MatchString(path)
+// responseError is an error of the S3 API with the status code of the response.
+type responseError interface {
+	error
+	HTTPStatusCode() int
+}
+
+// backendError classifies an error returned by the S3 API, by the status code of the response.
+func backendError(err error) error {
+	return storeutil.BackendError(err, responseError.HTTPStatusCode)
 }
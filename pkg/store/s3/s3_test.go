@@ -5,14 +5,26 @@
 package s3
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
@@ -20,27 +32,32 @@ import (
 // MockS3API is a mock implementation for the S3API interface
 type MockS3API struct {
 	mock.Mock
-	s3iface.S3API
+	S3API
 }
 
-func (m *MockS3API) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+func (m *MockS3API) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
 	args := m.Called(ctx, input)
 	return args.Get(0).(*s3.ListObjectsV2Output), args.Error(1)
 }
 
-func (m *MockS3API) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+func (m *MockS3API) HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
 	args := m.Called(ctx, input)
 	return args.Get(0).(*s3.HeadObjectOutput), args.Error(1)
 }
 
+func (m *MockS3API) GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+}
+
 func TestListModuleVersions(t *testing.T) {
 	is := is.New(t)
 	mockS3 := new(MockS3API)
 
 	store := NewS3Store(mockS3, "us-east-1", "mytestbucket", zap.NewNop())
 
-	mockS3.On("ListObjectsV2WithContext", mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{
-		Contents: []*s3.Object{
+	mockS3.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
 			{Key: aws.String("testnamespace/testname/testprovider/1.0.0/1.0.0.zip")},
 			{Key: aws.String("testnamespace/testname/testprovider/1.1.1/1.1.1.zip")},
 		},
@@ -62,7 +79,7 @@ func TestGetModuleVersion(t *testing.T) {
 
 	t.Run("returns matching version", func(t *testing.T) {
 		is := is.New(t)
-		mockS3.On("HeadObjectWithContext", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+		mockS3.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
 		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
 		is.True(err == nil)
 		is.Equal(ver.Version, "1.0.0")
@@ -75,5 +92,499 @@ func TestGetModuleVersion(t *testing.T) {
 		is.True(err != nil)
 		is.True(ver == nil)
 		is.Equal(err.Error(), "module version path 'test-owner/test-repo/generic/1.0.0' is not valid")
+		is.True(errors.Is(err, core.ErrNotFound))
+	})
+}
+
+func TestModuleDocs(t *testing.T) {
+	is := is.New(t)
+	mockS3 := new(MockS3API)
+
+	store := NewS3Store(mockS3, "us-east-1", "mytestbucket", zap.NewNop())
+	store.SetModuleDocs(true)
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("README.md")
+	is.NoErr(err)
+	w.Write([]byte("# Test module"))
+	w, err = zw.Create("main.tf")
+	is.NoErr(err)
+	w.Write([]byte(`output "id" {}`))
+	is.NoErr(zw.Close())
+
+	published := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockS3.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("testnamespace/testname/testprovider/1.0.0/1.0.0.zip"), ETag: aws.String(`"abc"`), LastModified: aws.Time(published)},
+		},
+	}, nil)
+	mockS3.On("GetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("mytestbucket"),
+		Key:    aws.String("testnamespace/testname/testprovider/1.0.0/1.0.0.zip"),
+	}).Return(&s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(archive.Bytes()))}, nil).Once()
+
+	for range 2 {
+		vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+		is.NoErr(err)
+		is.Equal(len(vers), 1)
+		is.Equal(vers[0].Metadata.PublishedAt, published)
+		is.Equal(vers[0].Metadata.Readme, "# Test module")
+		is.Equal(vers[0].Metadata.Outputs, []core.ModuleOutput{{Name: "id"}})
+	}
+
+	// The archive is only downloaded once
+	mockS3.AssertExpectations(t)
+	mockS3.AssertNumberOfCalls(t, "GetObject", 1)
+}
+
+func TestListModules(t *testing.T) {
+	is := is.New(t)
+	mockS3 := new(MockS3API)
+
+	store := NewS3Store(mockS3, "us-east-1", "mytestbucket", zap.NewNop())
+
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket")}).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("testnamespace/testname/testprovider/1.0.0/1.0.0.zip")},
+			{Key: aws.String("testnamespace/testname/testprovider/1.1.1/1.1.1.zip")},
+		},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), ContinuationToken: aws.String("next")}).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("testnamespace/testname2/testprovider/1.0.0/1.0.0.zip")},
+			{Key: aws.String("not-a-module.txt")},
+		},
+	}, nil).Once()
+
+	modules, err := store.ListModules(context.Background())
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+		{Namespace: "testnamespace", Name: "testname2", Provider: "testprovider"},
+	})
+	mockS3.AssertExpectations(t)
+}
+
+func TestPagination(t *testing.T) {
+	is := is.New(t)
+	mockS3 := new(MockS3API)
+
+	store := NewS3Store(mockS3, "us-east-1", "mytestbucket", zap.NewNop())
+
+	prefix := aws.String("testnamespace/testname/testprovider/")
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), Prefix: prefix}).Return(&s3.ListObjectsV2Output{
+		Contents:              []types.Object{{Key: aws.String("testnamespace/testname/testprovider/1.0.0/1.0.0.zip")}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), Prefix: prefix, ContinuationToken: aws.String("next")}).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{{Key: aws.String("testnamespace/testname/testprovider/2.0.0/2.0.0.zip")}},
+	}, nil).Once()
+
+	vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(len(vers), 2)
+	is.Equal(vers[0].Version, "1.0.0")
+	is.Equal(vers[1].Version, "2.0.0")
+	mockS3.AssertExpectations(t)
+}
+
+func TestKeyPrefix(t *testing.T) {
+	mockS3 := new(MockS3API)
+
+	store := NewS3Store(mockS3, "us-east-1", "mytestbucket", zap.NewNop())
+	store.SetKeyPrefix("/terraform/modules/")
+
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), Prefix: aws.String("terraform/modules/testnamespace/testname/testprovider/")}).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{{Key: aws.String("terraform/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")}},
+	}, nil)
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), Prefix: aws.String("terraform/modules/")}).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{{Key: aws.String("terraform/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")}},
+	}, nil)
+	mockS3.On("HeadObject", mock.Anything, &s3.HeadObjectInput{Bucket: aws.String("mytestbucket"), Key: aws.String("terraform/modules/testnamespace/testname/testprovider/2.0.0/2.0.0.zip")}).Return(&s3.HeadObjectOutput{}, nil)
+
+	t.Run("lists versions", func(t *testing.T) {
+		is := is.New(t)
+		vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+		is.NoErr(err)
+		is.Equal(len(vers), 1)
+		is.Equal(vers[0].Version, "1.0.0")
+		is.Equal(vers[0].SourceURL, "s3::https://mytestbucket.s3.us-east-1.amazonaws.com/terraform/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")
+	})
+
+	t.Run("gets version", func(t *testing.T) {
+		is := is.New(t)
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "s3::https://mytestbucket.s3.us-east-1.amazonaws.com/terraform/modules/testnamespace/testname/testprovider/2.0.0/2.0.0.zip")
+	})
+
+	t.Run("lists modules", func(t *testing.T) {
+		is := is.New(t)
+		modules, err := store.ListModules(context.Background())
+		is.NoErr(err)
+		is.Equal(modules, []core.ModuleAddress{{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"}})
+	})
+}
+
+func TestIndex(t *testing.T) {
+	mockS3 := new(MockS3API)
+
+	store := NewS3Store(mockS3, "us-east-1", "mytestbucket", zap.NewNop())
+
+	mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket")}).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("testnamespace/testname/testprovider/1.0.0/1.0.0.zip")},
+			{Key: aws.String("testnamespace/testname/testprovider/1.1.1/1.1.1.zip")},
+			{Key: aws.String("othernamespace/othername/testprovider/0.1.0/0.1.0.zip")},
+			{Key: aws.String("not-a-module.txt")},
+		},
+	}, nil).Once()
+
+	t.Run("requires index to be enabled", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(store.ReloadCache(context.Background()), ErrIndexDisabled)
+		is.Equal(store.ReloadModule(context.Background(), "testnamespace", "testname", "testprovider"), ErrIndexDisabled)
+	})
+
+	store.SetIndexEnabled(true)
+
+	t.Run("builds index", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(store.ReloadCache(context.Background()))
+
+		stats := store.CacheStats().(CacheStats)
+		is.True(stats.Indexed)
+		is.Equal(stats.Modules, 2)
+		is.Equal(stats.ModuleVersions, 3)
+		is.True(!stats.IndexedAt.IsZero())
+	})
+
+	t.Run("serves lookups from index", func(t *testing.T) {
+		is := is.New(t)
+		vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+		is.NoErr(err)
+		is.Equal(len(vers), 2)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.1")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "s3::https://mytestbucket.s3.us-east-1.amazonaws.com/testnamespace/testname/testprovider/1.1.1/1.1.1.zip")
+
+		_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+		is.Equal(err.Error(), "version '2.0.0' not found for module 'testnamespace/testname/testprovider'")
+
+		_, err = store.ListModuleVersions(context.Background(), "unknown", "unknown", "unknown")
+		is.Equal(err.Error(), "module 'unknown/unknown/unknown' not found")
+		is.True(errors.Is(err, core.ErrNotFound))
+
+		modules, err := store.ListModules(context.Background())
+		is.NoErr(err)
+		is.Equal(modules, []core.ModuleAddress{
+			{Namespace: "othernamespace", Name: "othername", Provider: "testprovider"},
+			{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+		})
+
+		// The bucket was only listed when building the index
+		mockS3.AssertNumberOfCalls(t, "ListObjectsV2", 1)
+		mockS3.AssertNotCalled(t, "HeadObject", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reloads single module", func(t *testing.T) {
+		is := is.New(t)
+		mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), Prefix: aws.String("testnamespace/testname/testprovider/")}).Return(&s3.ListObjectsV2Output{
+			Contents: []types.Object{{Key: aws.String("testnamespace/testname/testprovider/2.0.0/2.0.0.zip")}},
+		}, nil).Once()
+		mockS3.On("ListObjectsV2", mock.Anything, &s3.ListObjectsV2Input{Bucket: aws.String("mytestbucket"), Prefix: aws.String("othernamespace/othername/testprovider/")}).Return(&s3.ListObjectsV2Output{}, nil).Once()
+
+		is.NoErr(store.ReloadModule(context.Background(), "testnamespace", "testname", "testprovider"))
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+		is.NoErr(err)
+		is.Equal(ver.Version, "2.0.0")
+
+		// Removed modules are dropped from the index
+		is.NoErr(store.ReloadModule(context.Background(), "othernamespace", "othername", "testprovider"))
+		modules, err := store.ListModules(context.Background())
+		is.NoErr(err)
+		is.Equal(len(modules), 1)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("disabling drops index", func(t *testing.T) {
+		is := is.New(t)
+		store.SetIndexEnabled(false)
+		stats := store.CacheStats().(CacheStats)
+		is.True(!stats.Indexed)
+		is.Equal(stats.Modules, 0)
+	})
+}
+
+// fakeS3Server is a minimal S3-compatible server for the path-style bucket `bucket`,
+// supporting object listings and HEAD requests for `keys`.
+func fakeS3Server(t *testing.T, bucket string, keys []string) *httptest.Server {
+	type object struct {
+		Key          string
+		ETag         string
+		LastModified time.Time
+	}
+	type listBucketResult struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		IsTruncated bool
+		Contents    []object
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		objectKey, ok := strings.CutPrefix(r.URL.Path, "/"+bucket)
+		if !ok {
+			t.Errorf("unexpected request path '%s'", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		objectKey = strings.TrimPrefix(objectKey, "/")
+
+		switch {
+		case r.Method == http.MethodGet && objectKey == "" && r.URL.Query().Get("list-type") == "2":
+			res := listBucketResult{Name: bucket}
+			for _, key := range keys {
+				if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+					res.Contents = append(res.Contents, object{Key: key, ETag: `"etag"`, LastModified: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)})
+				}
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			xml.NewEncoder(w).Encode(res)
+		case r.Method == http.MethodHead:
+			for _, key := range keys {
+				if key == objectKey {
+					w.Header().Set("ETag", `"etag"`)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			for _, key := range keys {
+				if key == objectKey {
+					w.Header().Set("ETag", `"etag"`)
+					io.WriteString(w, "archive "+key)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+}
+
+// fakeS3Client returns a client for the path-style fake S3 server `srv`, with static credentials.
+func fakeS3Client(t *testing.T, srv *httptest.Server) *s3.Client {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	client, err := NewClient(context.Background(), ClientOptions{
+		Region:    "us-east-1",
+		Endpoint:  srv.URL,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestCustomEndpoint(t *testing.T) {
+	is := is.New(t)
+	srv := fakeS3Server(t, "modules", []string{
+		"testnamespace/testname/testprovider/1.0.0/1.0.0.zip",
+		"testnamespace/testname/testprovider/1.1.0/1.1.0.zip",
+	})
+	defer srv.Close()
+
+	client := fakeS3Client(t, srv)
+
+	store := NewS3Store(client, "us-east-1", "modules", zap.NewNop())
+	store.SetSourceURLBase("https://minio.example.com/modules/")
+
+	vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(len(vers), 2)
+	is.Equal(vers[0].SourceURL, "s3::https://minio.example.com/modules/testnamespace/testname/testprovider/1.0.0/1.0.0.zip")
+
+	ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+	is.NoErr(err)
+	is.Equal(ver.SourceURL, "s3::https://minio.example.com/modules/testnamespace/testname/testprovider/1.1.0/1.1.0.zip")
+
+	store = NewS3Store(client, "us-east-1", "modules", zap.NewNop())
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+	is.True(errors.Is(err, core.ErrNotFound))
+}
+
+func TestBackendErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+	}))
+	defer srv.Close()
+
+	store := NewS3Store(fakeS3Client(t, srv), "us-east-1", "modules", zap.NewNop())
+
+	// The credentials of the store are rejected, which is not the fault of the client
+	_, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+	is.True(errors.Is(err, core.ErrUnavailable))
+
+	_, err = store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.0.0")
+	is.True(errors.Is(err, core.ErrUnavailable))
+}
+
+func TestEndpointSourceURLBase(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		base     string
+	}{
+		{"https://minio.example.com", "https://minio.example.com/modules"},
+		{"https://minio.example.com/", "https://minio.example.com/modules"},
+		{"http://localhost:9000/s3", "http://localhost:9000/s3/modules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			is := is.New(t)
+			base, err := EndpointSourceURLBase(tt.endpoint, "modules")
+			is.NoErr(err)
+			is.Equal(base, tt.base)
+		})
+	}
+
+	t.Run("requires absolute URL", func(t *testing.T) {
+		is := is.New(t)
+		_, err := EndpointSourceURLBase("minio.example.com", "modules")
+		is.True(err != nil)
+	})
+}
+
+func TestDownloadModes(t *testing.T) {
+	srv := fakeS3Server(t, "modules", []string{
+		"testnamespace/testname/testprovider/1.1.0/1.1.0.zip",
+	})
+	defer srv.Close()
+
+	client := fakeS3Client(t, srv)
+
+	t.Run("presigned", func(t *testing.T) {
+		is := is.New(t)
+		store := NewS3Store(client, "us-east-1", "modules", zap.NewNop())
+		store.SetDownloadMode(DownloadPresigned, 5*time.Minute)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		is.True(strings.HasPrefix(ver.SourceURL, srv.URL+"/modules/testnamespace/testname/testprovider/1.1.0/1.1.0.zip?"))
+		is.True(strings.Contains(ver.SourceURL, "X-Amz-Signature="))
+		is.True(strings.Contains(ver.SourceURL, "X-Amz-Expires=300"))
+
+		// The presigned URL is downloadable without credentials
+		resp, err := http.Get(ver.SourceURL)
+		is.NoErr(err)
+		defer resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusOK)
+
+		vers, err := store.ListModuleVersions(context.Background(), "testnamespace", "testname", "testprovider")
+		is.NoErr(err)
+		is.Equal(vers[0].SourceURL, "s3::https://modules.s3.us-east-1.amazonaws.com/testnamespace/testname/testprovider/1.1.0/1.1.0.zip")
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		is := is.New(t)
+		store := NewS3Store(client, "us-east-1", "modules", zap.NewNop())
+		store.SetDownloadMode(DownloadProxy, 0)
+
+		ver, err := store.GetModuleVersion(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		is.Equal(ver.SourceURL, "/download/module/testnamespace/testname/testprovider/1.1.0?archive=zip")
+
+		archive, err := store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "1.1.0")
+		is.NoErr(err)
+		defer archive.Close()
+		body, err := io.ReadAll(archive)
+		is.NoErr(err)
+		is.Equal(string(body), "archive testnamespace/testname/testprovider/1.1.0/1.1.0.zip")
+
+		_, err = store.GetModuleArchive(context.Background(), "testnamespace", "testname", "testprovider", "2.0.0")
+		is.True(err != nil)
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	// Isolate the tests from the AWS configuration of the environment
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	err := os.WriteFile(filepath.Join(dir, "credentials"), []byte("[registry]\naws_access_key_id = profile-id\naws_secret_access_key = profile-secret\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("profile", func(t *testing.T) {
+		is := is.New(t)
+		cfg, err := LoadConfig(context.Background(), ClientOptions{Region: "eu-north-1", Profile: "registry"})
+		is.NoErr(err)
+		is.Equal(cfg.Region, "eu-north-1")
+
+		creds, err := cfg.Credentials.Retrieve(context.Background())
+		is.NoErr(err)
+		is.Equal(creds.AccessKeyID, "profile-id")
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		is := is.New(t)
+		_, err := LoadConfig(context.Background(), ClientOptions{Region: "eu-north-1", Profile: "unknown"})
+		is.True(err != nil)
+	})
+
+	t.Run("assume role", func(t *testing.T) {
+		is := is.New(t)
+		cfg, err := LoadConfig(context.Background(), ClientOptions{
+			Region:     "eu-north-1",
+			Profile:    "registry",
+			RoleARN:    "arn:aws:iam::123456789012:role/registry",
+			ExternalID: "external",
+		})
+		is.NoErr(err)
+		cache, ok := cfg.Credentials.(*aws.CredentialsCache)
+		is.True(ok)
+		is.True(cache.IsCredentialsProvider(&stscreds.AssumeRoleProvider{}))
+	})
+
+	t.Run("web identity", func(t *testing.T) {
+		is := is.New(t)
+		cfg, err := LoadConfig(context.Background(), ClientOptions{
+			Region:               "eu-north-1",
+			RoleARN:              "arn:aws:iam::123456789012:role/registry",
+			WebIdentityTokenFile: filepath.Join(dir, "token"),
+		})
+		is.NoErr(err)
+		cache, ok := cfg.Credentials.(*aws.CredentialsCache)
+		is.True(ok)
+		is.True(cache.IsCredentialsProvider(&stscreds.WebIdentityRoleProvider{}))
+	})
+
+	t.Run("web identity requires role", func(t *testing.T) {
+		is := is.New(t)
+		_, err := LoadConfig(context.Background(), ClientOptions{
+			Region:               "eu-north-1",
+			WebIdentityTokenFile: filepath.Join(dir, "token"),
+		})
+		is.True(err != nil)
 	})
 }
@@ -0,0 +1,295 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/nrkno/terraform-registry/pkg/core"
+)
+
+// BlobStore keeps the module archives and provider files of the store. Blobs are content addressed,
+// so a key is always written with the same content.
+type BlobStore interface {
+	// Put writes the blob `key` with the `size` bytes in `r`.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get returns the contents of the blob `key`.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob `key`. Removing a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// blob is a blob written to the blob store.
+type blob struct {
+	key    string
+	sha256 string
+	size   int64
+}
+
+// putBlob copies `r` to a temporary file, and writes it to the blob store once `verify`, if any, accepts it.
+func (s *SQLStore) putBlob(ctx context.Context, r io.Reader, verify func(b blob, f *os.File) error) (blob, error) {
+	f, err := os.CreateTemp("", "terraform-registry-blob-")
+	if err != nil {
+		return blob{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return blob{}, fmt.Errorf("unable to read blob: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	b := blob{key: "sha256/" + sum, sha256: sum, size: size}
+
+	if verify != nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return blob{}, err
+		}
+		if err := verify(b, f); err != nil {
+			return blob{}, err
+		}
+	}
+
+	// The blob is recorded before it is written, so that it is not collected while the version is published
+	_, err = s.db.ExecContext(ctx, s.rebind(`INSERT INTO blobs (blob_key, written_at) VALUES (?, ?)
+		ON CONFLICT (blob_key) DO UPDATE SET written_at = excluded.written_at`), b.key, formatTime(time.Now()))
+	if err != nil {
+		return blob{}, fmt.Errorf("unable to record blob: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return blob{}, err
+	}
+	if err := s.blobs.Put(ctx, b.key, f, size); err != nil {
+		return blob{}, fmt.Errorf("unable to write blob: %w", err)
+	}
+	return b, nil
+}
+
+// unreferencedBlob is the condition of the `blobs` rows that no version refers to.
+const unreferencedBlob = `NOT EXISTS (SELECT 1 FROM module_versions WHERE blob_key = blobs.blob_key)
+	AND NOT EXISTS (SELECT 1 FROM provider_packages WHERE blob_key = blobs.blob_key)
+	AND NOT EXISTS (SELECT 1 FROM provider_versions
+		WHERE shasums_blob_key = blobs.blob_key OR shasums_signature_blob_key = blobs.blob_key)`
+
+// DeleteUnreferencedBlobs removes the blobs that no version refers to, such as the files of failed
+// publishes, and returns the number of blobs removed. Blobs are shared by versions with the same
+// content, so only blobs last written more than `gracePeriod` ago are removed; publishes must complete
+// within `gracePeriod`. It is safe to call from several registry replicas at once.
+func (s *SQLStore) DeleteUnreferencedBlobs(ctx context.Context, gracePeriod time.Duration) (int, error) {
+	cutoff := formatTime(time.Now().Add(-gracePeriod))
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT blob_key FROM blobs WHERE written_at < ? AND `+unreferencedBlob), cutoff)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, dbError(err)
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		ok, err := s.deleteBlob(ctx, key, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("unable to delete blob '%s': %w", key, err)
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// deleteBlob removes the blob `key` unless it has been written since `cutoff` or a version refers to it.
+// The row of the blob is locked until the blob is removed, so a publish of the same content, which writes
+// the row before the blob, waits and then writes the blob again.
+func (s *SQLStore) deleteBlob(ctx context.Context, key, cutoff string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM blobs WHERE blob_key = ? AND written_at < ? AND `+unreferencedBlob), key, cutoff)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// getBlob returns the contents of the blob `key`. Blobs that can not be read make the store unavailable.
+func (s *SQLStore) getBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := s.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, core.BackendError(err, 0)
+	}
+	return r, nil
+}
+
+// FileBlobStore keeps blobs in a directory, such as a volume shared by the registry replicas.
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore returns a blob store keeping blobs in `dir`.
+func NewFileBlobStore(dir string) *FileBlobStore {
+	return &FileBlobStore{dir: dir}
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid blob key '%s'", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file, which is renamed into place once complete.
+func (s *FileBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if n != size {
+		f.Close()
+		return fmt.Errorf("blob '%s' is %d bytes, expected %d", key, n, size)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// Get returns the blob file, which supports HTTP Range requests when served by the registry.
+func (s *FileBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, core.NotFoundError("blob '%s' not found", key)
+	}
+	return f, err
+}
+
+func (s *FileBlobStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3BlobAPI defines the subset of S3 client methods used by S3BlobStore. It is implemented by `*s3.Client`.
+type S3BlobAPI interface {
+	PutObject(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// S3BlobStore keeps blobs in an S3 bucket.
+type S3BlobStore struct {
+	client S3BlobAPI
+	bucket string
+	prefix string
+}
+
+// NewS3BlobStore returns a blob store keeping blobs in `bucket`, with object names prefixed by `prefix`.
+func NewS3BlobStore(client S3BlobAPI, bucket, prefix string) *S3BlobStore {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3BlobStore{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(s.prefix + path.Clean(key)),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+	})
+	return err
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + path.Clean(key)),
+	})
+	if err != nil {
+		err = fmt.Errorf("unable to get blob '%s': %w", key, err)
+		var resp interface{ HTTPStatusCode() int }
+		if errors.As(err, &resp) {
+			return nil, core.BackendError(err, resp.HTTPStatusCode())
+		}
+		return nil, err
+	}
+	return sizedReader{out.Body, aws.ToInt64(out.ContentLength)}, nil
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + path.Clean(key)),
+	})
+	return err
+}
+
+// sizedReader is an object body that knows its size, for the Content-Length of downloads.
+type sizedReader struct {
+	io.ReadCloser
+	size int64
+}
+
+func (r sizedReader) Size() int64 {
+	return r.size
+}
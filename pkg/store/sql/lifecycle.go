@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package sql
+
+import "context"
+
+// VersionStatus marks a module or provider version as deprecated or yanked.
+type VersionStatus struct {
+	Deprecated bool
+	Yanked     bool
+	Reason     string
+}
+
+// VersionLifecycle holds the status of module and provider versions. Modules are keyed by
+// `namespace/name/provider`, providers by `namespace/name`, and then by version.
+type VersionLifecycle struct {
+	Modules   map[string]map[string]VersionStatus
+	Providers map[string]map[string]VersionStatus
+}
+
+const (
+	lifecycleModule   = "module"
+	lifecycleProvider = "provider"
+)
+
+// GetVersionLifecycle returns the status of module and provider versions, so that the deprecations
+// made through one registry replica are picked up by the others.
+func (s *SQLStore) GetVersionLifecycle(ctx context.Context) (VersionLifecycle, error) {
+	lifecycle := VersionLifecycle{
+		Modules:   make(map[string]map[string]VersionStatus),
+		Providers: make(map[string]map[string]VersionStatus),
+	}
+
+	statuses, err := s.versionStatuses(ctx)
+	if err != nil {
+		return lifecycle, err
+	}
+	for key, status := range statuses {
+		m := lifecycle.Modules
+		if key.kind == lifecycleProvider {
+			m = lifecycle.Providers
+		}
+		if m[key.address] == nil {
+			m[key.address] = make(map[string]VersionStatus)
+		}
+		m[key.address][key.version] = status
+	}
+	return lifecycle, nil
+}
+
+// SetModuleVersionStatus sets or, if `status` is nil, clears the status of a module version.
+// The module is addressed by `namespace/name/provider`.
+func (s *SQLStore) SetModuleVersionStatus(ctx context.Context, address, version string, status *VersionStatus) error {
+	return s.setVersionStatus(ctx, versionStatusKey{lifecycleModule, address, version}, status)
+}
+
+// SetProviderVersionStatus sets or, if `status` is nil, clears the status of a provider version.
+// The provider is addressed by `namespace/name`.
+func (s *SQLStore) SetProviderVersionStatus(ctx context.Context, address, version string, status *VersionStatus) error {
+	return s.setVersionStatus(ctx, versionStatusKey{lifecycleProvider, address, version}, status)
+}
+
+// versionStatusKey identifies the status of a module or provider version.
+type versionStatusKey struct {
+	kind    string
+	address string
+	version string
+}
+
+// versionStatuses returns the status of all versions.
+func (s *SQLStore) versionStatuses(ctx context.Context) (map[versionStatusKey]VersionStatus, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, address, version, deprecated, yanked, reason FROM version_statuses`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[versionStatusKey]VersionStatus)
+	for rows.Next() {
+		var key versionStatusKey
+		var status VersionStatus
+		if err := rows.Scan(&key.kind, &key.address, &key.version, &status.Deprecated, &status.Yanked, &status.Reason); err != nil {
+			return nil, err
+		}
+		statuses[key] = status
+	}
+	return statuses, rows.Err()
+}
+
+// setVersionStatus upserts or, if `status` is nil, deletes the status of a version.
+func (s *SQLStore) setVersionStatus(ctx context.Context, key versionStatusKey, status *VersionStatus) error {
+	if status == nil {
+		_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM version_statuses WHERE kind = ? AND address = ? AND version = ?`),
+			key.kind, key.address, key.version)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO version_statuses
+		(kind, address, version, deprecated, yanked, reason) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (kind, address, version) DO UPDATE
+		SET deprecated = excluded.deprecated, yanked = excluded.yanked, reason = excluded.reason`),
+		key.kind, key.address, key.version, status.Deprecated, status.Yanked, status.Reason)
+	return err
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// migrations are the schema changes of the database, applied in order. A migration must never be
+// changed once released; add a new one instead. The statements must work with both SQLite and Postgres.
+var migrations = [][]string{
+	// 1: Initial schema
+	{
+		`CREATE TABLE module_versions (
+			namespace    TEXT NOT NULL,
+			name         TEXT NOT NULL,
+			provider     TEXT NOT NULL,
+			version      TEXT NOT NULL,
+			blob_key     TEXT NOT NULL,
+			sha256       TEXT NOT NULL,
+			size         BIGINT NOT NULL,
+			metadata     TEXT NOT NULL,
+			published_at TEXT NOT NULL,
+			downloads    BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (namespace, name, provider, version)
+		)`,
+		`CREATE TABLE signing_keys (
+			namespace   TEXT NOT NULL,
+			key_id      TEXT NOT NULL,
+			ascii_armor TEXT NOT NULL,
+			created_at  TEXT NOT NULL,
+			PRIMARY KEY (namespace, key_id)
+		)`,
+		`CREATE TABLE provider_versions (
+			namespace                  TEXT NOT NULL,
+			name                       TEXT NOT NULL,
+			version                    TEXT NOT NULL,
+			protocols                  TEXT NOT NULL,
+			key_id                     TEXT NOT NULL,
+			shasums_blob_key           TEXT NOT NULL,
+			shasums_signature_blob_key TEXT NOT NULL,
+			published_at               TEXT NOT NULL,
+			PRIMARY KEY (namespace, name, version),
+			FOREIGN KEY (namespace, key_id) REFERENCES signing_keys (namespace, key_id)
+		)`,
+		`CREATE TABLE provider_packages (
+			namespace TEXT NOT NULL,
+			name      TEXT NOT NULL,
+			version   TEXT NOT NULL,
+			os        TEXT NOT NULL,
+			arch      TEXT NOT NULL,
+			filename  TEXT NOT NULL,
+			blob_key  TEXT NOT NULL,
+			sha256    TEXT NOT NULL,
+			size      BIGINT NOT NULL,
+			downloads BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (namespace, name, version, os, arch),
+			FOREIGN KEY (namespace, name, version) REFERENCES provider_versions (namespace, name, version) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE version_statuses (
+			kind       TEXT NOT NULL,
+			address    TEXT NOT NULL,
+			version    TEXT NOT NULL,
+			deprecated BOOLEAN NOT NULL,
+			yanked     BOOLEAN NOT NULL,
+			reason     TEXT NOT NULL,
+			PRIMARY KEY (kind, address, version)
+		)`,
+	},
+	// 2: Blobs written, so that the ones no version refers to can be collected
+	{
+		`CREATE TABLE blobs (
+			blob_key   TEXT NOT NULL PRIMARY KEY,
+			written_at TEXT NOT NULL
+		)`,
+	},
+}
+
+// Migrate applies the pending schema migrations. It is safe to call from several registry replicas
+// at once, as the migrations are applied in a single transaction, which is serialised by an advisory
+// lock on Postgres.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.dialect == DialectPostgres {
+		// Arbitrary key identifying the migrations of this registry
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(7353226048)`); err != nil {
+			return fmt.Errorf("unable to lock schema migrations: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER NOT NULL PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("unable to create schema migrations table: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("unable to get schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d", current, len(migrations))
+	}
+
+	for i := current; i < len(migrations); i++ {
+		for _, stmt := range migrations[i] {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("schema migration %d failed: %w", i+1, err)
+			}
+		}
+		_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), i+1, formatTime(time.Now()))
+		if err != nil {
+			return fmt.Errorf("schema migration %d failed: %w", i+1, err)
+		}
+		s.logger.Info("applied schema migration", zap.Int("version", i+1))
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,345 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	"go.uber.org/zap"
+)
+
+// ProviderRelease is a provider version to publish, as built by GoReleaser.
+type ProviderRelease struct {
+	Namespace string
+	Name      string
+	Version   string
+	// Protocols are the supported Terraform provider protocol versions. Defaults to `5.0`.
+	Protocols []string
+	// SHASums is the contents of the `SHA256SUMS` file, listing the checksums of the packages.
+	SHASums []byte
+	// SHASumsSignature is the detached GPG signature of `SHASums`, ASCII armored or binary. It
+	// must be made by one of the signing keys of the namespace.
+	SHASumsSignature []byte
+	Packages         []ProviderPackage
+}
+
+// ProviderPackage is the zip archive of a provider release for a platform.
+type ProviderPackage struct {
+	OS   string
+	Arch string
+	// Filename defaults to `terraform-provider-{name}_{version}_{os}_{arch}.zip`, and must be listed in the checksums.
+	Filename string
+	Content  io.Reader
+}
+
+// AddSigningKey adds an ASCII armored GPG public key allowed to sign the provider releases of `namespace`,
+// and returns its key ID.
+func (s *SQLStore) AddSigningKey(ctx context.Context, namespace, asciiArmor string) (string, error) {
+	if !addressPartRegex.MatchString(namespace) {
+		return "", fmt.Errorf("invalid namespace '%s'", namespace)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(asciiArmor))
+	if err != nil || len(keyring) != 1 {
+		return "", errors.New("unable to read GPG public key, must be a single ASCII armored key")
+	}
+	keyID := keyring[0].PrimaryKey.KeyIdString()
+
+	_, err = s.db.ExecContext(ctx, s.rebind(`INSERT INTO signing_keys (namespace, key_id, ascii_armor, created_at)
+		VALUES (?, ?, ?, ?) ON CONFLICT (namespace, key_id) DO NOTHING`), namespace, keyID, asciiArmor, formatTime(time.Now()))
+	if err != nil {
+		return "", fmt.Errorf("unable to add signing key '%s': %w", keyID, err)
+	}
+	return keyID, nil
+}
+
+// signingKeys returns the signing keys of the namespace.
+func (s *SQLStore) signingKeys(ctx context.Context, namespace string) (openpgp.EntityList, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT ascii_armor FROM signing_keys WHERE namespace = ?`), namespace)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var keyring openpgp.EntityList
+	for rows.Next() {
+		var armor string
+		if err := rows.Scan(&armor); err != nil {
+			return nil, err
+		}
+		keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armor))
+		if err != nil {
+			return nil, err
+		}
+		keyring = append(keyring, keys...)
+	}
+	return keyring, dbError(rows.Err())
+}
+
+// PublishProviderVersion adds a version of a provider. The checksums must be signed by one of the signing
+// keys of the namespace, and list the checksums of all packages. Returns `ErrVersionExists` if the version
+// has already been published.
+func (s *SQLStore) PublishProviderVersion(ctx context.Context, release ProviderRelease) error {
+	address := release.Namespace + "/" + release.Name
+	for _, part := range []string{release.Namespace, release.Name} {
+		if !addressPartRegex.MatchString(part) {
+			return fmt.Errorf("invalid provider address '%s'", address)
+		}
+	}
+	if !storeutil.VersionRegex.MatchString(release.Version) {
+		return fmt.Errorf("invalid version '%s', must be a SemVer version without 'v' prefix", release.Version)
+	}
+	if len(release.Packages) == 0 {
+		return errors.New("provider release has no packages")
+	}
+	if exists, err := s.exists(ctx, `SELECT 1 FROM provider_versions WHERE namespace = ? AND name = ? AND version = ?`, release.Namespace, release.Name, release.Version); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("provider '%s' version '%s': %w", address, release.Version, ErrVersionExists)
+	}
+
+	keyring, err := s.signingKeys(ctx, release.Namespace)
+	if err != nil {
+		return err
+	}
+	if len(keyring) == 0 {
+		return fmt.Errorf("no signing keys for namespace '%s'", release.Namespace)
+	}
+	key, err := storeutil.CheckDetachedSignature(keyring, release.SHASums, release.SHASumsSignature)
+	if err != nil {
+		return fmt.Errorf("SHA checksums signature not valid for the signing keys of namespace '%s': %w", release.Namespace, err)
+	}
+	sums := storeutil.ParseSHASums(release.SHASums)
+
+	protocols := release.Protocols
+	if len(protocols) == 0 {
+		// https://developer.hashicorp.com/terraform/registry/providers/publishing
+		protocols = []string{"5.0"}
+	}
+
+	type pkg struct {
+		ProviderPackage
+		blob blob
+	}
+	pkgs := make([]pkg, 0, len(release.Packages))
+	for _, p := range release.Packages {
+		if p.OS == "" || p.Arch == "" {
+			return errors.New("provider package is missing OS or architecture")
+		}
+		if p.Filename == "" {
+			p.Filename = fmt.Sprintf("terraform-provider-%s_%s_%s_%s.zip", release.Name, release.Version, p.OS, p.Arch)
+		}
+		sum, ok := sums[p.Filename]
+		if !ok {
+			return fmt.Errorf("provider package '%s' not listed in SHA checksums", p.Filename)
+		}
+		b, err := s.putBlob(ctx, p.Content, func(b blob, _ *os.File) error {
+			if b.sha256 != sum {
+				return fmt.Errorf("checksum of provider package '%s' does not match SHA checksums", p.Filename)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, pkg{p, b})
+	}
+
+	sumsBlob, err := s.putBlob(ctx, bytes.NewReader(release.SHASums), nil)
+	if err != nil {
+		return err
+	}
+	sigBlob, err := s.putBlob(ctx, bytes.NewReader(release.SHASumsSignature), nil)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The version may have been published since it was checked, while the files were written
+	res, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO provider_versions
+		(namespace, name, version, protocols, key_id, shasums_blob_key, shasums_signature_blob_key, published_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`),
+		release.Namespace, release.Name, release.Version, strings.Join(protocols, ","), key.PrimaryKey.KeyIdString(),
+		sumsBlob.key, sigBlob.key, formatTime(time.Now()))
+	if err == nil {
+		err = insertedRow(res, ErrVersionExists)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add provider '%s' version '%s': %w", address, release.Version, err)
+	}
+	for _, p := range pkgs {
+		_, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO provider_packages
+			(namespace, name, version, os, arch, filename, blob_key, sha256, size)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			release.Namespace, release.Name, release.Version, p.OS, p.Arch, p.Filename, p.blob.key, p.blob.sha256, p.blob.size)
+		if err != nil {
+			return fmt.Errorf("unable to add provider package '%s': %w", p.Filename, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.logger.Info("published provider version",
+		zap.String("name", address),
+		zap.String("version", release.Version),
+		zap.Int("platform_count", len(pkgs)),
+	)
+	return nil
+}
+
+func (s *SQLStore) ListProviderVersions(ctx context.Context, namespace string, name string) (*core.ProviderVersions, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT v.version, v.protocols, p.os, p.arch
+		FROM provider_versions v
+		JOIN provider_packages p ON p.namespace = v.namespace AND p.name = v.name AND p.version = v.version
+		WHERE v.namespace = ? AND v.name = ?
+		ORDER BY v.published_at, v.version, p.os, p.arch`), namespace, name)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	versions := &core.ProviderVersions{Versions: make([]core.ProviderVersion, 0)}
+	for rows.Next() {
+		var version, protocols string
+		var platform core.Platform
+		if err := rows.Scan(&version, &protocols, &platform.OS, &platform.Arch); err != nil {
+			return nil, err
+		}
+		if n := len(versions.Versions); n == 0 || versions.Versions[n-1].Version != version {
+			versions.Versions = append(versions.Versions, core.ProviderVersion{
+				Version:   version,
+				Protocols: strings.Split(protocols, ","),
+			})
+		}
+		ver := &versions.Versions[len(versions.Versions)-1]
+		ver.Platforms = append(ver.Platforms, platform)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	if len(versions.Versions) == 0 {
+		return nil, core.NotFoundError("provider '%s/%s' not found", namespace, name)
+	}
+	return versions, nil
+}
+
+// GetProviderVersion returns the package of a provider version for a platform.
+func (s *SQLStore) GetProviderVersion(ctx context.Context, namespace string, name string, version string, os string, arch string) (*core.Provider, error) {
+	var protocols, keyID, armor, filename, sum string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT v.protocols, v.key_id, k.ascii_armor, p.filename, p.sha256
+		FROM provider_versions v
+		JOIN provider_packages p ON p.namespace = v.namespace AND p.name = v.name AND p.version = v.version
+		JOIN signing_keys k ON k.namespace = v.namespace AND k.key_id = v.key_id
+		WHERE v.namespace = ? AND v.name = ? AND v.version = ? AND p.os = ? AND p.arch = ?`),
+		namespace, name, version, os, arch).Scan(&protocols, &keyID, &armor, &filename, &sum)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.NotFoundError("provider '%s/%s/%s' not found for %s_%s", namespace, name, version, os, arch)
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	sumsFile := shaSumsFilename(name, version)
+	return &core.Provider{
+		Protocols:           strings.Split(protocols, ","),
+		OS:                  os,
+		Arch:                arch,
+		Filename:            filename,
+		DownloadURL:         storeutil.ProviderProxyURL(namespace, name, version, filename),
+		SHASumsURL:          storeutil.ProviderProxyURL(namespace, name, version, sumsFile),
+		SHASumsSignatureURL: storeutil.ProviderProxyURL(namespace, name, version, sumsFile+".sig"),
+		SHASum:              sum,
+		SigningKeys:         core.SigningKeys{GPGPublicKeys: []core.GpgPublicKeys{{KeyID: keyID, ASCIIArmor: armor}}},
+	}, nil
+}
+
+func shaSumsFilename(name, version string) string {
+	return fmt.Sprintf("terraform-provider-%s_%s_SHA256SUMS", name, version)
+}
+
+// GetProviderAsset returns the contents of a file of a provider version. Downloads of packages are counted.
+func (s *SQLStore) GetProviderAsset(ctx context.Context, namespace string, name string, tag string, asset string) (io.ReadCloser, error) {
+	notFound := core.NotFoundError("asset '%s' not found for provider '%s/%s/%s'", asset, namespace, name, tag)
+
+	var sumsKey, sigKey string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT shasums_blob_key, shasums_signature_blob_key FROM provider_versions
+		WHERE namespace = ? AND name = ? AND version = ?`), namespace, name, tag).Scan(&sumsKey, &sigKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+	switch asset {
+	case shaSumsFilename(name, tag):
+		return s.getBlob(ctx, sumsKey)
+	case shaSumsFilename(name, tag) + ".sig":
+		return s.getBlob(ctx, sigKey)
+	}
+
+	var key string
+	err = s.db.QueryRowContext(ctx, s.rebind(`SELECT blob_key FROM provider_packages
+		WHERE namespace = ? AND name = ? AND version = ? AND filename = ?`), namespace, name, tag, asset).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	r, err := s.getBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.rebind(`UPDATE provider_packages SET downloads = downloads + 1
+		WHERE namespace = ? AND name = ? AND version = ? AND filename = ?`), namespace, name, tag, asset)
+	if err != nil {
+		s.logger.Warn("failed to count provider download", zap.Error(err))
+	}
+	return r, nil
+}
+
+// ProviderDownloads returns the number of package downloads of each version of the provider.
+func (s *SQLStore) ProviderDownloads(ctx context.Context, namespace, name string) (map[string]int64, error) {
+	return s.downloads(ctx, `SELECT version, downloads FROM provider_packages
+		WHERE namespace = ? AND name = ?`, namespace, name)
+}
+
+// ListProviders returns all providers with published versions, sorted by address.
+func (s *SQLStore) ListProviders(ctx context.Context) ([]core.ProviderAddress, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT namespace, name FROM provider_versions ORDER BY namespace, name`)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	providers := make([]core.ProviderAddress, 0)
+	for rows.Next() {
+		var p core.ProviderAddress
+		if err := rows.Scan(&p.Namespace, &p.Name); err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, dbError(rows.Err())
+}
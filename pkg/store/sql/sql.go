@@ -0,0 +1,388 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package sql
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect is the SQL dialect of the database.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// ErrVersionExists is returned when publishing a version that already exists. Published versions are immutable.
+var ErrVersionExists = errors.New("version already exists")
+
+var addressPartRegex = regexp.MustCompile(`^[\w-]+$`)
+
+// SQLStore is a module and provider store for an upload based workflow. The metadata of modules and
+// providers, such as versions, checksums, platforms, signing keys and download counts, is kept in a
+// SQL database, while the module archives and provider files are kept in a `BlobStore`. Several
+// registry replicas can share the same database and blob store.
+//
+// Module archives and provider files are served through the registry from the `/download/module/`
+// and `/download/provider/` routes.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+	blobs   BlobStore
+	logger  *zap.Logger
+}
+
+// Open opens the database at `dsn`. Postgres is used for `postgres://` and `postgresql://` URLs,
+// and SQLite otherwise, with `dsn` being the path of the database file, optionally prefixed with `sqlite://`.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		db, err := sql.Open("pgx", dsn)
+		return db, DialectPostgres, err
+	}
+
+	dsn = strings.TrimPrefix(dsn, "sqlite://")
+	if dsn == "" {
+		return nil, "", errors.New("missing database path")
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	// Wait for locks held by other connections instead of failing, and let readers run concurrently with writers
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	return db, DialectSQLite, err
+}
+
+// NewSQLStore returns a store using the database `db` with the SQL `dialect`, and the blob store
+// `blobs` for module archives and provider files. Call `Migrate` to create or update the schema.
+func NewSQLStore(db *sql.DB, dialect Dialect, blobs BlobStore, logger *zap.Logger) *SQLStore {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &SQLStore{
+		db:      db,
+		dialect: dialect,
+		blobs:   blobs,
+		logger:  logger,
+	}
+}
+
+// rebind replaces the `?` placeholders of `query` with the placeholders of the dialect.
+func (s *SQLStore) rebind(query string) string {
+	if s.dialect != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Times are stored as text, which sorts in chronological order for both dialects.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// PublishModuleVersion adds a version of a module, with its source code in the gzipped tarball `archive`.
+// Returns `ErrVersionExists` if the version has already been published.
+func (s *SQLStore) PublishModuleVersion(ctx context.Context, namespace, name, provider, version string, archive io.Reader, meta *core.ModuleMetadata) error {
+	address := namespace + "/" + name + "/" + provider
+	for _, part := range []string{namespace, name, provider} {
+		if !addressPartRegex.MatchString(part) {
+			return fmt.Errorf("invalid module address '%s'", address)
+		}
+	}
+	if !storeutil.VersionRegex.MatchString(version) {
+		return fmt.Errorf("invalid version '%s', must be a SemVer version without 'v' prefix", version)
+	}
+	if exists, err := s.exists(ctx, `SELECT 1 FROM module_versions WHERE namespace = ? AND name = ? AND provider = ? AND version = ?`, namespace, name, provider, version); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("module '%s' version '%s': %w", address, version, ErrVersionExists)
+	}
+
+	blob, err := s.putBlob(ctx, archive, func(_ blob, f *os.File) error {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("module archive is not a gzipped tarball: %w", err)
+		}
+		if _, err := tar.NewReader(gzr).Next(); err != nil && err != io.EOF {
+			return fmt.Errorf("module archive is not a gzipped tarball: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if meta == nil {
+		meta = &core.ModuleMetadata{}
+	}
+	meta.PublishedAt = time.Now().UTC()
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	// The version may have been published since it was checked, while the archive was written
+	res, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO module_versions
+		(namespace, name, provider, version, blob_key, sha256, size, metadata, published_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`),
+		namespace, name, provider, version, blob.key, blob.sha256, blob.size, string(b), formatTime(meta.PublishedAt))
+	if err == nil {
+		err = insertedRow(res, ErrVersionExists)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add module '%s' version '%s': %w", address, version, err)
+	}
+
+	s.logger.Info("published module version",
+		zap.String("name", address),
+		zap.String("version", version),
+	)
+	return nil
+}
+
+// ListModuleVersions returns the published versions of the module.
+func (s *SQLStore) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]*core.ModuleVersion, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT version, metadata FROM module_versions
+		WHERE namespace = ? AND name = ? AND provider = ?
+		ORDER BY published_at, version`), namespace, name, provider)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	versions := make([]*core.ModuleVersion, 0)
+	for rows.Next() {
+		var version, meta string
+		if err := rows.Scan(&version, &meta); err != nil {
+			return nil, err
+		}
+		ver, err := moduleVersion(namespace, name, provider, version, meta)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, ver)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	if len(versions) == 0 {
+		return nil, core.NotFoundError("module '%s' not found", path.Join(namespace, name, provider))
+	}
+	return versions, nil
+}
+
+// GetModuleVersion returns a published version of the module.
+func (s *SQLStore) GetModuleVersion(ctx context.Context, namespace, name, provider, version string) (*core.ModuleVersion, error) {
+	var meta string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT metadata FROM module_versions
+		WHERE namespace = ? AND name = ? AND provider = ? AND version = ?`), namespace, name, provider, version).Scan(&meta)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, path.Join(namespace, name, provider))
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return moduleVersion(namespace, name, provider, version, meta)
+}
+
+func moduleVersion(namespace, name, provider, version, meta string) (*core.ModuleVersion, error) {
+	ver := &core.ModuleVersion{
+		Version:   version,
+		SourceURL: storeutil.ModuleProxyURL(path.Join(namespace, name, provider), version, "tar.gz"),
+		Metadata:  &core.ModuleMetadata{},
+	}
+	if err := json.Unmarshal([]byte(meta), ver.Metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata of module '%s' version '%s': %w", path.Join(namespace, name, provider), version, err)
+	}
+	return ver, nil
+}
+
+// ListModules returns all modules with published versions, sorted by address.
+func (s *SQLStore) ListModules(ctx context.Context) ([]core.ModuleAddress, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT namespace, name, provider FROM module_versions ORDER BY namespace, name, provider`)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	modules := make([]core.ModuleAddress, 0)
+	for rows.Next() {
+		var m core.ModuleAddress
+		if err := rows.Scan(&m.Namespace, &m.Name, &m.Provider); err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	return modules, dbError(rows.Err())
+}
+
+// GetModuleArchive returns the gzipped tarball with the source code of a module version, and counts the download.
+// Reading the archive fails if its checksum does not match the one recorded when it was published.
+func (s *SQLStore) GetModuleArchive(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	var key, sum string
+	var size int64
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT blob_key, sha256, size FROM module_versions
+		WHERE namespace = ? AND name = ? AND provider = ? AND version = ?`), namespace, name, provider, version).Scan(&key, &sum, &size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.NotFoundError("version '%s' not found for module '%s'", version, path.Join(namespace, name, provider))
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	r, err := s.getBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.rebind(`UPDATE module_versions SET downloads = downloads + 1
+		WHERE namespace = ? AND name = ? AND provider = ? AND version = ?`), namespace, name, provider, version)
+	if err != nil {
+		s.logger.Warn("failed to count module download", zap.Error(err))
+	}
+
+	return &verifyingReader{ReadCloser: r, hash: sha256.New(), sha256: sum, size: size}, nil
+}
+
+// ModuleDownloads returns the number of downloads of each version of the module.
+func (s *SQLStore) ModuleDownloads(ctx context.Context, namespace, name, provider string) (map[string]int64, error) {
+	return s.downloads(ctx, `SELECT version, downloads FROM module_versions
+		WHERE namespace = ? AND name = ? AND provider = ?`, namespace, name, provider)
+}
+
+func (s *SQLStore) downloads(ctx context.Context, query string, args ...any) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	downloads := make(map[string]int64)
+	for rows.Next() {
+		var version string
+		var n int64
+		if err := rows.Scan(&version, &n); err != nil {
+			return nil, err
+		}
+		downloads[version] += n
+	}
+	return downloads, dbError(rows.Err())
+}
+
+// dbError classifies an error of the database, which makes the store unavailable.
+func dbError(err error) error {
+	return core.BackendError(err, 0)
+}
+
+// insertedRow returns `errConflict` if the insert of `res` did not add a row, due to a conflict.
+func insertedRow(res sql.Result, errConflict error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errConflict
+	}
+	return nil
+}
+
+// exists returns whether `query` returns any rows.
+func (s *SQLStore) exists(ctx context.Context, query string, args ...any) (bool, error) {
+	var one int
+	err := s.db.QueryRowContext(ctx, s.rebind(query), args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// statusTimeout limits the time spent counting the modules and providers for the health endpoint.
+const statusTimeout = 2 * time.Second
+
+// Status returns the number of published modules, providers and downloads.
+func (s *SQLStore) Status(ctx context.Context) any {
+	var status struct {
+		ModuleVersions    int64  `json:"module_versions"`
+		ModuleDownloads   int64  `json:"module_downloads"`
+		ProviderVersions  int64  `json:"provider_versions"`
+		ProviderDownloads int64  `json:"provider_downloads"`
+		Error             string `json:"error,omitempty"`
+	}
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+	err := s.db.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM module_versions),
+		(SELECT COALESCE(SUM(downloads), 0) FROM module_versions),
+		(SELECT COUNT(*) FROM provider_versions),
+		(SELECT COALESCE(SUM(downloads), 0) FROM provider_packages)`,
+	).Scan(&status.ModuleVersions, &status.ModuleDownloads, &status.ProviderVersions, &status.ProviderDownloads)
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// verifyingReader fails at the end of a blob whose checksum or size does not match.
+type verifyingReader struct {
+	io.ReadCloser
+	hash   hash.Hash
+	sha256 string
+	size   int64
+	read   int64
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	r.read += int64(n)
+	if err == io.EOF {
+		if r.read != r.size || hex.EncodeToString(r.hash.Sum(nil)) != r.sha256 {
+			return n, errors.New("checksum of blob does not match")
+		}
+	}
+	return n, err
+}
+
+// Size returns the size of the blob, for the Content-Length of downloads.
+func (r *verifyingReader) Size() int64 {
+	return r.size
+}
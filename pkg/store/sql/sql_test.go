@@ -0,0 +1,449 @@
+// SPDX-FileCopyrightText: 2025 NRK
+//
+// SPDX-License-Identifier: MIT
+
+package sql
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/matryer/is"
+	"github.com/nrkno/terraform-registry/pkg/core"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storetest"
+	"github.com/nrkno/terraform-registry/pkg/store/internal/storeutil"
+)
+
+func newTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	dir := t.TempDir()
+	db, dialect, err := Open(filepath.Join(dir, "registry.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := NewSQLStore(db, dialect, NewFileBlobStore(filepath.Join(dir, "blobs")), nil)
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// testArchive returns a gzipped tarball with a `main.tf` file.
+func testArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	if err := tw.WriteHeader(&tar.Header{Name: "main.tf", Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(content))
+	tw.Close()
+	gzw.Close()
+	return buf.Bytes()
+}
+
+// testProviderRelease returns a release of `terraform-provider-test` signed by `key`, with packages for `platforms`.
+func testProviderRelease(t *testing.T, key *openpgp.Entity, version string, platforms ...core.Platform) ProviderRelease {
+	names := make([]string, len(platforms))
+	for i, p := range platforms {
+		names[i] = p.OS + "_" + p.Arch
+	}
+	r := storetest.NewProviderRelease(t, key, version, names...)
+
+	release := ProviderRelease{Namespace: "testnamespace", Name: "test", Version: version, SHASums: r.SHASums.Content, SHASumsSignature: r.Signature.Content}
+	for _, p := range r.Packages {
+		release.Packages = append(release.Packages, ProviderPackage{OS: p.OS, Arch: p.Arch, Content: bytes.NewReader(p.Content)})
+	}
+	return release
+}
+
+func TestMigrate(t *testing.T) {
+	is := is.New(t)
+	store := newTestStore(t)
+
+	// Already applied migrations are skipped
+	is.NoErr(store.Migrate(context.Background()))
+	var version int
+	is.NoErr(store.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	is.Equal(version, len(migrations))
+
+	_, err := store.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, '')`, len(migrations)+1)
+	is.NoErr(err)
+	err = store.Migrate(context.Background())
+	is.Equal(err.Error(), fmt.Sprintf("database schema version %d is newer than the latest known version %d", len(migrations)+1, len(migrations)))
+}
+
+func TestRebind(t *testing.T) {
+	is := is.New(t)
+	store := &SQLStore{dialect: DialectPostgres}
+	is.Equal(store.rebind(`SELECT a FROM b WHERE c = ? AND d = ?`), `SELECT a FROM b WHERE c = $1 AND d = $2`)
+	store.dialect = DialectSQLite
+	is.Equal(store.rebind(`SELECT a FROM b WHERE c = ?`), `SELECT a FROM b WHERE c = ?`)
+}
+
+func TestModules(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(testArchive(t, "# 1.0.0")), nil))
+	is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.1.0", bytes.NewReader(testArchive(t, "# 1.1.0")),
+		&core.ModuleMetadata{CommitSHA: "0123456789abcdef", Description: "A test module"}))
+	is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "other", "testprovider", "0.1.0", bytes.NewReader(testArchive(t, "# 0.1.0")), nil))
+
+	err := store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(testArchive(t, "# changed")), nil)
+	is.True(errors.Is(err, ErrVersionExists))
+	err = store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "v2.0.0", bytes.NewReader(testArchive(t, "")), nil)
+	is.Equal(err.Error(), "invalid version 'v2.0.0', must be a SemVer version without 'v' prefix")
+	err = store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "2.0.0", bytes.NewReader([]byte("not an archive")), nil)
+	is.Equal(err.Error(), "module archive is not a gzipped tarball: gzip: invalid header")
+	err = store.PublishModuleVersion(ctx, "testnamespace", "../testname", "testprovider", "2.0.0", bytes.NewReader(testArchive(t, "")), nil)
+	is.Equal(err.Error(), "invalid module address 'testnamespace/../testname/testprovider'")
+
+	versions, err := store.ListModuleVersions(ctx, "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(len(versions), 2)
+	is.Equal(versions[0].Version, "1.0.0")
+	is.Equal(versions[0].SourceURL, "/download/module/testnamespace/testname/testprovider/1.0.0?archive=tar.gz")
+	is.Equal(versions[1].Version, "1.1.0")
+	is.Equal(versions[1].Metadata.CommitSHA, "0123456789abcdef")
+	is.True(!versions[1].Metadata.PublishedAt.IsZero())
+
+	_, err = store.ListModuleVersions(ctx, "testnamespace", "missing", "testprovider")
+	is.Equal(err.Error(), "module 'testnamespace/missing/testprovider' not found")
+	is.True(errors.Is(err, core.ErrNotFound))
+
+	ver, err := store.GetModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.1.0")
+	is.NoErr(err)
+	is.Equal(ver.Metadata.Description, "A test module")
+	_, err = store.GetModuleVersion(ctx, "testnamespace", "testname", "testprovider", "3.0.0")
+	is.Equal(err.Error(), "version '3.0.0' not found for module 'testnamespace/testname/testprovider'")
+
+	modules, err := store.ListModules(ctx)
+	is.NoErr(err)
+	is.Equal(modules, []core.ModuleAddress{
+		{Namespace: "testnamespace", Name: "other", Provider: "testprovider"},
+		{Namespace: "testnamespace", Name: "testname", Provider: "testprovider"},
+	})
+
+	// Archives are served and counted
+	for range 2 {
+		r, err := store.GetModuleArchive(ctx, "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		b, err := io.ReadAll(r)
+		r.Close()
+		is.NoErr(err)
+		is.Equal(b, testArchive(t, "# 1.0.0"))
+	}
+	downloads, err := store.ModuleDownloads(ctx, "testnamespace", "testname", "testprovider")
+	is.NoErr(err)
+	is.Equal(downloads, map[string]int64{"1.0.0": 2, "1.1.0": 0})
+
+	_, err = store.GetModuleArchive(ctx, "testnamespace", "testname", "testprovider", "3.0.0")
+	is.Equal(err.Error(), "version '3.0.0' not found for module 'testnamespace/testname/testprovider'")
+	is.True(errors.Is(err, core.ErrNotFound))
+
+	// Failing queries make the store unavailable, rather than the module missing
+	is.NoErr(store.db.Close())
+	_, err = store.ListModuleVersions(ctx, "testnamespace", "testname", "testprovider")
+	is.True(errors.Is(err, core.ErrUnavailable))
+	_, err = store.GetModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0")
+	is.True(errors.Is(err, core.ErrUnavailable))
+}
+
+func TestModuleArchiveChecksum(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+	archive := testArchive(t, "# 1.0.0")
+	is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(archive), nil))
+
+	// Blobs are content addressed
+	sum := sha256.Sum256(archive)
+	blobs := store.blobs.(*FileBlobStore)
+	name, err := blobs.path("sha256/" + hex.EncodeToString(sum[:]))
+	is.NoErr(err)
+	is.NoErr(os.WriteFile(name, []byte("tampered"), 0o644))
+
+	r, err := store.GetModuleArchive(ctx, "testnamespace", "testname", "testprovider", "1.0.0")
+	is.NoErr(err)
+	defer r.Close()
+	_, err = io.ReadAll(r)
+	is.Equal(err.Error(), "checksum of blob does not match")
+}
+
+// hookBlobStore calls `beforePut`, if set, before writing a blob.
+type hookBlobStore struct {
+	BlobStore
+	beforePut func()
+}
+
+func (s *hookBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if s.beforePut != nil {
+		s.beforePut()
+	}
+	return s.BlobStore.Put(ctx, key, r, size)
+}
+
+func TestConcurrentPublish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("module", func(t *testing.T) {
+		is := is.New(t)
+		store := newTestStore(t)
+		blobs := &hookBlobStore{BlobStore: store.blobs}
+		store.blobs = blobs
+
+		// Another replica publishes the version while the archive is written
+		blobs.beforePut = func() {
+			blobs.beforePut = nil
+			is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(testArchive(t, "# other")), nil))
+		}
+		archive := testArchive(t, "# 1.0.0")
+		err := store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(archive), nil)
+		is.True(errors.Is(err, ErrVersionExists))
+
+		// The archive of the failed attempt is kept until unreferenced blobs are deleted
+		sum := sha256.Sum256(archive)
+		r, err := store.blobs.Get(ctx, "sha256/"+hex.EncodeToString(sum[:]))
+		is.NoErr(err)
+		r.Close()
+		n, err := store.DeleteUnreferencedBlobs(ctx, 0)
+		is.NoErr(err)
+		is.Equal(n, 1)
+		_, err = store.blobs.Get(ctx, "sha256/"+hex.EncodeToString(sum[:]))
+		is.True(errors.Is(err, core.ErrNotFound))
+		r, err = store.GetModuleArchive(ctx, "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		b, err := io.ReadAll(r)
+		r.Close()
+		is.NoErr(err)
+		is.Equal(b, testArchive(t, "# other"))
+	})
+
+	t.Run("provider", func(t *testing.T) {
+		is := is.New(t)
+		store := newTestStore(t)
+		blobs := &hookBlobStore{BlobStore: store.blobs}
+		store.blobs = blobs
+		key, armored := storetest.NewKey(t)
+		_, err := store.AddSigningKey(ctx, "testnamespace", string(armored))
+		is.NoErr(err)
+		linux := core.Platform{OS: "linux", Arch: "amd64"}
+		darwin := core.Platform{OS: "darwin", Arch: "arm64"}
+
+		blobs.beforePut = func() {
+			blobs.beforePut = nil
+			is.NoErr(store.PublishProviderVersion(ctx, testProviderRelease(t, key, "1.0.0", linux)))
+		}
+		err = store.PublishProviderVersion(ctx, testProviderRelease(t, key, "1.0.0", linux, darwin))
+		is.True(errors.Is(err, ErrVersionExists))
+
+		// Only the files not shared with the published version are deleted, including the checksums
+		// and signature of the failed attempt
+		n, err := store.DeleteUnreferencedBlobs(ctx, 0)
+		is.NoErr(err)
+		is.Equal(n, 3)
+		for file, exists := range map[string]bool{
+			"terraform-provider-test_1.0.0_linux_amd64.zip":  true,
+			"terraform-provider-test_1.0.0_darwin_arm64.zip": false,
+		} {
+			sum := sha256.Sum256([]byte("package " + file))
+			r, err := store.blobs.Get(ctx, "sha256/"+hex.EncodeToString(sum[:]))
+			is.Equal(err == nil, exists)
+			if err == nil {
+				r.Close()
+			}
+		}
+		versions, err := store.ListProviderVersions(ctx, "testnamespace", "test")
+		is.NoErr(err)
+		is.Equal(versions.Versions[0].Platforms, []core.Platform{linux})
+	})
+}
+
+func TestDeleteUnreferencedBlobs(t *testing.T) {
+	ctx := context.Background()
+	archive := testArchive(t, "# shared")
+	sum := sha256.Sum256(archive)
+	key := "sha256/" + hex.EncodeToString(sum[:])
+
+	t.Run("published between the failed attempt and the delete", func(t *testing.T) {
+		is := is.New(t)
+		store := newTestStore(t)
+		is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(testArchive(t, "# 1.0.0")), nil))
+		err := store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(archive), nil)
+		is.True(errors.Is(err, ErrVersionExists))
+
+		// The blob is found unreferenced, then a version with the same content is published before it is deleted
+		cutoff := formatTime(time.Now())
+		is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "other", "testprovider", "1.0.0", bytes.NewReader(archive), nil))
+		deleted, err := store.deleteBlob(ctx, key, cutoff)
+		is.NoErr(err)
+		is.True(!deleted)
+		deleted, err = store.deleteBlob(ctx, key, formatTime(time.Now()))
+		is.NoErr(err)
+		is.True(!deleted)
+
+		r, err := store.GetModuleArchive(ctx, "testnamespace", "other", "testprovider", "1.0.0")
+		is.NoErr(err)
+		b, err := io.ReadAll(r)
+		r.Close()
+		is.NoErr(err)
+		is.Equal(b, archive)
+	})
+
+	t.Run("publish in progress", func(t *testing.T) {
+		is := is.New(t)
+		store := newTestStore(t)
+		blobs := &hookBlobStore{BlobStore: store.blobs}
+		store.blobs = blobs
+
+		// Blobs of publishes in progress are not referenced yet, but were written within the grace period
+		blobs.beforePut = func() {
+			n, err := store.DeleteUnreferencedBlobs(ctx, time.Hour)
+			is.NoErr(err)
+			is.Equal(n, 0)
+		}
+		is.NoErr(store.PublishModuleVersion(ctx, "testnamespace", "testname", "testprovider", "1.0.0", bytes.NewReader(archive), nil))
+		r, err := store.GetModuleArchive(ctx, "testnamespace", "testname", "testprovider", "1.0.0")
+		is.NoErr(err)
+		r.Close()
+	})
+}
+
+func TestProviders(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+	key, armored := storetest.NewKey(t)
+	linux := core.Platform{OS: "linux", Arch: "amd64"}
+	darwin := core.Platform{OS: "darwin", Arch: "arm64"}
+
+	// Releases must be signed by a key of the namespace
+	err := store.PublishProviderVersion(ctx, testProviderRelease(t, key, "1.0.0", linux))
+	is.Equal(err.Error(), "no signing keys for namespace 'testnamespace'")
+	keyID, err := store.AddSigningKey(ctx, "testnamespace", string(armored))
+	is.NoErr(err)
+	is.Equal(keyID, key.PrimaryKey.KeyIdString())
+	otherKey, _ := storetest.NewKey(t)
+	err = store.PublishProviderVersion(ctx, testProviderRelease(t, otherKey, "1.0.0", linux))
+	is.True(err != nil)
+
+	release := testProviderRelease(t, key, "1.0.0", linux, darwin)
+	release.Protocols = []string{"5.0", "6.0"}
+	is.NoErr(store.PublishProviderVersion(ctx, release))
+	is.NoErr(store.PublishProviderVersion(ctx, testProviderRelease(t, key, "1.1.0", linux)))
+	err = store.PublishProviderVersion(ctx, testProviderRelease(t, key, "1.1.0", linux))
+	is.True(errors.Is(err, ErrVersionExists))
+
+	// Packages must match the checksums
+	release = testProviderRelease(t, key, "2.0.0", linux)
+	release.Packages[0].Content = bytes.NewReader([]byte("tampered"))
+	err = store.PublishProviderVersion(ctx, release)
+	is.Equal(err.Error(), "checksum of provider package 'terraform-provider-test_2.0.0_linux_amd64.zip' does not match SHA checksums")
+	release = testProviderRelease(t, key, "2.0.0", linux)
+	release.Packages[0].Filename = "other.zip"
+	err = store.PublishProviderVersion(ctx, release)
+	is.Equal(err.Error(), "provider package 'other.zip' not listed in SHA checksums")
+
+	versions, err := store.ListProviderVersions(ctx, "testnamespace", "test")
+	is.NoErr(err)
+	is.Equal(versions, &core.ProviderVersions{Versions: []core.ProviderVersion{
+		{Version: "1.0.0", Protocols: []string{"5.0", "6.0"}, Platforms: []core.Platform{darwin, linux}},
+		{Version: "1.1.0", Protocols: []string{"5.0"}, Platforms: []core.Platform{linux}},
+	}})
+	_, err = store.ListProviderVersions(ctx, "testnamespace", "missing")
+	is.Equal(err.Error(), "provider 'testnamespace/missing' not found")
+
+	provider, err := store.GetProviderVersion(ctx, "testnamespace", "test", "1.0.0", "linux", "amd64")
+	is.NoErr(err)
+	pkg := []byte("package terraform-provider-test_1.0.0_linux_amd64.zip")
+	sum := sha256.Sum256(pkg)
+	is.Equal(provider, &core.Provider{
+		Protocols:           []string{"5.0", "6.0"},
+		OS:                  "linux",
+		Arch:                "amd64",
+		Filename:            "terraform-provider-test_1.0.0_linux_amd64.zip",
+		DownloadURL:         "/download/provider/testnamespace/test/1.0.0/asset/terraform-provider-test_1.0.0_linux_amd64.zip",
+		SHASumsURL:          "/download/provider/testnamespace/test/1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS",
+		SHASumsSignatureURL: "/download/provider/testnamespace/test/1.0.0/asset/terraform-provider-test_1.0.0_SHA256SUMS.sig",
+		SHASum:              hex.EncodeToString(sum[:]),
+		SigningKeys:         core.SigningKeys{GPGPublicKeys: []core.GpgPublicKeys{{KeyID: keyID, ASCIIArmor: string(armored)}}},
+	})
+	_, err = store.GetProviderVersion(ctx, "testnamespace", "test", "1.1.0", "darwin", "arm64")
+	is.Equal(err.Error(), "provider 'testnamespace/test/1.1.0' not found for darwin_arm64")
+
+	// Files are served, and package downloads counted
+	for asset, want := range map[string][]byte{
+		"terraform-provider-test_1.0.0_linux_amd64.zip": pkg,
+		"terraform-provider-test_1.0.0_SHA256SUMS":      testProviderRelease(t, key, "1.0.0", linux, darwin).SHASums,
+	} {
+		r, err := store.GetProviderAsset(ctx, "testnamespace", "test", "1.0.0", asset)
+		is.NoErr(err)
+		b, err := io.ReadAll(r)
+		r.Close()
+		is.NoErr(err)
+		is.Equal(b, want)
+	}
+	r, err := store.GetProviderAsset(ctx, "testnamespace", "test", "1.0.0", "terraform-provider-test_1.0.0_SHA256SUMS.sig")
+	is.NoErr(err)
+	sig, err := io.ReadAll(r)
+	r.Close()
+	is.NoErr(err)
+	_, err = storeutil.CheckDetachedSignature(openpgp.EntityList{key}, testProviderRelease(t, key, "1.0.0", linux, darwin).SHASums, sig)
+	is.NoErr(err)
+	_, err = store.GetProviderAsset(ctx, "testnamespace", "test", "1.0.0", "terraform-provider-test_1.1.0_linux_amd64.zip")
+	is.Equal(err.Error(), "asset 'terraform-provider-test_1.1.0_linux_amd64.zip' not found for provider 'testnamespace/test/1.0.0'")
+
+	downloads, err := store.ProviderDownloads(ctx, "testnamespace", "test")
+	is.NoErr(err)
+	is.Equal(downloads, map[string]int64{"1.0.0": 1, "1.1.0": 0})
+
+	providers, err := store.ListProviders(ctx)
+	is.NoErr(err)
+	is.Equal(providers, []core.ProviderAddress{{Namespace: "testnamespace", Name: "test"}})
+}
+
+func TestVersionStatus(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	lifecycle, err := store.GetVersionLifecycle(ctx)
+	is.NoErr(err)
+	is.Equal(len(lifecycle.Modules)+len(lifecycle.Providers), 0)
+
+	is.NoErr(store.SetModuleVersionStatus(ctx, "myorg/network/generic", "1.2.0", &VersionStatus{Deprecated: true}))
+	is.NoErr(store.SetModuleVersionStatus(ctx, "myorg/network/generic", "1.2.0", &VersionStatus{Deprecated: true, Reason: "Use 2.x"}))
+	is.NoErr(store.SetProviderVersionStatus(ctx, "myorg/internal", "0.3.1", &VersionStatus{Yanked: true}))
+	lifecycle, err = store.GetVersionLifecycle(ctx)
+	is.NoErr(err)
+	is.Equal(lifecycle, VersionLifecycle{
+		Modules:   map[string]map[string]VersionStatus{"myorg/network/generic": {"1.2.0": {Deprecated: true, Reason: "Use 2.x"}}},
+		Providers: map[string]map[string]VersionStatus{"myorg/internal": {"0.3.1": {Yanked: true}}},
+	})
+
+	// Only the status of the version is cleared
+	is.NoErr(store.SetModuleVersionStatus(ctx, "myorg/network/generic", "1.2.0", nil))
+	lifecycle, err = store.GetVersionLifecycle(ctx)
+	is.NoErr(err)
+	is.Equal(lifecycle, VersionLifecycle{
+		Modules:   map[string]map[string]VersionStatus{},
+		Providers: map[string]map[string]VersionStatus{"myorg/internal": {"0.3.1": {Yanked: true}}},
+	})
+}